@@ -0,0 +1,170 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"mysql-cdc/internal/config"
+	"mysql-cdc/internal/mysql"
+	"mysql-cdc/internal/nats"
+	"mysql-cdc/internal/processor"
+)
+
+// errSkipped marks a check that couldn't run because a check it depends on failed
+var errSkipped = errors.New("skipped")
+
+// preflightCheck is a single check run by the check subcommand
+type preflightCheck struct {
+	name string
+	run  func() error
+}
+
+// runCheck runs the preflight checks, prints a report and returns the process exit code
+func runCheck(cfg *config.Config, logger *logrus.Logger) int {
+	checker := mysql.NewChecker(
+		cfg.MySQL.Host,
+		cfg.MySQL.Port,
+		cfg.MySQL.User,
+		cfg.MySQL.Password,
+		&cfg.MySQL.TLS,
+		cfg.MySQL.Strict,
+		logger,
+	)
+
+	var server *mysql.ServerInfo
+	var publisher *nats.Publisher
+	defer func() {
+		if publisher != nil {
+			publisher.Close()
+		}
+	}()
+
+	// requireNATS skips JetStream checks when NATS is unreachable
+	requireNATS := func(check func() error) func() error {
+		return func() error {
+			if publisher == nil {
+				return errSkipped
+			}
+			return check()
+		}
+	}
+
+	checks := []preflightCheck{
+		{"MySQL connection and permissions", checker.CheckConnectionAndPermissions},
+		{"MySQL server detection", func() error {
+			var err error
+			server, err = checker.DetectServer()
+			if err == nil {
+				logger.Infof("Detected %s server version %s", server.Flavor, server.Version)
+			}
+			return err
+		}},
+		{"MySQL server configuration", func() error {
+			if server == nil {
+				return errSkipped
+			}
+			return checker.CheckServerConfig(server, cfg.MySQL.UseGTID, cfg.MySQL.MinBinlogRetention)
+		}},
+		{"MySQL SELECT permission", func() error {
+			if server != nil && strings.EqualFold(server.RowMetadata, "FULL") {
+				logger.Info("Column metadata comes from the binlog, SELECT permission is not required")
+				return nil
+			}
+			return metadataChecker(cfg, logger).CheckSelectAccess(cfg.Filters.Tables)
+		}},
+		{"MySQL server_id", func() error {
+			return checker.CheckServerID(cfg.MySQL.ServerID)
+		}},
+		{"Processor configuration", func() error {
+			return processor.ValidateRules(&cfg.Processor)
+		}},
+		{"NATS connection", func() error {
+			var err error
+			publisher, err = nats.NewPublisher(cfg.NATS.URL, &cfg.NATS.Auth, &cfg.NATS.TLS, cfg.NATS.Subject, cfg.NATS.MaxReconnect, cfg.NATS.ReconnectWait, nil, cfg.Events.Format, logger)
+			return err
+		}},
+	}
+
+	// JetStream is only needed by some features
+	needsJetStream := cfg.Watermark.KVBucket != "" || cfg.NATS.ConsumerLag.Enabled ||
+		(cfg.Limits.MaxRowSize > 0 && cfg.Limits.RowSizePolicy == "reference") ||
+		cfg.NATS.JetStream.Enabled
+	if needsJetStream {
+		checks = append(checks, preflightCheck{"JetStream", requireNATS(func() error {
+			return publisher.CheckJetStream()
+		})})
+	}
+	// Routed subjects depend on the events, so only the fixed subject can be checked.
+	// A stream the service creates itself doesn't need to exist yet.
+	if cfg.NATS.JetStream.Enabled && !cfg.NATS.JetStream.Create && cfg.Routing.Subject == "" {
+		subject, stream := cfg.NATS.Subject, cfg.NATS.JetStream.Stream
+		checks = append(checks, preflightCheck{fmt.Sprintf("Stream for subject '%s'", subject), requireNATS(func() error {
+			return publisher.CheckStreamForSubject(subject, stream)
+		})})
+	}
+	if cfg.Watermark.Enabled && cfg.Watermark.KVBucket != "" {
+		bucket := cfg.Watermark.KVBucket
+		checks = append(checks, preflightCheck{fmt.Sprintf("Watermark KV bucket '%s'", bucket), requireNATS(func() error {
+			return publisher.CheckKV(bucket)
+		})})
+	}
+	if cfg.Limits.MaxRowSize > 0 && cfg.Limits.RowSizePolicy == "reference" {
+		bucket := cfg.Limits.ReferenceBucket
+		checks = append(checks, preflightCheck{fmt.Sprintf("Reference object store '%s'", bucket), requireNATS(func() error {
+			return publisher.CheckObjectStore(bucket)
+		})})
+	}
+	if cfg.Limits.Oversize.Strategy == "reference" {
+		bucket := cfg.Limits.Oversize.Bucket
+		checks = append(checks, preflightCheck{fmt.Sprintf("Oversize object store '%s'", bucket), requireNATS(func() error {
+			return publisher.CheckObjectStore(bucket)
+		})})
+	}
+	if cfg.NATS.ConsumerLag.Enabled {
+		for _, consumer := range cfg.NATS.ConsumerLag.Consumers {
+			stream, consumer := cfg.NATS.ConsumerLag.Stream, consumer
+			checks = append(checks, preflightCheck{fmt.Sprintf("Consumer '%s' on stream '%s'", consumer, stream), requireNATS(func() error {
+				return publisher.CheckConsumer(stream, consumer)
+			})})
+		}
+	}
+
+	var passed, failed, skipped int
+	fmt.Println("Preflight check report:")
+	for _, check := range checks {
+		err := check.run()
+		switch {
+		case err == nil:
+			passed++
+			fmt.Printf("  [ OK ] %s\n", check.name)
+		case errors.Is(err, errSkipped):
+			skipped++
+			fmt.Printf("  [SKIP] %s\n", check.name)
+		default:
+			failed++
+			fmt.Printf("  [FAIL] %s: %v\n", check.name, err)
+		}
+	}
+	fmt.Printf("%d passed, %d failed, %d skipped\n", passed, failed, skipped)
+
+	if failed > 0 || skipped > 0 {
+		return 1
+	}
+	return 0
+}
+
+// metadataChecker returns a checker for the user that reads column metadata
+func metadataChecker(cfg *config.Config, logger *logrus.Logger) *mysql.Checker {
+	return mysql.NewChecker(
+		cfg.MySQL.Metadata.Host,
+		cfg.MySQL.Metadata.Port,
+		cfg.MySQL.Metadata.User,
+		cfg.MySQL.Metadata.Password,
+		&cfg.MySQL.TLS,
+		cfg.MySQL.Strict,
+		logger,
+	)
+}
@@ -0,0 +1,178 @@
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"mysql-cdc/internal/config"
+	"mysql-cdc/internal/processor"
+)
+
+// Controller is the runtime state and control of the event processor
+type Controller interface {
+	Status() processor.Status
+	TableStats() map[string]processor.TableStats
+	Pause() bool
+	Resume() bool
+}
+
+// Server serves the admin HTTP API:
+//
+//	GET  /status  - binlog position, lag, paused and transform script hashes
+//	GET  /tables  - published event counts per table
+//	POST /pause   - stop reading binlog events
+//	POST /resume  - resume reading binlog events
+//	POST /reload  - reload processor rules and scripts from the config file
+//
+// With several sources, responses are objects keyed by source name, and
+// ?source=<name> limits a request to one source.
+type Server struct {
+	config      *config.AdminConfig
+	controllers map[string]Controller // By source name; a single "" without sources
+	reload      func() error
+	server      *http.Server
+	logger      *logrus.Logger
+}
+
+// NewServer creates a new admin API server. Returns nil if the API is disabled.
+func NewServer(cfg *config.AdminConfig, controllers map[string]Controller, reload func() error, logger *logrus.Logger) *Server {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	s := &Server{
+		config:      cfg,
+		controllers: controllers,
+		reload:      reload,
+		logger:      logger,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", s.handle(http.MethodGet, s.status))
+	mux.HandleFunc("/tables", s.handle(http.MethodGet, s.tables))
+	mux.HandleFunc("/pause", s.handle(http.MethodPost, s.pause))
+	mux.HandleFunc("/resume", s.handle(http.MethodPost, s.resume))
+	mux.HandleFunc("/reload", s.handle(http.MethodPost, s.reloadTransforms))
+	s.server = &http.Server{
+		Addr:              cfg.Address,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return s
+}
+
+// Start listens on the configured address and serves requests in the background
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", s.config.Address)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.config.Address, err)
+	}
+	go func() {
+		if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Errorf("Admin API stopped: %v", err)
+		}
+	}()
+	s.logger.Infof("Admin API listening on %s", listener.Addr())
+	return nil
+}
+
+// Close stops the server, waiting briefly for requests in progress
+func (s *Server) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return s.server.Shutdown(ctx)
+}
+
+// handle wraps a handler with the method and token checks
+func (s *Server) handle(method string, handler func(w http.ResponseWriter, r *http.Request)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			writeError(w, http.StatusMethodNotAllowed, fmt.Sprintf("%s requires %s", r.URL.Path, method))
+			return
+		}
+		if s.config.Token != "" {
+			want := "Bearer " + s.config.Token
+			if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(want)) != 1 {
+				writeError(w, http.StatusUnauthorized, "invalid or missing token")
+				return
+			}
+		}
+		handler(w, r)
+	}
+}
+
+// each applies fn to the controller of each source the request is for and writes
+// the results: fn's result without sources, otherwise an object keyed by source name
+func (s *Server) each(w http.ResponseWriter, r *http.Request, fn func(Controller) interface{}) {
+	if controller, ok := s.controllers[""]; ok {
+		writeJSON(w, http.StatusOK, fn(controller))
+		return
+	}
+	results := make(map[string]interface{}, len(s.controllers))
+	if name := r.URL.Query().Get("source"); name != "" {
+		controller, ok := s.controllers[name]
+		if !ok {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("unknown source: %s", name))
+			return
+		}
+		results[name] = fn(controller)
+	} else {
+		for name, controller := range s.controllers {
+			results[name] = fn(controller)
+		}
+	}
+	writeJSON(w, http.StatusOK, results)
+}
+
+func (s *Server) status(w http.ResponseWriter, r *http.Request) {
+	s.each(w, r, func(c Controller) interface{} { return c.Status() })
+}
+
+func (s *Server) tables(w http.ResponseWriter, r *http.Request) {
+	s.each(w, r, func(c Controller) interface{} { return c.TableStats() })
+}
+
+func (s *Server) pause(w http.ResponseWriter, r *http.Request) {
+	s.each(w, r, func(c Controller) interface{} {
+		return map[string]bool{"paused": true, "changed": c.Pause()}
+	})
+}
+
+func (s *Server) resume(w http.ResponseWriter, r *http.Request) {
+	s.each(w, r, func(c Controller) interface{} {
+		return map[string]bool{"paused": false, "changed": c.Resume()}
+	})
+}
+
+func (s *Server) reloadTransforms(w http.ResponseWriter, r *http.Request) {
+	if err := s.reload(); err != nil {
+		s.logger.Errorf("Admin API reload failed: %v", err)
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	// Sources share the processor settings, so any has the scripts of all
+	var scripts map[string]string
+	for _, controller := range s.controllers {
+		scripts = controller.Status().Scripts
+		break
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"reloaded": true, "scripts": scripts})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,383 @@
+package binlog
+
+import (
+	"strconv"
+	"strings"
+)
+
+// DMLStatement is a simple INSERT, REPLACE, UPDATE or DELETE statement parsed from a
+// statement-format QueryEvent. Values are the literals written in the statement.
+type DMLStatement struct {
+	Type    string // INSERT, UPDATE or DELETE
+	Table   TableName
+	Columns []string        // INSERT column list; empty if the statement doesn't name the columns
+	Values  [][]interface{} // INSERT value tuples, in Columns order (table order without a column list)
+	Set     map[string]interface{}
+	Where   map[string]interface{} // column = literal conditions joined by AND
+}
+
+// dmlToken is a token of a DML statement; literal is set for string, number and
+// NULL/TRUE/FALSE tokens
+type dmlToken struct {
+	text    string
+	literal bool
+	value   interface{}
+}
+
+// ParseDML parses a simple INSERT, REPLACE, UPDATE or DELETE statement, using defaultSchema
+// for unqualified table names. Only single-table statements whose values and WHERE
+// conditions are literals (column = literal, joined by AND) are supported; ok is
+// false for anything else, such as expressions, functions, subqueries, joins,
+// INSERT ... SELECT or ON DUPLICATE KEY UPDATE.
+func ParseDML(query, defaultSchema string) (stmt *DMLStatement, ok bool) {
+	tokens, ok := lexDML(query)
+	if !ok || len(tokens) == 0 {
+		return nil, false
+	}
+	// A trailing semicolon is allowed, nothing after it
+	if tokens[len(tokens)-1].text == ";" {
+		tokens = tokens[:len(tokens)-1]
+	}
+
+	p := &dmlParser{tokens: tokens, defaultSchema: defaultSchema}
+	switch strings.ToUpper(p.next().text) {
+	case "INSERT", "REPLACE":
+		// REPLACE is captured as an insert of the new row
+		stmt, ok = p.insert()
+	case "UPDATE":
+		stmt, ok = p.update()
+	case "DELETE":
+		stmt, ok = p.delete()
+	default:
+		return nil, false
+	}
+	if !ok || !p.done() {
+		return nil, false
+	}
+	return stmt, true
+}
+
+// dmlParser walks the tokens of a DML statement
+type dmlParser struct {
+	tokens        []dmlToken
+	i             int
+	defaultSchema string
+}
+
+func (p *dmlParser) done() bool { return p.i >= len(p.tokens) }
+
+func (p *dmlParser) peek() dmlToken {
+	if p.done() {
+		return dmlToken{}
+	}
+	return p.tokens[p.i]
+}
+
+func (p *dmlParser) next() dmlToken {
+	t := p.peek()
+	p.i++
+	return t
+}
+
+// keyword consumes the next token if it's the given keyword or punctuation
+func (p *dmlParser) keyword(kw string) bool {
+	if t := p.peek(); !t.literal && strings.EqualFold(t.text, kw) {
+		p.i++
+		return true
+	}
+	return false
+}
+
+// identifier reads a column or table name
+func (p *dmlParser) identifier() (string, bool) {
+	t := p.next()
+	if t.literal || t.text == "" || strings.ContainsAny(t.text, ".,()=;") {
+		return "", false
+	}
+	return t.text, true
+}
+
+// table reads a possibly qualified table name
+func (p *dmlParser) table() (TableName, bool) {
+	name, ok := p.identifier()
+	if !ok {
+		return TableName{}, false
+	}
+	if !p.keyword(".") {
+		return TableName{Schema: p.defaultSchema, Name: name}, true
+	}
+	table, ok := p.identifier()
+	return TableName{Schema: name, Name: table}, ok
+}
+
+// literal reads a literal value
+func (p *dmlParser) literal() (interface{}, bool) {
+	t := p.next()
+	return t.value, t.literal
+}
+
+// column reads a column name, dropping a table qualifier
+func (p *dmlParser) column() (string, bool) {
+	name, ok := p.identifier()
+	for ok && p.keyword(".") {
+		name, ok = p.identifier()
+	}
+	return name, ok
+}
+
+// assignments reads "col = literal" pairs separated by sep
+func (p *dmlParser) assignments(sep string) (map[string]interface{}, bool) {
+	values := make(map[string]interface{})
+	for {
+		col, ok := p.column()
+		if !ok || !p.keyword("=") {
+			return nil, false
+		}
+		value, ok := p.literal()
+		if !ok {
+			return nil, false
+		}
+		values[col] = value
+		if !p.keyword(sep) {
+			return values, true
+		}
+	}
+}
+
+// where reads an optional WHERE clause of AND-ed equality conditions
+func (p *dmlParser) where() (map[string]interface{}, bool) {
+	if !p.keyword("WHERE") {
+		return nil, true
+	}
+	return p.assignments("AND")
+}
+
+func (p *dmlParser) insert() (*DMLStatement, bool) {
+	p.keyword("IGNORE")
+	p.keyword("INTO")
+	table, ok := p.table()
+	if !ok {
+		return nil, false
+	}
+	stmt := &DMLStatement{Type: "INSERT", Table: table}
+
+	if p.keyword("(") {
+		for {
+			col, ok := p.identifier()
+			if !ok {
+				return nil, false
+			}
+			stmt.Columns = append(stmt.Columns, col)
+			if p.keyword(")") {
+				break
+			}
+			if !p.keyword(",") {
+				return nil, false
+			}
+		}
+	}
+	if !p.keyword("VALUES") && !p.keyword("VALUE") {
+		return nil, false
+	}
+	for {
+		if !p.keyword("(") {
+			return nil, false
+		}
+		var tuple []interface{}
+		for {
+			value, ok := p.literal()
+			if !ok {
+				return nil, false
+			}
+			tuple = append(tuple, value)
+			if p.keyword(")") {
+				break
+			}
+			if !p.keyword(",") {
+				return nil, false
+			}
+		}
+		if len(stmt.Columns) > 0 && len(tuple) != len(stmt.Columns) {
+			return nil, false
+		}
+		stmt.Values = append(stmt.Values, tuple)
+		if !p.keyword(",") {
+			return stmt, true
+		}
+	}
+}
+
+func (p *dmlParser) update() (*DMLStatement, bool) {
+	p.keyword("LOW_PRIORITY")
+	p.keyword("IGNORE")
+	table, ok := p.table()
+	if !ok || !p.keyword("SET") {
+		return nil, false
+	}
+	set, ok := p.assignments(",")
+	if !ok {
+		return nil, false
+	}
+	where, ok := p.where()
+	if !ok {
+		return nil, false
+	}
+	return &DMLStatement{Type: "UPDATE", Table: table, Set: set, Where: where}, true
+}
+
+func (p *dmlParser) delete() (*DMLStatement, bool) {
+	p.keyword("LOW_PRIORITY")
+	p.keyword("QUICK")
+	p.keyword("IGNORE")
+	if !p.keyword("FROM") {
+		return nil, false
+	}
+	table, ok := p.table()
+	if !ok {
+		return nil, false
+	}
+	where, ok := p.where()
+	if !ok {
+		return nil, false
+	}
+	return &DMLStatement{Type: "DELETE", Table: table, Where: where}, true
+}
+
+// lexDML splits a statement into identifiers, keywords, punctuation and literals,
+// dropping comments. ok is false for characters the parser doesn't handle, like
+// operators other than "=" or variables. Function calls, charset introducers and
+// hex literals lex as words and are rejected by the parser.
+func lexDML(query string) (tokens []dmlToken, ok bool) {
+	q := query
+	for len(q) > 0 {
+		c := q[0]
+		switch {
+		case strings.HasPrefix(q, "/*"):
+			end := strings.Index(q, "*/")
+			if end < 0 {
+				return nil, false
+			}
+			q = q[end+2:]
+		case strings.HasPrefix(q, "-- ") || c == '#':
+			end := strings.IndexByte(q, '\n')
+			if end < 0 {
+				return tokens, true
+			}
+			q = q[end+1:]
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			q = q[1:]
+		case c == '`':
+			var b strings.Builder
+			i := 1
+			for ; i < len(q); i++ {
+				if q[i] == '`' {
+					if i+1 < len(q) && q[i+1] == '`' {
+						b.WriteByte('`')
+						i++
+						continue
+					}
+					break
+				}
+				b.WriteByte(q[i])
+			}
+			if i >= len(q) {
+				return nil, false
+			}
+			tokens = append(tokens, dmlToken{text: b.String()})
+			q = q[i+1:]
+		case c == '\'' || c == '"':
+			s, rest, ok := unquote(q)
+			if !ok {
+				return nil, false
+			}
+			tokens = append(tokens, dmlToken{text: q[:len(q)-len(rest)], literal: true, value: s})
+			q = rest
+		case strings.IndexByte(".,()=;", c) >= 0:
+			tokens = append(tokens, dmlToken{text: q[:1]})
+			q = q[1:]
+		case c == '-' || c == '+' || (c >= '0' && c <= '9'):
+			end := 1
+			for end < len(q) && strings.IndexByte("0123456789.eE", q[end]) >= 0 {
+				end++
+			}
+			value, ok := number(q[:end])
+			if !ok {
+				return nil, false
+			}
+			tokens = append(tokens, dmlToken{text: q[:end], literal: true, value: value})
+			q = q[end:]
+		case c == '_' || c == '$' || c >= 0x80 || (c|0x20 >= 'a' && c|0x20 <= 'z'):
+			end := 1
+			for end < len(q) && (q[end] == '_' || q[end] == '$' || q[end] >= 0x80 ||
+				(q[end] >= '0' && q[end] <= '9') || (q[end]|0x20 >= 'a' && q[end]|0x20 <= 'z')) {
+				end++
+			}
+			word := q[:end]
+			switch strings.ToUpper(word) {
+			case "NULL":
+				tokens = append(tokens, dmlToken{text: word, literal: true})
+			case "TRUE":
+				tokens = append(tokens, dmlToken{text: word, literal: true, value: int64(1)})
+			case "FALSE":
+				tokens = append(tokens, dmlToken{text: word, literal: true, value: int64(0)})
+			default:
+				tokens = append(tokens, dmlToken{text: word})
+			}
+			q = q[end:]
+		default:
+			return nil, false
+		}
+	}
+	return tokens, true
+}
+
+// unquote reads a quoted string at the start of q, returning its value and the rest of q
+func unquote(q string) (value string, rest string, ok bool) {
+	quote := q[0]
+	var b strings.Builder
+	for i := 1; i < len(q); i++ {
+		c := q[i]
+		switch {
+		case c == '\\' && i+1 < len(q):
+			i++
+			switch q[i] {
+			case '0':
+				b.WriteByte(0)
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'b':
+				b.WriteByte('\b')
+			case 'Z':
+				b.WriteByte(26)
+			default:
+				b.WriteByte(q[i])
+			}
+		case c == quote && i+1 < len(q) && q[i+1] == quote:
+			b.WriteByte(quote)
+			i++
+		case c == quote:
+			return b.String(), q[i+1:], true
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", "", false
+}
+
+// number parses an integer or decimal literal
+func number(s string) (interface{}, bool) {
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return i, true
+	}
+	if u, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return u, true
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f, true
+	}
+	return nil, false
+}
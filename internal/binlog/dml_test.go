@@ -0,0 +1,149 @@
+package binlog
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDML(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  *DMLStatement
+	}{
+		{
+			name:  "insert with columns",
+			query: "INSERT INTO orders (id, status, amount) VALUES (1, 'new', 9.5)",
+			want: &DMLStatement{Type: "INSERT", Table: TableName{"shop", "orders"},
+				Columns: []string{"id", "status", "amount"},
+				Values:  [][]interface{}{{int64(1), "new", 9.5}}},
+		},
+		{
+			name:  "multi-row insert without columns, qualified table",
+			query: "insert ignore into `other`.`orders` values (1, NULL), (2, TRUE);",
+			want: &DMLStatement{Type: "INSERT", Table: TableName{"other", "orders"},
+				Values: [][]interface{}{{int64(1), nil}, {int64(2), int64(1)}}},
+		},
+		{
+			name:  "replace",
+			query: "REPLACE INTO orders (id) VALUE (18446744073709551615)",
+			want: &DMLStatement{Type: "INSERT", Table: TableName{"shop", "orders"},
+				Columns: []string{"id"}, Values: [][]interface{}{{uint64(18446744073709551615)}}},
+		},
+		{
+			name:  "negative numbers",
+			query: "INSERT INTO t (a, b, c) VALUES (-5, +3, -0.25)",
+			want: &DMLStatement{Type: "INSERT", Table: TableName{"shop", "t"},
+				Columns: []string{"a", "b", "c"}, Values: [][]interface{}{{int64(-5), int64(3), -0.25}}},
+		},
+		{
+			name:  "quoted and escaped strings",
+			query: `INSERT INTO t (a, b, c, d) VALUES ('it''s', 'a\'b\\c', "say ""hi""", 'line\nbreak\t\0')`,
+			want: &DMLStatement{Type: "INSERT", Table: TableName{"shop", "t"},
+				Columns: []string{"a", "b", "c", "d"},
+				Values:  [][]interface{}{{"it's", `a'b\c`, `say "hi"`, "line\nbreak\t\x00"}}},
+		},
+		{
+			name:  "keywords inside strings and comments",
+			query: "/* app:checkout */ INSERT INTO t (a) VALUES ('SELECT 1 LIMIT 2') -- trailing\n",
+			want: &DMLStatement{Type: "INSERT", Table: TableName{"shop", "t"},
+				Columns: []string{"a"}, Values: [][]interface{}{{"SELECT 1 LIMIT 2"}}},
+		},
+		{
+			name:  "update",
+			query: "UPDATE orders SET status = 'paid', amount = 10 WHERE id = 7 AND orders.shop_id = -2",
+			want: &DMLStatement{Type: "UPDATE", Table: TableName{"shop", "orders"},
+				Set:   map[string]interface{}{"status": "paid", "amount": int64(10)},
+				Where: map[string]interface{}{"id": int64(7), "shop_id": int64(-2)}},
+		},
+		{
+			name:  "update without where",
+			query: "UPDATE LOW_PRIORITY orders SET note = NULL",
+			want: &DMLStatement{Type: "UPDATE", Table: TableName{"shop", "orders"},
+				Set: map[string]interface{}{"note": nil}},
+		},
+		{
+			name:  "delete",
+			query: "DELETE QUICK FROM shop.orders WHERE id = 3",
+			want: &DMLStatement{Type: "DELETE", Table: TableName{"shop", "orders"},
+				Where: map[string]interface{}{"id": int64(3)}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseDML(tt.query, "shop")
+			if !ok {
+				t.Fatalf("ParseDML(%q) rejected the statement", tt.query)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseDML(%q) =\n%+v\nwant\n%+v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDMLRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"update with limit", "UPDATE t SET a = 1 WHERE id = 2 LIMIT 1"},
+		{"delete with limit", "DELETE FROM t WHERE id = 2 LIMIT 1"},
+		{"update with order by", "UPDATE t SET a = 1 ORDER BY id"},
+		{"function value", "INSERT INTO t (a) VALUES (NOW())"},
+		{"function in set", "UPDATE t SET a = UPPER('x') WHERE id = 1"},
+		{"function in where", "DELETE FROM t WHERE id = ABS(-1)"},
+		{"on duplicate key update", "INSERT INTO t (id, a) VALUES (1, 2) ON DUPLICATE KEY UPDATE a = 3"},
+		{"insert select", "INSERT INTO t (id) SELECT id FROM u"},
+		{"insert set", "INSERT INTO t SET a = 1"},
+		{"expression", "UPDATE t SET a = a + 1 WHERE id = 1"},
+		{"expression without spaces", "UPDATE t SET a = a+1 WHERE id = 1"},
+		{"arithmetic on literals", "INSERT INTO t (a) VALUES (1-2)"},
+		{"comparison other than =", "DELETE FROM t WHERE id > 1"},
+		{"or", "DELETE FROM t WHERE id = 1 OR id = 2"},
+		{"in list", "DELETE FROM t WHERE id IN (1, 2)"},
+		{"variable", "INSERT INTO t (a) VALUES (@x)"},
+		{"hex literal", "INSERT INTO t (a) VALUES (0x1F)"},
+		{"charset introducer", "INSERT INTO t (a) VALUES (_utf8mb4'x')"},
+		{"exponent with sign", "INSERT INTO t (a) VALUES (1e-5)"},
+		{"multi-table update", "UPDATE t, u SET t.a = 1 WHERE t.id = u.id"},
+		{"multi-table delete", "DELETE t FROM t JOIN u ON t.id = u.id"},
+		{"column count mismatch", "INSERT INTO t (a, b) VALUES (1)"},
+		{"unterminated string", "INSERT INTO t (a) VALUES ('x)"},
+		{"unterminated comment", "INSERT INTO t (a) VALUES (1) /* x"},
+		{"statement after semicolon", "DELETE FROM t WHERE id = 1; DELETE FROM u"},
+		{"not dml", "SELECT 1"},
+		{"empty", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if stmt, ok := ParseDML(tt.query, "shop"); ok {
+				t.Errorf("ParseDML(%q) = %+v, want it rejected", tt.query, stmt)
+			}
+		})
+	}
+}
+
+func TestEndsTransaction(t *testing.T) {
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"COMMIT", true},
+		{"/* comment */ commit", true},
+		{"ROLLBACK", true},
+		{"ROLLBACK WORK", true},
+		{"ROLLBACK TO SAVEPOINT s1", false},
+		{"rollback work to `s1`", false},
+		{"SAVEPOINT s1", false},
+		{"RELEASE SAVEPOINT s1", false},
+		{"INSERT INTO t VALUES (1)", false},
+		{"ALTER TABLE t ADD COLUMN c INT", true},
+		{"TRUNCATE TABLE t", true},
+	}
+	for _, tt := range tests {
+		if got := EndsTransaction(tt.query); got != tt.want {
+			t.Errorf("EndsTransaction(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
@@ -2,27 +2,91 @@ package binlog
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
-	"os"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-mysql-org/go-mysql/mysql"
 	"github.com/go-mysql-org/go-mysql/replication"
 	"github.com/sirupsen/logrus"
+
+	"mysql-cdc/internal/position"
 )
 
+// ErrEndOfRange is returned by ReadEvent once the end position set with SetEnd is reached
+var ErrEndOfRange = errors.New("end of binlog range reached")
+
+// ErrResumed is returned by ReadEvent after the reader reconnected to the server
+// following a broken stream. Reading resumes from the last transaction boundary,
+// so the events of the interrupted transaction are read again.
+var ErrResumed = errors.New("binlog stream resumed")
+
+// ErrReconnectFailed is returned by ReadEvent once restarting a broken stream has
+// failed more times than the reconnect policy allows
+var ErrReconnectFailed = errors.New("giving up reconnecting to the binlog stream")
+
+// IsPurged reports whether a read error means the server no longer has the binlog
+// the reader needs, e.g. because it was purged before the position was reached
+func IsPurged(err error) bool {
+	var myErr *mysql.MyError
+	if errors.As(err, &myErr) {
+		return myErr.Code == mysql.ER_MASTER_FATAL_ERROR_READING_BINLOG
+	}
+	// Errors from the syncer aren't always unwrappable
+	return strings.Contains(err.Error(), fmt.Sprintf("ERROR %d ", mysql.ER_MASTER_FATAL_ERROR_READING_BINLOG))
+}
+
 // Reader handles reading binlog events from MySQL
 type Reader struct {
 	syncer       *replication.BinlogSyncer
+	syncerCfg    replication.BinlogSyncerConfig
 	streamer     *replication.BinlogStreamer
 	position     mysql.Position
-	positionFile string
+	store        position.Store // nil = persist nothing
 	currentFile  string
 	logger       *logrus.Logger
+	end          mysql.Position // Position to stop reading at (empty = read forever)
+	mu           sync.RWMutex   // Guards position, checkpoint and dirty for readers on other goroutines
+	checkpoint   mysql.Position // Position persisted to the position store
+	manualCommit bool           // Only Commit advances the checkpoint; reading doesn't
+	saved        bool           // Position was loaded from the position store
+
+	flushInterval time.Duration   // Position writes are coalesced to at most one per interval (<= 0 = write synchronously)
+	dirty         bool            // Position changed since the last write
+	eventTypes    map[string]bool // Whitelisted event classes (nil = all)
+	stopFlush     chan struct{}
+	flushDone     chan struct{}
+
+	boundary       mysql.Position // Position after the last complete transaction read
+	inTransaction  bool           // Between a transaction's start and its commit
+	broken         bool           // Stream failed and must be restarted
+	reconnectDelay time.Duration
+	reconnectAt    time.Time
+	reconnectFails int           // Failed attempts since the stream broke
+	initialDelay   time.Duration // Wait before the first attempt, doubled after each failure
+	maxDelay       time.Duration // Longest wait between attempts
+	maxRetries     int           // Failed attempts before giving up (0 = retry forever)
+	check          func() error  // Run before restarting a broken stream
+
+	useGTID        bool
+	flavor         string
+	gtidSet        mysql.GTIDSet // Transactions read completely; nil until known
+	gtidNext       string        // GTID of the transaction being read
+	checkpointGTID string        // GTID set persisted with the checkpoint
+	gtidMarks      []gtidMark    // GTID sets at transaction boundaries not committed yet (manual commit)
+}
+
+// gtidMark is the GTID set read up to a transaction boundary
+type gtidMark struct {
+	position mysql.Position
+	set      string
 }
 
 // NewReader creates a new binlog reader
-func NewReader(host string, port int, user, password string, serverID uint32, flavor string, useGTID bool, positionFile string, startPos uint32, logger *logrus.Logger) (*Reader, error) {
+func NewReader(host string, port int, user, password string, tlsConfig *tls.Config, serverID uint32, flavor string, useGTID bool, store position.Store, start mysql.Position, flushInterval time.Duration, eventTypes []string, raw bool, logger *logrus.Logger) (*Reader, error) {
 	// Set default flavor if not specified
 	if flavor == "" {
 		flavor = "mysql"
@@ -35,73 +99,176 @@ func NewReader(host string, port int, user, password string, serverID uint32, fl
 		Port:     uint16(port),
 		User:     user,
 		Password: password,
+		// nil unless mysql.tls is enabled
+		TLSConfig: tlsConfig,
+		// Raw mode leaves events other than rotations and format descriptions undecoded
+		RawModeEnabled: raw,
+		// Broken streams are restarted by the reader from a transaction boundary; the
+		// syncer would resume mid-transaction, where row events can't be decoded
+		DisableRetrySync: true,
+		// The syncer's own log lines go through the service's logger and format
+		Logger: logger,
 	}
 
-	// Note: GTID support in go-mysql is handled automatically when using GTID position
-	// For now, we use file:position format. GTID can be enabled by using mysql.GTIDSet
-	// in the position instead of mysql.Position
-	if useGTID {
-		logger.Info("GTID replication requested (currently using file:position format)")
+	// GTID events aren't decoded in raw mode, so the GTID set can't be tracked
+	if useGTID && raw {
+		logger.Warn("GTID positioning isn't available with binlog passthrough, using file:position")
+		useGTID = false
 	}
 
 	syncer := replication.NewBinlogSyncer(cfg)
 
-	// Load position from file if exists
-	position := mysql.Position{
-		Name: "",
-		Pos:  startPos,
-	}
-
-	if data, err := os.ReadFile(positionFile); err == nil {
-		if len(data) > 0 {
-			posStr := string(data)
-			// Parse "filename:position" format
-			// Find last colon to handle filenames that might contain colons
-			lastColon := -1
-			for i := len(posStr) - 1; i >= 0; i-- {
-				if posStr[i] == ':' {
-					lastColon = i
-					break
-				}
-			}
-			if lastColon > 0 && lastColon < len(posStr)-1 {
-				name := posStr[:lastColon]
-				var pos uint32
-				if _, err := fmt.Sscanf(posStr[lastColon+1:], "%d", &pos); err == nil {
-					position.Name = name
-					position.Pos = pos
-					logger.Infof("Loaded binlog position from file: %s:%d", position.Name, position.Pos)
-				} else {
-					// Fallback to old format (just filename)
-					position.Name = posStr
-					logger.Infof("Loaded binlog position from file: %s", position.Name)
-				}
-			} else {
-				// Fallback to old format (just filename)
-				position.Name = posStr
-				logger.Infof("Loaded binlog position from file: %s", position.Name)
+	// Load the saved position if there is one
+	position := start
+	var gtidSet mysql.GTIDSet
+
+	saved := false
+	var data []byte
+	if store != nil {
+		var err error
+		if data, err = store.Load(); err != nil {
+			return nil, fmt.Errorf("failed to load binlog position: %w", err)
+		}
+	}
+	if len(data) > 0 {
+		saved = true
+		loaded, gtid := parsePositionFile(string(data))
+		position.Name = loaded.Name
+		if loaded.Pos > 0 {
+			position.Pos = loaded.Pos
+			logger.Infof("Loaded binlog position from %s: %s:%d", store, position.Name, position.Pos)
+		} else {
+			// Old format (just filename)
+			logger.Infof("Loaded binlog position from %s: %s", store, position.Name)
+		}
+		if useGTID && gtid != "" {
+			var err error
+			gtidSet, err = mysql.ParseGTIDSet(flavor, gtid)
+			if err != nil {
+				return nil, fmt.Errorf("invalid GTID set in saved position: %w", err)
 			}
 		}
 	}
 
-	streamer, err := syncer.StartSync(position)
-	if err != nil {
-		return nil, fmt.Errorf("failed to start binlog sync: %w", err)
+	var streamer *replication.BinlogStreamer
+	var err error
+	if gtidSet != nil {
+		streamer, err = syncer.StartSyncGTID(gtidSet.Clone())
+		if err != nil {
+			return nil, fmt.Errorf("failed to start binlog sync: %w", err)
+		}
+		logger.Infof("Started binlog sync from GTID set: %s", gtidSet)
+	} else {
+		streamer, err = syncer.StartSync(position)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start binlog sync: %w", err)
+		}
+		logger.Infof("Started binlog sync from position: %s:%d", position.Name, position.Pos)
+		if useGTID {
+			logger.Info("No saved GTID set, the executed GTID set is taken from the start of the next binlog file read; until then file:position is persisted")
+		}
+	}
+
+	r := &Reader{
+		syncer:        syncer,
+		syncerCfg:     cfg,
+		streamer:      streamer,
+		position:      position,
+		boundary:      position,
+		checkpoint:    position,
+		store:         store,
+		saved:         saved,
+		currentFile:   position.Name,
+		logger:        logger,
+		flushInterval: flushInterval,
+		useGTID:       useGTID,
+		flavor:        flavor,
+		gtidSet:       gtidSet,
+		initialDelay:  time.Second,
+		maxDelay:      10 * time.Second,
+	}
+	if gtidSet != nil {
+		r.checkpointGTID = gtidSet.String()
+	}
+
+	if len(eventTypes) > 0 {
+		r.eventTypes = make(map[string]bool, len(eventTypes))
+		for _, t := range eventTypes {
+			r.eventTypes[t] = true
+		}
+		logger.Infof("Processing binlog event types: %v", eventTypes)
 	}
 
-	logger.Infof("Started binlog sync from position: %s:%d", position.Name, position.Pos)
+	if flushInterval > 0 {
+		r.stopFlush = make(chan struct{})
+		r.flushDone = make(chan struct{})
+		go r.flushLoop()
+	}
+
+	return r, nil
+}
 
-	return &Reader{
-		syncer:       syncer,
-		streamer:     streamer,
-		position:     position,
-		positionFile: positionFile,
-		currentFile:  position.Name,
-		logger:       logger,
-	}, nil
+// ParsePosition parses a "filename:position" string. A string without a valid
+// position (the old position file format) is taken as a file name alone.
+func ParsePosition(s string) mysql.Position {
+	// Find last colon to handle filenames that might contain colons
+	lastColon := strings.LastIndex(s, ":")
+	if lastColon > 0 && lastColon < len(s)-1 {
+		var pos uint32
+		if _, err := fmt.Sscanf(s[lastColon+1:], "%d", &pos); err == nil {
+			return mysql.Position{Name: s[:lastColon], Pos: pos}
+		}
+	}
+	return mysql.Position{Name: s}
 }
 
-// SavePosition saves the current binlog position to file
+// parsePositionFile parses a saved position: "filename:position" and, on a second
+// line, the GTID set read up to that position (if GTIDs are used)
+func parsePositionFile(data string) (mysql.Position, string) {
+	line, gtid, _ := strings.Cut(strings.TrimSpace(data), "\n")
+	return ParsePosition(strings.TrimSpace(line)), strings.TrimSpace(gtid)
+}
+
+// FormatGTID formats a MySQL GTID as "source_uuid:transaction_id"
+func FormatGTID(sid []byte, gno int64) string {
+	if len(sid) != 16 {
+		return ""
+	}
+	return fmt.Sprintf("%x-%x-%x-%x-%x:%d", sid[0:4], sid[4:6], sid[6:8], sid[8:10], sid[10:16], gno)
+}
+
+// SetEnd makes ReadEvent return ErrEndOfRange once the given position is reached
+func (r *Reader) SetEnd(end mysql.Position) {
+	r.end = end
+}
+
+// SetReconnectCheck sets a function run before a broken stream is restarted, e.g.
+// to verify permissions and server variables after a server restart. The stream
+// isn't restarted until the check passes.
+func (r *Reader) SetReconnectCheck(check func() error) {
+	r.check = check
+}
+
+// SetReconnectPolicy sets the backoff between attempts to restart a broken stream
+// and the number of failed attempts after which ReadEvent returns
+// ErrReconnectFailed (0 = retry forever)
+func (r *Reader) SetReconnectPolicy(initialDelay, maxDelay time.Duration, maxRetries int) {
+	r.initialDelay = initialDelay
+	r.maxDelay = maxDelay
+	r.maxRetries = maxRetries
+}
+
+// EnableManualCommit stops reading from advancing the persisted position; only
+// Commit does. Used when positions may only be persisted once events are delivered.
+func (r *Reader) EnableManualCommit() {
+	r.mu.Lock()
+	r.manualCommit = true
+	r.mu.Unlock()
+}
+
+// SavePosition saves the current binlog position. With a flush interval the
+// position is updated in memory and written to file by the background flusher.
+// With manual commit only the read position is updated.
 func (r *Reader) SavePosition(name string, pos uint32) error {
 	if name == "" {
 		name = r.currentFile
@@ -109,33 +276,149 @@ func (r *Reader) SavePosition(name string, pos uint32) error {
 	if name == "" {
 		return nil
 	}
-	// Save as "filename:position"
-	posStr := fmt.Sprintf("%s:%d", name, pos)
-	if err := os.WriteFile(r.positionFile, []byte(posStr), 0644); err != nil {
-		return fmt.Errorf("failed to save position: %w", err)
-	}
+	r.mu.Lock()
 	r.position.Name = name
 	r.position.Pos = pos
+	manual := r.manualCommit
+	if !manual {
+		r.checkpoint = r.position
+		r.dirty = true
+	}
+	r.mu.Unlock()
 	r.currentFile = name
+
+	if manual || r.flushInterval > 0 {
+		return nil
+	}
+	return r.flush()
+}
+
+// Commit persists a position whose events have all been delivered, along with
+// the GTID set read up to it
+func (r *Reader) Commit(position mysql.Position) error {
+	r.mu.Lock()
+	r.checkpoint = position
+	r.dirty = true
+	committed := 0
+	for committed < len(r.gtidMarks) && r.gtidMarks[committed].position.Compare(position) <= 0 {
+		r.checkpointGTID = r.gtidMarks[committed].set
+		committed++
+	}
+	r.gtidMarks = r.gtidMarks[committed:]
+	r.mu.Unlock()
+
+	if r.flushInterval > 0 {
+		return nil
+	}
+	return r.flush()
+}
+
+// flush writes the latest position to the store if it changed since the last write
+func (r *Reader) flush() error {
+	// Without a position store nothing is persisted
+	if r.store == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	if !r.dirty {
+		r.mu.Unlock()
+		return nil
+	}
+	position := r.checkpoint
+	gtid := r.checkpointGTID
+	r.dirty = false
+	r.mu.Unlock()
+
+	// Save as "filename:position", followed by the GTID set on its own line
+	posStr := fmt.Sprintf("%s:%d", position.Name, position.Pos)
+	if gtid != "" {
+		posStr += "\n" + gtid
+	}
+	if err := r.store.Save([]byte(posStr)); err != nil {
+		r.mu.Lock()
+		r.dirty = true
+		r.mu.Unlock()
+		return fmt.Errorf("failed to save position: %w", err)
+	}
 	return nil
 }
 
-// ReadEvent reads the next binlog event
-func (r *Reader) ReadEvent() (*replication.BinlogEvent, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+// flushLoop periodically writes the latest position until Close is called
+func (r *Reader) flushLoop() {
+	defer close(r.flushDone)
+
+	ticker := time.NewTicker(r.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stopFlush:
+			return
+		case <-ticker.C:
+			if err := r.flush(); err != nil {
+				r.logger.Warnf("Failed to save position: %v", err)
+			}
+		}
+	}
+}
+
+// Position returns the position of the last event read
+func (r *Reader) Position() mysql.Position {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.position
+}
+
+// ReadEvent reads the next binlog event, skipping events that aren't whitelisted.
+// Waiting for an event or to restart a broken stream stops when ctx is done.
+func (r *Reader) ReadEvent(ctx context.Context) (*replication.BinlogEvent, error) {
+	if r.end.Name != "" && r.Position().Compare(r.end) >= 0 {
+		return nil, ErrEndOfRange
+	}
+
+	if r.broken {
+		if err := r.reconnect(ctx); err != nil {
+			return nil, err
+		}
+		return nil, ErrResumed
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
+	for {
+		event, err := r.readEvent(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if r.eventTypes != nil {
+			if class := eventClass(event); class != "" && !r.eventTypes[class] {
+				continue
+			}
+		}
+		return event, nil
+	}
+}
+
+// readEvent reads the next binlog event and records its position
+func (r *Reader) readEvent(ctx context.Context) (*replication.BinlogEvent, error) {
 	event, err := r.streamer.GetEvent(ctx)
 	if err != nil {
+		// Any other error closes the stream (server restart, lost connection, ...)
+		if !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, context.Canceled) {
+			r.broken = true
+			r.reconnectFails = 0
+			r.reconnectDelay = r.initialDelay
+			r.reconnectAt = time.Now().Add(r.reconnectDelay)
+		}
 		return nil, fmt.Errorf("failed to get binlog event: %w", err)
 	}
 
 	// Handle RotateEvent to update current file name
 	if e, ok := event.Event.(*replication.RotateEvent); ok {
 		r.currentFile = string(e.NextLogName)
-		r.position.Name = r.currentFile
-		r.position.Pos = uint32(e.Position)
-		if err := r.SavePosition(r.currentFile, r.position.Pos); err != nil {
+		if err := r.SavePosition(r.currentFile, uint32(e.Position)); err != nil {
 			r.logger.Warnf("Failed to save position: %v", err)
 		}
 	} else {
@@ -147,13 +430,224 @@ func (r *Reader) ReadEvent() (*replication.BinlogEvent, error) {
 		}
 	}
 
+	// Transactions end with an XID event, or a COMMIT, ROLLBACK or DDL query.
+	// Queries within a transaction (SAVEPOINT, statement-based DML, ...) don't end
+	// it; those outside one are statements of their own.
+	switch e := event.Event.(type) {
+	case *replication.RotateEvent:
+		r.boundary = r.Position()
+	case *replication.XIDEvent:
+		r.endTransaction()
+	case *replication.QueryEvent:
+		query := string(e.Query)
+		switch {
+		case firstKeyword(query) == "BEGIN" || strings.HasPrefix(strings.ToUpper(strings.TrimSpace(query)), "XA START"):
+			r.inTransaction = true
+		case !r.inTransaction || EndsTransaction(query):
+			r.endTransaction()
+		}
+	case *replication.GTIDEvent:
+		// Anonymous transactions (GTID mode off on their origin) have no GTID
+		if e.GNO > 0 {
+			r.gtidNext = FormatGTID(e.SID, e.GNO)
+		}
+	case *replication.MariadbGTIDEvent:
+		r.gtidNext = e.GTID.String()
+		// MariaDB logs no BEGIN: the GTID event starts the transaction, unless it's
+		// a standalone statement
+		r.inTransaction = !e.IsStandalone()
+	case *replication.PreviousGTIDsEvent:
+		r.initGTIDSet(e.GTIDSets)
+	case *replication.MariadbGTIDListEvent:
+		gtids := make([]string, len(e.GTIDs))
+		for i, gtid := range e.GTIDs {
+			gtids[i] = gtid.String()
+		}
+		r.initGTIDSet(strings.Join(gtids, ","))
+	default:
+		switch event.Header.EventType {
+		case replication.XA_PREPARE_LOG_EVENT:
+			// A prepared XA transaction is logged as a transaction of its own
+			r.endTransaction()
+		case replication.XID_EVENT:
+			// Raw mode only decodes rotations
+			r.boundary = r.Position()
+		}
+	}
+
 	return event, nil
 }
 
-// Close closes the binlog reader
+// endTransaction records the current position as a transaction boundary and adds
+// the transaction's GTID to the GTID set
+func (r *Reader) endTransaction() {
+	r.inTransaction = false
+	r.boundary = r.Position()
+	if !r.useGTID {
+		return
+	}
+	if r.gtidNext != "" && r.gtidSet != nil {
+		if err := r.gtidSet.Update(r.gtidNext); err != nil {
+			r.logger.Warnf("Failed to add GTID %s to the GTID set: %v", r.gtidNext, err)
+		}
+	}
+	r.gtidNext = ""
+	if r.gtidSet == nil {
+		return
+	}
+
+	set := r.gtidSet.String()
+	r.mu.Lock()
+	if r.manualCommit {
+		r.gtidMarks = append(r.gtidMarks, gtidMark{position: r.boundary, set: set})
+	} else {
+		r.checkpointGTID = set
+		r.dirty = true
+	}
+	r.mu.Unlock()
+}
+
+// initGTIDSet takes the GTID set executed before the current binlog file, written at
+// its start, as the GTID set read so far if it isn't known yet
+func (r *Reader) initGTIDSet(s string) {
+	if !r.useGTID || r.gtidSet != nil {
+		return
+	}
+	set, err := mysql.ParseGTIDSet(r.flavor, s)
+	if err != nil {
+		r.logger.Warnf("Failed to parse GTID set %q: %v", s, err)
+		return
+	}
+	r.gtidSet = set
+	r.logger.Infof("GTID set read so far: %s", set)
+}
+
+// reconnect restarts a broken stream from the last transaction boundary once the
+// reconnect check passes, backing off between attempts. Returns an error wrapping
+// ErrReconnectFailed once the attempts allowed by the reconnect policy are used up.
+func (r *Reader) reconnect(ctx context.Context) error {
+	timer := time.NewTimer(time.Until(r.reconnectAt))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+	}
+
+	err := func() error {
+		if r.check != nil {
+			if err := r.check(); err != nil {
+				return fmt.Errorf("server check failed: %w", err)
+			}
+		}
+		syncer := replication.NewBinlogSyncer(r.syncerCfg)
+		var streamer *replication.BinlogStreamer
+		var err error
+		if r.gtidSet != nil {
+			streamer, err = syncer.StartSyncGTID(r.gtidSet.Clone())
+		} else {
+			streamer, err = syncer.StartSync(r.boundary)
+		}
+		if err != nil {
+			syncer.Close()
+			return fmt.Errorf("failed to restart binlog sync: %w", err)
+		}
+		r.syncer.Close()
+		r.syncer = syncer
+		r.streamer = streamer
+		return nil
+	}()
+	if err != nil {
+		r.reconnectFails++
+		if r.maxRetries > 0 && r.reconnectFails >= r.maxRetries {
+			return fmt.Errorf("%w after %d attempts: %w", ErrReconnectFailed, r.reconnectFails, err)
+		}
+		r.reconnectDelay = min(2*r.reconnectDelay, r.maxDelay)
+		r.reconnectAt = time.Now().Add(r.reconnectDelay)
+		return err
+	}
+
+	r.mu.Lock()
+	r.position = r.boundary
+	r.mu.Unlock()
+	r.currentFile = r.boundary.Name
+	r.broken = false
+	r.inTransaction = false
+	r.gtidNext = ""
+	if r.gtidSet != nil {
+		r.logger.Infof("Resumed binlog sync from GTID set: %s", r.gtidSet)
+		return nil
+	}
+	r.logger.Infof("Resumed binlog sync from position: %s:%d", r.boundary.Name, r.boundary.Pos)
+	return nil
+}
+
+// HasSavedPosition reports whether the reader resumed from a saved position
+func (r *Reader) HasSavedPosition() bool {
+	return r.saved
+}
+
+// Reposition restarts the stream at position, or after the GTID set if GTIDs are
+// used, and persists it. Used once an initial snapshot was taken at that position,
+// before any event is read.
+func (r *Reader) Reposition(position mysql.Position, gtid string) error {
+	var gtidSet mysql.GTIDSet
+	if r.useGTID && gtid != "" {
+		var err error
+		gtidSet, err = mysql.ParseGTIDSet(r.flavor, gtid)
+		if err != nil {
+			return fmt.Errorf("invalid GTID set %q: %w", gtid, err)
+		}
+	}
+
+	syncer := replication.NewBinlogSyncer(r.syncerCfg)
+	var streamer *replication.BinlogStreamer
+	var err error
+	if gtidSet != nil {
+		streamer, err = syncer.StartSyncGTID(gtidSet.Clone())
+	} else {
+		streamer, err = syncer.StartSync(position)
+	}
+	if err != nil {
+		syncer.Close()
+		return fmt.Errorf("failed to restart binlog sync: %w", err)
+	}
+	r.syncer.Close()
+	r.syncer = syncer
+	r.streamer = streamer
+
+	r.mu.Lock()
+	r.position = position
+	r.checkpoint = position
+	r.checkpointGTID = ""
+	if gtidSet != nil {
+		r.checkpointGTID = gtidSet.String()
+	}
+	r.gtidMarks = nil
+	r.dirty = true
+	r.mu.Unlock()
+	r.boundary = position
+	r.currentFile = position.Name
+	r.gtidSet = gtidSet
+	r.gtidNext = ""
+	if gtidSet != nil {
+		r.logger.Infof("Restarted binlog sync from GTID set: %s", gtidSet)
+	} else {
+		r.logger.Infof("Restarted binlog sync from position: %s:%d", position.Name, position.Pos)
+	}
+	return r.flush()
+}
+
+// Close closes the binlog reader, writing out any pending position
 func (r *Reader) Close() {
 	if r.syncer != nil {
 		r.syncer.Close()
 	}
+	if r.stopFlush != nil {
+		close(r.stopFlush)
+		<-r.flushDone
+	}
+	if err := r.flush(); err != nil {
+		r.logger.Warnf("Failed to save position: %v", err)
+	}
 }
-
@@ -0,0 +1,296 @@
+package binlog
+
+import (
+	"strings"
+
+	"github.com/go-mysql-org/go-mysql/replication"
+)
+
+// ddlKeywords are the leading keywords of schema-changing statements
+var ddlKeywords = []string{"CREATE", "ALTER", "DROP", "RENAME", "TRUNCATE"}
+
+// firstKeyword returns the upper-cased first keyword of a statement,
+// skipping leading comments and whitespace
+func firstKeyword(query string) string {
+	q := strings.TrimSpace(query)
+	for strings.HasPrefix(q, "/*") {
+		end := strings.Index(q, "*/")
+		if end < 0 {
+			return ""
+		}
+		q = strings.TrimSpace(q[end+2:])
+	}
+	if i := strings.IndexAny(q, " \t\r\n(;"); i >= 0 {
+		q = q[:i]
+	}
+	return strings.ToUpper(q)
+}
+
+// IsDDL reports whether the statement changes schema
+func IsDDL(query string) bool {
+	keyword := firstKeyword(query)
+	for _, ddl := range ddlKeywords {
+		if keyword == ddl {
+			return true
+		}
+	}
+	return false
+}
+
+// IsDML reports whether the statement inserts, updates or deletes rows
+func IsDML(query string) bool {
+	switch firstKeyword(query) {
+	case "INSERT", "REPLACE", "UPDATE", "DELETE":
+		return true
+	}
+	return false
+}
+
+// EndsTransaction reports whether a statement logged within a transaction ends it:
+// COMMIT, ROLLBACK (but not ROLLBACK TO SAVEPOINT) or DDL, which commits implicitly
+func EndsTransaction(query string) bool {
+	switch firstKeyword(query) {
+	case "COMMIT":
+		return true
+	case "ROLLBACK":
+		// ROLLBACK [WORK] TO [SAVEPOINT] name
+		tokens := tokenize(query)
+		i := 1
+		if i < len(tokens) && strings.EqualFold(tokens[i], "WORK") {
+			i++
+		}
+		return i >= len(tokens) || !strings.EqualFold(tokens[i], "TO")
+	}
+	return IsDDL(query)
+}
+
+// IsTransactionControl reports whether the statement is BEGIN/COMMIT/ROLLBACK etc.
+func IsTransactionControl(query string) bool {
+	switch firstKeyword(query) {
+	case "BEGIN", "COMMIT", "ROLLBACK", "XA", "SAVEPOINT":
+		return true
+	}
+	return false
+}
+
+// Event classes that can be selected with the event type whitelist
+const (
+	EventClassRows  = "rows"  // Table map and row events
+	EventClassDDL   = "ddl"   // Schema-changing statements
+	EventClassQuery = "query" // Other statements
+	EventClassGTID  = "gtid"  // GTID events
+	EventClassXID   = "xid"   // Transaction commits
+)
+
+// EventClasses lists all valid event classes
+var EventClasses = []string{EventClassRows, EventClassDDL, EventClassQuery, EventClassGTID, EventClassXID}
+
+// eventClass returns the whitelist class of an event, or "" for events that are
+// always passed through (rotate, format description, transaction control
+// statements, which end transactions of non-transactional tables, ...)
+func eventClass(event *replication.BinlogEvent) string {
+	switch e := event.Event.(type) {
+	case *replication.TableMapEvent, *replication.RowsEvent:
+		return EventClassRows
+	case *replication.QueryEvent:
+		if IsDDL(string(e.Query)) {
+			return EventClassDDL
+		}
+		if IsTransactionControl(string(e.Query)) {
+			return ""
+		}
+		return EventClassQuery
+	case *replication.RowsQueryEvent:
+		return EventClassQuery
+	case *replication.GTIDEvent, *replication.MariadbGTIDEvent, *replication.PreviousGTIDsEvent, *replication.MariadbGTIDListEvent:
+		return EventClassGTID
+	case *replication.XIDEvent:
+		return EventClassXID
+	}
+	return ""
+}
+
+// TableName is a schema-qualified table name
+type TableName struct {
+	Schema string
+	Name   string
+}
+
+// DDLTables returns the tables whose columns a DDL statement may change, using
+// defaultSchema for unqualified names. ok is false when the statement can't be
+// attributed to specific tables (e.g. DROP DATABASE), in which case callers
+// should assume any table may have changed.
+func DDLTables(query, defaultSchema string) (tables []TableName, ok bool) {
+	tokens := tokenize(query)
+	if len(tokens) == 0 {
+		return nil, false
+	}
+
+	// readName reads a possibly qualified table name at tokens[i]
+	readName := func(i int) (TableName, int) {
+		if i >= len(tokens) {
+			return TableName{}, i
+		}
+		if i+2 < len(tokens) && tokens[i+1] == "." {
+			return TableName{Schema: tokens[i], Name: tokens[i+2]}, i + 3
+		}
+		return TableName{Schema: defaultSchema, Name: tokens[i]}, i + 1
+	}
+	// skip skips the given keywords in order, if present
+	skip := func(i int, keywords ...string) int {
+		for _, kw := range keywords {
+			if i < len(tokens) && strings.EqualFold(tokens[i], kw) {
+				i++
+			}
+		}
+		return i
+	}
+	// find returns the index of the token after the keyword
+	find := func(i int, keyword string) int {
+		for ; i < len(tokens); i++ {
+			if strings.EqualFold(tokens[i], keyword) {
+				return i + 1
+			}
+		}
+		return -1
+	}
+	// nameList reads comma-separated table names starting at tokens[i]
+	nameList := func(i int) []TableName {
+		var names []TableName
+		for i < len(tokens) {
+			var name TableName
+			name, i = readName(i)
+			names = append(names, name)
+			if i >= len(tokens) || tokens[i] != "," {
+				break
+			}
+			i++
+		}
+		return names
+	}
+
+	i := 1
+	switch strings.ToUpper(tokens[0]) {
+	case "CREATE":
+		i = skip(i, "OR", "REPLACE", "TEMPORARY")
+		if i >= len(tokens) {
+			return nil, false
+		}
+		switch strings.ToUpper(tokens[i]) {
+		case "TABLE":
+			name, _ := readName(skip(i+1, "IF", "NOT", "EXISTS"))
+			return []TableName{name}, true
+		case "INDEX", "UNIQUE", "FULLTEXT", "SPATIAL":
+			if on := find(i, "ON"); on > 0 {
+				name, _ := readName(on)
+				return []TableName{name}, true
+			}
+			return nil, false
+		}
+		// Views, triggers, routines, databases etc. don't change table columns
+		return nil, true
+
+	case "ALTER":
+		i = skip(i, "ONLINE", "IGNORE")
+		if i < len(tokens) && strings.EqualFold(tokens[i], "TABLE") {
+			name, _ := readName(skip(i+1, "IF", "EXISTS"))
+			return []TableName{name}, true
+		}
+		return nil, true
+
+	case "DROP":
+		i = skip(i, "TEMPORARY")
+		if i >= len(tokens) {
+			return nil, false
+		}
+		switch strings.ToUpper(tokens[i]) {
+		case "TABLE", "TABLES":
+			return nameList(skip(i+1, "IF", "EXISTS")), true
+		case "INDEX":
+			if on := find(i, "ON"); on > 0 {
+				name, _ := readName(on)
+				return []TableName{name}, true
+			}
+			return nil, false
+		case "DATABASE", "SCHEMA":
+			return nil, false
+		}
+		return nil, true
+
+	case "RENAME":
+		if i >= len(tokens) || !strings.EqualFold(tokens[i], "TABLE") {
+			return nil, true
+		}
+		i++
+		for i < len(tokens) {
+			var name TableName
+			name, i = readName(i)
+			tables = append(tables, name)
+			i = skip(i, "TO")
+			if i < len(tokens) && tokens[i] == "," {
+				i++
+			}
+		}
+		return tables, true
+
+	case "TRUNCATE":
+		// Truncating doesn't change the table's columns
+		return nil, true
+	}
+	return nil, false
+}
+
+// tokenize splits a statement into identifiers/keywords and the punctuation
+// ".", "," and "(", dropping comments. Backtick quotes are removed.
+func tokenize(query string) []string {
+	var tokens []string
+	q := query
+	for len(q) > 0 {
+		switch {
+		case strings.HasPrefix(q, "/*"):
+			end := strings.Index(q, "*/")
+			if end < 0 {
+				return tokens
+			}
+			q = q[end+2:]
+		case strings.HasPrefix(q, "-- ") || q[0] == '#':
+			end := strings.IndexByte(q, '\n')
+			if end < 0 {
+				return tokens
+			}
+			q = q[end+1:]
+		case q[0] == '`':
+			var b strings.Builder
+			i := 1
+			for ; i < len(q); i++ {
+				if q[i] == '`' {
+					if i+1 < len(q) && q[i+1] == '`' {
+						b.WriteByte('`')
+						i++
+						continue
+					}
+					break
+				}
+				b.WriteByte(q[i])
+			}
+			tokens = append(tokens, b.String())
+			if i >= len(q) {
+				return tokens
+			}
+			q = q[i+1:]
+		case strings.ContainsRune(".,(;", rune(q[0])):
+			tokens = append(tokens, q[:1])
+			q = q[1:]
+		case strings.ContainsRune(" \t\r\n)", rune(q[0])):
+			q = q[1:]
+		default:
+			end := strings.IndexAny(q, " \t\r\n.,();`")
+			if end < 0 {
+				end = len(q)
+			}
+			tokens = append(tokens, q[:end])
+			q = q[end:]
+		}
+	}
+	return tokens
+}
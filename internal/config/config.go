@@ -3,6 +3,9 @@ package config
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -10,11 +13,48 @@ import (
 
 // Config represents the application configuration
 type Config struct {
-	MySQL    MySQLConfig    `yaml:"mysql"`
-	Binlog   BinlogConfig   `yaml:"binlog"`
-	NATS     NATSConfig     `yaml:"nats"`
-	Logging  LoggingConfig  `yaml:"logging"`
+	MySQL     MySQLConfig     `yaml:"mysql"`
+	Binlog    BinlogConfig    `yaml:"binlog"`
+	NATS      NATSConfig      `yaml:"nats"`
+	Sinks     []SinkConfig    `yaml:"sinks"` // Destinations change events are published to (default: nats)
+	Logging   LoggingConfig   `yaml:"logging"`
 	Processor ProcessorConfig `yaml:"processor"`
+	Heartbeat HeartbeatConfig `yaml:"heartbeat"`
+	Watermark WatermarkConfig `yaml:"watermark"`
+	CaughtUp  CaughtUpConfig  `yaml:"caught_up"`
+	Limits    LimitsConfig    `yaml:"limits"`
+	Filters   FiltersConfig   `yaml:"filters"`
+	Priority  PriorityConfig  `yaml:"priority"`
+	Pipeline  PipelineConfig  `yaml:"pipeline"`
+	Cache     CacheConfig     `yaml:"cache"`
+	Events    EventsConfig    `yaml:"events"`
+	Delivery  DeliveryConfig  `yaml:"delivery"`
+	Snapshot  SnapshotConfig  `yaml:"snapshot"`
+	Routing   RoutingConfig   `yaml:"routing"`
+	Errors    ErrorsConfig    `yaml:"errors"`
+	Alerts    AlertsConfig    `yaml:"alerts"`
+	// Report panics and repeated errors to Sentry or a webhook
+	ErrorReporting ErrorReportingConfig `yaml:"error_reporting"`
+	// Post lifecycle events and alerts to a webhook (e.g. Slack)
+	Notifications NotificationsConfig `yaml:"notifications"`
+	Metrics       MetricsConfig       `yaml:"metrics"`
+	// HTTP API for runtime state, pause/resume and transform reload
+	Admin AdminConfig `yaml:"admin"`
+	// MySQL servers replicated by this process, each as an independent pipeline
+	Sources []SourceConfig `yaml:"sources"`
+
+	SourceName string    `yaml:"-"` // Name of the sources entry this is the configuration of
+	sources    []*Config // Configuration of each sources entry
+}
+
+// SourceConfig is a MySQL server replicated alongside the others of sources. The
+// rest of the configuration is shared, with per-source subjects and positions.
+type SourceConfig struct {
+	Name         string    `yaml:"name"`          // Used in logs, admin API responses and the defaults below (required)
+	MySQL        yaml.Node `yaml:"mysql"`         // mysql settings replacing the top-level ones; the others are inherited
+	Subject      string    `yaml:"subject"`       // nats.subject (default: nats.subject with the name after its fixed tokens)
+	PositionFile string    `yaml:"position_file"` // binlog.position_file (default: the top-level one with the name before the extension)
+	PositionKey  string    `yaml:"position_key"`  // binlog.position_store.key (default: the top-level key with -<name>, or "mysql-cdc-<server_id>")
 }
 
 // MySQLConfig contains MySQL connection settings
@@ -24,46 +64,604 @@ type MySQLConfig struct {
 	User     string `yaml:"user"`
 	Password string `yaml:"password"`
 	ServerID uint32 `yaml:"server_id"`
-	Flavor   string `yaml:"flavor"` // mysql, mariadb
-	Version  string `yaml:"version"` // Optional: 5.6, 5.7, 8.0, etc.
+	Flavor   string `yaml:"flavor"`   // auto (detect from the server), mysql, mariadb, percona
+	Version  string `yaml:"version"`  // Optional: 5.6, 5.7, 8.0, etc. (detected from the server if empty)
 	UseGTID  bool   `yaml:"use_gtid"` // Use GTID for replication (MySQL 5.6+)
+	// Table name case handling: auto (detect from the server), 0 (case-sensitive), 1 or 2 (case-insensitive)
+	LowerCaseTableNames string `yaml:"lower_case_table_names"`
+	// Fail startup instead of warning when the server configuration could lose or mis-decode events
+	Strict bool `yaml:"strict"`
+	// Warn (or fail in strict mode) when binlogs are purged sooner than this (default: 24h)
+	MinBinlogRetention time.Duration `yaml:"min_binlog_retention"`
+	// Connection used to read column metadata from INFORMATION_SCHEMA
+	Metadata MetadataConfig `yaml:"metadata"`
+	// TLS for the replication, metadata and snapshot connections
+	TLS MySQLTLSConfig `yaml:"tls"`
+	// Restarting the replication stream after it breaks
+	Reconnect ReconnectConfig `yaml:"reconnect"`
+}
+
+// ReconnectConfig contains settings for restarting a broken replication stream
+type ReconnectConfig struct {
+	InitialDelay time.Duration `yaml:"initial_delay"` // Wait before the first attempt, doubled after each failure (default: 1s)
+	MaxDelay     time.Duration `yaml:"max_delay"`     // Longest wait between attempts (default: 10s)
+	MaxRetries   int           `yaml:"max_retries"`   // Failed attempts before the service stops (0 = retry forever)
+}
+
+// MySQLTLSConfig contains MySQL TLS settings
+type MySQLTLSConfig struct {
+	Mode       string `yaml:"mode"`        // disabled (default), required, verify-ca or verify-identity
+	CA         string `yaml:"ca"`          // CA certificates file (default for verify-*: the system's)
+	Cert       string `yaml:"cert"`        // Client certificate file, for users that require X509
+	Key        string `yaml:"key"`         // Client certificate's private key file
+	ServerName string `yaml:"server_name"` // Name verified by verify-identity (default: the host connected to)
+}
+
+// MetadataConfig contains settings for the column metadata connection pool
+type MetadataConfig struct {
+	Host            string        `yaml:"host"`              // Defaults to mysql.host, e.g. point at a read replica
+	Port            int           `yaml:"port"`              // Defaults to mysql.port
+	User            string        `yaml:"user"`              // Defaults to mysql.user
+	Password        string        `yaml:"password"`          // Defaults to mysql.password
+	MaxOpenConns    int           `yaml:"max_open_conns"`    // Defaults to 1
+	MaxIdleConns    int           `yaml:"max_idle_conns"`    // Defaults to max_open_conns
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"` // 0 = connections are reused forever
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"`
+	ConnectTimeout  time.Duration `yaml:"connect_timeout"` // 0 = driver default
+	ReadTimeout     time.Duration `yaml:"read_timeout"`
+	WriteTimeout    time.Duration `yaml:"write_timeout"`
 }
 
 // BinlogConfig contains binlog settings
 type BinlogConfig struct {
 	PositionFile  string `yaml:"position_file"`
 	StartPosition uint32 `yaml:"start_position"`
-	StartTimestamp uint32 `yaml:"start_timestamp"`
+	// Where the position is persisted: the position file (default), a NATS KV bucket, a MySQL table or Redis
+	PositionStore  PositionStoreConfig `yaml:"position_store"`
+	StartTimestamp uint32              `yaml:"start_timestamp"`
+	// Coalesce position writes to at most one per interval, in the background. Negative: write
+	// after every event (default: after every event for the position file, 200ms for other stores)
+	PositionFlushInterval time.Duration `yaml:"position_flush_interval"`
+	// Binlog event classes to process: rows, ddl, query, gtid, xid (empty = all)
+	EventTypes []string `yaml:"event_types"`
+	// Attach key=value annotations from statement comments (e.g. /* app=checkout */) to events
+	QueryContext bool `yaml:"query_context"`
+	// Turn simple DML statements logged in STATEMENT/MIXED format into best-effort change events
+	StatementFallback bool `yaml:"statement_fallback"`
+	// Publish raw binlog events instead of decoded change events
+	Passthrough PassthroughConfig `yaml:"passthrough"`
+	// Process an explicit range of the binlog and exit
+	Range RangeConfig `yaml:"range"`
+	// Publish non-DDL QueryEvents to a separate subject for auditing
+	Statements StatementsConfig `yaml:"statements"`
+	DDL        DDLConfig        `yaml:"ddl"`
+}
+
+// PositionStoreConfig selects where binlog positions are persisted
+type PositionStoreConfig struct {
+	Type   string                   `yaml:"type"` // file (default), nats_kv, mysql or redis
+	Key    string                   `yaml:"key"`  // Key the position is stored under (default: "mysql-cdc-<mysql.server_id>")
+	NATSKV PositionNATSKVConfig     `yaml:"nats_kv"`
+	MySQL  PositionMySQLStoreConfig `yaml:"mysql"`
+	Redis  PositionRedisConfig      `yaml:"redis"`
+}
+
+// PositionNATSKVConfig contains settings of the NATS KV position store
+type PositionNATSKVConfig struct {
+	Bucket string `yaml:"bucket"` // KV bucket on nats.url, created if missing (default: mysql_cdc_positions)
+}
+
+// PositionMySQLStoreConfig contains settings of the MySQL position store. The
+// connection defaults to mysql.metadata's.
+type PositionMySQLStoreConfig struct {
+	Table    string `yaml:"table"` // "database.table", created if missing
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+}
+
+// PositionRedisConfig contains settings of the Redis position store
+type PositionRedisConfig struct {
+	Address  string        `yaml:"address"` // host:port (default: localhost:6379)
+	Password string        `yaml:"password"`
+	DB       int           `yaml:"db"`
+	Timeout  time.Duration `yaml:"timeout"` // Dial, read and write timeout (default: 5s)
+}
+
+// StatementsConfig contains statement capture settings
+type StatementsConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Subject string `yaml:"subject"` // Defaults to "<nats.subject>.statements"
+}
+
+// DDLConfig contains DDL event settings
+type DDLConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Subject string `yaml:"subject"` // Defaults to "<nats.subject>.ddl"
 }
 
 // NATSConfig contains NATS connection settings
 type NATSConfig struct {
-	URL           string        `yaml:"url"`
-	Subject       string        `yaml:"subject"`
-	MaxReconnect  int           `yaml:"max_reconnect"`
-	ReconnectWait time.Duration `yaml:"reconnect_wait"`
+	URL           string            `yaml:"url"`
+	Auth          NATSAuthConfig    `yaml:"auth"`
+	TLS           NATSTLSConfig     `yaml:"tls"`
+	Subject       string            `yaml:"subject"`
+	MaxReconnect  int               `yaml:"max_reconnect"`
+	ReconnectWait time.Duration     `yaml:"reconnect_wait"`
+	Signing       SigningConfig     `yaml:"signing"`
+	ConsumerLag   ConsumerLagConfig `yaml:"consumer_lag"`
+	SlowSink      SlowSinkConfig    `yaml:"slow_sink"`
+	JetStream     JetStreamConfig   `yaml:"jetstream"`
+	Batch         BatchConfig       `yaml:"batch"`
+}
+
+// BatchConfig contains settings for publishing change events in batches
+type BatchConfig struct {
+	Enabled   bool          `yaml:"enabled"`
+	MaxEvents int           `yaml:"max_events"` // Events per batch message before it's published (default: 100)
+	Interval  time.Duration `yaml:"interval"`   // How often pending batches are published (default: 100ms)
+}
+
+// SinkConfig configures a destination change events are published to
+type SinkConfig struct {
+	Type string `yaml:"type"` // nats, stdout, file, webhook, redis, kafka or s3
+	Name string `yaml:"name"` // Used in logs and metrics (default: the type)
+	// fail (default): the publish fails and errors.publish applies
+	// skip: the error is logged and the event counts as published to this sink
+	OnError string `yaml:"on_error"`
+	// Event encoding: rows, columnar or debezium (default: events.format; nats always uses events.format)
+	Format string `yaml:"format"`
+	// kafka, webhook: gzip or none (default); s3: gzip (default) or none
+	Compression string `yaml:"compression"`
+
+	Path    string            `yaml:"path"`    // file: file events are appended to, one JSON object per line
+	URL     string            `yaml:"url"`     // webhook: endpoint each event is POSTed to
+	Headers map[string]string `yaml:"headers"` // webhook: extra request headers
+	Timeout time.Duration     `yaml:"timeout"` // webhook, s3: HTTP timeout (default: 10s, 30s); redis, kafka: dial, read and write timeout (default: 5s, 10s)
+
+	// file: rotation of path to a timestamped file, optionally gzipped
+	MaxSize        int64         `yaml:"max_size"`        // Rotate once the file reaches this many bytes (0 = no limit)
+	RotateInterval time.Duration `yaml:"rotate_interval"` // Rotate at each multiple of this interval (0 = never)
+	Compress       bool          `yaml:"compress"`        // Gzip rotated files
+
+	// redis: stream each event is added to with XADD
+	Address  string `yaml:"address"` // host:port, any node of a cluster (default: localhost:6379)
+	Password string `yaml:"password"`
+	DB       int    `yaml:"db"`
+	Key      string `yaml:"key"`     // Stream key template: {database}, {table} and {type} (default: cdc:{database}.{table})
+	MaxLen   int64  `yaml:"max_len"` // Trim streams to about this many entries (0 = no trimming)
+
+	// kafka: topic each event is produced to, keyed by its primary key
+	Brokers  []string `yaml:"brokers"`  // Bootstrap brokers, host:port
+	Topic    string   `yaml:"topic"`    // Topic template: {database}, {table} and {type} (default: cdc.{database}.{table})
+	Acks     string   `yaml:"acks"`     // all (default), leader or none
+	Username string   `yaml:"username"` // SASL/PLAIN user, with password (empty = no authentication)
+	TLS      bool     `yaml:"tls"`      // Connect to the brokers with TLS
+
+	// s3: objects of buffered events, partitioned by database, table and date
+	Bucket          string        `yaml:"bucket"`
+	Region          string        `yaml:"region"`            // default: us-east-1
+	Endpoint        string        `yaml:"endpoint"`          // S3-compatible storage URL, addressed path style (default: AWS S3)
+	Prefix          string        `yaml:"prefix"`            // Key prefix of the objects
+	AccessKeyID     string        `yaml:"access_key_id"`     // default: AWS_ACCESS_KEY_ID
+	SecretAccessKey string        `yaml:"secret_access_key"` // default: AWS_SECRET_ACCESS_KEY
+	ObjectFormat    string        `yaml:"object_format"`     // jsonl (default) or parquet
+	FlushInterval   time.Duration `yaml:"flush_interval"`    // How often buffered events are written (default: 1m)
+	MaxEvents       int           `yaml:"max_events"`        // Events per object before it's written early (default: 10000)
+}
+
+// JetStreamConfig contains settings for publishing change events to JetStream
+type JetStreamConfig struct {
+	Enabled         bool          `yaml:"enabled"`          // Always on with at_least_once and exactly_once delivery
+	Stream          string        `yaml:"stream"`           // Stream publishes must be stored in (empty = any stream capturing the subject)
+	Create          bool          `yaml:"create"`           // Create the stream at startup if it doesn't exist
+	Dedup           bool          `yaml:"dedup"`            // Send event dedup IDs as Nats-Msg-Id. Always on with exactly_once delivery
+	DuplicateWindow time.Duration `yaml:"duplicate_window"` // Duplicate window of a created stream (default: 2m)
+}
+
+// ConsumerLagConfig contains JetStream consumer lag reporting settings
+type ConsumerLagConfig struct {
+	Enabled       bool          `yaml:"enabled"`
+	Stream        string        `yaml:"stream"`         // JetStream stream the consumers read from
+	Consumers     []string      `yaml:"consumers"`      // Durable consumer names to monitor
+	Interval      time.Duration `yaml:"interval"`       // Defaults to 30s
+	Subject       string        `yaml:"subject"`        // Defaults to "<nats.subject>.consumer_lag"
+	WarnThreshold uint64        `yaml:"warn_threshold"` // Log a warning when lag reaches this many messages (0 = never)
+}
+
+// SlowSinkConfig contains slow sink detection settings
+type SlowSinkConfig struct {
+	Enabled        bool          `yaml:"enabled"`
+	BufferedBytes  int           `yaml:"buffered_bytes"`  // Alert when the outbound buffer stays above this size (0 = disabled)
+	PublishLatency time.Duration `yaml:"publish_latency"` // Alert when publishes take at least this long (0 = disabled)
+	Duration       time.Duration `yaml:"duration"`        // How long the condition must last before alerting (default: 30s)
+	Subject        string        `yaml:"subject"`         // Defaults to "<nats.subject>.alerts"
+}
+
+// NATSAuthConfig contains NATS authentication settings. At most one method can be set.
+type NATSAuthConfig struct {
+	Token           string `yaml:"token"`
+	Username        string `yaml:"username"`
+	Password        string `yaml:"password"`
+	NKeySeedFile    string `yaml:"nkey_seed_file"`   // File with the user's NKey seed (SU...)
+	CredentialsFile string `yaml:"credentials_file"` // .creds file with the user JWT and NKey seed (e.g. Synadia Cloud)
+}
+
+// NATSTLSConfig contains NATS TLS settings
+type NATSTLSConfig struct {
+	CAFile             string `yaml:"ca_file"`              // CA certificates the server's certificate is verified with (default: the system's)
+	CertFile           string `yaml:"cert_file"`            // Client certificate, for servers that verify clients
+	KeyFile            string `yaml:"key_file"`             // Client certificate's private key
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"` // Don't verify the server's certificate (testing only)
+}
+
+// SigningConfig contains payload signing settings
+type SigningConfig struct {
+	Enabled   bool   `yaml:"enabled"`
+	Algorithm string `yaml:"algorithm"` // hmac-sha256 (default) or ed25519
+	Key       string `yaml:"key"`       // HMAC secret, or base64 ed25519 seed/private key
+	KeyFile   string `yaml:"key_file"`  // Read the key from a file instead of the config
+	KeyID     string `yaml:"key_id"`    // Optional key identifier sent alongside the signature
+	Header    string `yaml:"header"`    // Signature header name (default: Cdc-Signature)
+}
+
+// HeartbeatConfig contains heartbeat event settings
+type HeartbeatConfig struct {
+	Enabled  bool          `yaml:"enabled"`
+	Interval time.Duration `yaml:"interval"` // Defaults to 10s
+	Subject  string        `yaml:"subject"`  // Defaults to "<nats.subject>.heartbeat"
+}
+
+// WatermarkConfig contains committed position announcement settings
+type WatermarkConfig struct {
+	Enabled  bool          `yaml:"enabled"`
+	Interval time.Duration `yaml:"interval"`  // Defaults to 5s
+	Subject  string        `yaml:"subject"`   // Defaults to "<nats.subject>.watermark"
+	KVBucket string        `yaml:"kv_bucket"` // Optional: also store the watermark in this KV bucket
+	KVKey    string        `yaml:"kv_key"`    // KV key (default: "watermark")
+}
+
+// CaughtUpConfig contains settings for the one-time signal sent when the stream catches up with the master
+type CaughtUpConfig struct {
+	Enabled  bool          `yaml:"enabled"`
+	Interval time.Duration `yaml:"interval"` // How often the master position is checked (default: 5s)
+	Subject  string        `yaml:"subject"`  // Defaults to "<nats.subject>.caught_up"
+}
+
+// AlertsConfig contains operational alert settings
+type AlertsConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Subject string `yaml:"subject"` // Defaults to "cdc.ops.alerts"
+	// Compare cached table schemas with INFORMATION_SCHEMA at this interval (0 = only after DDL)
+	SchemaDriftInterval time.Duration `yaml:"schema_drift_interval"`
+}
+
+// ErrorReportingConfig contains settings for reporting panics and repeated pipeline
+// errors. Reporting is enabled when sentry_dsn or webhook_url is set.
+type ErrorReportingConfig struct {
+	SentryDSN   string        `yaml:"sentry_dsn"`  // Report to Sentry
+	WebhookURL  string        `yaml:"webhook_url"` // POST reports as JSON to this URL
+	Environment string        `yaml:"environment"` // Environment name attached to reports
+	Threshold   int           `yaml:"threshold"`   // Occurrences of an error within the window before it's reported (default: 5)
+	Window      time.Duration `yaml:"window"`      // Default: 1m
+	Timeout     time.Duration `yaml:"timeout"`     // HTTP timeout (default: 5s)
+}
+
+// NotificationsConfig contains webhook notification settings
+type NotificationsConfig struct {
+	WebhookURL string        `yaml:"webhook_url"` // Notifications are disabled if empty
+	Format     string        `yaml:"format"`      // generic (default): the alert event as JSON; slack: a Slack message
+	Events     []string      `yaml:"events"`      // Notification and alert names to send (default: see README)
+	Timeout    time.Duration `yaml:"timeout"`     // HTTP timeout (default: 5s)
+}
+
+// MetricsConfig contains metrics export settings
+type MetricsConfig struct {
+	Interval time.Duration `yaml:"interval"` // How often gauges (lag, position) are reported (default: 10s)
+	StatsD   StatsDConfig  `yaml:"statsd"`
+}
+
+// StatsDConfig contains settings for pushing metrics to a StatsD or DogStatsD agent
+type StatsDConfig struct {
+	Enabled       bool          `yaml:"enabled"`
+	Address       string        `yaml:"address"`        // Agent UDP address (default: "127.0.0.1:8125")
+	Format        string        `yaml:"format"`         // statsd (default) or dogstatsd (adds tags)
+	Prefix        string        `yaml:"prefix"`         // Metric name prefix (default: "mysql_cdc")
+	Tags          []string      `yaml:"tags"`           // Tags added to every metric, e.g. "env:prod" (dogstatsd only)
+	FlushInterval time.Duration `yaml:"flush_interval"` // Max time metrics are buffered (default: 1s)
+}
+
+// AdminConfig contains admin HTTP API settings
+type AdminConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Address string `yaml:"address"` // Listen address (default: "127.0.0.1:8080")
+	Token   string `yaml:"token"`   // Optional: required as "Authorization: Bearer <token>"
+}
+
+// LimitsConfig contains event size limits
+type LimitsConfig struct {
+	MaxRowSize        int    `yaml:"max_row_size"`        // Max serialized row size in bytes (0 = unlimited)
+	RowSizePolicy     string `yaml:"row_size_policy"`     // truncate (default), reference, dead_letter
+	ReferenceBucket   string `yaml:"reference_bucket"`    // JetStream object store bucket for the reference policy
+	DeadLetterSubject string `yaml:"dead_letter_subject"` // Defaults to "<nats.subject>.dead_letter"
+	// Per-column max value lengths in bytes, keyed by "column", "table.column" or "database.table.column"
+	ColumnMaxLength map[string]int `yaml:"column_max_length"`
+	// Split events with more rows into several messages with part/parts markers (0 = unlimited)
+	MaxRowsPerMessage int `yaml:"max_rows_per_message"`
+	// Per-table publish rate caps
+	Throttle []ThrottleRule `yaml:"throttle"`
+	// What to do with events whose encoded payload exceeds the NATS max_payload
+	Oversize OversizeConfig `yaml:"oversize"`
+}
+
+// OversizeConfig contains the strategy for events too large to publish
+type OversizeConfig struct {
+	Strategy string `yaml:"strategy"` // split, drop_columns, subject or reference (empty = fail the publish)
+	MaxSize  int    `yaml:"max_size"` // Max payload in bytes (0 = the NATS server's max_payload less 1 KiB for headers)
+	Subject  string `yaml:"subject"`  // Subject of the subject strategy (default: "<nats.subject>.oversize")
+	Bucket   string `yaml:"bucket"`   // Object store bucket of the reference strategy (default: limits.reference_bucket)
+}
+
+// ThrottleRule caps the publish rate of matching tables
+type ThrottleRule struct {
+	Database     string  `yaml:"database"`       // Database name (empty = all databases)
+	Table        string  `yaml:"table"`          // Table name (empty = all tables)
+	MaxPerSecond float64 `yaml:"max_per_second"` // Max events published per second
+	Burst        int     `yaml:"burst"`          // Events allowed above the rate in a burst (default: 1)
+}
+
+// FiltersConfig contains settings that decide which events are published
+type FiltersConfig struct {
+	IncludeSystemSchemas bool       `yaml:"include_system_schemas"` // Publish changes to mysql, sys, information_schema and performance_schema
+	Tables               []TableRef `yaml:"tables"`                 // Only publish changes to these tables (empty = all)
+	// "database.table" globs, e.g. "shop.*"; a leading "!" excludes matching tables
+	Patterns []string       `yaml:"patterns"`
+	Sampling []SamplingRule `yaml:"sampling"`
+	// Only publish transactions originating on these server IDs (empty = all)
+	ServerIDs []uint32 `yaml:"server_ids"`
+	// Only publish MariaDB transactions with these GTID domain IDs (empty = all)
+	GTIDDomainIDs []uint32 `yaml:"gtid_domain_ids"`
+}
+
+// SamplingRule publishes only a fraction of the events of matching tables
+type SamplingRule struct {
+	Database string `yaml:"database"` // Database name (empty = all databases)
+	Table    string `yaml:"table"`    // Table name (empty = all tables)
+	Rate     int    `yaml:"rate"`     // Publish 1 in every Rate events
+}
+
+// PriorityConfig contains table priority settings used when delivery falls behind
+type PriorityConfig struct {
+	High      []TableRef `yaml:"high"`       // Tables whose events are delivered ahead of all others
+	QueueSize int        `yaml:"queue_size"` // Events buffered per lane (default: 1000)
+}
+
+// TableRef identifies a table; empty fields match everything
+type TableRef struct {
+	Database string `yaml:"database"`
+	Table    string `yaml:"table"`
+}
+
+// PipelineConfig contains parallel delivery settings
+type PipelineConfig struct {
+	Workers   int    `yaml:"workers"`    // Number of parallel transform/publish workers (0 or 1 = sequential)
+	Key       string `yaml:"key"`        // Ordering key: table (default) or primary_key
+	QueueSize int    `yaml:"queue_size"` // Events buffered per worker (default: 1000)
+}
+
+// CacheConfig contains metadata cache settings
+type CacheConfig struct {
+	TableMap      CacheSizeConfig `yaml:"table_map"`      // Binlog table map events by table ID
+	Columns       CacheSizeConfig `yaml:"columns"`        // Column names/types fetched from INFORMATION_SCHEMA
+	StatsInterval time.Duration   `yaml:"stats_interval"` // Log hit/miss rates at this interval (0 = disabled)
+}
+
+// CacheSizeConfig bounds a cache
+type CacheSizeConfig struct {
+	MaxSize int           `yaml:"max_size"` // Max entries (0 = unbounded)
+	TTL     time.Duration `yaml:"ttl"`      // Entry lifetime (0 = never expire)
+}
+
+// EventsConfig contains settings for the shape of published change events
+type EventsConfig struct {
+	// Payload shape: rows (default, one map per row), columnar (column names once, rows as value arrays)
+	// or debezium (Debezium MySQL connector envelope, one row per event)
+	Format string `yaml:"format"`
+	// Settings of the debezium format
+	Debezium DebeziumConfig `yaml:"debezium"`
+	// Unit of the timestamp field: seconds (default), milliseconds, microseconds or iso8601
+	Timestamp string `yaml:"timestamp"`
+	// Event ID: ulid (default) or gtid ("<gtid>/<n>", stable across re-reads; ULID without GTIDs)
+	ID string `yaml:"id"`
+	// Attach column types, nullability and comments: none (default), always, or first (first event per table)
+	Schema string `yaml:"schema"`
+	// Publish each table's schema on first encounter and after DDL
+	Announcements AnnouncementsConfig `yaml:"announcements"`
+	// Merge consecutive single-row events of the same table within a transaction
+	Coalesce CoalesceConfig `yaml:"coalesce"`
+	// Where change events take their correlation ID from (transform scripts can also set it)
+	Correlation CorrelationConfig `yaml:"correlation"`
+	// Transaction metadata on each event, or one TRANSACTION envelope per transaction
+	Transactions TransactionsConfig `yaml:"transactions"`
+	// Attach a metadata block with the format version, source server and position
+	Metadata bool `yaml:"metadata"`
+	// Convert column values to stable JSON types by column type
+	Types TypesConfig `yaml:"types"`
+	// Publish ENUM and SET values read from the binlog as their index and bitmask
+	// instead of their labels
+	RawEnums bool `yaml:"raw_enums"`
+}
+
+// TypesConfig contains settings of the column value conversion (see internal/typeconv)
+type TypesConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	Timezone string `yaml:"timezone"` // IANA time zone DATETIME values are in (default: UTC)
+	Geometry string `yaml:"geometry"` // Spatial values as wkt (default) or geojson
+}
+
+// DebeziumConfig contains settings of the Debezium event format
+type DebeziumConfig struct {
+	ServerName string `yaml:"server_name"` // Logical server name in source.name (default: nats.subject)
+}
+
+// CoalesceConfig contains settings for merging per-row events into multi-row events
+type CoalesceConfig struct {
+	Enabled bool `yaml:"enabled"`
+	MaxRows int  `yaml:"max_rows"` // Max rows in a merged event (default: 1000)
+}
+
+// CorrelationConfig contains the sources of change event correlation IDs, tried in order
+type CorrelationConfig struct {
+	QueryContext string `yaml:"query_context"` // Statement comment annotation key (requires binlog.query_context)
+	Column       string `yaml:"column"`        // Row column, read from the event's first row
+}
+
+// TransactionsConfig contains settings for grouping change events by source transaction
+type TransactionsConfig struct {
+	Metadata   bool `yaml:"metadata"`    // Attach the transaction's ID, XID, sequence number and last-event flag to each event
+	Envelope   bool `yaml:"envelope"`    // Publish each transaction's changes together in TRANSACTION events
+	MaxChanges int  `yaml:"max_changes"` // Changes per TRANSACTION event before the next part is started (default: 1000)
+}
+
+// AnnouncementsConfig contains table schema announcement settings
+type AnnouncementsConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Subject string `yaml:"subject"` // Defaults to "<nats.subject>.schema"
+}
+
+// RangeConfig contains bounded run settings. The run is bounded when End is set.
+type RangeConfig struct {
+	Start        string `yaml:"start"`         // "file:pos" to start at (default: binlog.start_position or the range position file)
+	End          string `yaml:"end"`           // "file:pos" to stop at
+	PositionFile string `yaml:"position_file"` // Position file of the run (empty = persist nothing)
+}
+
+// PassthroughConfig contains raw binlog passthrough settings
+type PassthroughConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Subject string `yaml:"subject"` // Defaults to nats.subject
+}
+
+// DeliveryConfig contains delivery guarantee settings
+type DeliveryConfig struct {
+	// at_most_once: persist positions when read, drop events whose publish fails
+	// at_least_once (default): publish to JetStream, persist positions only after acks, retry failed publishes
+	// exactly_once: at_least_once plus JetStream dedup IDs
+	Mode          string        `yaml:"mode"`
+	AckTimeout    time.Duration `yaml:"ack_timeout"`    // JetStream publish ack timeout (default: 5s)
+	RetryInterval time.Duration `yaml:"retry_interval"` // Wait between publish retries (default: 1s)
+	DrainTimeout  time.Duration `yaml:"drain_timeout"`  // Time given to in-flight events on shutdown (default: 30s, negative = don't wait)
+	// Skip re-publishing recently published events after a restart rewinds to the last persisted position
+	ReplayGuard ReplayGuardConfig `yaml:"replay_guard"`
+}
+
+// SnapshotConfig contains initial snapshot settings
+type SnapshotConfig struct {
+	Enabled   bool     `yaml:"enabled"`    // Snapshot existing rows when there's no saved position
+	Tables    []string `yaml:"tables"`     // database.table names to snapshot (empty = every captured table)
+	ChunkSize int      `yaml:"chunk_size"` // Rows read per query and published per event (default: 1000)
+	// database.table -> SQL condition limiting the rows snapshotted, e.g. created_at > '2024-01-01'
+	Where map[string]string `yaml:"where"`
+	// global (default): FLUSH TABLES WITH READ LOCK while the snapshot starts, for an exact position
+	// none: no lock; changes made while the snapshot starts may be both in it and streamed after it
+	Lock string `yaml:"lock"`
+}
+
+// ErrorsConfig contains the error policy of each pipeline stage
+type ErrorsConfig struct {
+	Decode    ErrorPolicyConfig `yaml:"decode"`    // Decoding row events into change events (default: skip)
+	Transform ErrorPolicyConfig `yaml:"transform"` // Processor rules and JavaScript transforms (default: skip)
+	Publish   ErrorPolicyConfig `yaml:"publish"`   // Publishing change events (default: skip with at_most_once delivery, retry otherwise)
+	// Subject events are dead-lettered to by the dlq policy (default: "<nats.subject>.dead_letter")
+	DeadLetterSubject string `yaml:"dead_letter_subject"`
+	// File dead letters are appended to as JSON lines instead of the dead-letter subject
+	DeadLetterFile string `yaml:"dead_letter_file"`
+}
+
+// ErrorPolicyConfig decides what happens to an event that fails a pipeline stage
+type ErrorPolicyConfig struct {
+	// fail: stop the service without advancing the position past the event
+	// skip: log and drop the event
+	// dlq: publish the event and the error to the dead-letter subject
+	// retry: try again every delivery.retry_interval
+	OnError    string `yaml:"on_error"`
+	MaxRetries int    `yaml:"max_retries"` // Retries before the retry policy fails the service (0 = retry forever)
+}
+
+// RoutingConfig contains per-event subject routing settings
+type RoutingConfig struct {
+	// Subject template with {database}, {table}, {type} and {tenant} placeholders (empty = nats.subject)
+	Subject string `yaml:"subject"`
+	// Column whose value is substituted for {tenant}; rows are grouped by it
+	TenantColumn string `yaml:"tenant_column"`
+	// Tenant used for rows without a tenant column value (default: "unknown")
+	DefaultTenant string `yaml:"default_tenant"`
+	// Handling of tables without a primary key or NOT NULL unique key
+	Keyless KeylessConfig `yaml:"keyless"`
+}
+
+// KeylessConfig sets how events of tables without a primary key or NOT NULL
+// unique key are keyed and routed
+type KeylessConfig struct {
+	// full_row (default): rows are keyed by all their column values; table: events
+	// are only ordered per table; skip: events are dropped
+	Policy string `yaml:"policy"`
+	// Subject template for these tables ({database}, {table}, {type}); empty = the usual subject
+	Subject string `yaml:"subject"`
+}
+
+// ReplayGuardConfig contains settings for the persisted ring of recently published event IDs
+type ReplayGuardConfig struct {
+	Enabled       bool          `yaml:"enabled"`
+	File          string        `yaml:"file"`           // Default: .published_ids
+	Size          int           `yaml:"size"`           // Number of event IDs remembered (default: 10000)
+	FlushInterval time.Duration `yaml:"flush_interval"` // Interval between file writes (default: 200ms)
 }
 
 // LoggingConfig contains logging settings
 type LoggingConfig struct {
-	Level string `yaml:"level"`
+	Level  string `yaml:"level"`
+	Format string `yaml:"format"` // text (default) or json, one object per line
 }
 
 // ProcessorConfig contains processor/transformer settings
 type ProcessorConfig struct {
-	Enabled     bool            `yaml:"enabled"`
-	Script      string          `yaml:"script"`      // Path to JavaScript transformation script
-	Rules       []ProcessorRule `yaml:"rules"`       // YAML-based transformation rules
+	Enabled bool            `yaml:"enabled"`
+	Script  string          `yaml:"script"`  // Path to JavaScript transformation script
+	Workers int             `yaml:"workers"` // JavaScript transform workers with persistent runtimes (0 = runtimes pooled per call)
+	Rules   []ProcessorRule `yaml:"rules"`   // YAML-based transformation rules
+	// Salt mixed into anonymizer hashes so fake values can't be reversed with a lookup table
+	AnonymizeSalt string `yaml:"anonymize_salt"`
+	// HTTP requests from JavaScript scripts, to enrich events from internal services
+	HTTP ProcessorHTTPConfig `yaml:"http"`
+	// Reload rules and scripts when the config file or a script changes (SIGHUP always reloads)
+	Watch         bool          `yaml:"watch"`
+	WatchInterval time.Duration `yaml:"watch_interval"` // How often the files are checked (default: 2s)
+}
+
+// ProcessorHTTPConfig contains settings for the http binding of JavaScript scripts
+type ProcessorHTTPConfig struct {
+	Enabled         bool          `yaml:"enabled"`
+	AllowedHosts    []string      `yaml:"allowed_hosts"`     // Hosts scripts may request: example.com, example.com:8080 or *.example.com
+	Timeout         time.Duration `yaml:"timeout"`           // Per request, including reading the response (default: 5s)
+	MaxResponseSize int           `yaml:"max_response_size"` // Max response body size in bytes (default: 1048576)
 }
 
 // ProcessorRule defines transformation rules for specific tables
 type ProcessorRule struct {
-	Database   string            `yaml:"database"`   // Database name (empty = all databases)
-	Table      string            `yaml:"table"`      // Table name (empty = all tables)
-	Include    []string          `yaml:"include"`    // Fields to include (empty = all fields)
-	Exclude    []string          `yaml:"exclude"`     // Fields to exclude
-	Rename     map[string]string `yaml:"rename"`     // Field rename mapping (old_name -> new_name)
-	AddFields  map[string]string `yaml:"add_fields"` // Fields to add with static values
+	Database  string            `yaml:"database"`   // Database name (empty = all databases)
+	Table     string            `yaml:"table"`      // Table name (empty = all tables)
+	Include   []string          `yaml:"include"`    // Fields to include (empty = all fields)
+	Exclude   []string          `yaml:"exclude"`    // Fields to exclude
+	Rename    map[string]string `yaml:"rename"`     // Field rename mapping (old_name -> new_name)
+	AddFields map[string]string `yaml:"add_fields"` // Fields to add with static values
+	Anonymize map[string]string `yaml:"anonymize"`  // Column -> anonymizer preset (fake_name, fake_email, fake_phone, hash, date_jitter[:days])
+	Script    string            `yaml:"script"`     // JavaScript transformation script for the matched tables, run after the settings above
+	Where     string            `yaml:"where"`      // Row condition, e.g. row.status == 'active' && row.amount > 100 (empty = all rows)
+	Mask      []string          `yaml:"mask"`       // Columns masked keeping their format, optionally with the last N characters visible (ssn, card_number:4)
+	Hash      map[string]string `yaml:"hash"`       // Column -> hash algorithm (sha256, sha512, sha1, md5)
+	Binary    map[string]string `yaml:"binary"`     // Binary column -> output (base64, hex, skip, truncate:N)
 }
 
 // LoadConfig loads configuration from a YAML file
@@ -73,19 +671,817 @@ func LoadConfig(path string) (*Config, error) {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
+	config, err := parseConfig(data, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := loadSources(config, data); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// SourceConfigs returns the configuration of each source: one per sources entry,
+// or the configuration itself without sources
+func (c *Config) SourceConfigs() []*Config {
+	if len(c.sources) == 0 {
+		return []*Config{c}
+	}
+	return c.sources
+}
+
+// Source returns the configuration of the sources entry with the given name. An
+// empty name is only allowed without sources, and returns the configuration itself.
+func (c *Config) Source(name string) (*Config, error) {
+	if name == "" {
+		if len(c.sources) > 0 {
+			return nil, fmt.Errorf("a source is required: the configuration has sources")
+		}
+		return c, nil
+	}
+	for _, source := range c.sources {
+		if source.SourceName == name {
+			return source, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown source: %s", name)
+}
+
+// parseConfig parses a configuration file and sets its defaults. With a source,
+// the source's settings are applied before the defaults.
+func parseConfig(data []byte, source *SourceConfig) (*Config, error) {
 	var config Config
 	if err := yaml.Unmarshal(data, &config); err != nil {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
+	if source != nil {
+		if err := applySource(&config, source); err != nil {
+			return nil, fmt.Errorf("sources %s: %w", source.Name, err)
+		}
+	}
+
+	// A templated nats.subject routes change events like routing.subject; its fixed
+	// leading tokens are the base the other subjects default to
+	if strings.Contains(config.NATS.Subject, "{") {
+		if config.Routing.Subject != "" {
+			return nil, fmt.Errorf("nats.subject can't be a template when routing.subject is set")
+		}
+		base := subjectBase(config.NATS.Subject)
+		if base == "" {
+			return nil, fmt.Errorf("templated nats.subject %q must start with a fixed token, e.g. cdc.{database}.{table}", config.NATS.Subject)
+		}
+		config.Routing.Subject = config.NATS.Subject
+		config.NATS.Subject = base
+	}
 
 	// Set defaults
+	if len(config.Sinks) == 0 {
+		config.Sinks = []SinkConfig{{Type: "nats"}}
+	}
+	sinkNames := make(map[string]bool, len(config.Sinks))
+	for i := range config.Sinks {
+		sink := &config.Sinks[i]
+		switch sink.Type {
+		case "nats", "stdout":
+		case "file":
+			if sink.Path == "" {
+				return nil, fmt.Errorf("sinks[%d]: file sink requires a path", i)
+			}
+			if sink.MaxSize < 0 || sink.RotateInterval < 0 {
+				return nil, fmt.Errorf("sinks[%d]: max_size and rotate_interval can't be negative", i)
+			}
+		case "webhook":
+			if sink.URL == "" {
+				return nil, fmt.Errorf("sinks[%d]: webhook sink requires a url", i)
+			}
+			if sink.Timeout == 0 {
+				sink.Timeout = 10 * time.Second
+			}
+		case "redis":
+			if sink.Address == "" {
+				sink.Address = "localhost:6379"
+			}
+			if sink.Key == "" {
+				sink.Key = "cdc:{database}.{table}"
+			}
+			if sink.Timeout <= 0 {
+				sink.Timeout = 5 * time.Second
+			}
+			if sink.MaxLen < 0 {
+				return nil, fmt.Errorf("sinks[%d]: max_len can't be negative", i)
+			}
+		case "kafka":
+			if len(sink.Brokers) == 0 {
+				return nil, fmt.Errorf("sinks[%d]: kafka sink requires brokers", i)
+			}
+			if sink.Topic == "" {
+				sink.Topic = "cdc.{database}.{table}"
+			}
+			if sink.Acks == "" {
+				sink.Acks = "all"
+			}
+			if sink.Acks != "all" && sink.Acks != "leader" && sink.Acks != "none" {
+				return nil, fmt.Errorf("invalid sinks[%d].acks %q: must be all, leader or none", i, sink.Acks)
+			}
+			if sink.Timeout <= 0 {
+				sink.Timeout = 10 * time.Second
+			}
+		case "s3":
+			if sink.Bucket == "" {
+				return nil, fmt.Errorf("sinks[%d]: s3 sink requires a bucket", i)
+			}
+			if sink.Region == "" {
+				sink.Region = "us-east-1"
+			}
+			if sink.ObjectFormat == "" {
+				sink.ObjectFormat = "jsonl"
+			}
+			if sink.ObjectFormat != "jsonl" && sink.ObjectFormat != "parquet" {
+				return nil, fmt.Errorf("invalid sinks[%d].object_format %q: must be jsonl or parquet", i, sink.ObjectFormat)
+			}
+			if sink.Compression == "" {
+				sink.Compression = "gzip"
+			}
+			if sink.FlushInterval <= 0 {
+				sink.FlushInterval = time.Minute
+			}
+			if sink.MaxEvents <= 0 {
+				sink.MaxEvents = 10000
+			}
+			if sink.Timeout <= 0 {
+				sink.Timeout = 30 * time.Second
+			}
+			sink.Prefix = strings.Trim(sink.Prefix, "/")
+		default:
+			return nil, fmt.Errorf("invalid sinks[%d].type %q: must be nats, stdout, file, webhook, redis, kafka or s3", i, sink.Type)
+		}
+		if sink.Name == "" {
+			sink.Name = sink.Type
+		}
+		if sinkNames[sink.Name] {
+			return nil, fmt.Errorf("duplicate sink name %q: give sinks of the same type a name", sink.Name)
+		}
+		sinkNames[sink.Name] = true
+		if sink.OnError == "" {
+			sink.OnError = "fail"
+		}
+		if sink.OnError != "fail" && sink.OnError != "skip" {
+			return nil, fmt.Errorf("invalid sinks[%d].on_error %q: must be fail or skip", i, sink.OnError)
+		}
+		switch sink.Type {
+		case "kafka", "webhook", "s3":
+			if sink.Compression == "" {
+				sink.Compression = "none"
+			}
+			if sink.Compression != "gzip" && sink.Compression != "none" {
+				return nil, fmt.Errorf("invalid sinks[%d].compression %q: must be gzip or none", i, sink.Compression)
+			}
+		default:
+			if sink.Compression != "" {
+				return nil, fmt.Errorf("sinks[%d]: compression is only supported by kafka, webhook and s3 sinks", i)
+			}
+		}
+	}
+	if config.NATS.Batch.Enabled {
+		if len(config.Sinks) > 1 || config.Sinks[0].Type != "nats" {
+			return nil, fmt.Errorf("nats.batch can only be used when nats is the only sink")
+		}
+		if config.NATS.Batch.MaxEvents <= 0 {
+			config.NATS.Batch.MaxEvents = 100
+		}
+		if config.NATS.Batch.Interval <= 0 {
+			config.NATS.Batch.Interval = 100 * time.Millisecond
+		}
+	}
 	if config.NATS.ReconnectWait == 0 {
 		config.NATS.ReconnectWait = 2 * time.Second
 	}
+	if config.MySQL.MinBinlogRetention == 0 {
+		config.MySQL.MinBinlogRetention = 24 * time.Hour
+	}
+	if config.MySQL.Reconnect.InitialDelay <= 0 {
+		config.MySQL.Reconnect.InitialDelay = time.Second
+	}
+	if config.MySQL.Reconnect.MaxDelay <= 0 {
+		config.MySQL.Reconnect.MaxDelay = 10 * time.Second
+	}
+	if config.MySQL.Reconnect.MaxDelay < config.MySQL.Reconnect.InitialDelay {
+		return nil, fmt.Errorf("mysql.reconnect.max_delay (%s) must not be less than initial_delay (%s)", config.MySQL.Reconnect.MaxDelay, config.MySQL.Reconnect.InitialDelay)
+	}
+	if config.MySQL.Reconnect.MaxRetries < 0 {
+		return nil, fmt.Errorf("mysql.reconnect.max_retries must not be negative")
+	}
+	if config.MySQL.Metadata.Host == "" {
+		config.MySQL.Metadata.Host = config.MySQL.Host
+	}
+	if config.MySQL.Metadata.Port == 0 {
+		config.MySQL.Metadata.Port = config.MySQL.Port
+	}
+	if config.MySQL.Metadata.User == "" {
+		config.MySQL.Metadata.User = config.MySQL.User
+		config.MySQL.Metadata.Password = config.MySQL.Password
+	}
+	if err := setPositionStoreDefaults(&config); err != nil {
+		return nil, err
+	}
+	// The position file is written after every event, as before coalescing was
+	// added; the other stores are remote, and a mysql store on the source server
+	// would otherwise write again for every event its own writes add to the binlog
+	if config.Binlog.PositionFlushInterval == 0 && config.Binlog.PositionStore.Type != "file" {
+		config.Binlog.PositionFlushInterval = 200 * time.Millisecond
+	}
+	if config.MySQL.Metadata.MaxOpenConns <= 0 {
+		config.MySQL.Metadata.MaxOpenConns = 1
+	}
+	if config.MySQL.Metadata.MaxIdleConns <= 0 {
+		config.MySQL.Metadata.MaxIdleConns = config.MySQL.Metadata.MaxOpenConns
+	}
+	if config.MySQL.LowerCaseTableNames == "" {
+		config.MySQL.LowerCaseTableNames = "auto"
+	}
+	switch config.MySQL.LowerCaseTableNames {
+	case "auto", "0", "1", "2":
+	default:
+		return nil, fmt.Errorf("invalid mysql.lower_case_table_names: %s", config.MySQL.LowerCaseTableNames)
+	}
 	if config.MySQL.Flavor == "" {
-		config.MySQL.Flavor = "mysql"
+		config.MySQL.Flavor = "auto"
+	}
+	switch config.MySQL.Flavor {
+	case "auto", "mysql", "mariadb", "percona":
+	default:
+		return nil, fmt.Errorf("invalid mysql.flavor: %s", config.MySQL.Flavor)
+	}
+	if config.NATS.ConsumerLag.Interval == 0 {
+		config.NATS.ConsumerLag.Interval = 30 * time.Second
+	}
+	if config.NATS.ConsumerLag.Subject == "" {
+		config.NATS.ConsumerLag.Subject = config.NATS.Subject + ".consumer_lag"
+	}
+	if config.NATS.SlowSink.Duration == 0 {
+		config.NATS.SlowSink.Duration = 30 * time.Second
+	}
+	if config.ErrorReporting.Threshold <= 0 {
+		config.ErrorReporting.Threshold = 5
+	}
+	if config.ErrorReporting.Window == 0 {
+		config.ErrorReporting.Window = time.Minute
+	}
+	if config.ErrorReporting.Timeout == 0 {
+		config.ErrorReporting.Timeout = 5 * time.Second
+	}
+	if config.Notifications.Format == "" {
+		config.Notifications.Format = "generic"
+	}
+	if config.Notifications.Format != "generic" && config.Notifications.Format != "slack" {
+		return nil, fmt.Errorf("invalid notifications.format: %s", config.Notifications.Format)
+	}
+	if len(config.Notifications.Events) == 0 {
+		config.Notifications.Events = []string{"started", "stopped", "failed", "sink_down", "sink_failover", "consumer_lag", "binlog_purged"}
+	}
+	if config.Notifications.Timeout == 0 {
+		config.Notifications.Timeout = 5 * time.Second
+	}
+	if config.Metrics.Interval == 0 {
+		config.Metrics.Interval = 10 * time.Second
+	}
+	if config.Metrics.StatsD.Address == "" {
+		config.Metrics.StatsD.Address = "127.0.0.1:8125"
+	}
+	if config.Metrics.StatsD.Format == "" {
+		config.Metrics.StatsD.Format = "statsd"
+	}
+	if config.Metrics.StatsD.Format != "statsd" && config.Metrics.StatsD.Format != "dogstatsd" {
+		return nil, fmt.Errorf("invalid metrics.statsd.format: %s", config.Metrics.StatsD.Format)
+	}
+	if config.Metrics.StatsD.Prefix == "" {
+		config.Metrics.StatsD.Prefix = "mysql_cdc"
+	}
+	if config.Metrics.StatsD.FlushInterval == 0 {
+		config.Metrics.StatsD.FlushInterval = time.Second
+	}
+	if config.Admin.Address == "" {
+		config.Admin.Address = "127.0.0.1:8080"
+	}
+	if config.Alerts.Subject == "" {
+		config.Alerts.Subject = "cdc.ops.alerts"
+	}
+	if config.NATS.SlowSink.Subject == "" {
+		config.NATS.SlowSink.Subject = config.NATS.Subject + ".alerts"
+		if config.Alerts.Enabled {
+			config.NATS.SlowSink.Subject = config.Alerts.Subject
+		}
+	}
+	if config.Limits.RowSizePolicy == "" {
+		config.Limits.RowSizePolicy = "truncate"
+	}
+	if config.Limits.ReferenceBucket == "" {
+		config.Limits.ReferenceBucket = "cdc_large_values"
+	}
+	switch config.Limits.Oversize.Strategy {
+	case "", "split", "drop_columns", "subject", "reference":
+	default:
+		return nil, fmt.Errorf("invalid limits.oversize.strategy: %s", config.Limits.Oversize.Strategy)
+	}
+	if config.Limits.Oversize.Subject == "" {
+		config.Limits.Oversize.Subject = config.NATS.Subject + ".oversize"
+	}
+	if config.Limits.Oversize.Bucket == "" {
+		config.Limits.Oversize.Bucket = config.Limits.ReferenceBucket
+	}
+	if config.Limits.DeadLetterSubject == "" {
+		config.Limits.DeadLetterSubject = config.NATS.Subject + ".dead_letter"
+	}
+	if config.Heartbeat.Interval == 0 {
+		config.Heartbeat.Interval = 10 * time.Second
+	}
+	if config.Heartbeat.Subject == "" {
+		config.Heartbeat.Subject = config.NATS.Subject + ".heartbeat"
+	}
+	if config.Watermark.Interval == 0 {
+		config.Watermark.Interval = 5 * time.Second
+	}
+	if config.Watermark.Subject == "" {
+		config.Watermark.Subject = config.NATS.Subject + ".watermark"
+	}
+	if config.Watermark.KVKey == "" {
+		config.Watermark.KVKey = "watermark"
+	}
+	if config.CaughtUp.Interval == 0 {
+		config.CaughtUp.Interval = 5 * time.Second
+	}
+	if config.CaughtUp.Subject == "" {
+		config.CaughtUp.Subject = config.NATS.Subject + ".caught_up"
+	}
+	if config.Binlog.Statements.Subject == "" {
+		config.Binlog.Statements.Subject = config.NATS.Subject + ".statements"
+	}
+	if config.Binlog.DDL.Subject == "" {
+		config.Binlog.DDL.Subject = config.NATS.Subject + ".ddl"
+	}
+	for name, pos := range map[string]string{"start": config.Binlog.Range.Start, "end": config.Binlog.Range.End} {
+		if pos != "" && !strings.Contains(pos, ":") {
+			return nil, fmt.Errorf("invalid binlog.range.%s %q: expected file:pos", name, pos)
+		}
+	}
+	if config.Binlog.Passthrough.Subject == "" {
+		config.Binlog.Passthrough.Subject = config.NATS.Subject
+	}
+
+	if config.Events.Announcements.Subject == "" {
+		config.Events.Announcements.Subject = config.NATS.Subject + ".schema"
+	}
+
+	for i, rule := range config.Limits.Throttle {
+		if rule.MaxPerSecond <= 0 {
+			return nil, fmt.Errorf("limits.throttle[%d]: max_per_second must be positive", i)
+		}
+	}
+
+	if config.Pipeline.Key == "" {
+		config.Pipeline.Key = "table"
+	}
+	if config.Pipeline.Key != "table" && config.Pipeline.Key != "primary_key" {
+		return nil, fmt.Errorf("invalid pipeline.key: %s", config.Pipeline.Key)
+	}
+
+	for _, t := range config.Binlog.EventTypes {
+		switch t {
+		case "rows", "ddl", "query", "gtid", "xid":
+		default:
+			return nil, fmt.Errorf("invalid binlog.event_types entry: %s", t)
+		}
+	}
+	// Transactions end with XID events, which checkpoints and held or coalesced
+	// events wait for, and DDL invalidates the cached columns rows are decoded with
+	if len(config.Binlog.EventTypes) > 0 {
+		for _, required := range []string{"xid", "ddl"} {
+			if !slices.Contains(config.Binlog.EventTypes, required) {
+				return nil, fmt.Errorf("binlog.event_types must include %s: positions and column metadata depend on it", required)
+			}
+		}
+	}
+
+	if config.Events.Format == "" {
+		config.Events.Format = "rows"
+	}
+	switch config.Events.Format {
+	case "rows", "columnar", "debezium":
+	default:
+		return nil, fmt.Errorf("invalid events.format: %s", config.Events.Format)
+	}
+	debezium := config.Events.Format == "debezium"
+	for i := range config.Sinks {
+		sink := &config.Sinks[i]
+		if sink.Format == "" {
+			sink.Format = config.Events.Format
+		}
+		switch sink.Format {
+		case "rows", "columnar":
+		case "debezium":
+			debezium = true
+		case "avro", "protobuf":
+			return nil, fmt.Errorf("sinks[%d].format %s isn't supported: events are encoded as JSON (rows, columnar or debezium); for a compact archive use an s3 sink with object_format: parquet", i, sink.Format)
+		default:
+			return nil, fmt.Errorf("invalid sinks[%d].format %q: must be rows, columnar or debezium", i, sink.Format)
+		}
+		if sink.Type == "nats" && sink.Format != config.Events.Format {
+			return nil, fmt.Errorf("sinks[%d]: the nats sink publishes in events.format, set that instead", i)
+		}
+	}
+	if config.Events.Format == "debezium" {
+		// Debezium events hold a single row. Sinks with format: debezium split the
+		// events they're given themselves, so other sinks keep multi-row events.
+		config.Limits.MaxRowsPerMessage = 1
+	}
+	if debezium {
+		if config.Events.Transactions.Envelope {
+			return nil, fmt.Errorf("events.transactions.envelope can't be used with the debezium format")
+		}
+		if config.Events.Debezium.ServerName == "" {
+			config.Events.Debezium.ServerName = config.NATS.Subject
+		}
+	}
+	if config.Events.Timestamp == "" {
+		config.Events.Timestamp = "seconds"
+	}
+	switch config.Events.Timestamp {
+	case "seconds", "milliseconds", "microseconds", "iso8601":
+	default:
+		return nil, fmt.Errorf("invalid events.timestamp: %s", config.Events.Timestamp)
+	}
+	if config.Events.ID == "" {
+		config.Events.ID = "ulid"
+	}
+	if config.Events.ID != "ulid" && config.Events.ID != "gtid" {
+		return nil, fmt.Errorf("invalid events.id: %s", config.Events.ID)
+	}
+	if config.Events.Types.Timezone == "" {
+		config.Events.Types.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(config.Events.Types.Timezone); err != nil {
+		return nil, fmt.Errorf("invalid events.types.timezone: %w", err)
+	}
+	if config.Events.Types.Geometry == "" {
+		config.Events.Types.Geometry = "wkt"
+	}
+	if config.Events.Types.Geometry != "wkt" && config.Events.Types.Geometry != "geojson" {
+		return nil, fmt.Errorf("invalid events.types.geometry: %s", config.Events.Types.Geometry)
+	}
+	if config.Events.Coalesce.MaxRows <= 0 {
+		config.Events.Coalesce.MaxRows = 1000
+	}
+	if config.Events.Transactions.MaxChanges <= 0 {
+		config.Events.Transactions.MaxChanges = 1000
+	}
+	if config.Events.Correlation.QueryContext != "" && !config.Binlog.QueryContext {
+		return nil, fmt.Errorf("events.correlation.query_context requires binlog.query_context")
+	}
+	if config.Events.Schema == "" {
+		config.Events.Schema = "none"
+	}
+	switch config.Events.Schema {
+	case "none", "always", "first":
+	default:
+		return nil, fmt.Errorf("invalid events.schema: %s", config.Events.Schema)
+	}
+
+	if config.Logging.Format == "" {
+		config.Logging.Format = "text"
+	}
+	if config.Logging.Format != "text" && config.Logging.Format != "json" {
+		return nil, fmt.Errorf("invalid logging.format: %s", config.Logging.Format)
+	}
+
+	if config.Delivery.AckTimeout == 0 {
+		config.Delivery.AckTimeout = 5 * time.Second
+	}
+	if config.Delivery.RetryInterval == 0 {
+		config.Delivery.RetryInterval = time.Second
+	}
+	if httpCfg := &config.Processor.HTTP; httpCfg.Enabled {
+		if len(httpCfg.AllowedHosts) == 0 {
+			return nil, fmt.Errorf("processor.http requires allowed_hosts")
+		}
+		if httpCfg.Timeout == 0 {
+			httpCfg.Timeout = 5 * time.Second
+		}
+		if httpCfg.MaxResponseSize == 0 {
+			httpCfg.MaxResponseSize = 1 << 20
+		}
+	}
+	auth := &config.NATS.Auth
+	methods := 0
+	for _, set := range []bool{auth.Token != "", auth.Username != "" || auth.Password != "", auth.NKeySeedFile != "", auth.CredentialsFile != ""} {
+		if set {
+			methods++
+		}
+	}
+	if methods > 1 {
+		return nil, fmt.Errorf("nats.auth: set only one of token, username/password, nkey_seed_file and credentials_file")
+	}
+	if auth.Password != "" && auth.Username == "" {
+		return nil, fmt.Errorf("nats.auth.password requires username")
+	}
+	if (config.NATS.TLS.CertFile == "") != (config.NATS.TLS.KeyFile == "") {
+		return nil, fmt.Errorf("nats.tls requires both cert_file and key_file for client certificates")
+	}
+	switch config.MySQL.TLS.Mode {
+	case "":
+		config.MySQL.TLS.Mode = "disabled"
+	case "disabled", "required", "verify-ca", "verify-identity":
+	default:
+		return nil, fmt.Errorf("invalid mysql.tls.mode: %s (supported: disabled, required, verify-ca, verify-identity)", config.MySQL.TLS.Mode)
+	}
+	if (config.MySQL.TLS.Cert == "") != (config.MySQL.TLS.Key == "") {
+		return nil, fmt.Errorf("mysql.tls requires both cert and key for client certificates")
+	}
+	if config.Processor.WatchInterval == 0 {
+		config.Processor.WatchInterval = 2 * time.Second
+	}
+	if config.Delivery.DrainTimeout == 0 {
+		config.Delivery.DrainTimeout = 30 * time.Second
+	}
+	if strings.Contains(config.Routing.Subject, "{tenant}") && config.Routing.TenantColumn == "" {
+		return nil, fmt.Errorf("routing.subject uses {tenant} but routing.tenant_column is not set")
+	}
+	for _, pattern := range config.Filters.Patterns {
+		glob := strings.TrimPrefix(pattern, "!")
+		if _, err := filepath.Match(glob, ""); err != nil || glob == "" {
+			return nil, fmt.Errorf("invalid filters.patterns entry %q", pattern)
+		}
+	}
+	if config.Routing.Keyless.Policy == "" {
+		config.Routing.Keyless.Policy = "full_row"
+	}
+	switch config.Routing.Keyless.Policy {
+	case "full_row", "table", "skip":
+	default:
+		return nil, fmt.Errorf("invalid routing.keyless.policy %q: must be full_row, table or skip", config.Routing.Keyless.Policy)
+	}
+	if config.Routing.DefaultTenant == "" {
+		config.Routing.DefaultTenant = "unknown"
+	}
+
+	if config.Delivery.ReplayGuard.File == "" {
+		config.Delivery.ReplayGuard.File = ".published_ids"
+	}
+	if config.Delivery.ReplayGuard.Size <= 0 {
+		config.Delivery.ReplayGuard.Size = 10000
+	}
+	if config.Delivery.ReplayGuard.FlushInterval <= 0 {
+		config.Delivery.ReplayGuard.FlushInterval = 200 * time.Millisecond
+	}
+	defaultMode := config.Delivery.Mode == ""
+	if defaultMode {
+		config.Delivery.Mode = "at_least_once"
+	}
+	switch config.Delivery.Mode {
+	case "at_most_once":
+	case "at_least_once", "exactly_once":
+		// The s3 sink acknowledges events once buffered, before they're written
+		for _, sink := range config.Sinks {
+			if sink.Type != "s3" {
+				continue
+			}
+			if defaultMode {
+				return nil, fmt.Errorf("the s3 sink %s buffers events before writing them, which requires delivery.mode: at_most_once (the default is at_least_once)", sink.Name)
+			}
+			return nil, fmt.Errorf("delivery.mode %s can't be combined with the s3 sink %s, which buffers events before writing them", config.Delivery.Mode, sink.Name)
+		}
+	default:
+		return nil, fmt.Errorf("invalid delivery.mode: %s", config.Delivery.Mode)
+	}
+
+	if config.Delivery.Mode != "at_most_once" {
+		config.NATS.JetStream.Enabled = true
+	}
+	if config.Delivery.Mode == "exactly_once" {
+		config.NATS.JetStream.Dedup = true
+	}
+	if config.NATS.JetStream.Create && config.NATS.JetStream.Stream == "" {
+		return nil, fmt.Errorf("nats.jetstream.create requires nats.jetstream.stream")
+	}
+	if config.NATS.JetStream.DuplicateWindow == 0 {
+		config.NATS.JetStream.DuplicateWindow = 2 * time.Minute
+	}
+
+	if config.Snapshot.ChunkSize <= 0 {
+		config.Snapshot.ChunkSize = 1000
+	}
+	if config.Snapshot.Lock == "" {
+		config.Snapshot.Lock = "global"
+	}
+	switch config.Snapshot.Lock {
+	case "global", "none":
+	default:
+		return nil, fmt.Errorf("invalid snapshot.lock %q: must be global or none", config.Snapshot.Lock)
+	}
+	for _, table := range config.Snapshot.Tables {
+		if database, name, ok := strings.Cut(table, "."); !ok || database == "" || name == "" {
+			return nil, fmt.Errorf("invalid snapshot.tables entry %q: must be database.table", table)
+		}
+	}
+	for table, where := range config.Snapshot.Where {
+		if database, name, ok := strings.Cut(table, "."); !ok || database == "" || name == "" {
+			return nil, fmt.Errorf("invalid snapshot.where table %q: must be database.table", table)
+		}
+		if strings.TrimSpace(where) == "" {
+			return nil, fmt.Errorf("snapshot.where condition for %s is empty", table)
+		}
+	}
+	if config.Snapshot.Enabled && config.Binlog.Passthrough.Enabled {
+		return nil, fmt.Errorf("snapshot can't be combined with binlog.passthrough")
+	}
+
+	if config.Errors.Publish.OnError == "" && config.Delivery.Mode != "at_most_once" {
+		config.Errors.Publish.OnError = "retry"
+	}
+	for stage, policy := range map[string]*ErrorPolicyConfig{
+		"decode":    &config.Errors.Decode,
+		"transform": &config.Errors.Transform,
+		"publish":   &config.Errors.Publish,
+	} {
+		if policy.OnError == "" {
+			policy.OnError = "skip"
+		}
+		switch policy.OnError {
+		case "fail", "skip", "dlq", "retry":
+		default:
+			return nil, fmt.Errorf("invalid errors.%s.on_error: %s", stage, policy.OnError)
+		}
+	}
+	if config.Errors.DeadLetterSubject == "" {
+		config.Errors.DeadLetterSubject = config.NATS.Subject + ".dead_letter"
+	}
+
+	switch config.Limits.RowSizePolicy {
+	case "truncate", "reference", "dead_letter":
+	default:
+		return nil, fmt.Errorf("invalid limits.row_size_policy: %s", config.Limits.RowSizePolicy)
 	}
 
 	return &config, nil
 }
 
+// setPositionStoreDefaults validates binlog.position_store and fills in its defaults
+func setPositionStoreDefaults(config *Config) error {
+	store := &config.Binlog.PositionStore
+	if store.Type == "" {
+		store.Type = "file"
+	}
+	if store.Key == "" {
+		store.Key = fmt.Sprintf("mysql-cdc-%d", config.MySQL.ServerID)
+	}
+	switch store.Type {
+	case "file":
+	case "nats_kv":
+		if store.NATSKV.Bucket == "" {
+			store.NATSKV.Bucket = "mysql_cdc_positions"
+		}
+	case "mysql":
+		database, table, ok := strings.Cut(store.MySQL.Table, ".")
+		if !ok || database == "" || table == "" {
+			return fmt.Errorf("binlog.position_store.mysql.table must be \"database.table\"")
+		}
+		if store.MySQL.Host == "" {
+			store.MySQL.Host = config.MySQL.Metadata.Host
+		}
+		if store.MySQL.Port == 0 {
+			store.MySQL.Port = config.MySQL.Metadata.Port
+		}
+		if store.MySQL.User == "" {
+			store.MySQL.User = config.MySQL.Metadata.User
+			store.MySQL.Password = config.MySQL.Metadata.Password
+		}
+		// Position writes must not be captured themselves
+		config.Filters.Patterns = append(config.Filters.Patterns, "!"+store.MySQL.Table)
+	case "redis":
+		if store.Redis.Address == "" {
+			store.Redis.Address = "localhost:6379"
+		}
+		if store.Redis.Timeout <= 0 {
+			store.Redis.Timeout = 5 * time.Second
+		}
+	default:
+		return fmt.Errorf("invalid binlog.position_store.type: %s", store.Type)
+	}
+	return nil
+}
+
+// subjectBase returns the tokens of a subject template before the first one with a placeholder
+func subjectBase(template string) string {
+	tokens := strings.Split(template, ".")
+	for i, token := range tokens {
+		if strings.Contains(token, "{") {
+			return strings.Join(tokens[:i], ".")
+		}
+	}
+	return template
+}
+
+// loadSources parses the configuration of each sources entry
+func loadSources(config *Config, data []byte) error {
+	names := make(map[string]bool, len(config.Sources))
+	positions := make(map[string]string, len(config.Sources))
+	for i := range config.Sources {
+		source := &config.Sources[i]
+		if source.Name == "" {
+			return fmt.Errorf("sources entry %d has no name", i+1)
+		}
+		if strings.IndexFunc(source.Name, func(r rune) bool {
+			return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_')
+		}) >= 0 {
+			return fmt.Errorf("invalid source name %q: only letters, digits, - and _ are allowed", source.Name)
+		}
+		if names[source.Name] {
+			return fmt.Errorf("duplicate source name: %s", source.Name)
+		}
+		names[source.Name] = true
+
+		sourceConfig, err := parseConfig(data, source)
+		if err != nil {
+			return err
+		}
+		// Sources share the publisher, whose subject is the top-level one, so their
+		// change events are always routed
+		if sourceConfig.Routing.Subject == "" {
+			sourceConfig.Routing.Subject = sourceConfig.NATS.Subject
+		}
+		// Sources sharing a position would overwrite each other's
+		store := &sourceConfig.Binlog.PositionStore
+		position := store.Type + ":" + store.Key
+		if store.Type == "file" {
+			position = "file:" + sourceConfig.Binlog.PositionFile
+		}
+		if other, ok := positions[position]; ok && position != "file:" {
+			return fmt.Errorf("sources %s and %s have the same position (%s), set position_file or position_key", other, source.Name, position)
+		}
+		positions[position] = source.Name
+		config.sources = append(config.sources, sourceConfig)
+	}
+	return nil
+}
+
+// applySource applies the settings of a sources entry to the configuration
+func applySource(config *Config, source *SourceConfig) error {
+	config.SourceName = source.Name
+	config.Sources = nil
+	if !source.MySQL.IsZero() {
+		if err := source.MySQL.Decode(&config.MySQL); err != nil {
+			return fmt.Errorf("invalid mysql settings: %w", err)
+		}
+	}
+
+	if source.Subject != "" {
+		config.NATS.Subject = source.Subject
+	} else {
+		config.NATS.Subject = sourceSubject(config.NATS.Subject, source.Name)
+	}
+	config.Routing.Subject = sourceSubject(config.Routing.Subject, source.Name)
+	config.Routing.Keyless.Subject = sourceSubject(config.Routing.Keyless.Subject, source.Name)
+
+	if source.PositionFile != "" {
+		config.Binlog.PositionFile = source.PositionFile
+	} else {
+		config.Binlog.PositionFile = sourceFile(config.Binlog.PositionFile, source.Name)
+	}
+	config.Binlog.Range.PositionFile = sourceFile(config.Binlog.Range.PositionFile, source.Name)
+	// Each source's processor keeps its own replay guard ring
+	if config.Delivery.ReplayGuard.File == "" {
+		config.Delivery.ReplayGuard.File = ".published_ids"
+	}
+	config.Delivery.ReplayGuard.File = sourceFile(config.Delivery.ReplayGuard.File, source.Name)
+	if source.PositionKey != "" {
+		config.Binlog.PositionStore.Key = source.PositionKey
+	} else if config.Binlog.PositionStore.Key != "" {
+		config.Binlog.PositionStore.Key += "-" + source.Name
+	}
+	return nil
+}
+
+// sourceSubject inserts a source name into a subject after its fixed leading tokens,
+// e.g. cdc.{database}.{table} becomes cdc.shard1.{database}.{table}
+func sourceSubject(template, name string) string {
+	if template == "" {
+		return ""
+	}
+	base := subjectBase(template)
+	if base == "" {
+		return name + "." + template
+	}
+	return base + "." + name + strings.TrimPrefix(template, base)
+}
+
+// sourceFile inserts a source name into a file name before its extension
+func sourceFile(path, name string) string {
+	if path == "" {
+		return ""
+	}
+	ext := filepath.Ext(path)
+	if ext == filepath.Base(path) {
+		// A dotfile like .binlog_position has no extension
+		ext = ""
+	}
+	return strings.TrimSuffix(path, ext) + "." + name + ext
+}
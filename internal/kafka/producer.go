@@ -0,0 +1,445 @@
+// Package kafka is a minimal Kafka producer. It speaks just enough of the Kafka
+// protocol for the Kafka sink: metadata lookups, produce requests and SASL/PLAIN
+// authentication.
+package kafka
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// API keys and versions of the requests sent
+const (
+	apiProduce          = 0
+	apiMetadata         = 3
+	apiSaslHandshake    = 17
+	apiSaslAuthenticate = 36
+
+	produceVersion  = 3 // First version with record batches
+	metadataVersion = 1
+)
+
+// maxAttempts bounds the attempts to produce a record when partition leadership
+// moves or the connection breaks
+const maxAttempts = 3
+
+// Options are the settings of a producer
+type Options struct {
+	Brokers     []string    // Bootstrap brokers, host:port
+	ClientID    string      // Sent with every request
+	Username    string      // SASL/PLAIN user (empty = no authentication)
+	Password    string      // SASL/PLAIN password
+	TLS         *tls.Config // nil = plaintext
+	Acks        int16       // -1 = all in-sync replicas, 1 = the leader, 0 = none
+	Compression string      // Record batch compression: "" or "none", or "gzip"
+	Timeout     time.Duration
+}
+
+// Header is a record header
+type Header struct {
+	Key   string
+	Value []byte
+}
+
+// Error is an error code returned by a broker
+type Error int16
+
+var errorNames = map[Error]string{
+	1:  "OFFSET_OUT_OF_RANGE",
+	2:  "CORRUPT_MESSAGE",
+	3:  "UNKNOWN_TOPIC_OR_PARTITION",
+	5:  "LEADER_NOT_AVAILABLE",
+	6:  "NOT_LEADER_OR_FOLLOWER",
+	7:  "REQUEST_TIMED_OUT",
+	10: "MESSAGE_TOO_LARGE",
+	17: "INVALID_TOPIC_EXCEPTION",
+	19: "NOT_ENOUGH_REPLICAS",
+	20: "NOT_ENOUGH_REPLICAS_AFTER_APPEND",
+	29: "TOPIC_AUTHORIZATION_FAILED",
+	33: "UNSUPPORTED_SASL_MECHANISM",
+	34: "ILLEGAL_SASL_STATE",
+	58: "SASL_AUTHENTICATION_FAILED",
+}
+
+func (e Error) Error() string {
+	if name, ok := errorNames[e]; ok {
+		return fmt.Sprintf("kafka error %d (%s)", int16(e), name)
+	}
+	return fmt.Sprintf("kafka error %d", int16(e))
+}
+
+// retriable reports whether the request may succeed once metadata is refreshed
+func (e Error) retriable() bool {
+	switch e {
+	case 3, 5, 6, 7, 19, 20:
+		return true
+	}
+	return false
+}
+
+// Producer sends records to the leaders of their partitions, one produce request
+// per record. Partition leaders are looked up on first use and again when a
+// broker reports that leadership moved.
+type Producer struct {
+	opts Options
+
+	mu          sync.Mutex
+	brokers     map[int32]string   // Address by node ID
+	leaders     map[string][]int32 // Node ID of each partition's leader, by topic
+	conns       map[string]*conn   // By address
+	next        uint32             // Partition of the next record without a key
+	correlation int32
+}
+
+// Dial creates a producer, connecting to a bootstrap broker to check that the
+// cluster is reachable
+func Dial(opts Options) (*Producer, error) {
+	if len(opts.Brokers) == 0 {
+		return nil, fmt.Errorf("no brokers")
+	}
+	p := &Producer{
+		opts:    opts,
+		brokers: make(map[int32]string),
+		leaders: make(map[string][]int32),
+		conns:   make(map[string]*conn),
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, err := p.bootstrap(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Produce writes a record to the topic and waits for the acknowledgement required
+// by Options.Acks. Records with a key go to the partition the key hashes to, as
+// with the Java client's default partitioner; others are spread round-robin.
+func (p *Producer) Produce(topic string, key, value []byte, headers []Header) error {
+	batch, err := recordBatch(key, value, headers, time.Now(), p.opts.Compression)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for attempt := 1; ; attempt++ {
+		err = p.produce(topic, key, batch)
+		if err == nil {
+			return nil
+		}
+		var kafkaErr Error
+		if errors.As(err, &kafkaErr) && !kafkaErr.retriable() || attempt == maxAttempts {
+			return err
+		}
+		// Leadership may have moved: look up the partitions again
+		delete(p.leaders, topic)
+	}
+}
+
+// produce sends a record batch to the leader of the record's partition
+func (p *Producer) produce(topic string, key, batch []byte) error {
+	leaders, err := p.partitions(topic)
+	if err != nil {
+		return err
+	}
+	var partition int32
+	if key != nil {
+		partition = int32(positive(murmur2(key)) % uint32(len(leaders)))
+	} else {
+		partition = int32(p.next % uint32(len(leaders)))
+		p.next++
+	}
+	address, ok := p.brokers[leaders[partition]]
+	if !ok {
+		return Error(5)
+	}
+	c, err := p.conn(address)
+	if err != nil {
+		return err
+	}
+
+	var e encoder
+	e.int16(-1) // No transactional ID
+	e.int16(p.opts.Acks)
+	e.int32(int32(p.opts.Timeout / time.Millisecond))
+	e.int32(1)
+	e.string(topic)
+	e.int32(1)
+	e.int32(partition)
+	e.bytes(batch)
+	resp, err := p.roundTrip(c, apiProduce, produceVersion, e.buf, p.opts.Acks != 0)
+	if err != nil || p.opts.Acks == 0 {
+		return err
+	}
+
+	d := decoder{buf: resp}
+	for topics := d.int32(); topics > 0; topics-- {
+		d.string()
+		for partitions := d.int32(); partitions > 0; partitions-- {
+			d.int32()
+			if code := Error(d.int16()); code != 0 && d.err == nil {
+				return fmt.Errorf("failed to produce to %s/%d: %w", topic, partition, code)
+			}
+			d.int64() // Base offset
+			d.int64() // Log append time
+		}
+	}
+	return d.err
+}
+
+// partitions returns the leader of each partition of a topic, fetching metadata
+// if they aren't known
+func (p *Producer) partitions(topic string) ([]int32, error) {
+	if leaders, ok := p.leaders[topic]; ok {
+		return leaders, nil
+	}
+	c, err := p.bootstrap()
+	if err != nil {
+		return nil, err
+	}
+
+	var e encoder
+	e.int32(1)
+	e.string(topic)
+	resp, err := p.roundTrip(c, apiMetadata, metadataVersion, e.buf, true)
+	if err != nil {
+		return nil, err
+	}
+
+	d := decoder{buf: resp}
+	for brokers := d.int32(); brokers > 0; brokers-- {
+		id := d.int32()
+		host := d.string()
+		port := d.int32()
+		d.string() // Rack
+		p.brokers[id] = net.JoinHostPort(host, fmt.Sprint(port))
+	}
+	d.int32() // Controller ID
+	var leaders []int32
+	var topicErr Error
+	for topics := d.int32(); topics > 0; topics-- {
+		topicErr = Error(d.int16())
+		d.string()
+		d.int8() // Internal
+		for partitions := d.int32(); partitions > 0; partitions-- {
+			d.int16()
+			index := d.int32()
+			leader := d.int32()
+			for replicas := d.int32(); replicas > 0; replicas-- {
+				d.int32()
+			}
+			for isr := d.int32(); isr > 0; isr-- {
+				d.int32()
+			}
+			if index >= 0 && index < 1<<16 {
+				for int(index) >= len(leaders) {
+					leaders = append(leaders, -1)
+				}
+				leaders[index] = leader
+			}
+		}
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	if topicErr != 0 {
+		return nil, fmt.Errorf("failed to look up topic %s: %w", topic, topicErr)
+	}
+	if len(leaders) == 0 {
+		return nil, fmt.Errorf("failed to look up topic %s: %w", topic, Error(5))
+	}
+	p.leaders[topic] = leaders
+	return leaders, nil
+}
+
+// bootstrap returns a connection to any reachable broker, trying the bootstrap
+// brokers in turn
+func (p *Producer) bootstrap() (*conn, error) {
+	var errs []error
+	for _, address := range p.opts.Brokers {
+		c, err := p.conn(address)
+		if err == nil {
+			return c, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, fmt.Errorf("no broker reachable: %w", errors.Join(errs...))
+}
+
+// conn returns the connection to a broker, connecting and authenticating if needed
+func (p *Producer) conn(address string) (*conn, error) {
+	if c, ok := p.conns[address]; ok {
+		return c, nil
+	}
+	dialer := &net.Dialer{Timeout: p.opts.Timeout}
+	var netConn net.Conn
+	var err error
+	if p.opts.TLS != nil {
+		netConn, err = tls.DialWithDialer(dialer, "tcp", address, p.opts.TLS)
+	} else {
+		netConn, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", address, err)
+	}
+	c := &conn{Conn: netConn, rd: bufio.NewReader(netConn), address: address}
+	p.conns[address] = c
+	if p.opts.Username != "" {
+		if err := p.authenticate(c); err != nil {
+			p.drop(c)
+			return nil, fmt.Errorf("failed to authenticate to %s: %w", address, err)
+		}
+	}
+	return c, nil
+}
+
+// authenticate authenticates a connection with SASL/PLAIN
+func (p *Producer) authenticate(c *conn) error {
+	var e encoder
+	e.string("PLAIN")
+	resp, err := p.roundTrip(c, apiSaslHandshake, 1, e.buf, true)
+	if err != nil {
+		return err
+	}
+	d := decoder{buf: resp}
+	if code := Error(d.int16()); code != 0 {
+		return code
+	}
+
+	e = encoder{}
+	e.bytes([]byte("\x00" + p.opts.Username + "\x00" + p.opts.Password))
+	resp, err = p.roundTrip(c, apiSaslAuthenticate, 0, e.buf, true)
+	if err != nil {
+		return err
+	}
+	d = decoder{buf: resp}
+	code := Error(d.int16())
+	message := d.string()
+	if d.err != nil {
+		return d.err
+	}
+	if code != 0 {
+		return fmt.Errorf("%w: %s", code, message)
+	}
+	return nil
+}
+
+// roundTrip sends a request and returns the body of its response, if one is
+// expected. The connection is dropped if it fails, so it's redialed next time.
+func (p *Producer) roundTrip(c *conn, apiKey, version int16, body []byte, response bool) ([]byte, error) {
+	p.correlation++
+	resp, err := c.roundTrip(apiKey, version, p.correlation, p.opts.ClientID, body, response, p.opts.Timeout)
+	if err != nil {
+		p.drop(c)
+		return nil, fmt.Errorf("request to %s failed: %w", c.address, err)
+	}
+	return resp, nil
+}
+
+// drop closes and forgets a connection
+func (p *Producer) drop(c *conn) {
+	c.Close()
+	delete(p.conns, c.address)
+}
+
+// Close closes the connections to the brokers
+func (p *Producer) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var errs []error
+	for address, c := range p.conns {
+		errs = append(errs, c.Close())
+		delete(p.conns, address)
+	}
+	return errors.Join(errs...)
+}
+
+// conn is a connection to a broker
+type conn struct {
+	net.Conn
+	rd      *bufio.Reader
+	address string
+}
+
+// roundTrip sends a request with a v1 request header and reads the response's
+// body, after its v0 header
+func (c *conn) roundTrip(apiKey, version int16, correlation int32, clientID string, body []byte, response bool, timeout time.Duration) ([]byte, error) {
+	var e encoder
+	e.int32(0) // Size, set below
+	e.int16(apiKey)
+	e.int16(version)
+	e.int32(correlation)
+	e.string(clientID)
+	e.buf = append(e.buf, body...)
+	binary.BigEndian.PutUint32(e.buf, uint32(len(e.buf)-4))
+
+	if timeout > 0 {
+		c.SetDeadline(time.Now().Add(timeout))
+	}
+	if _, err := c.Write(e.buf); err != nil || !response {
+		return nil, err
+	}
+	var header [8]byte
+	if _, err := io.ReadFull(c.rd, header[:]); err != nil {
+		return nil, err
+	}
+	size := int32(binary.BigEndian.Uint32(header[:4]))
+	if size < 4 || size > 64<<20 {
+		return nil, fmt.Errorf("invalid response size %d", size)
+	}
+	if got := int32(binary.BigEndian.Uint32(header[4:])); got != correlation {
+		return nil, fmt.Errorf("response to request %d received for request %d", got, correlation)
+	}
+	resp := make([]byte, size-4)
+	if _, err := io.ReadFull(c.rd, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// murmur2 is the hash the Java client's default partitioner applies to keys
+func murmur2(data []byte) uint32 {
+	const (
+		seed uint32 = 0x9747b28c
+		m    uint32 = 0x5bd1e995
+		r           = 24
+	)
+	length := len(data)
+	h := seed ^ uint32(length)
+	for i := 0; i+4 <= length; i += 4 {
+		k := binary.LittleEndian.Uint32(data[i:])
+		k *= m
+		k ^= k >> r
+		k *= m
+		h *= m
+		h ^= k
+	}
+	tail := data[length&^3:]
+	switch len(tail) {
+	case 3:
+		h ^= uint32(tail[2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint32(tail[1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint32(tail[0])
+		h *= m
+	}
+	h ^= h >> 13
+	h *= m
+	h ^= h >> 15
+	return h
+}
+
+// positive clears the sign bit, as the Java client's Utils.toPositive
+func positive(h uint32) uint32 {
+	return h & 0x7fffffff
+}
@@ -0,0 +1,144 @@
+package metrics
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"mysql-cdc/internal/config"
+)
+
+// maxPacketSize keeps datagrams below the typical network MTU
+const maxPacketSize = 1432
+
+// StatsD pushes metrics to a StatsD or DogStatsD agent over UDP. Metrics are
+// buffered and sent when a datagram is full or every flush interval.
+type StatsD struct {
+	conn   net.Conn
+	prefix string
+	tags   string // Global tags in DogStatsD format, without the leading "|#"
+	dog    bool   // Send tags (DogStatsD); plain StatsD drops them
+	logger *logrus.Logger
+
+	mu  sync.Mutex
+	buf []byte
+
+	stopFlush chan struct{}
+	flushDone chan struct{}
+}
+
+// NewStatsD creates a new StatsD client. Returns nil if the exporter is disabled.
+func NewStatsD(cfg *config.StatsDConfig, logger *logrus.Logger) (*StatsD, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	conn, err := net.Dial("udp", cfg.Address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to StatsD at %s: %w", cfg.Address, err)
+	}
+
+	prefix := cfg.Prefix
+	if prefix != "" && !strings.HasSuffix(prefix, ".") {
+		prefix += "."
+	}
+	s := &StatsD{
+		conn:      conn,
+		prefix:    prefix,
+		tags:      strings.Join(cfg.Tags, ","),
+		dog:       cfg.Format == "dogstatsd",
+		logger:    logger,
+		stopFlush: make(chan struct{}),
+		flushDone: make(chan struct{}),
+	}
+	go s.flushLoop(cfg.FlushInterval)
+
+	logger.Infof("Pushing %s metrics to %s", cfg.Format, cfg.Address)
+	return s, nil
+}
+
+// Count adds to a counter
+func (s *StatsD) Count(name string, value int64, tags ...string) {
+	s.add(name, strconv.FormatInt(value, 10), "c", tags)
+}
+
+// Gauge sets a gauge
+func (s *StatsD) Gauge(name string, value float64, tags ...string) {
+	s.add(name, strconv.FormatFloat(value, 'f', -1, 64), "g", tags)
+}
+
+// Timing records a duration in milliseconds
+func (s *StatsD) Timing(name string, d time.Duration, tags ...string) {
+	s.add(name, strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', -1, 64), "ms", tags)
+}
+
+// add buffers a metric line like "<prefix><name>:<value>|<type>|#<tags>"
+func (s *StatsD) add(name, value, kind string, tags []string) {
+	line := s.prefix + name + ":" + value + "|" + kind
+	if s.dog && (s.tags != "" || len(tags) > 0) {
+		all := s.tags
+		if len(tags) > 0 {
+			if all != "" {
+				all += ","
+			}
+			all += strings.Join(tags, ",")
+		}
+		line += "|#" + all
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.buf) > 0 && len(s.buf)+1+len(line) > maxPacketSize {
+		s.send()
+	}
+	if len(s.buf) > 0 {
+		s.buf = append(s.buf, '\n')
+	}
+	s.buf = append(s.buf, line...)
+}
+
+// send writes the buffered lines as one datagram. Caller holds mu
+func (s *StatsD) send() {
+	if len(s.buf) == 0 {
+		return
+	}
+	if _, err := s.conn.Write(s.buf); err != nil {
+		// The agent being away shouldn't flood the logs
+		s.logger.Debugf("Failed to send metrics: %v", err)
+	}
+	s.buf = s.buf[:0]
+}
+
+// flushLoop periodically sends buffered metrics until Close is called
+func (s *StatsD) flushLoop(interval time.Duration) {
+	defer close(s.flushDone)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopFlush:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			s.send()
+			s.mu.Unlock()
+		}
+	}
+}
+
+// Close sends any buffered metrics and closes the connection
+func (s *StatsD) Close() {
+	close(s.stopFlush)
+	<-s.flushDone
+	s.mu.Lock()
+	s.send()
+	s.mu.Unlock()
+	s.conn.Close()
+}
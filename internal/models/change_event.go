@@ -1,13 +1,356 @@
 package models
 
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
 // ChangeEvent represents a database change event
 type ChangeEvent struct {
-	Type      string                 `json:"type"`      // INSERT, UPDATE, DELETE
-	Database  string                 `json:"database"`
-	Table     string                 `json:"table"`
-	Timestamp int64                  `json:"timestamp"`
-	Rows      []map[string]interface{} `json:"rows"`
-	OldRows   []map[string]interface{} `json:"old_rows,omitempty"` // For UPDATE events
-	RawJSON   []byte                 `json:"-"`         // Raw JSON from JavaScript transformation (if available)
+	ID           string                   `json:"id,omitempty"` // Unique event ID (ULID, or GTID-derived with events.id: gtid)
+	Type         string                   `json:"type"`         // INSERT, UPDATE, DELETE, SNAPSHOT for rows read by a backfill or the initial snapshot, or TRANSACTION
+	Database     string                   `json:"database"`
+	Table        string                   `json:"table"`
+	Timestamp    Timestamp                `json:"timestamp"`              // Encoded in the unit set by events.timestamp
+	CommitTS     int64                    `json:"commit_ts,omitempty"`    // Unix milliseconds the transaction committed at, from the binlog
+	ProcessedTS  int64                    `json:"processed_ts,omitempty"` // Unix milliseconds the event was read at
+	Rows         []map[string]interface{} `json:"rows"`
+	OldRows      []map[string]interface{} `json:"old_rows,omitempty"`      // For UPDATE events
+	QueryContext map[string]string        `json:"query_context,omitempty"` // Annotations parsed from statement comments
+	RawJSON      []byte                   `json:"-"`                       // Raw JSON from JavaScript transformation (if available)
+	PrimaryKey   []string                 `json:"primary_key,omitempty"`   // Primary key column names of the table (if known)
+	RowKey       []string                 `json:"row_key,omitempty"`       // Columns identifying rows of tables without a primary key
+	Schema       []ColumnSchema           `json:"schema,omitempty"`        // Column metadata, if events.schema is enabled
+
+	// Source position metadata
+	GTID          string `json:"gtid,omitempty"`           // GTID of the transaction, if GTIDs are enabled
+	BinlogFile    string `json:"binlog_file,omitempty"`    // Binlog file of the row event
+	BinlogPos     uint32 `json:"binlog_pos,omitempty"`     // End position of the row event in the binlog file
+	TransactionID string `json:"transaction_id,omitempty"` // GTID, or "file:pos" of the transaction's BEGIN without GTIDs
+	SchemaVersion string `json:"schema_version,omitempty"` // Hash of the table's column names and types; changes after DDL
+	CorrelationID string `json:"correlation_id,omitempty"` // Caller-defined ID for tracing the change across services
+	Part          int    `json:"part,omitempty"`           // 1-based part number of an event split by limits.max_rows_per_message
+	Parts         int    `json:"parts,omitempty"`          // Total number of parts the event was split into
+
+	// Set with events.transactions
+	Transaction *TransactionInfo  `json:"transaction,omitempty"` // Place of the event in its source transaction
+	Changes     []json.RawMessage `json:"changes,omitempty"`     // Change events of a TRANSACTION event, encoded like published events
+	Envelope    []*ChangeEvent    `json:"-"`                     // Change events of a TRANSACTION event before delivery
+
+	// Set on events parsed from a statement-format QueryEvent (binlog.statement_fallback):
+	// rows hold only the values written in the statement
+	BestEffort bool   `json:"best_effort,omitempty"`
+	Statement  string `json:"statement,omitempty"`
+
+	Subject string `json:"-"` // Subject to publish to instead of nats.subject (set by routing)
+	Keyless bool   `json:"-"` // Table has neither a primary key nor a NOT NULL unique key
+	DedupID string `json:"-"` // Deterministic ID derived from the binlog position, used to drop replayed duplicates
+	OnDone  func() `json:"-"` // Called once the event has been published or deliberately dropped
+
+	SinksDone []string `json:"-"` // Sinks that accepted the event, skipped when a failed publish is retried
+
+	// Set with events.metadata
+	Metadata *EventMetadata `json:"metadata,omitempty"` // Filled in from the event's fields when it's encoded
+	Origin   *EventOrigin   `json:"-"`                  // Server the event was read from
+}
+
+// EventFormatVersion is the version of the change event format, in the metadata
+// block. It's increased when fields change meaning or are removed.
+const EventFormatVersion = 1
+
+// EventMetadata is the provenance of a change event, for consumers that
+// deduplicate, trace or handle several event format versions
+type EventMetadata struct {
+	Version       int    `json:"version"` // EventFormatVersion
+	EventID       string `json:"event_id,omitempty"`
+	SchemaVersion string `json:"schema_version,omitempty"`
+	SourceHost    string `json:"source_host"`
+	SourcePort    int    `json:"source_port"`
+	ServerID      uint32 `json:"server_id,omitempty"` // server_id of the server that wrote the binlog event
+	Source        string `json:"source,omitempty"`    // sources[].name
+	BinlogFile    string `json:"binlog_file,omitempty"`
+	BinlogPos     uint32 `json:"binlog_pos,omitempty"`
+	GTID          string `json:"gtid,omitempty"`
+}
+
+// EventOrigin is the server a change event was read from
+type EventOrigin struct {
+	Host     string
+	Port     int
+	ServerID uint32 // 0 for rows read with SELECT
+	Source   string
+}
+
+// metadata returns the metadata block of the event
+func (e *ChangeEvent) metadata() *EventMetadata {
+	return &EventMetadata{
+		Version:       EventFormatVersion,
+		EventID:       e.ID,
+		SchemaVersion: e.SchemaVersion,
+		SourceHost:    e.Origin.Host,
+		SourcePort:    e.Origin.Port,
+		ServerID:      e.Origin.ServerID,
+		Source:        e.Origin.Source,
+		BinlogFile:    e.BinlogFile,
+		BinlogPos:     e.BinlogPos,
+		GTID:          e.GTID,
+	}
+}
+
+// LogFields returns the event's table, type, binlog position, ID and correlation
+// ID as structured log fields
+func (e *ChangeEvent) LogFields() map[string]interface{} {
+	fields := map[string]interface{}{
+		"database":   e.Database,
+		"table":      e.Table,
+		"event_type": e.Type,
+	}
+	if e.BinlogFile != "" {
+		fields["position"] = fmt.Sprintf("%s:%d", e.BinlogFile, e.BinlogPos)
+	}
+	if e.ID != "" {
+		fields["event_id"] = e.ID
+	}
+	if e.CorrelationID != "" {
+		fields["correlation_id"] = e.CorrelationID
+	}
+	return fields
+}
+
+// TransactionInfo places a change event in its source transaction
+type TransactionInfo struct {
+	ID       string `json:"id"`             // Same as the event's transaction_id
+	XID      uint64 `json:"xid,omitempty"`  // XID of the commit, set on the last event of transactions ending with one
+	Sequence int    `json:"sequence"`       // 1-based number of the event (or TRANSACTION part) within the transaction
+	Last     bool   `json:"last,omitempty"` // Last event (or TRANSACTION part) of the transaction
+}
+
+// ColumnSchema describes a table column
+type ColumnSchema struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"` // Full column type, e.g. varchar(255) or int unsigned
+	Nullable   bool   `json:"nullable"`
+	PrimaryKey bool   `json:"primary_key,omitempty"`
+	Invisible  bool   `json:"invisible,omitempty"` // Invisible column, e.g. a generated invisible primary key (MySQL 8.0.30+)
+	Comment    string `json:"comment,omitempty"`
+}
+
+// ColumnarChangeEvent is the compact encoding of a ChangeEvent: column names are
+// listed once and each row is an array of values in the same order
+type ColumnarChangeEvent struct {
+	ID            string            `json:"id,omitempty"`
+	Type          string            `json:"type"`
+	Database      string            `json:"database"`
+	Table         string            `json:"table"`
+	Timestamp     Timestamp         `json:"timestamp"`
+	CommitTS      int64             `json:"commit_ts,omitempty"`
+	ProcessedTS   int64             `json:"processed_ts,omitempty"`
+	Columns       []string          `json:"columns"`
+	Rows          [][]interface{}   `json:"rows"`
+	OldRows       [][]interface{}   `json:"old_rows,omitempty"`
+	QueryContext  map[string]string `json:"query_context,omitempty"`
+	PrimaryKey    []string          `json:"primary_key,omitempty"`
+	RowKey        []string          `json:"row_key,omitempty"`
+	Schema        []ColumnSchema    `json:"schema,omitempty"`
+	GTID          string            `json:"gtid,omitempty"`
+	BinlogFile    string            `json:"binlog_file,omitempty"`
+	BinlogPos     uint32            `json:"binlog_pos,omitempty"`
+	TransactionID string            `json:"transaction_id,omitempty"`
+	SchemaVersion string            `json:"schema_version,omitempty"`
+	CorrelationID string            `json:"correlation_id,omitempty"`
+	Part          int               `json:"part,omitempty"`
+	Parts         int               `json:"parts,omitempty"`
+	Transaction   *TransactionInfo  `json:"transaction,omitempty"`
+	Changes       []json.RawMessage `json:"changes,omitempty"`
+	BestEffort    bool              `json:"best_effort,omitempty"`
+	Statement     string            `json:"statement,omitempty"`
+	Metadata      *EventMetadata    `json:"metadata,omitempty"`
+}
+
+// Columnar converts the event to the compact columnar encoding. Columns are the
+// sorted union of the keys of all rows; values missing from a row are null.
+func (e *ChangeEvent) Columnar() *ColumnarChangeEvent {
+	seen := make(map[string]bool)
+	var columns []string
+	for _, rows := range [][]map[string]interface{}{e.Rows, e.OldRows} {
+		for _, row := range rows {
+			for col := range row {
+				if !seen[col] {
+					seen[col] = true
+					columns = append(columns, col)
+				}
+			}
+		}
+	}
+	sort.Strings(columns)
+
+	toValues := func(rows []map[string]interface{}) [][]interface{} {
+		out := make([][]interface{}, len(rows))
+		for i, row := range rows {
+			values := make([]interface{}, len(columns))
+			for j, col := range columns {
+				values[j] = row[col]
+			}
+			out[i] = values
+		}
+		return out
+	}
+
+	c := &ColumnarChangeEvent{
+		ID:            e.ID,
+		Type:          e.Type,
+		Database:      e.Database,
+		Table:         e.Table,
+		Timestamp:     e.Timestamp,
+		CommitTS:      e.CommitTS,
+		ProcessedTS:   e.ProcessedTS,
+		Columns:       columns,
+		Rows:          toValues(e.Rows),
+		QueryContext:  e.QueryContext,
+		PrimaryKey:    e.PrimaryKey,
+		RowKey:        e.RowKey,
+		Schema:        e.Schema,
+		GTID:          e.GTID,
+		BinlogFile:    e.BinlogFile,
+		BinlogPos:     e.BinlogPos,
+		TransactionID: e.TransactionID,
+		SchemaVersion: e.SchemaVersion,
+		CorrelationID: e.CorrelationID,
+		Part:          e.Part,
+		Parts:         e.Parts,
+		Transaction:   e.Transaction,
+		Changes:       e.Changes,
+		BestEffort:    e.BestEffort,
+		Statement:     e.Statement,
+		Metadata:      e.Metadata,
+	}
+	if len(e.OldRows) > 0 {
+		c.OldRows = toValues(e.OldRows)
+	}
+	return c
+}
+
+// TableSchemaEvent announces a table's full schema, published when the table is
+// first seen and again after DDL that may have changed it
+type TableSchemaEvent struct {
+	Type       string         `json:"type"` // Always SCHEMA
+	Timestamp  int64          `json:"timestamp"`
+	Database   string         `json:"database"`
+	Table      string         `json:"table"`
+	Reason     string         `json:"reason"` // first_seen or ddl
+	Columns    []ColumnSchema `json:"columns"`
+	PrimaryKey []string       `json:"primary_key,omitempty"`
+	BinlogFile string         `json:"binlog_file"`
+	BinlogPos  uint32         `json:"binlog_pos"`
+}
+
+// StatementEvent represents a SQL statement captured from a binlog QueryEvent
+type StatementEvent struct {
+	Type          string `json:"type"` // Always STATEMENT
+	Database      string `json:"database"`
+	Query         string `json:"query"`
+	Timestamp     int64  `json:"timestamp"`
+	ExecutionTime uint32 `json:"execution_time"` // Seconds the statement took on the source
+	ErrorCode     uint16 `json:"error_code,omitempty"`
+}
+
+// DDLEvent represents a schema-changing statement read from the binlog
+type DDLEvent struct {
+	Type       string     `json:"type"` // Always DDL
+	Timestamp  int64      `json:"timestamp"`
+	Database   string     `json:"database"` // Default database the statement ran in
+	Query      string     `json:"query"`
+	Tables     []DDLTable `json:"tables,omitempty"` // Captured tables the statement changes; empty when it may change any table
+	GTID       string     `json:"gtid,omitempty"`
+	BinlogFile string     `json:"binlog_file"`
+	BinlogPos  uint32     `json:"binlog_pos"`
+}
+
+// DDLTable names a table changed by a DDL statement
+type DDLTable struct {
+	Database string `json:"database"`
+	Table    string `json:"table"`
+}
+
+// HeartbeatEvent is published periodically so consumers can tell an idle database from a stopped CDC
+type HeartbeatEvent struct {
+	Type               string `json:"type"` // Always HEARTBEAT
+	Timestamp          int64  `json:"timestamp"`
+	BinlogFile         string `json:"binlog_file"`
+	BinlogPos          uint32 `json:"binlog_pos"`
+	LastEventTimestamp int64  `json:"last_event_timestamp,omitempty"` // Binlog timestamp of the last event read
+	CaughtUp           *bool  `json:"caught_up,omitempty"`            // Whether the stream has caught up with the master (if caught_up is enabled)
+}
+
+// CaughtUpEvent is published once when the stream first catches up with the master's live position
+type CaughtUpEvent struct {
+	Type       string `json:"type"` // Always CAUGHT_UP
+	Timestamp  int64  `json:"timestamp"`
+	BinlogFile string `json:"binlog_file"` // Position read when the stream caught up
+	BinlogPos  uint32 `json:"binlog_pos"`
+	MasterFile string `json:"master_file"` // Master position it was compared against
+	MasterPos  uint32 `json:"master_pos"`
+	StartedAt  int64  `json:"started_at"` // When processing started, to measure the catch-up time
+}
+
+// WatermarkEvent announces the last committed binlog position so consumers can
+// check whether the CDC stream has caught up with a given write
+type WatermarkEvent struct {
+	Type       string `json:"type"` // Always WATERMARK
+	Timestamp  int64  `json:"timestamp"`
+	BinlogFile string `json:"binlog_file"`
+	BinlogPos  uint32 `json:"binlog_pos"`
+	GTID       string `json:"gtid,omitempty"`        // GTID of the last committed transaction, if GTIDs are enabled
+	CommitTime int64  `json:"commit_time,omitempty"` // Binlog timestamp of the last committed transaction
+}
+
+// OversizeEvent stands in for a change event too large to publish
+// (limits.oversize strategies subject and reference)
+type OversizeEvent struct {
+	Type          string                   `json:"type"` // Always OVERSIZE
+	Timestamp     int64                    `json:"timestamp"`
+	ID            string                   `json:"id,omitempty"`
+	EventType     string                   `json:"event_type"`
+	Database      string                   `json:"database"`
+	Table         string                   `json:"table"`
+	Size          int                      `json:"size"`           // Encoded size of the event in bytes
+	MaxSize       int                      `json:"max_size"`       // Max payload it exceeded
+	Ref           string                   `json:"$ref,omitempty"` // "bucket/key" of the event in the object store
+	Keys          []map[string]interface{} `json:"keys,omitempty"` // Primary key (or row key) values of the event's rows
+	GTID          string                   `json:"gtid,omitempty"`
+	BinlogFile    string                   `json:"binlog_file,omitempty"`
+	BinlogPos     uint32                   `json:"binlog_pos,omitempty"`
+	TransactionID string                   `json:"transaction_id,omitempty"`
+}
+
+// DeadLetterEvent carries an event that failed a pipeline stage, with the error
+type DeadLetterEvent struct {
+	Type       string       `json:"type"` // Always DEAD_LETTER
+	Timestamp  int64        `json:"timestamp"`
+	Stage      string       `json:"stage"` // decode, transform or publish
+	Error      string       `json:"error"`
+	Attempts   int          `json:"attempts"`
+	Database   string       `json:"database,omitempty"`
+	Table      string       `json:"table,omitempty"`
+	BinlogFile string       `json:"binlog_file"`
+	BinlogPos  uint32       `json:"binlog_pos"`
+	Event      *ChangeEvent `json:"event,omitempty"` // Not set for decode errors
 }
 
+// Alert severities
+const (
+	AlertSeverityInfo     = "info"
+	AlertSeverityWarning  = "warning"
+	AlertSeverityCritical = "critical"
+)
+
+// AlertEvent is a structured operational alert
+type AlertEvent struct {
+	Type      string                 `json:"type"` // Always ALERT
+	Timestamp int64                  `json:"timestamp"`
+	Severity  string                 `json:"severity"`
+	Name      string                 `json:"name"`
+	Message   string                 `json:"message"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+}
@@ -0,0 +1,136 @@
+package models
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Event formats (events.format)
+const (
+	FormatRows     = "rows"
+	FormatColumnar = "columnar"
+	FormatDebezium = "debezium"
+)
+
+// debeziumServerName is the logical server name in the source block of Debezium events
+var debeziumServerName atomic.Value
+
+// SetDebeziumServerName sets the logical server name Debezium events are attributed
+// to (source.name). Call it before publishing.
+func SetDebeziumServerName(name string) {
+	debeziumServerName.Store(name)
+}
+
+// DebeziumEvent is a change event in the envelope of Debezium's MySQL connector,
+// encoded as with the JSON converter's schemas disabled. It holds a single row.
+type DebeziumEvent struct {
+	Before      map[string]interface{} `json:"before"`
+	After       map[string]interface{} `json:"after"`
+	Source      DebeziumSource         `json:"source"`
+	Op          string                 `json:"op"` // c (create), u (update), d (delete) or r (read, for snapshots)
+	TsMs        int64                  `json:"ts_ms"`
+	Transaction *DebeziumTransaction   `json:"transaction"`
+}
+
+// DebeziumSource is the source block of a Debezium event
+type DebeziumSource struct {
+	Version   string  `json:"version"`
+	Connector string  `json:"connector"`
+	Name      string  `json:"name"`
+	TsMs      int64   `json:"ts_ms"`
+	Snapshot  string  `json:"snapshot"`
+	DB        string  `json:"db"`
+	Table     string  `json:"table"`
+	ServerID  int64   `json:"server_id"`
+	GTID      *string `json:"gtid"`
+	File      string  `json:"file"`
+	Pos       uint32  `json:"pos"`
+	Row       int     `json:"row"`
+	Query     *string `json:"query"`
+}
+
+// DebeziumTransaction is the transaction block of a Debezium event
+type DebeziumTransaction struct {
+	ID         string `json:"id"`
+	TotalOrder int    `json:"total_order"`
+}
+
+// debeziumOps maps change event types to Debezium operations
+var debeziumOps = map[string]string{
+	"INSERT":   "c",
+	"UPDATE":   "u",
+	"DELETE":   "d",
+	"SNAPSHOT": "r",
+}
+
+// Debezium converts the event to a Debezium envelope. Only the first row is
+// converted: events.format: debezium publishes one row per event.
+func (e *ChangeEvent) Debezium() *DebeziumEvent {
+	name, _ := debeziumServerName.Load().(string)
+	d := &DebeziumEvent{
+		Op:   debeziumOps[e.Type],
+		TsMs: time.Now().UnixMilli(),
+		Source: DebeziumSource{
+			Version:   "mysql-cdc",
+			Connector: "mysql",
+			Name:      name,
+			TsMs:      e.Timestamp.UnixMilli(),
+			Snapshot:  "false",
+			DB:        e.Database,
+			Table:     e.Table,
+			File:      e.BinlogFile,
+			Pos:       e.BinlogPos,
+		},
+	}
+	if e.Type == "SNAPSHOT" {
+		d.Source.Snapshot = "true"
+	}
+	if e.GTID != "" {
+		gtid := e.GTID
+		d.Source.GTID = &gtid
+	}
+	if e.Statement != "" {
+		query := e.Statement
+		d.Source.Query = &query
+	}
+	if e.Parts > 0 {
+		d.Source.Row = e.Part - 1
+	}
+	if e.Transaction != nil {
+		d.Transaction = &DebeziumTransaction{ID: e.Transaction.ID, TotalOrder: e.Transaction.Sequence}
+	}
+
+	var row, oldRow map[string]interface{}
+	if len(e.Rows) > 0 {
+		row = e.Rows[0]
+	}
+	if len(e.OldRows) > 0 {
+		oldRow = e.OldRows[0]
+	}
+	switch e.Type {
+	case "DELETE":
+		d.Before = row
+	case "UPDATE":
+		d.Before, d.After = oldRow, row
+	default:
+		d.After = row
+	}
+	return d
+}
+
+// Encoding returns the value a change event is encoded as in the given events.format:
+// the event itself, its columnar form or its Debezium envelope
+func (e *ChangeEvent) Encoding(format string) interface{} {
+	// Set here so the block reflects IDs and positions changed after the event was read
+	if e.Origin != nil {
+		e.Metadata = e.metadata()
+	}
+	switch format {
+	case FormatColumnar:
+		return e.Columnar()
+	case FormatDebezium:
+		return e.Debezium()
+	default:
+		return e
+	}
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"encoding/json"
+	"sync/atomic"
+	"time"
+)
+
+// Timestamp units for the change event timestamp field
+const (
+	TimestampSeconds      = "seconds"
+	TimestampMilliseconds = "milliseconds"
+	TimestampMicroseconds = "microseconds"
+	TimestampISO8601      = "iso8601"
+)
+
+// timestampUnit is the unit change event timestamps are encoded in
+var timestampUnit atomic.Value
+
+// SetTimestampUnit sets the unit change event timestamps are encoded in: seconds
+// (default), milliseconds, microseconds or iso8601. Call it before publishing.
+func SetTimestampUnit(unit string) {
+	timestampUnit.Store(unit)
+}
+
+// Timestamp is a change event time, encoded in the unit set with SetTimestampUnit
+type Timestamp struct {
+	time.Time
+}
+
+// NewTimestamp creates a timestamp
+func NewTimestamp(t time.Time) Timestamp {
+	return Timestamp{Time: t}
+}
+
+// Value returns the timestamp in the configured unit: an integer, or an RFC 3339 string
+func (t Timestamp) Value() interface{} {
+	unit, _ := timestampUnit.Load().(string)
+	switch unit {
+	case TimestampMilliseconds:
+		return t.UnixMilli()
+	case TimestampMicroseconds:
+		return t.UnixMicro()
+	case TimestampISO8601:
+		return t.UTC().Format(time.RFC3339Nano)
+	default:
+		return t.Unix()
+	}
+}
+
+// MarshalJSON encodes the timestamp in the configured unit
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.Value())
+}
+
+// ParseTimestamp reads a timestamp in the configured unit, as produced by Value.
+// Numbers may be int64 or float64 (as exported from JavaScript).
+func ParseTimestamp(v interface{}) (Timestamp, bool) {
+	var n int64
+	switch v := v.(type) {
+	case int64:
+		n = v
+	case float64:
+		n = int64(v)
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return Timestamp{}, false
+		}
+		return NewTimestamp(t), true
+	default:
+		return Timestamp{}, false
+	}
+
+	unit, _ := timestampUnit.Load().(string)
+	switch unit {
+	case TimestampMilliseconds:
+		return NewTimestamp(time.UnixMilli(n)), true
+	case TimestampMicroseconds:
+		return NewTimestamp(time.UnixMicro(n)), true
+	default:
+		return NewTimestamp(time.Unix(n, 0)), true
+	}
+}
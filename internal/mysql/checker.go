@@ -1,12 +1,16 @@
 package mysql
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"strings"
+	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	mysqldriver "github.com/go-sql-driver/mysql"
 	"github.com/sirupsen/logrus"
+
+	"mysql-cdc/internal/config"
 )
 
 // Checker validates MySQL connection and required permissions
@@ -15,36 +19,211 @@ type Checker struct {
 	port     int
 	user     string
 	password string
+	tls      *config.MySQLTLSConfig
+	strict   bool // Fail instead of warning on risky server configuration
 	logger   *logrus.Logger
 }
 
 // NewChecker creates a new MySQL checker
-func NewChecker(host string, port int, user, password string, logger *logrus.Logger) *Checker {
+func NewChecker(host string, port int, user, password string, tlsCfg *config.MySQLTLSConfig, strict bool, logger *logrus.Logger) *Checker {
 	return &Checker{
 		host:     host,
 		port:     port,
 		user:     user,
 		password: password,
+		tls:      tlsCfg,
+		strict:   strict,
 		logger:   logger,
 	}
 }
 
+// open opens a connection to the MySQL server
+func (c *Checker) open() (*sql.DB, error) {
+	dsn := mysqldriver.NewConfig()
+	dsn.User = c.user
+	dsn.Passwd = c.password
+	dsn.Net = "tcp"
+	dsn.Addr = fmt.Sprintf("%s:%d", c.host, c.port)
+	db, err := OpenDB(dsn, c.tls)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open MySQL connection: %w", err)
+	}
+	db.SetMaxOpenConns(1)
+	db.SetMaxIdleConns(1)
+	return db, nil
+}
+
+// LowerCaseTableNames returns the server's lower_case_table_names setting
+// (0 = case-sensitive names, 1 = stored lowercase, 2 = stored as given but compared lowercase)
+func (c *Checker) LowerCaseTableNames() (int, error) {
+	db, err := c.open()
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	var value int
+	if err := db.QueryRow("SELECT @@lower_case_table_names").Scan(&value); err != nil {
+		return 0, fmt.Errorf("failed to query lower_case_table_names: %w", err)
+	}
+	return value, nil
+}
+
+// MasterPosition returns the server's current binlog file and position
+func (c *Checker) MasterPosition() (string, uint32, error) {
+	db, err := c.open()
+	if err != nil {
+		return "", 0, err
+	}
+	defer db.Close()
+
+	return BinlogStatus(context.Background(), db)
+}
+
+// Querier runs queries on a connection pool or a single connection
+type Querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// BinlogStatus returns the server's current binlog file and position
+func BinlogStatus(ctx context.Context, db Querier) (string, uint32, error) {
+	// MySQL 8.4 replaced SHOW MASTER STATUS with SHOW BINARY LOG STATUS
+	var lastErr error
+	for _, query := range []string{"SHOW MASTER STATUS", "SHOW BINARY LOG STATUS"} {
+		file, pos, err := binlogStatus(ctx, db, query)
+		if err == nil {
+			return file, pos, nil
+		}
+		lastErr = err
+	}
+	return "", 0, lastErr
+}
+
+// binlogStatus reads File and Position from a SHOW MASTER STATUS style query,
+// whose other columns vary between versions and flavors
+func binlogStatus(ctx context.Context, db Querier, query string) (string, uint32, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to query binlog status: %w", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to read binlog status columns: %w", err)
+	}
+	if !rows.Next() {
+		return "", 0, fmt.Errorf("binlog status is empty (is binary logging enabled?)")
+	}
+	values := make([]sql.RawBytes, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return "", 0, fmt.Errorf("failed to read binlog status: %w", err)
+	}
+
+	var file string
+	var pos uint32
+	for i, col := range columns {
+		switch strings.ToLower(col) {
+		case "file":
+			file = string(values[i])
+		case "position":
+			if _, err := fmt.Sscanf(string(values[i]), "%d", &pos); err != nil {
+				return "", 0, fmt.Errorf("invalid binlog position %q: %w", values[i], err)
+			}
+		}
+	}
+	if file == "" {
+		return "", 0, fmt.Errorf("binlog status has no file")
+	}
+	return file, pos, nil
+}
+
+// ServerInfo describes the source server as detected at startup
+type ServerInfo struct {
+	Version  string // Full version string from SELECT VERSION()
+	Flavor   string // mysql, mariadb or percona
+	Major    int
+	Minor    int
+	GTIDMode bool // GTID-based replication is available on the server
+	// binlog_row_metadata: FULL puts column names and primary keys in the binlog,
+	// MINIMAL (or empty on servers without the setting) leaves them out
+	RowMetadata string
+	RowImage    string // binlog_row_image: FULL, MINIMAL or NOBLOB
+}
+
+// AtLeast reports whether the server version is at least major.minor
+func (s *ServerInfo) AtLeast(major, minor int) bool {
+	return s.Major > major || (s.Major == major && s.Minor >= minor)
+}
+
+// DetectServer queries the server version and GTID mode
+func (c *Checker) DetectServer() (*ServerInfo, error) {
+	db, err := c.open()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	info := &ServerInfo{}
+	if err := db.QueryRow("SELECT VERSION()").Scan(&info.Version); err != nil {
+		return nil, fmt.Errorf("failed to query server version: %w", err)
+	}
+
+	// Percona Server only identifies itself in version_comment
+	var comment string
+	if err := db.QueryRow("SELECT @@version_comment").Scan(&comment); err != nil {
+		c.logger.Debugf("Could not query version_comment: %v", err)
+	}
+
+	info.Flavor = detectFlavor(info.Version, comment)
+	fmt.Sscanf(info.Version, "%d.%d", &info.Major, &info.Minor)
+
+	if info.Flavor == "mariadb" {
+		// MariaDB GTIDs (10.0+) are always written and need no server setting
+		info.GTIDMode = info.Major >= 10
+	} else {
+		var gtidMode string
+		if err := db.QueryRow("SELECT @@gtid_mode").Scan(&gtidMode); err == nil {
+			info.GTIDMode = strings.EqualFold(gtidMode, "ON")
+		}
+	}
+
+	// binlog_row_metadata only exists on MySQL 8.0.1+ and MariaDB 10.5+
+	if err := db.QueryRow("SELECT @@binlog_row_metadata").Scan(&info.RowMetadata); err != nil {
+		info.RowMetadata = ""
+	}
+	if err := db.QueryRow("SELECT @@binlog_row_image").Scan(&info.RowImage); err != nil {
+		info.RowImage = "FULL"
+	}
+
+	return info, nil
+}
+
+// detectFlavor derives the server flavor from its version string and comment
+func detectFlavor(version, comment string) string {
+	switch {
+	case strings.Contains(strings.ToLower(version), "mariadb"):
+		return "mariadb"
+	case strings.Contains(strings.ToLower(comment), "percona"):
+		return "percona"
+	default:
+		return "mysql"
+	}
+}
+
 // CheckConnectionAndPermissions verifies MySQL connection and required permissions
 func (c *Checker) CheckConnectionAndPermissions() error {
-	// Build DSN
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/", c.user, c.password, c.host, c.port)
-
 	// Test connection
-	db, err := sql.Open("mysql", dsn)
+	db, err := c.open()
 	if err != nil {
-		return fmt.Errorf("failed to open MySQL connection: %w", err)
+		return err
 	}
 	defer db.Close()
 
-	// Set connection timeout
-	db.SetMaxOpenConns(1)
-	db.SetMaxIdleConns(1)
-
 	// Test connection with ping
 	if err := db.Ping(); err != nil {
 		return fmt.Errorf("failed to connect to MySQL server: %w", err)
@@ -52,51 +231,27 @@ func (c *Checker) CheckConnectionAndPermissions() error {
 
 	c.logger.Info("Successfully connected to MySQL server")
 
-	// Check required permissions
+	// Check required replication permissions. SELECT is checked separately by
+	// CheckSelectAccess, since it isn't needed when metadata comes from the binlog.
 	requiredPrivs := []string{
 		"REPLICATION SLAVE",
 		"REPLICATION CLIENT",
-		"SELECT",
 	}
 
-	// Get current user grants (SHOW GRANTS can return multiple rows)
-	var allGrants strings.Builder
-	rows, err := db.Query("SHOW GRANTS FOR CURRENT_USER()")
+	grants, err := userGrants(db)
 	if err != nil {
-		// Try alternative query for MySQL 5.6
-		rows, err = db.Query("SHOW GRANTS")
-		if err != nil {
-			return fmt.Errorf("failed to check grants: %w", err)
-		}
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var grant string
-		if err := rows.Scan(&grant); err != nil {
-			return fmt.Errorf("failed to scan grant: %w", err)
-		}
-		if allGrants.Len() > 0 {
-			allGrants.WriteString("; ")
-		}
-		allGrants.WriteString(grant)
-	}
-	if err := rows.Err(); err != nil {
-		return fmt.Errorf("error iterating grants: %w", err)
+		return err
 	}
 
-	grantsStr := allGrants.String()
-	grantsUpper := strings.ToUpper(grantsStr)
 	missingPrivs := []string{}
-
 	for _, priv := range requiredPrivs {
-		if !strings.Contains(grantsUpper, priv) {
+		if !hasPrivilege(grants, priv, "", "") {
 			missingPrivs = append(missingPrivs, priv)
 		}
 	}
 
 	if len(missingPrivs) > 0 {
-		return fmt.Errorf("missing required permissions: %s. Current grants: %s", strings.Join(missingPrivs, ", "), grantsStr)
+		return fmt.Errorf("missing required permissions: %s. Current grants: %s", strings.Join(missingPrivs, ", "), grantStatements(grants))
 	}
 
 	c.logger.Info("All required permissions verified")
@@ -136,6 +291,9 @@ func (c *Checker) CheckConnectionAndPermissions() error {
 	}
 
 	if binlogFormat != "" && binlogFormat != "ROW" {
+		if c.strict {
+			return fmt.Errorf("binlog_format is set to '%s', but ROW format is required for CDC", binlogFormat)
+		}
 		c.logger.Warnf("binlog_format is set to '%s', but ROW format is recommended for CDC", binlogFormat)
 	} else if binlogFormat == "ROW" {
 		c.logger.Info("binlog_format is set to ROW (recommended for CDC)")
@@ -144,3 +302,378 @@ func (c *Checker) CheckConnectionAndPermissions() error {
 	return nil
 }
 
+// CheckServerConfig validates binlog retention, GTID mode and binlog_row_image.
+// Problems that could lose data or produce mis-decoded events are logged as
+// warnings, or returned as an error in strict mode.
+func (c *Checker) CheckServerConfig(server *ServerInfo, useGTID bool, minRetention time.Duration) error {
+	db, err := c.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var problems []string
+
+	// Binlog retention (0 = binlogs are never purged automatically)
+	retention, err := binlogRetention(db)
+	if err != nil {
+		c.logger.Warnf("Could not verify binlog retention: %v", err)
+	} else if retention > 0 && retention < minRetention {
+		problems = append(problems, fmt.Sprintf(
+			"binlogs are purged after %s, which is less than the minimum of %s; a longer outage would lose events", retention, minRetention))
+	} else if retention > 0 {
+		c.logger.Infof("Binlog retention is %s", retention)
+	}
+
+	if useGTID && !server.GTIDMode {
+		problems = append(problems, "GTID replication is requested but GTID mode is not enabled on the server")
+	}
+
+	if server.RowImage != "" && !strings.EqualFold(server.RowImage, "FULL") {
+		problems = append(problems, fmt.Sprintf(
+			"binlog_row_image is set to '%s'; events will be missing the columns that aren't logged", server.RowImage))
+	}
+
+	if len(problems) == 0 {
+		c.logger.Info("Server configuration verified")
+		return nil
+	}
+	if c.strict {
+		return fmt.Errorf("server configuration check failed: %s", strings.Join(problems, "; "))
+	}
+	for _, problem := range problems {
+		c.logger.Warn(problem)
+	}
+	return nil
+}
+
+// binlogRetention returns how long the server keeps binlogs. MySQL 8.0 uses
+// binlog_expire_logs_seconds, older versions and MariaDB use expire_logs_days.
+func binlogRetention(db *sql.DB) (time.Duration, error) {
+	var seconds int64
+	if err := db.QueryRow("SELECT @@binlog_expire_logs_seconds").Scan(&seconds); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second, nil
+	}
+
+	var days float64
+	if err := db.QueryRow("SELECT @@expire_logs_days").Scan(&days); err != nil {
+		return 0, fmt.Errorf("failed to query expire_logs_days: %w", err)
+	}
+	return time.Duration(days * float64(24*time.Hour)), nil
+}
+
+// CheckServerID verifies that no other replica connected to the server, and not
+// the server itself, uses the given server_id. A duplicate makes the server drop
+// one of the connections, which shows up as repeated disconnects.
+func (c *Checker) CheckServerID(serverID uint32) error {
+	db, err := c.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var conflicts []string
+
+	var sourceID uint32
+	if err := db.QueryRow("SELECT @@server_id").Scan(&sourceID); err == nil && sourceID == serverID {
+		conflicts = append(conflicts, "the source server itself")
+	}
+
+	replicas, err := replicaHosts(db)
+	if err != nil {
+		c.logger.Warnf("Could not list connected replicas: %v", err)
+	}
+	for _, replica := range replicas {
+		if replica.serverID == serverID {
+			conflicts = append(conflicts, fmt.Sprintf("replica %s:%d", replica.host, replica.port))
+		}
+	}
+
+	if len(conflicts) == 0 {
+		c.logger.Infof("server_id %d is not used by any connected replica", serverID)
+		return nil
+	}
+	msg := fmt.Sprintf("server_id %d is already used by %s", serverID, strings.Join(conflicts, ", "))
+	if c.strict {
+		return fmt.Errorf("%s", msg)
+	}
+	c.logger.Warnf("%s; the server will keep disconnecting one of them", msg)
+	return nil
+}
+
+// replicaHost is a replica registered with the server
+type replicaHost struct {
+	serverID uint32
+	host     string
+	port     int
+}
+
+// replicaHosts lists registered replicas. SHOW REPLICAS replaces SHOW SLAVE HOSTS
+// on MySQL 8.0.22+; the column set differs between versions, so columns are
+// looked up by name.
+func replicaHosts(db *sql.DB) ([]replicaHost, error) {
+	rows, err := db.Query("SHOW REPLICAS")
+	if err != nil {
+		rows, err = db.Query("SHOW SLAVE HOSTS")
+		if err != nil {
+			return nil, fmt.Errorf("failed to query replicas: %w", err)
+		}
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read replica columns: %w", err)
+	}
+
+	var replicas []replicaHost
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		dest := make([]interface{}, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to scan replica: %w", err)
+		}
+
+		var replica replicaHost
+		for i, col := range columns {
+			switch strings.ToLower(col) {
+			case "server_id":
+				fmt.Sscanf(values[i].String, "%d", &replica.serverID)
+			case "host":
+				replica.host = values[i].String
+			case "port":
+				fmt.Sscanf(values[i].String, "%d", &replica.port)
+			}
+		}
+		replicas = append(replicas, replica)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating replicas: %w", err)
+	}
+	return replicas, nil
+}
+
+// CheckSelectAccess verifies that the user can read column metadata for the given
+// tables from INFORMATION_SCHEMA, which needs SELECT on them. With no tables
+// configured, SELECT on any database is accepted with a warning unless it's global.
+func (c *Checker) CheckSelectAccess(tables []config.TableRef) error {
+	db, err := c.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	grants, err := userGrants(db)
+	if err != nil {
+		return err
+	}
+
+	if len(tables) == 0 {
+		if hasPrivilege(grants, "SELECT", "", "") {
+			c.logger.Info("SELECT is granted on all databases")
+			return nil
+		}
+		var scopes []string
+		for _, g := range grants {
+			if g.grants("SELECT") {
+				scopes = append(scopes, g.database+"."+g.table)
+			}
+		}
+		if len(scopes) == 0 {
+			return fmt.Errorf("missing required permissions: SELECT. Current grants: %s", grantStatements(grants))
+		}
+		msg := fmt.Sprintf("SELECT is only granted on %s; column metadata for other tables can't be read", strings.Join(scopes, ", "))
+		if c.strict {
+			return fmt.Errorf("%s", msg)
+		}
+		c.logger.Warn(msg + ". Set filters.tables to limit replication to them")
+		return nil
+	}
+
+	var missing []string
+	for _, ref := range tables {
+		if !hasPrivilege(grants, "SELECT", ref.Database, ref.Table) {
+			missing = append(missing, tableRefString(ref))
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing SELECT permission on %s. Current grants: %s", strings.Join(missing, ", "), grantStatements(grants))
+	}
+
+	c.logger.Info("SELECT permission verified for all filtered tables")
+	return nil
+}
+
+// userGrant is a parsed privilege grant from SHOW GRANTS
+type userGrant struct {
+	statement  string
+	privileges []string // Upper-cased privilege names, without column lists
+	database   string   // Database name or pattern (may contain % and _), "*" for all
+	table      string   // Table name, "*" for all
+}
+
+// grants reports whether the grant includes the privilege
+func (g *userGrant) grants(priv string) bool {
+	for _, p := range g.privileges {
+		if p == priv || p == "ALL" || p == "ALL PRIVILEGES" {
+			return true
+		}
+	}
+	return false
+}
+
+// userGrants returns the current user's privilege grants (SHOW GRANTS can return multiple rows)
+func userGrants(db *sql.DB) ([]userGrant, error) {
+	rows, err := db.Query("SHOW GRANTS FOR CURRENT_USER()")
+	if err != nil {
+		// Try alternative query for MySQL 5.6
+		rows, err = db.Query("SHOW GRANTS")
+		if err != nil {
+			return nil, fmt.Errorf("failed to check grants: %w", err)
+		}
+	}
+	defer rows.Close()
+
+	var grants []userGrant
+	for rows.Next() {
+		var statement string
+		if err := rows.Scan(&statement); err != nil {
+			return nil, fmt.Errorf("failed to scan grant: %w", err)
+		}
+		if g, ok := parseGrant(statement); ok {
+			grants = append(grants, g)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating grants: %w", err)
+	}
+	return grants, nil
+}
+
+// parseGrant parses "GRANT <privileges> ON <db>.<table> TO ...". Role grants,
+// which have no ON clause, are skipped.
+func parseGrant(statement string) (userGrant, bool) {
+	upper := strings.ToUpper(statement)
+	if !strings.HasPrefix(upper, "GRANT ") {
+		return userGrant{}, false
+	}
+	on := strings.Index(upper, " ON ")
+	if on < 0 {
+		return userGrant{}, false
+	}
+
+	g := userGrant{statement: statement}
+	for _, priv := range strings.Split(upper[len("GRANT "):on], ",") {
+		priv = strings.TrimSpace(priv)
+		if strings.Contains(priv, "(") {
+			// Column-level grants don't cover the whole table
+			continue
+		}
+		g.privileges = append(g.privileges, priv)
+	}
+
+	target := strings.TrimSpace(statement[on+len(" ON "):])
+	for _, kind := range []string{"TABLE ", "FUNCTION ", "PROCEDURE "} {
+		if strings.HasPrefix(strings.ToUpper(target), kind) {
+			target = target[len(kind):]
+		}
+	}
+	var rest string
+	g.database, rest = readIdentifier(target)
+	if !strings.HasPrefix(rest, ".") {
+		return userGrant{}, false
+	}
+	g.table, _ = readIdentifier(rest[1:])
+	return g, true
+}
+
+// readIdentifier reads a possibly backtick-quoted identifier or "*" and returns the rest
+func readIdentifier(s string) (string, string) {
+	if strings.HasPrefix(s, "`") {
+		var b strings.Builder
+		for i := 1; i < len(s); i++ {
+			if s[i] == '`' {
+				if i+1 < len(s) && s[i+1] == '`' {
+					b.WriteByte('`')
+					i++
+					continue
+				}
+				return b.String(), s[i+1:]
+			}
+			b.WriteByte(s[i])
+		}
+		return b.String(), ""
+	}
+	end := strings.IndexAny(s, ". ")
+	if end < 0 {
+		return s, ""
+	}
+	return s[:end], s[end:]
+}
+
+// hasPrivilege reports whether a grant gives the privilege on the database/table.
+// Empty database/table require a grant on all databases/tables.
+func hasPrivilege(grants []userGrant, priv, database, table string) bool {
+	for i := range grants {
+		g := &grants[i]
+		if !g.grants(priv) {
+			continue
+		}
+		if g.database != "*" && (database == "" || !likeMatch(g.database, database)) {
+			continue
+		}
+		if g.table != "*" && (table == "" || !strings.EqualFold(g.table, table)) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// likeMatch matches a name against a database grant pattern, where % matches any
+// sequence, _ matches one character and \ escapes them
+func likeMatch(pattern, name string) bool {
+	if pattern == "" {
+		return name == ""
+	}
+	switch pattern[0] {
+	case '%':
+		for i := 0; i <= len(name); i++ {
+			if likeMatch(pattern[1:], name[i:]) {
+				return true
+			}
+		}
+		return false
+	case '_':
+		return name != "" && likeMatch(pattern[1:], name[1:])
+	case '\\':
+		if len(pattern) > 1 {
+			pattern = pattern[1:]
+		}
+	}
+	return name != "" && strings.EqualFold(pattern[:1], name[:1]) && likeMatch(pattern[1:], name[1:])
+}
+
+// grantStatements joins the grant statements for error messages
+func grantStatements(grants []userGrant) string {
+	statements := make([]string, len(grants))
+	for i, g := range grants {
+		statements[i] = g.statement
+	}
+	return strings.Join(statements, "; ")
+}
+
+// tableRefString formats a table reference, using * for empty fields
+func tableRefString(ref config.TableRef) string {
+	database, table := ref.Database, ref.Table
+	if database == "" {
+		database = "*"
+	}
+	if table == "" {
+		table = "*"
+	}
+	return database + "." + table
+}
@@ -0,0 +1,43 @@
+package nats
+
+import (
+	"time"
+
+	"mysql-cdc/internal/models"
+)
+
+// EnableAlerts makes Alert publish operational alerts to the given subject
+func (p *Publisher) EnableAlerts(subject string) {
+	p.alertSubject.Store(&subject)
+}
+
+// OnAlert sets a function that's called with every alert, e.g. to forward it
+// to a notification webhook. Alerts are passed on even if they aren't published.
+func (p *Publisher) OnAlert(fn func(event *models.AlertEvent)) {
+	p.alertHook.Store(&fn)
+}
+
+// Alert publishes a structured operational alert, if alerts are enabled
+func (p *Publisher) Alert(severity, name, message string, details map[string]interface{}) {
+	event := &models.AlertEvent{
+		Type:      "ALERT",
+		Timestamp: time.Now().Unix(),
+		Severity:  severity,
+		Name:      name,
+		Message:   message,
+		Details:   details,
+	}
+	p.notifyAlert(event)
+	if subject := p.alertSubject.Load(); subject != nil {
+		if err := p.PublishJSON(*subject, event); err != nil {
+			p.logger.Warnf("Failed to publish %s alert: %v", name, err)
+		}
+	}
+}
+
+// notifyAlert passes an alert to the OnAlert function, without waiting for it
+func (p *Publisher) notifyAlert(event *models.AlertEvent) {
+	if fn := p.alertHook.Load(); fn != nil {
+		go (*fn)(event)
+	}
+}
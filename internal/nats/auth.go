@@ -0,0 +1,34 @@
+package nats
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/nats-io/nats.go"
+
+	"mysql-cdc/internal/config"
+)
+
+// AuthOptions returns the connection options of the authentication method set
+// in nats.auth, if any
+func AuthOptions(cfg *config.NATSAuthConfig) ([]nats.Option, error) {
+	switch {
+	case cfg.Token != "":
+		return []nats.Option{nats.Token(cfg.Token)}, nil
+	case cfg.Username != "":
+		return []nats.Option{nats.UserInfo(cfg.Username, cfg.Password)}, nil
+	case cfg.NKeySeedFile != "":
+		opt, err := nats.NkeyOptionFromSeed(cfg.NKeySeedFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load NKey seed from %s: %w", cfg.NKeySeedFile, err)
+		}
+		return []nats.Option{opt}, nil
+	case cfg.CredentialsFile != "":
+		// The file is read on each (re)connect, so check it's there up front
+		if _, err := os.Stat(cfg.CredentialsFile); err != nil {
+			return nil, fmt.Errorf("failed to read credentials file: %w", err)
+		}
+		return []nats.Option{nats.UserCredentials(cfg.CredentialsFile)}, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,38 @@
+package nats
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+)
+
+// maxPooledBufferSize keeps unusually large buffers from being held by the pool
+const maxPooledBufferSize = 1 << 20
+
+// bufferPool holds reusable encode buffers
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// encodeJSON encodes v into a pooled buffer. The returned data is only valid until
+// release is called; NATS copies published data into its own write buffer, so the
+// buffer can be released as soon as the publish call returns.
+func encodeJSON(v interface{}) (data []byte, release func(), err error) {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	release = func() {
+		if buf.Cap() <= maxPooledBufferSize {
+			bufferPool.Put(buf)
+		}
+	}
+
+	if err := json.NewEncoder(buf).Encode(v); err != nil {
+		release()
+		return nil, nil, err
+	}
+
+	// Encoder terminates each value with a newline that json.Marshal doesn't add
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), release, nil
+}
@@ -0,0 +1,70 @@
+package nats
+
+import (
+	"fmt"
+)
+
+// CheckJetStream verifies that JetStream is enabled for the account
+func (p *Publisher) CheckJetStream() error {
+	js, err := p.conn.JetStream()
+	if err != nil {
+		return fmt.Errorf("failed to get JetStream context: %w", err)
+	}
+	if _, err := js.AccountInfo(); err != nil {
+		return fmt.Errorf("JetStream is not available: %w", err)
+	}
+	return nil
+}
+
+// CheckKV verifies that the JetStream KV bucket exists
+func (p *Publisher) CheckKV(bucket string) error {
+	js, err := p.conn.JetStream()
+	if err != nil {
+		return fmt.Errorf("failed to get JetStream context: %w", err)
+	}
+	if _, err := js.KeyValue(bucket); err != nil {
+		return fmt.Errorf("failed to get KV store '%s': %w", bucket, err)
+	}
+	return nil
+}
+
+// CheckObjectStore verifies that the JetStream object store bucket exists
+func (p *Publisher) CheckObjectStore(bucket string) error {
+	js, err := p.conn.JetStream()
+	if err != nil {
+		return fmt.Errorf("failed to get JetStream context: %w", err)
+	}
+	if _, err := js.ObjectStore(bucket); err != nil {
+		return fmt.Errorf("failed to get object store '%s': %w", bucket, err)
+	}
+	return nil
+}
+
+// CheckConsumer verifies that the durable consumer exists on the stream
+func (p *Publisher) CheckConsumer(stream, consumer string) error {
+	js, err := p.conn.JetStream()
+	if err != nil {
+		return fmt.Errorf("failed to get JetStream context: %w", err)
+	}
+	if _, err := js.ConsumerInfo(stream, consumer); err != nil {
+		return fmt.Errorf("failed to get consumer '%s' on stream '%s': %w", consumer, stream, err)
+	}
+	return nil
+}
+
+// CheckStreamForSubject verifies that a JetStream stream captures the subject and,
+// if stream is set, that it's that stream
+func (p *Publisher) CheckStreamForSubject(subject, stream string) error {
+	js, err := p.conn.JetStream()
+	if err != nil {
+		return fmt.Errorf("failed to get JetStream context: %w", err)
+	}
+	name, err := js.StreamNameBySubject(subject)
+	if err != nil {
+		return fmt.Errorf("no JetStream stream captures subject '%s': %w", subject, err)
+	}
+	if stream != "" && name != stream {
+		return fmt.Errorf("subject '%s' is captured by stream '%s', not '%s'", subject, name, stream)
+	}
+	return nil
+}
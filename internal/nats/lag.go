@@ -0,0 +1,144 @@
+package nats
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/sirupsen/logrus"
+
+	"mysql-cdc/internal/config"
+	"mysql-cdc/internal/models"
+)
+
+// ConsumerLag reports how far a JetStream durable consumer is behind the stream
+type ConsumerLag struct {
+	Type           string `json:"type"` // Always CONSUMER_LAG
+	Timestamp      int64  `json:"timestamp"`
+	Stream         string `json:"stream"`
+	Consumer       string `json:"consumer"`
+	NumPending     uint64 `json:"num_pending"`     // Messages not yet delivered to the consumer
+	NumAckPending  int    `json:"num_ack_pending"` // Messages delivered but not yet acknowledged
+	NumRedelivered int    `json:"num_redelivered"`
+	AckFloor       uint64 `json:"ack_floor"` // Stream sequence of the ack floor
+	StreamLastSeq  uint64 `json:"stream_last_seq"`
+	Lag            uint64 `json:"lag"` // StreamLastSeq - AckFloor
+}
+
+// Metrics receives consumer lag gauges
+type Metrics interface {
+	Gauge(name string, value float64, tags ...string)
+}
+
+// LagMonitor periodically queries consumer info for the configured durable consumers
+type LagMonitor struct {
+	publisher *Publisher
+	config    *config.ConsumerLagConfig
+	metrics   Metrics
+	logger    *logrus.Logger
+	behind    map[string]bool // Consumers whose lag is at or above the warn threshold
+}
+
+// NewLagMonitor creates a new consumer lag monitor
+func NewLagMonitor(publisher *Publisher, cfg *config.ConsumerLagConfig, logger *logrus.Logger) *LagMonitor {
+	return &LagMonitor{
+		publisher: publisher,
+		config:    cfg,
+		logger:    logger,
+		behind:    make(map[string]bool),
+	}
+}
+
+// SetMetrics sets the exporter consumer lag gauges are reported to
+func (m *LagMonitor) SetMetrics(metrics Metrics) {
+	m.metrics = metrics
+}
+
+// Run reports consumer lag until the context is cancelled
+func (m *LagMonitor) Run(ctx context.Context) {
+	js, err := m.publisher.conn.JetStream()
+	if err != nil {
+		m.logger.Errorf("Consumer lag reporting disabled: failed to get JetStream context: %v", err)
+		return
+	}
+
+	ticker := time.NewTicker(m.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, consumer := range m.config.Consumers {
+				m.report(js, consumer)
+			}
+		}
+	}
+}
+
+// report queries a single consumer and publishes its lag, and reports it to the
+// metrics exporter
+func (m *LagMonitor) report(js nats.JetStreamContext, consumer string) {
+	info, err := js.ConsumerInfo(m.config.Stream, consumer)
+	if err != nil {
+		m.logger.Warnf("Failed to get consumer info for %s/%s: %v", m.config.Stream, consumer, err)
+		return
+	}
+
+	lag := &ConsumerLag{
+		Type:           "CONSUMER_LAG",
+		Timestamp:      time.Now().Unix(),
+		Stream:         m.config.Stream,
+		Consumer:       consumer,
+		NumPending:     info.NumPending,
+		NumAckPending:  info.NumAckPending,
+		NumRedelivered: info.NumRedelivered,
+		AckFloor:       info.AckFloor.Stream,
+		StreamLastSeq:  info.Delivered.Stream + info.NumPending,
+	}
+	if lag.StreamLastSeq > lag.AckFloor {
+		lag.Lag = lag.StreamLastSeq - lag.AckFloor
+	}
+
+	m.logger.WithFields(logrus.Fields{
+		"stream":      lag.Stream,
+		"consumer":    lag.Consumer,
+		"pending":     lag.NumPending,
+		"ack_pending": lag.NumAckPending,
+		"lag":         lag.Lag,
+	}).Debug("Consumer lag")
+
+	if m.metrics != nil {
+		tags := []string{"stream:" + lag.Stream, "consumer:" + lag.Consumer}
+		m.metrics.Gauge("consumer.lag", float64(lag.Lag), tags...)
+		m.metrics.Gauge("consumer.pending", float64(lag.NumPending), tags...)
+		m.metrics.Gauge("consumer.ack_pending", float64(lag.NumAckPending), tags...)
+	}
+
+	if m.config.WarnThreshold > 0 {
+		details := map[string]interface{}{
+			"stream":    lag.Stream,
+			"consumer":  lag.Consumer,
+			"lag":       lag.Lag,
+			"threshold": m.config.WarnThreshold,
+		}
+		behind := lag.Lag >= m.config.WarnThreshold
+		if behind {
+			m.logger.Warnf("Consumer %s/%s is falling behind: %d messages behind", lag.Stream, lag.Consumer, lag.Lag)
+			if !m.behind[consumer] {
+				m.publisher.Alert(models.AlertSeverityWarning, "consumer_lag",
+					fmt.Sprintf("Consumer %s/%s is %d messages behind", lag.Stream, lag.Consumer, lag.Lag), details)
+			}
+		} else if m.behind[consumer] {
+			m.publisher.Alert(models.AlertSeverityInfo, "consumer_lag_resolved",
+				fmt.Sprintf("Consumer %s/%s caught up", lag.Stream, lag.Consumer), details)
+		}
+		m.behind[consumer] = behind
+	}
+
+	if err := m.publisher.PublishJSON(m.config.Subject, lag); err != nil {
+		m.logger.Warnf("Failed to publish consumer lag: %v", err)
+	}
+}
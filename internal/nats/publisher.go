@@ -1,40 +1,103 @@
 package nats
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/nats-io/nats.go"
 	"github.com/sirupsen/logrus"
 
+	"mysql-cdc/internal/config"
 	"mysql-cdc/internal/models"
 )
 
+const (
+	// EventIDHeader carries the change event's unique ID
+	EventIDHeader = "Cdc-Event-Id"
+	// CorrelationIDHeader carries the change event's correlation ID, if it has one
+	CorrelationIDHeader = "Cdc-Correlation-Id"
+	// BatchSizeHeader carries the number of change events in a batch message
+	BatchSizeHeader = "Cdc-Batch-Size"
+)
+
 // Publisher handles publishing events to NATS
 type Publisher struct {
 	conn    *nats.Conn
 	subject string
+	signer  *Signer
+	format  string // events.format change events are encoded in
 	logger  *logrus.Logger
+
+	js         nats.JetStreamContext // Set when change events are published to JetStream
+	ackTimeout time.Duration
+	dedup      bool   // Send event dedup IDs as Nats-Msg-Id
+	stream     string // Stream publishes must be stored in, if set
+
+	lastLatency  atomic.Int64           // Duration of the last publish, in nanoseconds
+	slowConsumer atomic.Bool            // Set when NATS reports a slow consumer error
+	alertSubject atomic.Pointer[string] // Set when operational alerts are enabled
+	alertHook    atomic.Pointer[func(event *models.AlertEvent)]
+	connectedURL atomic.Pointer[string] // Server the connection was last established to
 }
 
 // NewPublisher creates a new NATS publisher
-func NewPublisher(url, subject string, maxReconnect int, reconnectWait time.Duration, logger *logrus.Logger) (*Publisher, error) {
+func NewPublisher(url string, auth *config.NATSAuthConfig, tlsCfg *config.NATSTLSConfig, subject string, maxReconnect int, reconnectWait time.Duration, signer *Signer, format string, logger *logrus.Logger) (*Publisher, error) {
+	p := &Publisher{
+		subject: subject,
+		signer:  signer,
+		format:  format,
+		logger:  logger,
+	}
+
 	opts := []nats.Option{
 		nats.MaxReconnects(maxReconnect),
 		nats.ReconnectWait(reconnectWait),
 		nats.DisconnectErrHandler(func(nc *nats.Conn, err error) {
 			if err != nil {
 				logger.Warnf("NATS disconnected: %v", err)
+				// Buffered while disconnected, so it's delivered once the connection is back
+				p.Alert(models.AlertSeverityCritical, "sink_down", "NATS disconnected", map[string]interface{}{
+					"error": err.Error(),
+				})
 			}
 		}),
 		nats.ReconnectHandler(func(nc *nats.Conn) {
-			logger.Infof("NATS reconnected to %s", nc.ConnectedUrl())
+			url := nc.ConnectedUrl()
+			logger.Infof("NATS reconnected to %s", url)
+			p.Alert(models.AlertSeverityInfo, "sink_recovered", "NATS reconnected", map[string]interface{}{
+				"url": url,
+			})
+			if prev := p.connectedURL.Swap(&url); prev != nil && *prev != url {
+				logger.Warnf("NATS failed over from %s to %s", *prev, url)
+				p.Alert(models.AlertSeverityWarning, "sink_failover", "NATS failed over to another server", map[string]interface{}{
+					"from": *prev,
+					"to":   url,
+				})
+			}
 		}),
 		nats.ClosedHandler(func(nc *nats.Conn) {
 			logger.Warn("NATS connection closed")
 		}),
+		nats.ErrorHandler(func(nc *nats.Conn, sub *nats.Subscription, err error) {
+			if err == nats.ErrSlowConsumer {
+				p.slowConsumer.Store(true)
+			}
+			logger.Warnf("NATS error: %v", err)
+		}),
+	}
+
+	authOpts, err := AuthOptions(auth)
+	if err != nil {
+		return nil, err
 	}
+	opts = append(opts, authOpts...)
+	opts = append(opts, TLSOptions(tlsCfg)...)
 
 	conn, err := nats.Connect(url, opts...)
 	if err != nil {
@@ -43,36 +106,253 @@ func NewPublisher(url, subject string, maxReconnect int, reconnectWait time.Dura
 
 	logger.Infof("Connected to NATS at %s", url)
 
-	return &Publisher{
-		conn:    conn,
-		subject: subject,
-		logger:  logger,
-	}, nil
+	p.conn = conn
+	connectedURL := conn.ConnectedUrl()
+	p.connectedURL.Store(&connectedURL)
+	return p, nil
 }
 
 // Publish publishes a change event to NATS
 func (p *Publisher) Publish(event *models.ChangeEvent) error {
-	// Use raw JSON if available (from JavaScript transformation), otherwise marshal the struct
+	// Use raw JSON if available (from JavaScript transformation), otherwise encode the struct.
+	// Scripts decide the shape of their own output, so events.format doesn't apply to them.
 	var data []byte
-	var err error
-	
 	if len(event.RawJSON) > 0 {
 		data = event.RawJSON
 	} else {
-		data, err = json.Marshal(event)
+		encoded, release, err := encodeJSON(event.Encoding(p.format))
+		if err != nil {
+			return fmt.Errorf("failed to marshal event: %w", err)
+		}
+		defer release()
+		data = encoded
+	}
+
+	headers := make(map[string]string, 2)
+	if event.ID != "" {
+		headers[EventIDHeader] = event.ID
+	}
+	if event.CorrelationID != "" {
+		headers[CorrelationIDHeader] = event.CorrelationID
+	}
+
+	subject := p.subject
+	if event.Subject != "" {
+		subject = event.Subject
+	}
+
+	if err := p.publish(subject, data, headers, p.jsOpts(event.DedupID)...); err != nil {
+		return fmt.Errorf("failed to publish to NATS: %w", err)
+	}
+
+	p.logger.WithFields(event.LogFields()).Debugf("Published %s event for %s.%s", event.Type, event.Database, event.Table)
+	return nil
+}
+
+// PublishBatch publishes change events as one message holding a JSON array of them,
+// each encoded like Publish would. With dedup, the batch is identified by the dedup
+// IDs of its first and last events, so only an identical batch is dropped.
+func (p *Publisher) PublishBatch(subject string, events []*models.ChangeEvent) error {
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	for i, event := range events {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if len(event.RawJSON) > 0 {
+			buf.Write(event.RawJSON)
+			continue
+		}
+		encoded, release, err := encodeJSON(event.Encoding(p.format))
 		if err != nil {
 			return fmt.Errorf("failed to marshal event: %w", err)
 		}
+		buf.Write(encoded)
+		release()
 	}
+	buf.WriteByte(']')
 
-	if err := p.conn.Publish(p.subject, data); err != nil {
+	if subject == "" {
+		subject = p.subject
+	}
+	headers := map[string]string{BatchSizeHeader: strconv.Itoa(len(events))}
+	first, last := events[0], events[len(events)-1]
+	dedupID := ""
+	if first.DedupID != "" && last.DedupID != "" {
+		dedupID = first.DedupID + ".." + last.DedupID
+	}
+
+	if err := p.publish(subject, buf.Bytes(), headers, p.jsOpts(dedupID)...); err != nil {
 		return fmt.Errorf("failed to publish to NATS: %w", err)
 	}
 
-	p.logger.Debugf("Published %s event for %s.%s", event.Type, event.Database, event.Table)
+	p.logger.Debugf("Published batch of %d events to %s", len(events), subject)
+	return nil
+}
+
+// PublishJSON marshals v to JSON and publishes it to the given subject
+func (p *Publisher) PublishJSON(subject string, v interface{}) error {
+	data, release, err := encodeJSON(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+	defer release()
+
+	if err := p.publish(subject, data, nil); err != nil {
+		return fmt.Errorf("failed to publish to NATS: %w", err)
+	}
+
+	p.logger.Debugf("Published message to %s", subject)
+	return nil
+}
+
+// PublishRaw publishes data as-is with the given headers. With JetStream enabled the
+// publish is acked, and with dedup the dedup ID is sent as Nats-Msg-Id.
+func (p *Publisher) PublishRaw(subject string, data []byte, headers map[string]string, dedupID string) error {
+	if err := p.publish(subject, data, headers, p.jsOpts(dedupID)...); err != nil {
+		return fmt.Errorf("failed to publish to NATS: %w", err)
+	}
+	return nil
+}
+
+// PutKV marshals v to JSON and stores it under key in the given JetStream KV bucket
+func (p *Publisher) PutKV(bucket, key string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal value: %w", err)
+	}
+
+	js, err := p.conn.JetStream()
+	if err != nil {
+		return fmt.Errorf("failed to get JetStream context: %w", err)
+	}
+
+	kv, err := js.KeyValue(bucket)
+	if err != nil {
+		return fmt.Errorf("failed to get KV store '%s': %w", bucket, err)
+	}
+
+	if _, err := kv.Put(key, data); err != nil {
+		return fmt.Errorf("failed to put KV key '%s': %w", key, err)
+	}
+	return nil
+}
+
+// PutObject stores data under key in the given JetStream object store bucket
+func (p *Publisher) PutObject(bucket, key string, data []byte) error {
+	js, err := p.conn.JetStream()
+	if err != nil {
+		return fmt.Errorf("failed to get JetStream context: %w", err)
+	}
+
+	store, err := js.ObjectStore(bucket)
+	if err != nil {
+		return fmt.Errorf("failed to get object store '%s': %w", bucket, err)
+	}
+
+	if _, err := store.PutBytes(key, data); err != nil {
+		return fmt.Errorf("failed to put object '%s': %w", key, err)
+	}
+	return nil
+}
+
+// EnableJetStream makes change events be published to JetStream, waiting for the
+// stream's ack. With dedup, event dedup IDs are sent as Nats-Msg-Id, so duplicates
+// within the stream's duplicate window are dropped by the server. With a stream
+// name, a publish stored in any other stream fails.
+func (p *Publisher) EnableJetStream(ackTimeout time.Duration, dedup bool, stream string) error {
+	js, err := p.conn.JetStream(nats.MaxWait(ackTimeout))
+	if err != nil {
+		return fmt.Errorf("failed to get JetStream context: %w", err)
+	}
+	p.js = js
+	p.ackTimeout = ackTimeout
+	p.dedup = dedup
+	p.stream = stream
+	return nil
+}
+
+// EnsureStream creates the stream capturing subjects if it doesn't exist yet. An
+// existing stream is left as it is.
+func (p *Publisher) EnsureStream(name string, subjects []string, duplicateWindow time.Duration) error {
+	js, err := p.conn.JetStream()
+	if err != nil {
+		return fmt.Errorf("failed to get JetStream context: %w", err)
+	}
+	if _, err := js.StreamInfo(name); err == nil {
+		return nil
+	} else if !errors.Is(err, nats.ErrStreamNotFound) {
+		return fmt.Errorf("failed to get stream '%s': %w", name, err)
+	}
+
+	if _, err := js.AddStream(&nats.StreamConfig{
+		Name:       name,
+		Subjects:   subjects,
+		Duplicates: duplicateWindow,
+	}); err != nil {
+		return fmt.Errorf("failed to create stream '%s': %w", name, err)
+	}
+	p.logger.Infof("Created JetStream stream '%s' for subjects %s", name, strings.Join(subjects, ", "))
 	return nil
 }
 
+// jsOpts returns the publish options for a change event: with JetStream enabled,
+// wait for the stream's ack and let it drop duplicates
+func (p *Publisher) jsOpts(dedupID string) []nats.PubOpt {
+	if p.js == nil {
+		return nil
+	}
+	opts := []nats.PubOpt{nats.AckWait(p.ackTimeout)}
+	if p.dedup && dedupID != "" {
+		opts = append(opts, nats.MsgId(dedupID))
+	}
+	if p.stream != "" {
+		opts = append(opts, nats.ExpectStream(p.stream))
+	}
+	return opts
+}
+
+// publish sends data to the subject with the given headers, attaching signature headers if
+// signing is enabled. With JetStream publish options, the message is published to JetStream and acked.
+func (p *Publisher) publish(subject string, data []byte, headers map[string]string, jsOpts ...nats.PubOpt) error {
+	start := time.Now()
+	defer func() {
+		p.lastLatency.Store(int64(time.Since(start)))
+	}()
+
+	if p.signer == nil && len(headers) == 0 && len(jsOpts) == 0 {
+		return p.conn.Publish(subject, data)
+	}
+
+	msg := nats.NewMsg(subject)
+	msg.Data = data
+	for key, value := range headers {
+		msg.Header.Set(key, value)
+	}
+	if p.signer != nil {
+		for key, value := range p.signer.Headers(data) {
+			msg.Header.Set(key, value)
+		}
+	}
+
+	if len(jsOpts) > 0 {
+		ack, err := p.js.PublishMsg(msg, jsOpts...)
+		if err != nil {
+			return err
+		}
+		if ack.Duplicate {
+			p.logger.Debugf("JetStream dropped duplicate message (stream %s, seq %d)", ack.Stream, ack.Sequence)
+		}
+		return nil
+	}
+	return p.conn.PublishMsg(msg)
+}
+
+// MaxPayload returns the largest message the server accepts, headers included
+func (p *Publisher) MaxPayload() int64 {
+	return p.conn.MaxPayload()
+}
+
 // Close closes the NATS connection
 func (p *Publisher) Close() {
 	if p.conn != nil {
@@ -84,4 +364,3 @@ func (p *Publisher) Close() {
 func (p *Publisher) GetConn() *nats.Conn {
 	return p.conn
 }
-
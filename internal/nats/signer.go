@@ -0,0 +1,120 @@
+package nats
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+
+	"mysql-cdc/internal/config"
+)
+
+const (
+	// SigningAlgorithmHMAC signs payloads with HMAC-SHA256 using a shared secret
+	SigningAlgorithmHMAC = "hmac-sha256"
+	// SigningAlgorithmEd25519 signs payloads with an Ed25519 private key
+	SigningAlgorithmEd25519 = "ed25519"
+
+	defaultSignatureHeader = "Cdc-Signature"
+)
+
+// Signer computes payload signatures that are attached to published messages as headers
+type Signer struct {
+	algorithm  string
+	header     string
+	keyID      string
+	hmacKey    []byte
+	privateKey ed25519.PrivateKey
+}
+
+// NewSigner creates a signer from the signing configuration.
+// Returns nil if signing is not enabled.
+func NewSigner(cfg *config.SigningConfig) (*Signer, error) {
+	if cfg == nil || !cfg.Enabled {
+		return nil, nil
+	}
+
+	key, err := loadSigningKey(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	signer := &Signer{
+		algorithm: strings.ToLower(cfg.Algorithm),
+		header:    cfg.Header,
+		keyID:     cfg.KeyID,
+	}
+	if signer.algorithm == "" {
+		signer.algorithm = SigningAlgorithmHMAC
+	}
+	if signer.header == "" {
+		signer.header = defaultSignatureHeader
+	}
+
+	switch signer.algorithm {
+	case SigningAlgorithmHMAC:
+		signer.hmacKey = key
+	case SigningAlgorithmEd25519:
+		// The key is base64 encoded and may be either a 32-byte seed or a 64-byte private key
+		raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(key)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode ed25519 key: %w", err)
+		}
+		switch len(raw) {
+		case ed25519.SeedSize:
+			signer.privateKey = ed25519.NewKeyFromSeed(raw)
+		case ed25519.PrivateKeySize:
+			signer.privateKey = ed25519.PrivateKey(raw)
+		default:
+			return nil, fmt.Errorf("invalid ed25519 key length: %d bytes", len(raw))
+		}
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm: %s", cfg.Algorithm)
+	}
+
+	return signer, nil
+}
+
+// loadSigningKey reads the signing key from the config or the configured key file
+func loadSigningKey(cfg *config.SigningConfig) ([]byte, error) {
+	if cfg.KeyFile != "" {
+		data, err := os.ReadFile(cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read signing key file: %w", err)
+		}
+		return []byte(strings.TrimSpace(string(data))), nil
+	}
+	if cfg.Key == "" {
+		return nil, fmt.Errorf("signing is enabled but no key or key_file is configured")
+	}
+	return []byte(cfg.Key), nil
+}
+
+// Sign returns the base64-encoded signature of the payload
+func (s *Signer) Sign(data []byte) string {
+	var sig []byte
+	switch s.algorithm {
+	case SigningAlgorithmEd25519:
+		sig = ed25519.Sign(s.privateKey, data)
+	default:
+		mac := hmac.New(sha256.New, s.hmacKey)
+		mac.Write(data)
+		sig = mac.Sum(nil)
+	}
+	return base64.StdEncoding.EncodeToString(sig)
+}
+
+// Headers returns the signature headers for the payload
+func (s *Signer) Headers(data []byte) map[string]string {
+	headers := map[string]string{
+		s.header:                s.Sign(data),
+		s.header + "-Algorithm": s.algorithm,
+	}
+	if s.keyID != "" {
+		headers[s.header+"-Key-Id"] = s.keyID
+	}
+	return headers
+}
@@ -0,0 +1,103 @@
+package nats
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"mysql-cdc/internal/config"
+	"mysql-cdc/internal/models"
+)
+
+// SlowSinkMonitor raises an alert when NATS can't keep up with the publish rate
+// for longer than the configured duration
+type SlowSinkMonitor struct {
+	publisher *Publisher
+	config    *config.SlowSinkConfig
+	logger    *logrus.Logger
+}
+
+// NewSlowSinkMonitor creates a new slow sink monitor
+func NewSlowSinkMonitor(publisher *Publisher, cfg *config.SlowSinkConfig, logger *logrus.Logger) *SlowSinkMonitor {
+	return &SlowSinkMonitor{
+		publisher: publisher,
+		config:    cfg,
+		logger:    logger,
+	}
+}
+
+// Run checks the sink every second until the context is cancelled
+func (m *SlowSinkMonitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	var slowSince time.Time
+	alerting := false
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			slow, reason := m.check()
+			if !slow {
+				if alerting {
+					m.logger.Infof("NATS sink recovered after %s", time.Since(slowSince).Round(time.Second))
+					m.alert(models.AlertSeverityInfo, "slow_sink_resolved", "NATS sink recovered", nil)
+				}
+				slowSince = time.Time{}
+				alerting = false
+				continue
+			}
+
+			if slowSince.IsZero() {
+				slowSince = time.Now()
+			}
+			if !alerting && time.Since(slowSince) >= m.config.Duration {
+				alerting = true
+				m.logger.Warnf("NATS sink is slow for %s: %s", m.config.Duration, reason)
+				m.alert(models.AlertSeverityWarning, "slow_sink", reason, map[string]interface{}{
+					"since": slowSince.Unix(),
+				})
+			}
+		}
+	}
+}
+
+// check reports whether the sink is currently slow and why
+func (m *SlowSinkMonitor) check() (bool, string) {
+	if m.publisher.slowConsumer.Swap(false) {
+		return true, "NATS reported slow consumer"
+	}
+
+	if m.config.BufferedBytes > 0 {
+		if buffered, err := m.publisher.conn.Buffered(); err == nil && buffered >= m.config.BufferedBytes {
+			return true, "outbound buffer above threshold"
+		}
+	}
+
+	if m.config.PublishLatency > 0 {
+		if latency := time.Duration(m.publisher.lastLatency.Load()); latency >= m.config.PublishLatency {
+			return true, "publish latency above threshold"
+		}
+	}
+
+	return false, ""
+}
+
+// alert publishes an alert event to the alerts subject
+func (m *SlowSinkMonitor) alert(severity, name, message string, details map[string]interface{}) {
+	event := &models.AlertEvent{
+		Type:      "ALERT",
+		Timestamp: time.Now().Unix(),
+		Severity:  severity,
+		Name:      name,
+		Message:   message,
+		Details:   details,
+	}
+	m.publisher.notifyAlert(event)
+	if err := m.publisher.PublishJSON(m.config.Subject, event); err != nil {
+		m.logger.Warnf("Failed to publish slow sink alert: %v", err)
+	}
+}
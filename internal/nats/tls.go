@@ -0,0 +1,29 @@
+package nats
+
+import (
+	"crypto/tls"
+
+	"github.com/nats-io/nats.go"
+
+	"mysql-cdc/internal/config"
+)
+
+// TLSOptions returns the connection options of nats.tls. TLS is required once any
+// of its settings is set; a tls:// URL requires it with the system CAs as well.
+// The CA and client certificate files are read again on each reconnection.
+func TLSOptions(cfg *config.NATSTLSConfig) []nats.Option {
+	if cfg.CAFile == "" && cfg.CertFile == "" && !cfg.InsecureSkipVerify {
+		return nil
+	}
+	opts := []nats.Option{nats.Secure(&tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	})}
+	if cfg.CAFile != "" {
+		opts = append(opts, nats.RootCAs(cfg.CAFile))
+	}
+	if cfg.CertFile != "" {
+		opts = append(opts, nats.ClientCert(cfg.CertFile, cfg.KeyFile))
+	}
+	return opts
+}
@@ -0,0 +1,130 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"mysql-cdc/internal/config"
+	"mysql-cdc/internal/models"
+)
+
+// Notification names sent by the service itself, in addition to alert names
+const (
+	Started = "started"
+	Stopped = "stopped"
+	Failed  = "failed"
+)
+
+// Notification is the body posted to a generic webhook
+type Notification struct {
+	models.AlertEvent
+	Host string `json:"host,omitempty"`
+}
+
+// Notifier posts service lifecycle events and operational alerts to a webhook
+type Notifier struct {
+	config *config.NotificationsConfig
+	events map[string]bool // Notification names to send
+	client *http.Client
+	host   string
+	logger *logrus.Logger
+}
+
+// NewNotifier creates a new notifier. Returns nil if no webhook is configured.
+func NewNotifier(cfg *config.NotificationsConfig, logger *logrus.Logger) *Notifier {
+	if cfg.WebhookURL == "" {
+		return nil
+	}
+
+	events := make(map[string]bool, len(cfg.Events))
+	for _, name := range cfg.Events {
+		events[name] = true
+	}
+	host, _ := os.Hostname()
+
+	return &Notifier{
+		config: cfg,
+		events: events,
+		client: &http.Client{Timeout: cfg.Timeout},
+		host:   host,
+		logger: logger,
+	}
+}
+
+// Notify sends a notification if its name is one of the configured events.
+// Does nothing on a nil notifier.
+func (n *Notifier) Notify(severity, name, message string, details map[string]interface{}) {
+	if n == nil {
+		return
+	}
+	n.Alert(&models.AlertEvent{
+		Type:      "ALERT",
+		Timestamp: time.Now().Unix(),
+		Severity:  severity,
+		Name:      name,
+		Message:   message,
+		Details:   details,
+	})
+}
+
+// Alert forwards an operational alert if its name is one of the configured events
+func (n *Notifier) Alert(event *models.AlertEvent) {
+	if !n.events[event.Name] {
+		return
+	}
+
+	var body interface{} = &Notification{AlertEvent: *event, Host: n.host}
+	if n.config.Format == "slack" {
+		body = map[string]string{"text": slackText(event, n.host)}
+	}
+	if err := n.post(body); err != nil {
+		n.logger.Warnf("Failed to send %s notification: %v", event.Name, err)
+	}
+}
+
+// post sends v as JSON to the webhook
+func (n *Notifier) post(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification: %w", err)
+	}
+	resp, err := n.client.Post(n.config.WebhookURL, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// slackText formats an alert as a Slack message
+func slackText(event *models.AlertEvent, host string) string {
+	icon := ":information_source:"
+	switch event.Severity {
+	case models.AlertSeverityWarning:
+		icon = ":warning:"
+	case models.AlertSeverityCritical:
+		icon = ":rotating_light:"
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s *mysql-cdc %s* on `%s`: %s", icon, event.Name, host, event.Message)
+	keys := make([]string, 0, len(event.Details))
+	for k := range event.Details {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&b, "\n• %s: `%v`", k, event.Details[k])
+	}
+	return b.String()
+}
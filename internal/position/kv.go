@@ -0,0 +1,75 @@
+package position
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+	"github.com/sirupsen/logrus"
+
+	"mysql-cdc/internal/config"
+	cdcnats "mysql-cdc/internal/nats"
+)
+
+// kvStore keeps the position under a key of a JetStream KV bucket
+type kvStore struct {
+	conn   *nats.Conn
+	kv     nats.KeyValue
+	bucket string
+	key    string
+}
+
+func newKVStore(url string, auth *config.NATSAuthConfig, tlsCfg *config.NATSTLSConfig, bucket, key string, logger *logrus.Logger) (Store, error) {
+	opts, err := cdcnats.AuthOptions(auth)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, cdcnats.TLSOptions(tlsCfg)...)
+	conn, err := nats.Connect(url, append(opts, nats.Name("mysql-cdc position store"))...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+	js, err := conn.JetStream()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to get JetStream context: %w", err)
+	}
+
+	kv, err := js.KeyValue(bucket)
+	if errors.Is(err, nats.ErrBucketNotFound) {
+		kv, err = js.CreateKeyValue(&nats.KeyValueConfig{Bucket: bucket, History: 1})
+		if err == nil {
+			logger.Infof("Created KV bucket '%s' for binlog positions", bucket)
+		}
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to get KV bucket '%s': %w", bucket, err)
+	}
+	return &kvStore{conn: conn, kv: kv, bucket: bucket, key: key}, nil
+}
+
+func (s *kvStore) Load() ([]byte, error) {
+	entry, err := s.kv.Get(s.key)
+	if errors.Is(err, nats.ErrKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get KV key '%s': %w", s.key, err)
+	}
+	return entry.Value(), nil
+}
+
+func (s *kvStore) Save(data []byte) error {
+	if _, err := s.kv.Put(s.key, data); err != nil {
+		return fmt.Errorf("failed to put KV key '%s': %w", s.key, err)
+	}
+	return nil
+}
+
+func (s *kvStore) Close() error {
+	s.conn.Close()
+	return nil
+}
+
+func (s *kvStore) String() string { return fmt.Sprintf("NATS KV %s/%s", s.bucket, s.key) }
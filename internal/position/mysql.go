@@ -0,0 +1,77 @@
+package position
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/sirupsen/logrus"
+
+	"mysql-cdc/internal/config"
+	"mysql-cdc/internal/mysql"
+)
+
+// mysqlStore keeps the position in a row of a MySQL table
+type mysqlStore struct {
+	db    *sql.DB
+	table string // Quoted `database`.`table`
+	key   string
+}
+
+// newMySQLStore connects with the mysql.tls settings, like the other MySQL connections
+func newMySQLStore(cfg *config.PositionMySQLStoreConfig, tlsCfg *config.MySQLTLSConfig, key string, logger *logrus.Logger) (Store, error) {
+	dsn := mysqldriver.NewConfig()
+	dsn.User = cfg.User
+	dsn.Passwd = cfg.Password
+	dsn.Net = "tcp"
+	dsn.Addr = fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
+	db, err := mysql.OpenDB(dsn, tlsCfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database connection: %w", err)
+	}
+	db.SetMaxOpenConns(1)
+
+	database, table, _ := strings.Cut(cfg.Table, ".")
+	s := &mysqlStore{db: db, table: quoteName(database) + "." + quoteName(table), key: key}
+	if _, err := db.Exec("CREATE TABLE IF NOT EXISTS " + s.table + ` (
+		name VARCHAR(255) NOT NULL PRIMARY KEY,
+		position TEXT NOT NULL,
+		updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+	)`); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create position table %s: %w", cfg.Table, err)
+	}
+	logger.Infof("Persisting binlog positions to MySQL table %s on %s:%d", cfg.Table, cfg.Host, cfg.Port)
+	return s, nil
+}
+
+func (s *mysqlStore) Load() ([]byte, error) {
+	var data string
+	err := s.db.QueryRow("SELECT position FROM "+s.table+" WHERE name = ?", s.key).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read position: %w", err)
+	}
+	return []byte(data), nil
+}
+
+func (s *mysqlStore) Save(data []byte) error {
+	_, err := s.db.Exec("INSERT INTO "+s.table+" (name, position) VALUES (?, ?) ON DUPLICATE KEY UPDATE position = VALUES(position)",
+		s.key, string(data))
+	return err
+}
+
+func (s *mysqlStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *mysqlStore) String() string { return fmt.Sprintf("MySQL table %s, key %s", s.table, s.key) }
+
+// quoteName quotes a database or table name as an identifier
+func quoteName(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
@@ -0,0 +1,53 @@
+package position
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+
+	"mysql-cdc/internal/config"
+	"mysql-cdc/internal/redis"
+)
+
+// redisStore keeps the position under a Redis key
+type redisStore struct {
+	cfg    *config.PositionRedisConfig
+	key    string
+	client *redis.Client
+}
+
+func newRedisStore(cfg *config.PositionRedisConfig, key string, logger *logrus.Logger) (Store, error) {
+	client, err := redis.Dial(redis.Options{
+		Address:  cfg.Address,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+		Timeout:  cfg.Timeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+	logger.Infof("Persisting binlog positions to Redis at %s, key %s", cfg.Address, key)
+	return &redisStore{cfg: cfg, key: key, client: client}, nil
+}
+
+func (s *redisStore) Load() ([]byte, error) {
+	reply, err := s.client.Do("GET", s.key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read position from Redis: %w", err)
+	}
+	data, _ := reply.([]byte)
+	return data, nil
+}
+
+func (s *redisStore) Save(data []byte) error {
+	if _, err := s.client.Do("SET", s.key, string(data)); err != nil {
+		return fmt.Errorf("failed to save position to Redis: %w", err)
+	}
+	return nil
+}
+
+func (s *redisStore) Close() error {
+	return s.client.Close()
+}
+
+func (s *redisStore) String() string { return fmt.Sprintf("Redis %s, key %s", s.cfg.Address, s.key) }
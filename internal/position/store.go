@@ -0,0 +1,68 @@
+package position
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/sirupsen/logrus"
+
+	"mysql-cdc/internal/config"
+)
+
+// Store persists the binlog position. The data is the position file's content:
+// "filename:position" and, on a second line, the GTID set read up to it.
+type Store interface {
+	Load() ([]byte, error) // nil if no position has been saved
+	Save(data []byte) error
+	Close() error
+}
+
+// NewStore creates the position store configured in binlog.position_store.
+// File stores use path; an empty path persists nothing and returns nil.
+func NewStore(cfg *config.Config, path string, logger *logrus.Logger) (Store, error) {
+	storeCfg := &cfg.Binlog.PositionStore
+	switch storeCfg.Type {
+	case "nats_kv":
+		return newKVStore(cfg.NATS.URL, &cfg.NATS.Auth, &cfg.NATS.TLS, storeCfg.NATSKV.Bucket, storeCfg.Key, logger)
+	case "mysql":
+		return newMySQLStore(&storeCfg.MySQL, &cfg.MySQL.TLS, storeCfg.Key, logger)
+	case "redis":
+		return newRedisStore(&storeCfg.Redis, storeCfg.Key, logger)
+	default:
+		if path == "" {
+			return nil, nil
+		}
+		return &FileStore{path: path}, nil
+	}
+}
+
+// FileStore keeps the position in a local file
+type FileStore struct {
+	path string
+}
+
+// NewFileStore creates a store keeping the position in the file at path
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+func (s *FileStore) Load() ([]byte, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read position file: %w", err)
+	}
+	return data, nil
+}
+
+func (s *FileStore) Save(data []byte) error {
+	return os.WriteFile(s.path, data, 0644)
+}
+
+func (s *FileStore) Close() error { return nil }
+
+// String describes the store in logs
+func (s *FileStore) String() string { return "file " + s.path }
@@ -0,0 +1,22 @@
+package processor
+
+import (
+	"github.com/go-mysql-org/go-mysql/mysql"
+
+	"mysql-cdc/internal/models"
+)
+
+// Alerter publishes operational alerts
+type Alerter interface {
+	Alert(severity, name, message string, details map[string]interface{})
+}
+
+// alertPurged raises the alert for a binlog that's no longer on the server. The
+// stream can't continue without a new snapshot or a later start position.
+func alertPurged(alerter Alerter, position mysql.Position, err error) {
+	alerter.Alert(models.AlertSeverityCritical, "binlog_purged", "Binlog needed to continue is no longer on the server", map[string]interface{}{
+		"binlog_file": position.Name,
+		"binlog_pos":  position.Pos,
+		"error":       err.Error(),
+	})
+}
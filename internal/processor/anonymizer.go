@@ -0,0 +1,144 @@
+package processor
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Anonymizer presets that can be assigned to columns in processor rules
+const (
+	AnonymizeFakeName   = "fake_name"
+	AnonymizeFakeEmail  = "fake_email"
+	AnonymizeFakePhone  = "fake_phone"
+	AnonymizeHash       = "hash"
+	AnonymizeDateJitter = "date_jitter"
+)
+
+// defaultDateJitterDays is the maximum date shift (in either direction) for date_jitter
+const defaultDateJitterDays = 30
+
+var (
+	fakeFirstNames = []string{
+		"Alex", "Blake", "Casey", "Dana", "Eli", "Frankie", "Gray", "Harper",
+		"Indy", "Jordan", "Kai", "Logan", "Morgan", "Noel", "Parker", "Quinn",
+		"Riley", "Sage", "Taylor", "Val",
+	}
+	fakeLastNames = []string{
+		"Adams", "Baker", "Carter", "Diaz", "Evans", "Fisher", "Garcia", "Hughes",
+		"Ito", "Jensen", "Khan", "Lopez", "Murphy", "Nguyen", "Olsen", "Patel",
+		"Reyes", "Smith", "Turner", "Wright",
+	}
+
+	dateLayouts = []string{
+		"2006-01-02 15:04:05.999999",
+		"2006-01-02 15:04:05",
+		time.RFC3339Nano,
+		"2006-01-02",
+	}
+)
+
+// Anonymizer replaces column values with deterministic fake data.
+// The same input value (and salt) always produces the same output, so joins
+// and lookups across anonymized streams keep working.
+type Anonymizer struct {
+	preset     string
+	jitterDays int
+	salt       string
+}
+
+// NewAnonymizer parses a preset specification such as "fake_email" or "date_jitter:7"
+func NewAnonymizer(spec, salt string) (*Anonymizer, error) {
+	preset, arg, hasArg := strings.Cut(strings.TrimSpace(spec), ":")
+	a := &Anonymizer{
+		preset: strings.ToLower(preset),
+		salt:   salt,
+	}
+
+	switch a.preset {
+	case AnonymizeFakeName, AnonymizeFakeEmail, AnonymizeFakePhone, AnonymizeHash:
+		if hasArg {
+			return nil, fmt.Errorf("anonymizer '%s' does not take an argument", a.preset)
+		}
+	case AnonymizeDateJitter:
+		a.jitterDays = defaultDateJitterDays
+		if hasArg {
+			days, err := strconv.Atoi(arg)
+			if err != nil || days <= 0 {
+				return nil, fmt.Errorf("invalid date_jitter days: %s", arg)
+			}
+			a.jitterDays = days
+		}
+	default:
+		return nil, fmt.Errorf("unknown anonymizer preset: %s", preset)
+	}
+
+	return a, nil
+}
+
+// Apply returns the anonymized form of value. NULL values are left as-is.
+func (a *Anonymizer) Apply(value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+
+	sum := a.digest(value)
+	n := binary.BigEndian.Uint64(sum[:8])
+
+	switch a.preset {
+	case AnonymizeFakeName:
+		return fmt.Sprintf("%s %s",
+			fakeFirstNames[n%uint64(len(fakeFirstNames))],
+			fakeLastNames[(n/uint64(len(fakeFirstNames)))%uint64(len(fakeLastNames))])
+	case AnonymizeFakeEmail:
+		return fmt.Sprintf("user_%s@example.com", hex.EncodeToString(sum[:6]))
+	case AnonymizeFakePhone:
+		// 555 numbers are reserved for fictional use
+		return fmt.Sprintf("+1-555-%03d-%04d", n%1000, (n/1000)%10000)
+	case AnonymizeHash:
+		return hex.EncodeToString(sum[:])
+	case AnonymizeDateJitter:
+		return a.jitterDate(value, n)
+	}
+
+	return value
+}
+
+// digest hashes the value together with the salt
+func (a *Anonymizer) digest(value interface{}) [sha256.Size]byte {
+	var s string
+	switch v := value.(type) {
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	default:
+		s = fmt.Sprint(v)
+	}
+	return sha256.Sum256([]byte(a.salt + s))
+}
+
+// jitterDate shifts a date/datetime value by a deterministic number of days
+func (a *Anonymizer) jitterDate(value interface{}, n uint64) interface{} {
+	offset := time.Duration(int64(n%uint64(2*a.jitterDays+1))-int64(a.jitterDays)) * 24 * time.Hour
+
+	switch v := value.(type) {
+	case time.Time:
+		return v.Add(offset)
+	case []byte:
+		return a.jitterDate(string(v), n)
+	case string:
+		for _, layout := range dateLayouts {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t.Add(offset).Format(layout)
+			}
+		}
+	}
+
+	// Unknown representation - don't leak the original value
+	return nil
+}
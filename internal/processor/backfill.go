@@ -0,0 +1,140 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"mysql-cdc/internal/models"
+)
+
+// Backfill publishes the rows of a table whose primary key is between from and to
+// (inclusive) as SNAPSHOT events, through the same routing, limits, transforms and
+// delivery as binlog events. Rows are read in primary key order, batchSize at a
+// time, and each batch becomes one event. Returns the number of rows published.
+func (p *Processor) Backfill(ctx context.Context, database, table, from, to string, batchSize int) (int, error) {
+	info, err := p.getColumnInfo(database, table)
+	if err != nil {
+		return 0, err
+	}
+	if len(info.names) == 0 {
+		return 0, fmt.Errorf("table %s.%s not found", database, table)
+	}
+	if len(info.primaryKeys) != 1 {
+		return 0, fmt.Errorf("backfill needs a single-column primary key, %s.%s has %d primary key columns",
+			database, table, len(info.primaryKeys))
+	}
+	pk := info.primaryKeys[0]
+	pkIndex := 0
+	columns := make([]string, len(info.names))
+	for i, name := range info.names {
+		columns[i] = quoteIdentifier(name)
+		if name == pk {
+			pkIndex = i
+		}
+	}
+
+	// The first batch starts at from, the next ones after the last key read
+	selectRows := fmt.Sprintf("SELECT %s FROM %s.%s WHERE %s %%s ? AND %s <= ? ORDER BY %s LIMIT %d",
+		strings.Join(columns, ", "), quoteIdentifier(database), quoteIdentifier(table),
+		quoteIdentifier(pk), quoteIdentifier(pk), quoteIdentifier(pk), batchSize)
+	firstBatch := fmt.Sprintf(selectRows, ">=")
+	nextBatch := fmt.Sprintf(selectRows, ">")
+
+	p.startDelivery(ctx)
+
+	total := 0
+	query := firstBatch
+	var last interface{} = from
+	for ctx.Err() == nil {
+		select {
+		case err := <-p.failed:
+			return total, err
+		default:
+		}
+
+		var rows []map[string]interface{}
+		var err error
+		rows, last, err = p.readBatch(ctx, query, info, pkIndex, last, to)
+		if err != nil {
+			return total, err
+		}
+		if len(rows) == 0 {
+			break
+		}
+
+		event := p.snapshotEvent(database, table, info, rows)
+		event.DedupID = sourceDedupID(p.config.SourceName, fmt.Sprintf("snapshot:%s:%v", tableKey(database, table), rows[0][pk]))
+		p.announceSchema(database, table, info)
+		p.emit(ctx, event)
+
+		total += len(rows)
+		p.logger.Infof("Backfilled %d rows of %s.%s (up to %s = %v)", total, database, table, pk, rows[len(rows)-1][pk])
+		if len(rows) < batchSize {
+			break
+		}
+		query = nextBatch
+	}
+
+	if err := p.drain(ctx); err != nil {
+		return total, err
+	}
+	return total, ctx.Err()
+}
+
+// snapshotEvent builds a SNAPSHOT event for rows read from a table
+func (p *Processor) snapshotEvent(database, table string, info *columnInfo, rows []map[string]interface{}) *models.ChangeEvent {
+	now := time.Now()
+	return &models.ChangeEvent{
+		ID:            p.eventID("", 0),
+		Type:          "SNAPSHOT",
+		Database:      database,
+		Table:         table,
+		Timestamp:     models.NewTimestamp(now),
+		ProcessedTS:   now.UnixMilli(),
+		Rows:          rows,
+		OldRows:       make([]map[string]interface{}, 0),
+		PrimaryKey:    info.primaryKeys,
+		SchemaVersion: info.version,
+		Schema:        p.eventSchema(database, table, info),
+	}
+}
+
+// readBatch reads one batch of rows, returning them with the raw primary key
+// value of the last row to continue from
+func (p *Processor) readBatch(ctx context.Context, query string, info *columnInfo, pkIndex int, after interface{}, to string) ([]map[string]interface{}, interface{}, error) {
+	result, err := p.db.QueryContext(ctx, query, after, to)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read rows: %w", err)
+	}
+	defer result.Close()
+
+	var rows []map[string]interface{}
+	var last interface{}
+	values := make([]interface{}, len(info.names))
+	dest := make([]interface{}, len(values))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for result.Next() {
+		if err := result.Scan(dest...); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		row := make(map[string]interface{}, len(values))
+		for i, name := range info.names {
+			row[name] = p.columnValue(values[i], info.types[i], time.UTC)
+		}
+		rows = append(rows, row)
+		last = values[pkIndex]
+	}
+	if err := result.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	return rows, last, nil
+}
+
+// quoteIdentifier quotes a database, table or column name for use in a query
+func quoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
@@ -0,0 +1,178 @@
+package processor
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"mysql-cdc/internal/config"
+	"mysql-cdc/internal/models"
+)
+
+// BatchPublisher publishes several change events as one message (nats.batch)
+type BatchPublisher interface {
+	PublishBatch(subject string, events []*models.ChangeEvent) error
+}
+
+// Batcher collects change events per subject and publishes them in batches once
+// nats.batch.max_events events are collected, and every nats.batch.interval.
+// Events are done once their batch has been published.
+type Batcher struct {
+	config  *config.BatchConfig
+	flush   func(ctx context.Context, subject string, b *batch)
+	mu      sync.Mutex
+	pending map[string]*batch // By subject; "" is nats.subject
+}
+
+// batch is the events collected for one subject
+type batch struct {
+	events []*models.ChangeEvent
+	done   []func() // Called once the batch has been published
+}
+
+// NewBatcher creates a batcher that publishes batches with flush
+func NewBatcher(cfg *config.BatchConfig, flush func(ctx context.Context, subject string, b *batch)) *Batcher {
+	return &Batcher{
+		config:  cfg,
+		flush:   flush,
+		pending: make(map[string]*batch),
+	}
+}
+
+// Add adds the events produced from one change event to their subjects' batches.
+// done is called once all of them have been published.
+func (b *Batcher) Add(ctx context.Context, events []*models.ChangeEvent, done func()) {
+	if len(events) == 0 {
+		done()
+		return
+	}
+	subjects := make(map[string]bool)
+	for _, event := range events {
+		subjects[event.Subject] = true
+	}
+	remaining := int32(len(subjects))
+	partDone := func() {
+		if atomic.AddInt32(&remaining, -1) == 0 {
+			done()
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, event := range events {
+		pending, ok := b.pending[event.Subject]
+		if !ok {
+			pending = &batch{}
+			b.pending[event.Subject] = pending
+		}
+		pending.events = append(pending.events, event)
+	}
+	for subject := range subjects {
+		pending := b.pending[subject]
+		pending.done = append(pending.done, partDone)
+		// Flushed under the lock, so batches of a subject are published in order
+		if len(pending.events) >= b.config.MaxEvents {
+			delete(b.pending, subject)
+			b.flush(ctx, subject, pending)
+		}
+	}
+}
+
+// Run publishes pending batches every interval until the context is cancelled
+func (b *Batcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(b.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			b.Flush(ctx)
+		}
+	}
+}
+
+// Flush publishes all pending batches
+func (b *Batcher) Flush(ctx context.Context) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for subject, pending := range b.pending {
+		delete(b.pending, subject)
+		b.flush(ctx, subject, pending)
+	}
+}
+
+// flushBatch publishes a batch and completes its events. A batch that fails and
+// isn't retried is published event by event, so the publish error policy applies
+// to each event on its own.
+func (p *Processor) flushBatch(ctx context.Context, subject string, b *batch) {
+	result := p.publishBatch(ctx, subject, b.events)
+	if result == outcomeStopped && p.commits != nil {
+		// Not published: the position stays before the batch's events
+		return
+	}
+	for _, done := range b.done {
+		done()
+	}
+}
+
+// publishBatch publishes a batch, retrying it while the publish error policy is retry
+func (p *Processor) publishBatch(ctx context.Context, subject string, events []*models.ChangeEvent) outcome {
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		err := p.batchPublisher.PublishBatch(subject, events)
+		if p.metrics != nil {
+			p.metrics.Timing("publish.latency", time.Since(start))
+		}
+		if err == nil {
+			p.logger.Infof("Published batch of %d events", len(events))
+			p.count("batches.published")
+			for _, event := range events {
+				p.countPublished(event)
+			}
+			return outcomeOK
+		}
+		p.logger.Errorf("Error publishing batch of %d events: %v", len(events), err)
+		if ctx.Err() != nil {
+			return outcomeStopped
+		}
+		if p.config.Errors.Publish.OnError != OnErrorRetry {
+			break
+		}
+		if result := p.onError(ctx, letterFor(StagePublish, events[0]), attempt, err); result != outcomeRetry {
+			return result
+		}
+	}
+
+	for _, event := range events {
+		log := p.logger.WithFields(event.LogFields())
+		switch p.publish(ctx, event, log) {
+		case outcomeOK:
+			p.countPublished(event)
+		case outcomeStopped:
+			return outcomeStopped
+		}
+	}
+	return outcomeOK
+}
+
+// countPublished counts a published event in the table stats and reports it to
+// the metrics exporter
+func (p *Processor) countPublished(event *models.ChangeEvent) {
+	p.stats.add(event)
+	if p.metrics != nil {
+		tags := eventTags(event)
+		p.metrics.Count("events.published", 1, tags...)
+		p.metrics.Count("rows.published", int64(len(event.Rows)), tags...)
+		if event.CommitTS > 0 {
+			p.metrics.Timing("events.commit_lag", time.Since(time.UnixMilli(event.CommitTS)))
+		}
+	}
+}
+
+// batchable reports whether an event's table is published in batches: throttled
+// tables are published event by event at their own rate
+func (p *Processor) batchable(event *models.ChangeEvent) bool {
+	return p.batcher != nil && p.throttler.match(event.Database, event.Table) == nil
+}
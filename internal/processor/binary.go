@@ -0,0 +1,74 @@
+package processor
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Binary column output modes of the binary rule setting
+const (
+	BinaryBase64   = "base64"
+	BinaryHex      = "hex"
+	BinarySkip     = "skip"
+	BinaryTruncate = "truncate"
+)
+
+// BinaryEncoder controls how a binary column (BLOB, BINARY, VARBINARY) is
+// published: base64 or hex encoded, left out, or truncated to its first bytes
+type BinaryEncoder struct {
+	mode  string
+	limit int // Bytes kept by truncate
+}
+
+// NewBinaryEncoder parses a binary output mode: base64, hex, skip or truncate:N
+func NewBinaryEncoder(spec string) (*BinaryEncoder, error) {
+	mode, arg, hasArg := strings.Cut(strings.TrimSpace(spec), ":")
+	e := &BinaryEncoder{mode: mode}
+	switch mode {
+	case BinaryBase64, BinaryHex, BinarySkip:
+		if hasArg {
+			return nil, fmt.Errorf("binary mode %s takes no argument", mode)
+		}
+	case BinaryTruncate:
+		limit, err := strconv.Atoi(arg)
+		if err != nil || limit <= 0 {
+			return nil, fmt.Errorf("invalid number of bytes for binary mode truncate: %q", arg)
+		}
+		e.limit = limit
+	default:
+		return nil, fmt.Errorf("unknown binary mode %q (valid: base64, hex, skip, truncate:N)", spec)
+	}
+	return e, nil
+}
+
+// Apply returns the value's bytes encoded by the mode, and false if the column is
+// to be left out. NULL values are left as-is.
+func (e *BinaryEncoder) Apply(value interface{}) (interface{}, bool) {
+	if e.mode == BinarySkip {
+		return nil, false
+	}
+	if value == nil {
+		return nil, true
+	}
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		data = []byte(fmt.Sprint(v))
+	}
+	switch e.mode {
+	case BinaryHex:
+		return hex.EncodeToString(data), true
+	case BinaryTruncate:
+		if len(data) > e.limit {
+			data = data[:e.limit]
+		}
+	}
+	return base64.StdEncoding.EncodeToString(data), true
+}
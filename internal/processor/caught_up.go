@@ -0,0 +1,63 @@
+package processor
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+
+	"mysql-cdc/internal/models"
+)
+
+// SetMasterPosition sets the function used to query the master's current binlog
+// position, which the caught-up signal compares the read position against
+func (p *Processor) SetMasterPosition(masterPos func() (mysql.Position, error)) {
+	p.masterPos = masterPos
+}
+
+// CaughtUp reports whether the stream has caught up with the master's live position
+func (p *Processor) CaughtUp() bool {
+	return p.caughtUp.Load()
+}
+
+// runCaughtUp polls the master position until the reader reaches it, then publishes
+// a one-time caught-up event so orchestrators waiting on a backfill can proceed
+func (p *Processor) runCaughtUp(ctx context.Context) {
+	started := time.Now()
+	ticker := time.NewTicker(p.config.CaughtUp.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			master, err := p.masterPos()
+			if err != nil {
+				p.logger.Warnf("Failed to get master position: %v", err)
+				continue
+			}
+			pos := p.reader.Position()
+			if pos.Compare(master) < 0 {
+				p.logger.Debugf("Catching up: at %s:%d, master at %s:%d", pos.Name, pos.Pos, master.Name, master.Pos)
+				continue
+			}
+
+			p.caughtUp.Store(true)
+			p.logger.Infof("Caught up with master at %s:%d after %s", master.Name, master.Pos, time.Since(started).Round(time.Second))
+			event := &models.CaughtUpEvent{
+				Type:       "CAUGHT_UP",
+				Timestamp:  time.Now().Unix(),
+				BinlogFile: pos.Name,
+				BinlogPos:  pos.Pos,
+				MasterFile: master.Name,
+				MasterPos:  master.Pos,
+				StartedAt:  started.Unix(),
+			}
+			if err := p.publisher.PublishJSON(p.config.CaughtUp.Subject, event); err != nil {
+				p.logger.Warnf("Failed to publish caught-up event: %v", err)
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package processor
+
+import (
+	"context"
+	"maps"
+
+	"mysql-cdc/internal/models"
+)
+
+// coalesce emits a change event, or with events.coalesce holds it back to merge
+// following single-row events of the same table and transaction into it. ORMs that
+// issue one statement per row otherwise produce one message per row.
+func (p *Processor) coalesce(ctx context.Context, event *models.ChangeEvent) {
+	cfg := &p.config.Events.Coalesce
+	if !cfg.Enabled {
+		p.emit(ctx, event)
+		return
+	}
+
+	if pending := p.coalesced; pending != nil {
+		if len(event.Rows) == 1 &&
+			len(pending.Rows) < cfg.MaxRows &&
+			pending.TransactionID == event.TransactionID &&
+			pending.Type == event.Type &&
+			pending.Database == event.Database &&
+			pending.Table == event.Table &&
+			pending.SchemaVersion == event.SchemaVersion &&
+			maps.Equal(pending.QueryContext, event.QueryContext) {
+			pending.Rows = append(pending.Rows, event.Rows...)
+			pending.OldRows = append(pending.OldRows, event.OldRows...)
+			pending.BinlogPos = event.BinlogPos
+			return
+		}
+		p.flushCoalesced(ctx)
+	}
+
+	// Only events inside a transaction are held back, as its end flushes them
+	if len(event.Rows) == 1 && event.TransactionID != "" {
+		p.coalesced = event
+		return
+	}
+	p.emit(ctx, event)
+}
+
+// flushCoalesced emits the event being coalesced, if any
+func (p *Processor) flushCoalesced(ctx context.Context) {
+	if p.coalesced == nil {
+		return
+	}
+	event := p.coalesced
+	p.coalesced = nil
+	p.emit(ctx, event)
+}
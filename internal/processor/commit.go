@@ -0,0 +1,82 @@
+package processor
+
+import (
+	"sync"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/sirupsen/logrus"
+)
+
+// CommitTracker persists binlog positions only once every event read before them
+// has been delivered. Events and checkpoints (transaction boundaries) are
+// registered in binlog order; a checkpoint is committed when all events
+// registered before it are done, however the pipeline reorders delivery.
+type CommitTracker struct {
+	mu          sync.Mutex
+	next        uint64                    // Sequence of the next registered item
+	base        uint64                    // Lowest sequence not yet done
+	done        map[uint64]bool           // Done items at or above base
+	checkpoints map[uint64]mysql.Position // Checkpoint positions by sequence
+	commit      func(mysql.Position) error
+	logger      *logrus.Logger
+}
+
+// NewCommitTracker creates a tracker that calls commit with each committable position
+func NewCommitTracker(commit func(mysql.Position) error, logger *logrus.Logger) *CommitTracker {
+	return &CommitTracker{
+		done:        make(map[uint64]bool),
+		checkpoints: make(map[uint64]mysql.Position),
+		commit:      commit,
+		logger:      logger,
+	}
+}
+
+// Track registers an event and returns the function to call once it's delivered
+func (t *CommitTracker) Track() func() {
+	t.mu.Lock()
+	seq := t.next
+	t.next++
+	t.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { t.finish(seq) })
+	}
+}
+
+// Checkpoint registers a position that can be committed once all earlier events are done
+func (t *CommitTracker) Checkpoint(pos mysql.Position) {
+	t.mu.Lock()
+	seq := t.next
+	t.next++
+	t.checkpoints[seq] = pos
+	t.mu.Unlock()
+
+	t.finish(seq)
+}
+
+// finish marks an item done and commits the latest checkpoint that became reachable
+func (t *CommitTracker) finish(seq uint64) {
+	t.mu.Lock()
+	t.done[seq] = true
+
+	var pos mysql.Position
+	advanced := false
+	for t.done[t.base] {
+		delete(t.done, t.base)
+		if p, ok := t.checkpoints[t.base]; ok {
+			delete(t.checkpoints, t.base)
+			pos = p
+			advanced = true
+		}
+		t.base++
+	}
+
+	// Commit under the lock so positions are persisted in order
+	if advanced {
+		if err := t.commit(pos); err != nil {
+			t.logger.Warnf("Failed to commit position %s:%d: %v", pos.Name, pos.Pos, err)
+		}
+	}
+	t.mu.Unlock()
+}
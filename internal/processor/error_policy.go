@@ -0,0 +1,180 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"mysql-cdc/internal/config"
+	"mysql-cdc/internal/models"
+)
+
+// Error policies (errors.<stage>.on_error)
+const (
+	OnErrorFail  = "fail"
+	OnErrorSkip  = "skip"
+	OnErrorDLQ   = "dlq"
+	OnErrorRetry = "retry"
+)
+
+// Pipeline stages with their own error policy
+const (
+	StageDecode    = "decode"
+	StageTransform = "transform"
+	StagePublish   = "publish"
+)
+
+// outcome is the result of running an event through a pipeline stage
+type outcome int
+
+const (
+	outcomeOK      outcome = iota // Stage succeeded
+	outcomeRetry                  // Stage should be run again
+	outcomeDropped                // Event was skipped or dead-lettered
+	outcomeStopped                // Service is failing or shutting down
+)
+
+// policy returns the error policy of a pipeline stage
+func (p *Processor) policy(stage string) *config.ErrorPolicyConfig {
+	switch stage {
+	case StageDecode:
+		return &p.config.Errors.Decode
+	case StageTransform:
+		return &p.config.Errors.Transform
+	default:
+		return &p.config.Errors.Publish
+	}
+}
+
+// onError applies the stage's error policy to a failed attempt at an event.
+// letter describes the event in case it's dead-lettered.
+func (p *Processor) onError(ctx context.Context, letter *models.DeadLetterEvent, attempt int, err error) outcome {
+	p.reportError(letter.Stage+":"+tableKey(letter.Database, letter.Table), err, letterContext(letter))
+
+	policy := p.policy(letter.Stage)
+	p.count("errors", "stage:"+letter.Stage, "policy:"+policy.OnError)
+	switch policy.OnError {
+	case OnErrorRetry:
+		if policy.MaxRetries > 0 && attempt > policy.MaxRetries {
+			p.fail(fmt.Errorf("%s failed after %d attempts: %w", letter.Stage, attempt, err))
+			return outcomeStopped
+		}
+		select {
+		case <-ctx.Done():
+			return outcomeStopped
+		case <-time.After(p.config.Delivery.RetryInterval):
+			return outcomeRetry
+		}
+	case OnErrorDLQ:
+		letter.Type = "DEAD_LETTER"
+		letter.Timestamp = time.Now().Unix()
+		letter.Error = err.Error()
+		letter.Attempts = attempt
+		if !p.deadLetter(ctx, letter) {
+			return outcomeStopped
+		}
+		return outcomeDropped
+	case OnErrorFail:
+		p.fail(fmt.Errorf("%s failed: %w", letter.Stage, err))
+		return outcomeStopped
+	default:
+		return outcomeDropped
+	}
+}
+
+// letterLog returns a log entry with the fields of the dead letter's event
+func (p *Processor) letterLog(letter *models.DeadLetterEvent) *logrus.Entry {
+	if letter.Event != nil {
+		return p.logger.WithFields(letter.Event.LogFields())
+	}
+	return p.logger.WithFields(logrus.Fields{
+		"database": letter.Database,
+		"table":    letter.Table,
+		"position": fmt.Sprintf("%s:%d", letter.BinlogFile, letter.BinlogPos),
+	})
+}
+
+// deadLetter publishes a failed event to the dead-letter subject, or writes it to
+// the dead-letter file, reporting whether it was stored. Like change events,
+// failed publishes are retried unless positions are persisted as events are read.
+func (p *Processor) deadLetter(ctx context.Context, letter *models.DeadLetterEvent) bool {
+	for {
+		var err error
+		if p.deadLetterFile != nil {
+			err = p.writeDeadLetter(letter)
+		} else {
+			err = p.publisher.PublishJSON(p.config.Errors.DeadLetterSubject, letter)
+		}
+		if err == nil {
+			p.count("events.dead_lettered", "stage:"+letter.Stage)
+			p.letterLog(letter).Warnf("Dead-lettered %s.%s event at %s:%d after %s error",
+				letter.Database, letter.Table, letter.BinlogFile, letter.BinlogPos, letter.Stage)
+			return true
+		}
+		p.letterLog(letter).Errorf("Error dead-lettering event: %v", err)
+		if p.commits == nil || ctx.Err() != nil {
+			return false
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(p.config.Delivery.RetryInterval):
+		}
+	}
+}
+
+// writeDeadLetter appends a dead letter to the dead-letter file as a JSON line
+func (p *Processor) writeDeadLetter(letter *models.DeadLetterEvent) error {
+	data, err := json.Marshal(letter)
+	if err != nil {
+		return fmt.Errorf("failed to marshal dead letter: %w", err)
+	}
+	p.deadLetterMu.Lock()
+	defer p.deadLetterMu.Unlock()
+	_, err = p.deadLetterFile.Write(append(data, '\n'))
+	return err
+}
+
+// fail stops the service with the given error. Only the first error is kept.
+func (p *Processor) fail(err error) {
+	select {
+	case p.failed <- err:
+	default:
+	}
+}
+
+// letterFor describes a change event for the dead-letter subject
+func letterFor(stage string, event *models.ChangeEvent) *models.DeadLetterEvent {
+	return &models.DeadLetterEvent{
+		Stage:      stage,
+		Database:   event.Database,
+		Table:      event.Table,
+		BinlogFile: event.BinlogFile,
+		BinlogPos:  event.BinlogPos,
+		Event:      event,
+	}
+}
+
+// transform runs a change event through the transformer, applying the transform error policy
+func (p *Processor) transform(ctx context.Context, event *models.ChangeEvent, log *logrus.Entry) ([]*models.ChangeEvent, outcome) {
+	for attempt := 1; ; attempt++ {
+		events, err := p.transformer.Transform(event)
+		if err == nil {
+			return events, outcomeOK
+		}
+		// Rejected events aren't errors, they're just not published
+		if errors.Is(err, ErrEventRejected) {
+			log.Debugf("Event rejected by transformer: %s.%s (type: %s)", event.Database, event.Table, event.Type)
+			p.count("events.rejected", eventTags(event)...)
+			return nil, outcomeDropped
+		}
+		log.Errorf("Error transforming event: %v", err)
+		if result := p.onError(ctx, letterFor(StageTransform, event), attempt, err); result != outcomeRetry {
+			return nil, result
+		}
+	}
+}
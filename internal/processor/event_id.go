@@ -0,0 +1,68 @@
+package processor
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"mysql-cdc/internal/models"
+)
+
+// crockford is the Crockford base32 alphabet used by ULIDs
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// newULID returns a ULID: a 48-bit millisecond timestamp followed by 80 random
+// bits, encoded as 26 Crockford base32 characters that sort by creation time
+func newULID(now time.Time) string {
+	var id [16]byte
+	binary.BigEndian.PutUint64(id[:8], uint64(now.UnixMilli())<<16)
+	if _, err := rand.Read(id[6:]); err != nil {
+		// crypto/rand doesn't fail on supported platforms; fall back to the clock
+		binary.BigEndian.PutUint64(id[8:], uint64(now.UnixNano()))
+	}
+
+	// 128 bits in 26 characters of 5 bits, the first character carrying 3 bits
+	var out [26]byte
+	hi := binary.BigEndian.Uint64(id[:8])
+	lo := binary.BigEndian.Uint64(id[8:])
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
+
+// eventID builds the ID of a change event. With the gtid format, events of GTID
+// transactions get "<gtid>/<n>", n counting row events within the transaction, so
+// the ID is stable when the event is read again; otherwise a ULID is generated.
+func (p *Processor) eventID(gtid string, seq int) string {
+	if p.config.Events.ID == "gtid" && gtid != "" {
+		return fmt.Sprintf("%s/%d", gtid, seq)
+	}
+	return newULID(time.Now())
+}
+
+// correlationID takes a change event's correlation ID from the configured statement
+// comment annotation or row column, in that order. Transform scripts can override it.
+func (p *Processor) correlationID(event *models.ChangeEvent) string {
+	cfg := &p.config.Events.Correlation
+	if cfg.QueryContext != "" {
+		if v := event.QueryContext[cfg.QueryContext]; v != "" {
+			return v
+		}
+	}
+	if cfg.Column != "" && len(event.Rows) > 0 {
+		for col, v := range event.Rows[0] {
+			if v == nil || !namesEqual(col, cfg.Column) {
+				continue
+			}
+			if b, ok := v.([]byte); ok {
+				return string(b)
+			}
+			return fmt.Sprint(v)
+		}
+	}
+	return ""
+}
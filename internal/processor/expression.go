@@ -0,0 +1,455 @@
+package processor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Expression is a row condition of a processor rule (where), e.g.
+//
+//	row.status == 'active' && row.amount > 100
+//
+// Operands are row.<column> (old.<column> for the old row of an UPDATE), string,
+// number, true, false and null literals. Operators are ==, !=, <, <=, >, >=, &&,
+// ||, ! and parentheses. Values are compared as numbers when both sides are
+// numeric (numeric strings included), otherwise as strings; null only equals null.
+// A bare operand is true unless it's null, false, 0 or empty.
+type Expression struct {
+	source  string
+	root    exprNode
+	columns []string // Columns referred to, lowercased
+}
+
+// exprNode is a node of a parsed expression
+type exprNode interface {
+	eval(row, old map[string]interface{}) interface{}
+}
+
+// ParseExpression parses a row condition
+func ParseExpression(source string) (*Expression, error) {
+	tokens, err := tokenizeExpression(source)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at position %d", tok.text, tok.pos)
+	}
+	return &Expression{source: source, root: root, columns: p.columns}, nil
+}
+
+// Match evaluates the expression on a row and, for UPDATE events, its old row
+func (e *Expression) Match(row, old map[string]interface{}) bool {
+	return truthy(e.root.eval(row, old))
+}
+
+// Columns returns the lowercased names of the columns the expression refers to
+func (e *Expression) Columns() []string { return e.columns }
+
+// String returns the expression's source
+func (e *Expression) String() string { return e.source }
+
+// Token kinds
+const (
+	tokEOF = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOp
+)
+
+type exprToken struct {
+	kind int
+	text string
+	pos  int
+}
+
+// tokenizeExpression splits an expression into identifiers (dotted names and
+// keywords), string and number literals, and operators
+func tokenizeExpression(source string) ([]exprToken, error) {
+	var tokens []exprToken
+	runes := []rune(source)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '\'' || r == '"':
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(runes) && runes[j] != r; j++ {
+				if runes[j] == '\\' && j+1 < len(runes) {
+					j++
+				}
+				b.WriteRune(runes[j])
+			}
+			if j >= len(runes) {
+				return nil, fmt.Errorf("unterminated string at position %d", i)
+			}
+			tokens = append(tokens, exprToken{kind: tokString, text: b.String(), pos: i})
+			i = j + 1
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1]) && startsOperand(tokens)):
+			j := i + 1
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, exprToken{kind: tokNumber, text: string(runes[i:j]), pos: i})
+			i = j
+		case unicode.IsLetter(r) || r == '_':
+			j := i + 1
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_' || runes[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, exprToken{kind: tokIdent, text: string(runes[i:j]), pos: i})
+			i = j
+		default:
+			op := ""
+			if i+1 < len(runes) {
+				switch two := string(runes[i : i+2]); two {
+				case "==", "!=", "<=", ">=", "&&", "||":
+					op = two
+				}
+			}
+			if op == "" {
+				switch r {
+				case '<', '>', '!', '(', ')':
+					op = string(r)
+				default:
+					return nil, fmt.Errorf("unexpected %q at position %d", r, i)
+				}
+			}
+			tokens = append(tokens, exprToken{kind: tokOp, text: op, pos: i})
+			i += len(op)
+		}
+	}
+	return append(tokens, exprToken{kind: tokEOF, text: "end of expression", pos: len(runes)}), nil
+}
+
+// startsOperand reports whether the next token is an operand, so a minus sign
+// there is part of a negative number
+func startsOperand(tokens []exprToken) bool {
+	if len(tokens) == 0 {
+		return true
+	}
+	last := tokens[len(tokens)-1]
+	return last.kind == tokOp && last.text != ")"
+}
+
+// exprParser is a recursive descent parser over the tokens of an expression:
+//
+//	or         = and { "||" and }
+//	and        = unary { "&&" unary }
+//	unary      = "!" unary | comparison
+//	comparison = operand [ ( "==" | "!=" | "<" | "<=" | ">" | ">=" ) operand ]
+//	operand    = "(" or ")" | literal | row.<column> | old.<column>
+type exprParser struct {
+	tokens  []exprToken
+	pos     int
+	columns []string
+}
+
+func (p *exprParser) peek() exprToken { return p.tokens[p.pos] }
+
+func (p *exprParser) next() exprToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// accept consumes the next token if it's one of the given operators
+func (p *exprParser) accept(ops ...string) (string, bool) {
+	tok := p.peek()
+	if tok.kind != tokOp {
+		return "", false
+	}
+	for _, op := range ops {
+		if tok.text == op {
+			p.pos++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("||"); !ok {
+			return left, nil
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{or: true, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("&&"); !ok {
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if _, ok := p.accept("!"); ok {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand: operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *exprParser) parseComparison() (exprNode, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	op, ok := p.accept("==", "!=", "<", "<=", ">", ">=")
+	if !ok {
+		return left, nil
+	}
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return &compareNode{op: op, left: left, right: right}, nil
+}
+
+func (p *exprParser) parseOperand() (exprNode, error) {
+	if _, ok := p.accept("("); ok {
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := p.accept(")"); !ok {
+			tok := p.peek()
+			return nil, fmt.Errorf("expected ) at position %d, got %q", tok.pos, tok.text)
+		}
+		return inner, nil
+	}
+
+	tok := p.next()
+	switch tok.kind {
+	case tokString:
+		return literalNode{value: tok.text}, nil
+	case tokNumber:
+		n, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at position %d", tok.text, tok.pos)
+		}
+		return literalNode{value: n}, nil
+	case tokIdent:
+		switch tok.text {
+		case "true":
+			return literalNode{value: true}, nil
+		case "false":
+			return literalNode{value: false}, nil
+		case "null":
+			return literalNode{value: nil}, nil
+		}
+		if column, ok := strings.CutPrefix(tok.text, "row."); ok && column != "" {
+			p.columns = append(p.columns, strings.ToLower(column))
+			return columnNode{column: column}, nil
+		}
+		if column, ok := strings.CutPrefix(tok.text, "old."); ok && column != "" {
+			p.columns = append(p.columns, strings.ToLower(column))
+			return columnNode{column: column, old: true}, nil
+		}
+		return nil, fmt.Errorf("unknown name %q at position %d, columns are row.<column> or old.<column>", tok.text, tok.pos)
+	}
+	return nil, fmt.Errorf("expected a value at position %d, got %q", tok.pos, tok.text)
+}
+
+// literalNode is a string, number (float64), boolean or null literal
+type literalNode struct {
+	value interface{}
+}
+
+func (n literalNode) eval(row, old map[string]interface{}) interface{} { return n.value }
+
+// columnNode is a column of the row or old row, matched case-insensitively
+type columnNode struct {
+	column string
+	old    bool
+}
+
+func (n columnNode) eval(row, old map[string]interface{}) interface{} {
+	if n.old {
+		row = old
+	}
+	if v, ok := row[n.column]; ok {
+		return v
+	}
+	for name, v := range row {
+		if strings.EqualFold(name, n.column) {
+			return v
+		}
+	}
+	return nil
+}
+
+type logicalNode struct {
+	or          bool
+	left, right exprNode
+}
+
+func (n *logicalNode) eval(row, old map[string]interface{}) interface{} {
+	left := truthy(n.left.eval(row, old))
+	if n.or == left {
+		return left
+	}
+	return truthy(n.right.eval(row, old))
+}
+
+type notNode struct {
+	operand exprNode
+}
+
+func (n *notNode) eval(row, old map[string]interface{}) interface{} {
+	return !truthy(n.operand.eval(row, old))
+}
+
+type compareNode struct {
+	op          string
+	left, right exprNode
+}
+
+func (n *compareNode) eval(row, old map[string]interface{}) interface{} {
+	left, right := n.left.eval(row, old), n.right.eval(row, old)
+	if left == nil || right == nil {
+		// null only equals null and is neither less nor greater than anything
+		switch n.op {
+		case "==":
+			return left == nil && right == nil
+		case "!=":
+			return (left == nil) != (right == nil)
+		}
+		return false
+	}
+
+	var cmp int
+	if a, ok := exprNumber(left); ok {
+		if b, ok := exprNumber(right); ok {
+			switch {
+			case a < b:
+				cmp = -1
+			case a > b:
+				cmp = 1
+			}
+			return compareResult(n.op, cmp)
+		}
+	}
+	if a, ok := left.(bool); ok {
+		b, ok := right.(bool)
+		equal := ok && a == b
+		return (n.op == "==" && equal) || (n.op == "!=" && !equal)
+	}
+	cmp = strings.Compare(exprString(left), exprString(right))
+	return compareResult(n.op, cmp)
+}
+
+// compareResult applies a comparison operator to the result of a three-way comparison
+func compareResult(op string, cmp int) bool {
+	switch op {
+	case "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default:
+		return cmp >= 0
+	}
+}
+
+// exprNumber returns a value as a number, if it's numeric or a numeric string
+func exprNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case bool:
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(exprString(v)), 64)
+	return f, err == nil
+}
+
+// exprString returns a value as a string for comparisons
+func exprString(v interface{}) string {
+	switch s := v.(type) {
+	case string:
+		return s
+	case []byte:
+		return string(s)
+	}
+	return fmt.Sprint(v)
+}
+
+// truthy returns the truth value of an operand
+func truthy(v interface{}) bool {
+	switch b := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return b
+	case string:
+		return b != ""
+	case []byte:
+		return len(b) > 0
+	}
+	if n, ok := exprNumber(v); ok {
+		return n != 0
+	}
+	return true
+}
@@ -0,0 +1,124 @@
+package processor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpressionMatch(t *testing.T) {
+	row := map[string]interface{}{
+		"status":  "active",
+		"amount":  int64(150),
+		"balance": "-20.5",
+		"note":    nil,
+		"Name":    "O'Brien",
+		"deleted": false,
+		"data":    []byte("x"),
+	}
+	old := map[string]interface{}{"status": "pending", "amount": int64(90)}
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		// Comparisons
+		{"row.status == 'active'", true},
+		{`row.status == "active"`, true},
+		{"row.status != 'active'", false},
+		{"row.amount > 100", true},
+		{"row.amount >= 150", true},
+		{"row.amount < 150", false},
+		{"row.amount <= 150.0", true},
+		{"row.amount == '150'", true},
+		{"row.status < 'b'", true},
+
+		// Precedence: && binds tighter than ||, ! applies to a whole comparison
+		{"row.amount > 100 || row.status == 'x' && false", true},
+		{"(row.amount > 100 || row.status == 'x') && false", false},
+		{"false && false || true", true},
+		{"!row.deleted", true},
+		{"!row.deleted && row.amount > 100", true},
+		{"!(row.amount > 100)", false},
+		{"!row.amount == 150", false},
+		{"!!row.status", true},
+		{"!row.note", true},
+
+		// Negative numbers after operators and parentheses
+		{"row.balance < -20", true},
+		{"row.balance == -20.5", true},
+		{"row.amount > -1 && (-5 < row.amount)", true},
+		{"!(-1 > 0)", true},
+
+		// Null comparisons: null only equals null, and isn't ordered
+		{"row.note == null", true},
+		{"row.note != null", false},
+		{"row.missing == null", true},
+		{"row.status != null", true},
+		{"row.note < 1", false},
+		{"row.note > 1", false},
+		{"null == null", true},
+
+		// Quoted and escaped strings
+		{`row.name == 'O\'Brien'`, true},
+		{`row.name == "O'Brien"`, true},
+		{`'a\\b' == "a\\b"`, true},
+		{`'it\'s' != "it's"`, false},
+
+		// Old row of an UPDATE, case-insensitive column names
+		{"old.status == 'pending' && row.status == 'active'", true},
+		{"old.amount < row.amount", true},
+		{"row.NAME == 'O\\'Brien'", true},
+
+		// Bare operands
+		{"row.status", true},
+		{"row.note", false},
+		{"row.data", true},
+		{"0", false},
+		{"''", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			expr, err := ParseExpression(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseExpression() error: %v", err)
+			}
+			if got := expr.Match(row, old); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseExpressionErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"row.amount >",
+		"row.amount > > 1",
+		"(row.amount > 1",
+		"row.amount > 1)",
+		"row.amount = 1",
+		"row.amount - 1 > 0",
+		"amount > 1",
+		"row. > 1",
+		"'unterminated",
+		"row.a == 1.2.3",
+		"row.a == 1 row.b == 2",
+		"row.a & row.b",
+		"len(row.a) > 1",
+	}
+	for _, source := range tests {
+		if _, err := ParseExpression(source); err == nil {
+			t.Errorf("ParseExpression(%q) succeeded, want an error", source)
+		}
+	}
+}
+
+func TestExpressionColumns(t *testing.T) {
+	expr, err := ParseExpression("row.Status == 'a' || old.amount > 1 && row.x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := expr.Columns(), []string{"status", "amount", "x"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Columns() = %v, want %v", got, want)
+	}
+}
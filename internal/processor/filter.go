@@ -0,0 +1,181 @@
+package processor
+
+import (
+	"path/filepath"
+	"strings"
+
+	"mysql-cdc/internal/config"
+)
+
+// systemSchemas are MySQL's internal databases, excluded unless configured otherwise
+var systemSchemas = map[string]bool{
+	"mysql":              true,
+	"sys":                true,
+	"information_schema": true,
+	"performance_schema": true,
+}
+
+// Filter decides which change events are published
+type Filter struct {
+	includeSystemSchemas bool
+	tables               []config.TableRef // Tables to publish (empty = all)
+	include              []tablePattern    // Table globs to publish (empty = all)
+	exclude              []tablePattern    // Table globs never published
+	sampling             []*sampleRule
+	serverIDs            map[uint32]bool // Originating server IDs to publish (nil = all)
+	domainIDs            map[uint32]bool // MariaDB GTID domain IDs to publish (nil = all)
+}
+
+// tablePattern is a "database.table" glob; a pattern without a dot matches every
+// table of the databases it matches
+type tablePattern struct {
+	database string
+	table    string
+}
+
+// sampleRule publishes one in every rate events for matching tables
+type sampleRule struct {
+	database string
+	table    string
+	rate     uint64
+	counters map[string]uint64 // Events seen per "database.table"
+}
+
+// NewFilter creates a new event filter from the filters configuration
+func NewFilter(cfg *config.FiltersConfig) *Filter {
+	f := &Filter{
+		includeSystemSchemas: cfg.IncludeSystemSchemas,
+		tables:               cfg.Tables,
+	}
+	for _, pattern := range cfg.Patterns {
+		excluded := strings.HasPrefix(pattern, "!")
+		database, table, ok := strings.Cut(strings.TrimPrefix(pattern, "!"), ".")
+		if !ok {
+			table = "*"
+		}
+		if excluded {
+			f.exclude = append(f.exclude, tablePattern{database: database, table: table})
+		} else {
+			f.include = append(f.include, tablePattern{database: database, table: table})
+		}
+	}
+	if len(cfg.ServerIDs) > 0 {
+		f.serverIDs = make(map[uint32]bool, len(cfg.ServerIDs))
+		for _, id := range cfg.ServerIDs {
+			f.serverIDs[id] = true
+		}
+	}
+	if len(cfg.GTIDDomainIDs) > 0 {
+		f.domainIDs = make(map[uint32]bool, len(cfg.GTIDDomainIDs))
+		for _, id := range cfg.GTIDDomainIDs {
+			f.domainIDs[id] = true
+		}
+	}
+	for _, s := range cfg.Sampling {
+		if s.Rate <= 1 {
+			continue
+		}
+		f.sampling = append(f.sampling, &sampleRule{
+			database: s.Database,
+			table:    s.Table,
+			rate:     uint64(s.Rate),
+			counters: make(map[string]uint64),
+		})
+	}
+	return f
+}
+
+// Allow reports whether events for the table should be processed at all.
+// An empty table matches on the database alone (e.g. for statements).
+func (f *Filter) Allow(database, table string) bool {
+	if !f.includeSystemSchemas && systemSchemas[strings.ToLower(database)] {
+		return false
+	}
+	if !f.allowPattern(database, table) {
+		return false
+	}
+	if len(f.tables) == 0 {
+		return true
+	}
+	for _, ref := range f.tables {
+		if table == "" {
+			if ref.Database == "" || namesEqual(ref.Database, database) {
+				return true
+			}
+			continue
+		}
+		if matchesTable(ref.Database, ref.Table, database, table) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowPattern checks a table against the include and exclude globs. With an
+// empty table, a database passes if an include pattern matches it and it isn't
+// excluded as a whole ("db.*" or "db").
+func (f *Filter) allowPattern(database, table string) bool {
+	for _, p := range f.exclude {
+		if !globMatch(p.database, database) {
+			continue
+		}
+		if table == "" && p.table == "*" || table != "" && globMatch(p.table, table) {
+			return false
+		}
+	}
+	if len(f.include) == 0 {
+		return true
+	}
+	for _, p := range f.include {
+		if globMatch(p.database, database) && (table == "" || globMatch(p.table, table)) {
+			return true
+		}
+	}
+	return false
+}
+
+// globMatch matches a database or table name against a glob, following the
+// server's name case sensitivity
+func globMatch(pattern, name string) bool {
+	if !caseSensitiveNames.Load() {
+		pattern, name = strings.ToLower(pattern), strings.ToLower(name)
+	}
+	matched, _ := filepath.Match(pattern, name)
+	return matched
+}
+
+// AllowServer reports whether events originating on the given server should be published
+func (f *Filter) AllowServer(serverID uint32) bool {
+	return f.serverIDs == nil || f.serverIDs[serverID]
+}
+
+// AllowDomain reports whether MariaDB transactions in the given GTID domain should be published
+func (f *Filter) AllowDomain(domainID uint32) bool {
+	return f.domainIDs == nil || f.domainIDs[domainID]
+}
+
+// Sample reports whether an event for the table should be published under the sampling rules.
+// The first event of each table is always published, then one in every rate.
+func (f *Filter) Sample(database, table string) bool {
+	for _, rule := range f.sampling {
+		if !matchesTable(rule.database, rule.table, database, table) {
+			continue
+		}
+		key := tableKey(database, table)
+		n := rule.counters[key]
+		rule.counters[key] = n + 1
+		return n%rule.rate == 0
+	}
+	return true
+}
+
+// matchesTable checks a database/table pattern (empty = all) against a table
+func matchesTable(patternDB, patternTable, database, table string) bool {
+	if patternDB != "" && !namesEqual(patternDB, database) {
+		return false
+	}
+	if patternTable != "" && !namesEqual(patternTable, table) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,188 @@
+package processor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/dop251/goja"
+
+	"mysql-cdc/internal/config"
+)
+
+// httpBinding makes the requests of the http object of JavaScript scripts. Only
+// hosts of processor.http.allowed_hosts can be requested, redirects included, and
+// each request is bounded in time and response size.
+type httpBinding struct {
+	client  *http.Client
+	allowed []string // Lowercased host patterns
+	maxSize int
+}
+
+func newHTTPBinding(cfg *config.ProcessorHTTPConfig) *httpBinding {
+	b := &httpBinding{maxSize: cfg.MaxResponseSize}
+	for _, host := range cfg.AllowedHosts {
+		b.allowed = append(b.allowed, strings.ToLower(host))
+	}
+	b.client = &http.Client{
+		Timeout: cfg.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			return b.check(req.URL)
+		},
+	}
+	return b
+}
+
+// check returns an error unless the URL is an http(s) URL on an allowed host.
+// Patterns match a host on any port, host:port only on that port, and
+// *.example.com any subdomain of example.com.
+func (b *httpBinding) check(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported URL scheme %q", u.Scheme)
+	}
+	host := strings.ToLower(u.Hostname())
+	hostPort := strings.ToLower(u.Host)
+	for _, pattern := range b.allowed {
+		if pattern == host || pattern == hostPort {
+			return nil
+		}
+		if suffix, ok := strings.CutPrefix(pattern, "*."); ok && strings.HasSuffix(host, "."+suffix) {
+			return nil
+		}
+	}
+	return fmt.Errorf("host %s is not in processor.http.allowed_hosts", u.Host)
+}
+
+// httpResponse is a response handed to scripts
+type httpResponse struct {
+	status  int
+	headers map[string]interface{}
+	body    []byte
+}
+
+// do makes a request and reads its response
+func (b *httpBinding) do(method, rawURL string, headers http.Header, body io.Reader) (*httpResponse, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL %q: %w", rawURL, err)
+	}
+	if err := b.check(u); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = headers
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, int64(b.maxSize)+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response from %s: %w", u.Host, err)
+	}
+	if len(data) > b.maxSize {
+		return nil, fmt.Errorf("response from %s exceeds processor.http.max_response_size (%d bytes)", u.Host, b.maxSize)
+	}
+
+	response := &httpResponse{status: resp.StatusCode, headers: make(map[string]interface{}, len(resp.Header)), body: data}
+	for key := range resp.Header {
+		response.headers[strings.ToLower(key)] = resp.Header.Get(key)
+	}
+	return response, nil
+}
+
+// setupHTTPBindings sets up the http JavaScript object:
+//
+//	http.get(url, options)     - GET request
+//	http.request(url, options) - request with options.method (default GET) and options.body
+//
+// options.headers sets request headers; object bodies are sent as JSON. Requests are
+// synchronous and return {status, ok, headers, body, json()}. Failed requests throw,
+// so the event is handled by errors.transform; HTTP error statuses don't.
+func (t *Transformer) setupHTTPBindings(vm *goja.Runtime) error {
+	request := func(name string, call goja.FunctionCall, method string) goja.Value {
+		rawURL := call.Argument(0).String()
+		if goja.IsUndefined(call.Argument(0)) || rawURL == "" {
+			panic(vm.NewTypeError("%s: url is required", name))
+		}
+
+		headers := make(http.Header)
+		var body io.Reader
+		if options, ok := call.Argument(1).Export().(map[string]interface{}); ok {
+			if m, ok := options["method"].(string); ok && method == "" {
+				method = strings.ToUpper(m)
+			}
+			if h, ok := options["headers"].(map[string]interface{}); ok {
+				for key, value := range h {
+					headers.Set(key, fmt.Sprint(value))
+				}
+			}
+			switch v := options["body"].(type) {
+			case nil:
+			case string:
+				body = strings.NewReader(v)
+			default:
+				data, err := json.Marshal(v)
+				if err != nil {
+					panic(vm.NewTypeError("%s: failed to marshal body: %v", name, err))
+				}
+				body = strings.NewReader(string(data))
+				if headers.Get("Content-Type") == "" {
+					headers.Set("Content-Type", "application/json")
+				}
+			}
+		}
+		if method == "" {
+			method = http.MethodGet
+		}
+
+		resp, err := t.http.do(method, rawURL, headers, body)
+		if err != nil {
+			t.logger.Errorf("JavaScript %s %s failed: %v", name, rawURL, err)
+			panic(vm.NewGoError(fmt.Errorf("%s: %w", name, err)))
+		}
+		t.logger.Debugf("JavaScript %s %s: %d (%d bytes)", name, rawURL, resp.status, len(resp.body))
+
+		result := vm.NewObject()
+		result.Set("status", resp.status)
+		result.Set("ok", resp.status >= 200 && resp.status < 300)
+		result.Set("headers", resp.headers)
+		result.Set("body", string(resp.body))
+		result.Set("json", func(goja.FunctionCall) goja.Value {
+			var v interface{}
+			if err := json.Unmarshal(resp.body, &v); err != nil {
+				panic(vm.NewGoError(fmt.Errorf("%s: response is not JSON: %w", name, err)))
+			}
+			return vm.ToValue(v)
+		})
+		return result
+	}
+
+	httpObj := vm.NewObject()
+	if err := httpObj.Set("get", func(call goja.FunctionCall) goja.Value {
+		return request("http.get", call, http.MethodGet)
+	}); err != nil {
+		return fmt.Errorf("failed to set get function: %w", err)
+	}
+	if err := httpObj.Set("request", func(call goja.FunctionCall) goja.Value {
+		return request("http.request", call, "")
+	}); err != nil {
+		return fmt.Errorf("failed to set request function: %w", err)
+	}
+	if err := vm.Set("http", httpObj); err != nil {
+		return fmt.Errorf("failed to set http object: %w", err)
+	}
+	return nil
+}
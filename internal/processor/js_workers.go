@@ -0,0 +1,76 @@
+package processor
+
+import (
+	"github.com/dop251/goja"
+
+	"mysql-cdc/internal/models"
+)
+
+// jsJob is a transformation request for the JavaScript worker pool
+type jsJob struct {
+	event  *models.ChangeEvent
+	result chan jsResult
+}
+
+// jsResult is the outcome of a JavaScript transformation
+type jsResult struct {
+	events []*models.ChangeEvent
+	err    error
+}
+
+// startJSWorkers starts a fixed pool of workers for a script, each owning a
+// persistent runtime. Runtimes are created up front so script errors surface at
+// startup. Because runtimes are reused, global state set by the script persists
+// across events.
+func (t *Transformer) startJSWorkers(script *jsScript, n int) error {
+	runtimes := make([]jsRuntime, 0, n)
+	for i := 0; i < n; i++ {
+		vm, callable, err := t.newJSRuntime(script)
+		if err != nil {
+			return err
+		}
+		runtimes = append(runtimes, jsRuntime{vm: vm, callable: callable})
+	}
+
+	jobs := make(chan jsJob, n)
+	script.jobs = jobs
+	for _, rt := range runtimes {
+		go func(vm *goja.Runtime, callable goja.Callable) {
+			for job := range jobs {
+				events, err := t.runJavaScript(vm, callable, job.event)
+				job.result <- jsResult{events: events, err: err}
+			}
+		}(rt.vm, rt.callable)
+	}
+	return nil
+}
+
+// Close stops the JavaScript worker pools, once transforms in progress are done
+func (t *Transformer) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	stopJSWorkers(t.jsScripts)
+}
+
+// stopJSWorkers stops the worker pools of scripts. Later transforms with them
+// run on pooled runtimes.
+func stopJSWorkers(scripts []*jsScript) {
+	for _, script := range scripts {
+		if script.jobs != nil {
+			close(script.jobs)
+			script.jobs = nil
+		}
+	}
+}
+
+// hasJSWorkers reports whether scripts run on worker pools (processor.workers)
+func (t *Transformer) hasJSWorkers() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for _, script := range t.jsScripts {
+		if script.jobs != nil {
+			return true
+		}
+	}
+	return false
+}
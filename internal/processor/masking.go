@@ -0,0 +1,112 @@
+package processor
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// maskChar replaces masked characters
+const maskChar = '*'
+
+// Masker masks column values while preserving their format: letters and digits
+// are replaced with *, other characters (separators such as - or spaces) are
+// kept, so 123-45-6789 becomes ***-**-6789 with the last 4 characters visible.
+type Masker struct {
+	keepLast int // Letters and digits left visible at the end of the value
+}
+
+// NewMasker parses a mask specification: a column name, optionally followed by
+// the number of trailing characters left visible ("card_number:4")
+func NewMasker(spec string) (column string, m *Masker, err error) {
+	column, arg, hasArg := strings.Cut(strings.TrimSpace(spec), ":")
+	if column == "" {
+		return "", nil, fmt.Errorf("mask entry %q has no column", spec)
+	}
+	m = &Masker{}
+	if hasArg {
+		m.keepLast, err = strconv.Atoi(arg)
+		if err != nil || m.keepLast < 0 {
+			return "", nil, fmt.Errorf("invalid number of visible characters for mask of '%s': %s", column, arg)
+		}
+	}
+	return column, m, nil
+}
+
+// Apply returns the masked form of value as a string. NULL values are left as-is.
+func (m *Masker) Apply(value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	var s string
+	switch v := value.(type) {
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	default:
+		s = fmt.Sprint(v)
+	}
+
+	runes := []rune(s)
+	visible := m.keepLast
+	for i := len(runes) - 1; i >= 0; i-- {
+		if !unicode.IsLetter(runes[i]) && !unicode.IsDigit(runes[i]) {
+			continue
+		}
+		if visible > 0 {
+			visible--
+			continue
+		}
+		runes[i] = maskChar
+	}
+	return string(runes)
+}
+
+// hashAlgorithms are the algorithms of the hash rule setting
+var hashAlgorithms = map[string]func() hash.Hash{
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+	"sha1":   sha1.New,
+	"md5":    md5.New,
+}
+
+// Hasher replaces column values with the hex digest of the salted value
+type Hasher struct {
+	newHash func() hash.Hash
+	salt    string
+}
+
+// NewHasher creates a hasher for an algorithm: sha256, sha512, sha1 or md5
+func NewHasher(algorithm, salt string) (*Hasher, error) {
+	newHash, ok := hashAlgorithms[strings.ToLower(strings.TrimSpace(algorithm))]
+	if !ok {
+		return nil, fmt.Errorf("unknown hash algorithm: %s (supported: sha256, sha512, sha1, md5)", algorithm)
+	}
+	return &Hasher{newHash: newHash, salt: salt}, nil
+}
+
+// Apply returns the hex digest of the value. NULL values are left as-is.
+func (h *Hasher) Apply(value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	digest := h.newHash()
+	digest.Write([]byte(h.salt))
+	switch v := value.(type) {
+	case []byte:
+		digest.Write(v)
+	case string:
+		digest.Write([]byte(v))
+	default:
+		digest.Write([]byte(fmt.Sprint(v)))
+	}
+	return hex.EncodeToString(digest.Sum(nil))
+}
@@ -0,0 +1,63 @@
+package processor
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"mysql-cdc/internal/models"
+)
+
+// Metrics receives the processor's counters, gauges and timings
+type Metrics interface {
+	Count(name string, value int64, tags ...string)
+	Gauge(name string, value float64, tags ...string)
+	Timing(name string, d time.Duration, tags ...string)
+}
+
+// SetMetrics sets the exporter the processor reports metrics to
+func (p *Processor) SetMetrics(metrics Metrics) {
+	p.metrics = metrics
+}
+
+// count adds to a counter, if metrics are exported
+func (p *Processor) count(name string, tags ...string) {
+	if p.metrics != nil {
+		p.metrics.Count(name, 1, tags...)
+	}
+}
+
+// eventTags returns the metric tags of a change event
+func eventTags(event *models.ChangeEvent) []string {
+	return []string{
+		"database:" + event.Database,
+		"table:" + event.Table,
+		"type:" + strings.ToLower(event.Type),
+	}
+}
+
+// runMetrics reports the processor's gauges every metrics.interval
+func (p *Processor) runMetrics(ctx context.Context) {
+	ticker := time.NewTicker(p.config.Metrics.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// Seconds between now and the binlog timestamp of the last event read
+			if ts := p.lastEventTS.Load(); ts > 0 {
+				p.metrics.Gauge("replication.lag_seconds", time.Since(time.Unix(ts, 0)).Seconds())
+			}
+			p.metrics.Gauge("binlog.position", float64(p.reader.Position().Pos), "binlog_file:"+p.reader.Position().Name)
+			if p.config.CaughtUp.Enabled {
+				caughtUp := 0.0
+				if p.caughtUp.Load() {
+					caughtUp = 1
+				}
+				p.metrics.Gauge("caught_up", caughtUp)
+			}
+		}
+	}
+}
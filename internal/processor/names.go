@@ -0,0 +1,35 @@
+package processor
+
+import (
+	"strings"
+	"sync/atomic"
+)
+
+// caseSensitiveNames controls database/table name matching. It mirrors the source
+// server's lower_case_table_names: 0 means names are case-sensitive, 1 and 2 mean
+// they're compared case-insensitively.
+var caseSensitiveNames atomic.Bool
+
+// SetCaseSensitiveNames sets whether database and table names are matched
+// case-sensitively by filters, rules and the column cache. Call it before
+// creating the transformer and processor.
+func SetCaseSensitiveNames(sensitive bool) {
+	caseSensitiveNames.Store(sensitive)
+}
+
+// namesEqual compares two database or table names
+func namesEqual(a, b string) bool {
+	if caseSensitiveNames.Load() {
+		return a == b
+	}
+	return strings.EqualFold(a, b)
+}
+
+// tableKey returns the "database.table" key used for per-table caches and state
+func tableKey(database, table string) string {
+	key := database + "." + table
+	if caseSensitiveNames.Load() {
+		return key
+	}
+	return strings.ToLower(key)
+}
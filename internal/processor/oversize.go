@@ -0,0 +1,246 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"mysql-cdc/internal/config"
+	"mysql-cdc/internal/models"
+)
+
+// Oversize strategies
+const (
+	OversizeSplit       = "split"
+	OversizeDropColumns = "drop_columns"
+	OversizeSubject     = "subject"
+	OversizeReference   = "reference"
+)
+
+// oversizeHeaderRoom is left for message headers below the server's max_payload
+const oversizeHeaderRoom = 1024
+
+// payloadLimiter is implemented by publishers that know the largest message the
+// server accepts
+type payloadLimiter interface {
+	MaxPayload() int64
+}
+
+// OversizeGuard checks the encoded size of events before they're published and
+// applies the limits.oversize strategy to those too large for the server
+type OversizeGuard struct {
+	config    *config.OversizeConfig
+	format    string // events.format
+	publisher Publisher
+	logger    *logrus.Logger
+}
+
+// NewOversizeGuard creates an oversize guard. Returns nil if no strategy is set.
+func NewOversizeGuard(cfg *config.OversizeConfig, format string, publisher Publisher, logger *logrus.Logger) *OversizeGuard {
+	if cfg.Strategy == "" {
+		return nil
+	}
+	return &OversizeGuard{config: cfg, format: format, publisher: publisher, logger: logger}
+}
+
+// maxSize returns the largest encoded event that can be published, or 0 if unknown
+func (g *OversizeGuard) maxSize() int {
+	if g.config.MaxSize > 0 {
+		return g.config.MaxSize
+	}
+	if limiter, ok := g.publisher.(payloadLimiter); ok {
+		if max := int(limiter.MaxPayload()) - oversizeHeaderRoom; max > 0 {
+			return max
+		}
+	}
+	return 0
+}
+
+// Apply returns the events to publish for an event, and whether it was oversized:
+// the event itself if it fits, its parts, the event without its largest columns
+// or a reference to it in the object store. With the subject strategy, an
+// oversized event is replaced by a notice on the oversize subject and nothing is
+// returned. Events the strategy can't bring under the limit are returned as they
+// are, and fail to publish.
+func (g *OversizeGuard) Apply(event *models.ChangeEvent) ([]*models.ChangeEvent, bool) {
+	max := g.maxSize()
+	if max <= 0 {
+		return []*models.ChangeEvent{event}, false
+	}
+	size := g.size(event)
+	if size <= max {
+		return []*models.ChangeEvent{event}, false
+	}
+	log := g.logger.WithFields(event.LogFields())
+	log.Warnf("%s event for %s.%s is %d bytes, over the max payload of %d bytes, applying '%s' strategy",
+		event.Type, event.Database, event.Table, size, max, g.config.Strategy)
+
+	switch g.config.Strategy {
+	case OversizeSplit:
+		if parts := g.split(event, max); parts != nil {
+			return parts, true
+		}
+	case OversizeDropColumns:
+		if g.dropColumns(event, max) {
+			return []*models.ChangeEvent{event}, true
+		}
+	case OversizeSubject:
+		notice := oversizeNotice(event, size, max)
+		if err := g.publisher.PublishJSON(g.config.Subject, notice); err != nil {
+			log.Errorf("Failed to publish oversize notice: %v", err)
+			return []*models.ChangeEvent{event}, true
+		}
+		return nil, true
+	case OversizeReference:
+		if ref := g.reference(event, size, max); ref != nil {
+			return []*models.ChangeEvent{ref}, true
+		}
+	}
+	log.Warnf("'%s' strategy couldn't bring the %s event for %s.%s under the max payload", g.config.Strategy, event.Type, event.Database, event.Table)
+	return []*models.ChangeEvent{event}, true
+}
+
+// size returns the size of an event encoded as the publisher encodes it
+func (g *OversizeGuard) size(event *models.ChangeEvent) int {
+	if len(event.RawJSON) > 0 {
+		return len(event.RawJSON)
+	}
+	data, err := json.Marshal(event.Encoding(g.format))
+	if err != nil {
+		return 0
+	}
+	return len(data)
+}
+
+// split splits the event's rows into the fewest parts that each fit, halving the
+// rows per part until they do. Returns nil if a single row doesn't fit, or the
+// event has no rows of its own to split.
+func (g *OversizeGuard) split(event *models.ChangeEvent, max int) []*models.ChangeEvent {
+	if len(event.RawJSON) > 0 || len(event.Rows) < 2 {
+		return nil
+	}
+	for perPart := (len(event.Rows) + 1) / 2; perPart >= 1; perPart = (perPart + 1) / 2 {
+		parts := splitEvent(event, perPart)
+		fits := true
+		for _, part := range parts {
+			if g.size(part) > max {
+				fits = false
+				break
+			}
+		}
+		if fits {
+			return parts
+		}
+		if perPart == 1 {
+			break
+		}
+	}
+	return nil
+}
+
+// dropColumns removes the largest column values of the event's rows and old rows
+// until it fits, keeping its key columns, and lists them in the rows' _truncated
+// field. Returns false if it still doesn't fit.
+func (g *OversizeGuard) dropColumns(event *models.ChangeEvent, max int) bool {
+	if len(event.RawJSON) > 0 {
+		return false
+	}
+	keys := eventKeyColumns(event)
+	for g.size(event) > max {
+		var largest map[string]interface{}
+		var column string
+		largestSize := 0
+		for _, rows := range [][]map[string]interface{}{event.Rows, event.OldRows} {
+			for _, row := range rows {
+				for col, value := range row {
+					if col == truncatedMarker || slices.Contains(keys, col) {
+						continue
+					}
+					data, _ := json.Marshal(value)
+					if len(data) > largestSize {
+						largest, column, largestSize = row, col, len(data)
+					}
+				}
+			}
+		}
+		if largest == nil {
+			return false
+		}
+		delete(largest, column)
+		markTruncated(largest, []string{column})
+	}
+	return true
+}
+
+// reference stores the encoded event in the object store and returns an event
+// publishing a reference to it in its place. Returns nil if it can't be stored.
+func (g *OversizeGuard) reference(event *models.ChangeEvent, size, max int) *models.ChangeEvent {
+	data := event.RawJSON
+	if len(data) == 0 {
+		encoded, err := json.Marshal(event.Encoding(g.format))
+		if err != nil {
+			return nil
+		}
+		data = encoded
+	}
+	id := event.ID
+	if id == "" {
+		id = event.DedupID
+	}
+	key := fmt.Sprintf("%s/%s/%s", event.Database, event.Table, id)
+	if err := g.publisher.PutObject(g.config.Bucket, key, data); err != nil {
+		g.logger.WithFields(event.LogFields()).Errorf("Failed to store oversized event in object store %s: %v", g.config.Bucket, err)
+		return nil
+	}
+
+	notice := oversizeNotice(event, size, max)
+	notice.Ref = fmt.Sprintf("%s/%s", g.config.Bucket, key)
+	raw, err := json.Marshal(notice)
+	if err != nil {
+		return nil
+	}
+	ref := *event
+	ref.RawJSON = raw
+	return &ref
+}
+
+// oversizeNotice describes an oversized event, with the key values of its rows so
+// consumers can read them from the database
+func oversizeNotice(event *models.ChangeEvent, size, max int) *models.OversizeEvent {
+	notice := &models.OversizeEvent{
+		Type:          "OVERSIZE",
+		Timestamp:     time.Now().Unix(),
+		ID:            event.ID,
+		EventType:     event.Type,
+		Database:      event.Database,
+		Table:         event.Table,
+		Size:          size,
+		MaxSize:       max,
+		GTID:          event.GTID,
+		BinlogFile:    event.BinlogFile,
+		BinlogPos:     event.BinlogPos,
+		TransactionID: event.TransactionID,
+	}
+	if keys := eventKeyColumns(event); len(keys) > 0 {
+		for _, row := range event.Rows {
+			values := make(map[string]interface{}, len(keys))
+			for _, col := range keys {
+				values[col] = row[col]
+			}
+			notice.Keys = append(notice.Keys, values)
+		}
+	}
+	return notice
+}
+
+// eventKeyColumns returns the columns identifying the event's rows: its primary
+// key, or the row key of tables without one
+func eventKeyColumns(event *models.ChangeEvent) []string {
+	if len(event.PrimaryKey) > 0 {
+		return event.PrimaryKey
+	}
+	return event.RowKey
+}
@@ -0,0 +1,137 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/sirupsen/logrus"
+
+	"mysql-cdc/internal/binlog"
+	"mysql-cdc/internal/config"
+)
+
+// Headers of passthrough messages
+const (
+	BinlogFileHeader      = "Cdc-Binlog-File"
+	BinlogPosHeader       = "Cdc-Binlog-Pos"
+	BinlogEventTypeHeader = "Cdc-Binlog-Event-Type"
+	BinlogTimestampHeader = "Cdc-Binlog-Timestamp"
+)
+
+// RawPublisher publishes undecoded binlog events
+type RawPublisher interface {
+	PublishRaw(subject string, data []byte, headers map[string]string, dedupID string) error
+	Alerter
+}
+
+// Passthrough publishes binlog events as read from the server, without decoding
+// rows, filtering or transforming them. Each message holds one event's raw bytes
+// (header, body and checksum); its position and type are sent as headers.
+type Passthrough struct {
+	reader    Reader
+	publisher RawPublisher
+	config    *config.Config
+	logger    *logrus.Logger
+	commit    bool // Positions are committed once events are published (at-least-once and exactly-once delivery)
+	purged    bool // Binlog purged alert has been raised
+}
+
+// NewPassthrough creates a new passthrough publisher
+func NewPassthrough(reader Reader, publisher RawPublisher, cfg *config.Config, logger *logrus.Logger) *Passthrough {
+	return &Passthrough{
+		reader:    reader,
+		publisher: publisher,
+		config:    cfg,
+		logger:    logger,
+		commit:    cfg.Delivery.Mode != "at_most_once",
+	}
+}
+
+// Start publishes binlog events until the context is cancelled
+func (p *Passthrough) Start(ctx context.Context) error {
+	p.logger.Infof("Starting binlog passthrough to %s", p.config.Binlog.Passthrough.Subject)
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.logger.Info("Context cancelled, stopping binlog passthrough")
+			return nil
+		default:
+		}
+
+		event, err := p.reader.ReadEvent(ctx)
+		if errors.Is(err, binlog.ErrResumed) || (err != nil && ctx.Err() != nil) {
+			continue
+		}
+		if errors.Is(err, binlog.ErrReconnectFailed) {
+			p.logger.Errorf("Binlog stream couldn't be restarted, stopping: %v", err)
+			return err
+		}
+		if errors.Is(err, binlog.ErrEndOfRange) {
+			p.logger.Infof("Reached end of binlog range at %s:%d", p.reader.Position().Name, p.reader.Position().Pos)
+			return nil
+		}
+		if err != nil {
+			// Timeouts are expected when waiting for events
+			if errors.Is(err, context.DeadlineExceeded) ||
+				strings.Contains(err.Error(), "context deadline exceeded") {
+				continue
+			}
+			p.logger.Errorf("Error reading binlog event: %v", err)
+			if binlog.IsPurged(err) && !p.purged {
+				p.purged = true
+				alertPurged(p.publisher, p.reader.Position(), err)
+			}
+			time.Sleep(1 * time.Second)
+			continue
+		}
+
+		if event.Header.EventType == replication.HEARTBEAT_EVENT {
+			continue
+		}
+
+		position := p.reader.Position()
+		headers := map[string]string{
+			BinlogFileHeader:      position.Name,
+			BinlogPosHeader:       strconv.FormatUint(uint64(event.Header.LogPos), 10),
+			BinlogEventTypeHeader: event.Header.EventType.String(),
+			BinlogTimestampHeader: strconv.FormatUint(uint64(event.Header.Timestamp), 10),
+		}
+		dedupID := positionDedupID(p.config.SourceName, position.Name, event.Header.LogPos)
+
+		if !p.publish(ctx, event.RawData, headers, dedupID) {
+			continue
+		}
+
+		// Commit only at transaction boundaries so a restart never resumes mid-transaction
+		if p.commit && (event.Header.EventType == replication.XID_EVENT || event.Header.EventType == replication.ROTATE_EVENT) {
+			if err := p.reader.Commit(position); err != nil {
+				p.logger.Warnf("Failed to commit position %s:%d: %v", position.Name, position.Pos, err)
+			}
+		}
+	}
+}
+
+// publish publishes a raw event, retrying failed publishes when positions are
+// committed after delivery. Reports whether the event was published.
+func (p *Passthrough) publish(ctx context.Context, data []byte, headers map[string]string, dedupID string) bool {
+	for {
+		err := p.publisher.PublishRaw(p.config.Binlog.Passthrough.Subject, data, headers, dedupID)
+		if err == nil {
+			return true
+		}
+		p.logger.Errorf("Error publishing binlog event %s: %v", dedupID, err)
+		if !p.commit || ctx.Err() != nil {
+			return false
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(p.config.Delivery.RetryInterval):
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package processor
+
+import (
+	"context"
+
+	"mysql-cdc/internal/config"
+	"mysql-cdc/internal/models"
+)
+
+// defaultPriorityQueueSize is the number of events buffered per priority lane
+const defaultPriorityQueueSize = 1000
+
+// PriorityScheduler delivers events of high-priority tables ahead of other tables.
+// Events are split into a high and a low lane; a single dispatcher always drains the
+// high lane first. Each table always maps to the same lane, so ordering within a
+// table is preserved. Lanes are only non-empty when delivery falls behind reading,
+// such as when replaying a large backlog.
+type PriorityScheduler struct {
+	high    []config.TableRef
+	highCh  chan *models.ChangeEvent
+	lowCh   chan *models.ChangeEvent
+	deliver func(ctx context.Context, event *models.ChangeEvent)
+}
+
+// NewPriorityScheduler creates a new priority scheduler
+func NewPriorityScheduler(cfg *config.PriorityConfig, deliver func(ctx context.Context, event *models.ChangeEvent)) *PriorityScheduler {
+	size := cfg.QueueSize
+	if size <= 0 {
+		size = defaultPriorityQueueSize
+	}
+	return &PriorityScheduler{
+		high:    cfg.High,
+		highCh:  make(chan *models.ChangeEvent, size),
+		lowCh:   make(chan *models.ChangeEvent, size),
+		deliver: deliver,
+	}
+}
+
+// Submit queues an event on its table's lane, blocking if the lane is full
+func (s *PriorityScheduler) Submit(ctx context.Context, event *models.ChangeEvent) error {
+	lane := s.lowCh
+	if s.isHigh(event.Database, event.Table) {
+		lane = s.highCh
+	}
+
+	select {
+	case lane <- event:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Run dispatches queued events until the context is cancelled
+func (s *PriorityScheduler) Run(ctx context.Context) {
+	for {
+		// Always prefer the high-priority lane
+		select {
+		case event := <-s.highCh:
+			s.deliver(ctx, event)
+			continue
+		default:
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-s.highCh:
+			s.deliver(ctx, event)
+		case event := <-s.lowCh:
+			s.deliver(ctx, event)
+		}
+	}
+}
+
+// isHigh reports whether the table is marked as high priority
+func (s *PriorityScheduler) isHigh(database, table string) bool {
+	for _, ref := range s.high {
+		if matchesTable(ref.Database, ref.Table, database, table) {
+			return true
+		}
+	}
+	return false
+}
@@ -5,116 +5,414 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"hash/fnv"
+	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-mysql-org/go-mysql/mysql"
 	"github.com/go-mysql-org/go-mysql/replication"
+	mysqldriver "github.com/go-sql-driver/mysql"
 	"github.com/sirupsen/logrus"
 
+	"mysql-cdc/internal/binlog"
+	"mysql-cdc/internal/cache"
+	"mysql-cdc/internal/config"
 	"mysql-cdc/internal/models"
+	cdcmysql "mysql-cdc/internal/mysql"
+	"mysql-cdc/internal/typeconv"
 )
 
 // Processor processes binlog events and publishes them
 type Processor struct {
-	reader      Reader
-	publisher   Publisher
-	transformer *Transformer
-	logger      *logrus.Logger
-	tables       map[uint64]*replication.TableMapEvent // Cache table map events
-	columnNames  map[string][]string                    // Cache column names by "database.table"
-	columnTypes  map[string][]string                    // Cache column types by "database.table"
-	db           *sql.DB                                // Database connection for fetching column names
+	reader       Reader
+	publisher    Publisher
+	transformer  *Transformer
+	logger       *logrus.Logger
+	tables       *cache.Cache[uint64, *replication.TableMapEvent] // Cache table map events
+	columns      *cache.Cache[string, *columnInfo]                // Cache column info by "database.table"
+	db           *sql.DB                                          // Database connection for fetching column names
+	config       *config.Config
+	queryContext map[string]string // Annotations from the current transaction's statement comments
+	lastEventTS  atomic.Int64      // Binlog timestamp of the last event read
+	lastGTID     string            // GTID of the transaction currently being read
+	txnID        string            // ID of the transaction currently being read (see models.ChangeEvent.TransactionID)
+	txnEvents    int               // Row events read in the current GTID transaction
+	txnCommitTS  int64             // Commit time of the current transaction from its GTID event, in Unix milliseconds (0 if unknown)
+	skipDomain   bool              // Current MariaDB transaction's GTID domain is filtered out
+	watermarkMu  sync.Mutex
+	watermark    models.WatermarkEvent   // Last committed position
+	pendingMarks []models.WatermarkEvent // Checkpoints awaiting commit, in binlog order
+	boundaryTime int64                   // Binlog timestamp of the last transaction boundary
+	rowLimiter   *RowLimiter
+	filter       *Filter
+	throttler    *Throttler
+	router       *Router
+	scheduler    *PriorityScheduler  // nil unless table priorities are configured
+	workers      *WorkerPool         // nil unless parallel delivery is configured
+	commits      *CommitTracker      // nil with at-most-once delivery
+	coalesced    *models.ChangeEvent // Event absorbing following single-row events of the same table (events.coalesce)
+	txn          txnState            // Transaction being grouped (events.transactions)
+	replayGuard  *ReplayGuard        // nil unless delivery.replay_guard is enabled
+	inflight     sync.WaitGroup      // Dispatched events not yet delivered or dropped
+	deliveryOnce sync.Once           // Delivery starts with Start, or with the first backfilled or snapshot event
+	caughtUp     atomic.Bool         // Reader has reached the master's live position
+	masterPos    func() (mysql.Position, error)
+	failed       chan error // First error of a stage whose policy stops the service
+	purged       bool       // Binlog purged alert has been raised
+	reporter     ErrorReporter
+	metrics      Metrics // nil unless a metrics exporter is configured
+	drift        schemaDrift
+
+	fullRowMetadata bool              // Server writes column names and primary keys to the binlog
+	metadataSource  map[string]string // Column metadata source last logged per table
+	keylessLogged   map[string]bool   // Tables whose keyless handling has been logged
+	schemaSent      map[string]bool   // Tables whose schema has been attached to an event (events.schema: first)
+	announced       map[string]bool   // Tables whose schema has been announced; false = re-announce after DDL
+
+	batcher        *Batcher       // nil unless nats.batch is enabled
+	batchPublisher BatchPublisher // Publishes the batcher's batches
+	deadLetterMu   sync.Mutex
+	deadLetterFile *os.File // nil unless errors.dead_letter_file is set
+
+	// Delivery runs on a context of its own, so events read before a shutdown
+	// can still be delivered once reading stopped
+	deliveryCtx  context.Context
+	stopDelivery context.CancelFunc
+
+	// Runtime state served by the admin API
+	stats   tableStats
+	pauseMu sync.Mutex
+	resumed chan struct{} // Closed on Resume; nil unless reading is paused
+
+	originMu sync.Mutex
+	origins  map[uint32]*models.EventOrigin // By server_id; nil unless events.metadata is enabled
+
+	types    *typeconv.Converter // nil unless events.types is enabled
+	oversize *OversizeGuard      // nil unless limits.oversize.strategy is set
 }
 
 // Reader interface for reading binlog events
 type Reader interface {
-	ReadEvent() (*replication.BinlogEvent, error)
+	ReadEvent(ctx context.Context) (*replication.BinlogEvent, error)
+	Position() mysql.Position
+	Commit(position mysql.Position) error
 }
 
 // Publisher interface for publishing events
 type Publisher interface {
 	Publish(event *models.ChangeEvent) error
+	PublishJSON(subject string, v interface{}) error
+	PutKV(bucket, key string, v interface{}) error
+	PutObject(bucket, key string, data []byte) error
+	Alerter
 }
 
 // NewProcessor creates a new event processor
-func NewProcessor(reader Reader, publisher Publisher, transformer *Transformer, dbHost string, dbPort int, dbUser, dbPassword string, logger *logrus.Logger) (*Processor, error) {
-	// Create database connection for fetching column names
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/", dbUser, dbPassword, dbHost, dbPort)
-	db, err := sql.Open("mysql", dsn)
+func NewProcessor(reader Reader, publisher Publisher, transformer *Transformer, cfg *config.Config, logger *logrus.Logger) (*Processor, error) {
+	// Create database connection pool for fetching column names
+	meta := &cfg.MySQL.Metadata
+	dsn := mysqldriver.NewConfig()
+	dsn.User = meta.User
+	dsn.Passwd = meta.Password
+	dsn.Net = "tcp"
+	dsn.Addr = fmt.Sprintf("%s:%d", meta.Host, meta.Port)
+	dsn.Timeout = meta.ConnectTimeout
+	dsn.ReadTimeout = meta.ReadTimeout
+	dsn.WriteTimeout = meta.WriteTimeout
+	if cfg.Events.Types.Enabled {
+		// Backfills read TIMESTAMP values in UTC
+		dsn.Params = map[string]string{"time_zone": "'+00:00'"}
+	}
+	db, err := cdcmysql.OpenDB(dsn, &cfg.MySQL.TLS)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database connection: %w", err)
 	}
-	db.SetMaxOpenConns(1)
-	db.SetMaxIdleConns(1)
+	db.SetMaxOpenConns(meta.MaxOpenConns)
+	db.SetMaxIdleConns(meta.MaxIdleConns)
+	db.SetConnMaxLifetime(meta.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(meta.ConnMaxIdleTime)
 
-	return &Processor{
+	p := &Processor{
 		reader:      reader,
 		publisher:   publisher,
 		transformer: transformer,
 		logger:      logger,
-		tables:      make(map[uint64]*replication.TableMapEvent),
-		columnNames: make(map[string][]string),
-		columnTypes: make(map[string][]string),
+		tables:      cache.New[uint64, *replication.TableMapEvent](cfg.Cache.TableMap.MaxSize, cfg.Cache.TableMap.TTL),
+		columns:     cache.New[string, *columnInfo](cfg.Cache.Columns.MaxSize, cfg.Cache.Columns.TTL),
 		db:          db,
-	}, nil
+		config:      cfg,
+		rowLimiter:  NewRowLimiter(&cfg.Limits, publisher, logger),
+		filter:      NewFilter(&cfg.Filters),
+		throttler:   NewThrottler(cfg.Limits.Throttle, publisher),
+		router:      NewRouter(&cfg.Routing),
+		failed:      make(chan error, 1),
+
+		metadataSource: make(map[string]string),
+		keylessLogged:  make(map[string]bool),
+		schemaSent:     make(map[string]bool),
+		announced:      make(map[string]bool),
+		drift:          schemaDrift{tables: make(map[string]*driftState)},
+	}
+
+	// Delivery chain: priority scheduler -> worker pool -> deliver
+	deliver := p.deliver
+	if cfg.Pipeline.Workers > 1 {
+		p.workers = NewWorkerPool(&cfg.Pipeline, p.deliver, p.logger)
+		deliver = p.workers.Submit
+	} else if transformer != nil && transformer.hasJSWorkers() {
+		// Pooled JavaScript transforms still run off the read loop, on a single
+		// delivery worker to keep events in binlog order
+		pipeline := cfg.Pipeline
+		pipeline.Workers = 1
+		p.workers = NewWorkerPool(&pipeline, p.deliver, p.logger)
+		deliver = p.workers.Submit
+	}
+	if len(cfg.Priority.High) > 0 {
+		p.scheduler = NewPriorityScheduler(&cfg.Priority, deliver)
+	}
+	if cfg.Delivery.Mode != "at_most_once" {
+		p.commits = NewCommitTracker(p.commitPosition, logger)
+	}
+	if cfg.Delivery.ReplayGuard.Enabled {
+		guard, err := NewReplayGuard(&cfg.Delivery.ReplayGuard, logger)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		p.replayGuard = guard
+	}
+	if cfg.NATS.Batch.Enabled {
+		batchPublisher, ok := publisher.(BatchPublisher)
+		if !ok {
+			p.Close()
+			return nil, fmt.Errorf("nats.batch requires a publisher that supports batches")
+		}
+		p.batchPublisher = batchPublisher
+		p.batcher = NewBatcher(&cfg.NATS.Batch, p.flushBatch)
+	}
+	if cfg.Events.Metadata {
+		p.origins = make(map[uint32]*models.EventOrigin)
+	}
+	types, err := typeconv.New(&cfg.Events.Types)
+	if err != nil {
+		p.Close()
+		return nil, err
+	}
+	p.types = types
+	p.oversize = NewOversizeGuard(&cfg.Limits.Oversize, cfg.Events.Format, publisher, logger)
+	if cfg.Errors.DeadLetterFile != "" {
+		file, err := os.OpenFile(cfg.Errors.DeadLetterFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			p.Close()
+			return nil, fmt.Errorf("failed to open dead-letter file: %w", err)
+		}
+		p.deadLetterFile = file
+	}
+
+	return p, nil
+}
+
+// SetRowFormat records the server's binlog_row_metadata and binlog_row_image settings,
+// which decide where column metadata comes from and whether rows may be partial
+func (p *Processor) SetRowFormat(rowMetadata, rowImage string) {
+	p.fullRowMetadata = strings.EqualFold(rowMetadata, "FULL")
+	if p.fullRowMetadata {
+		p.logger.Info("binlog_row_metadata=FULL: column names and primary keys are read from the binlog")
+	} else {
+		p.logger.Info("binlog_row_metadata is not FULL: column names are read from INFORMATION_SCHEMA")
+	}
+	if rowImage != "" && !strings.EqualFold(rowImage, "FULL") {
+		p.logger.Warnf("binlog_row_image=%s: events only contain the columns written to the binlog", rowImage)
+	}
+}
+
+// logMetadataSource logs which column metadata source is used for a table when it
+// is first seen or changes
+func (p *Processor) logMetadataSource(database, table, source string) {
+	key := tableKey(database, table)
+	if p.metadataSource[key] == source {
+		return
+	}
+	p.metadataSource[key] = source
+	p.tableLog(database, table).Infof("Column metadata for %s.%s is read from %s", database, table, source)
+}
+
+// positionLog returns a log entry with the current binlog position, for log
+// lines about the binlog stream
+func (p *Processor) positionLog() *logrus.Entry {
+	pos := p.reader.Position()
+	if pos.Name == "" {
+		return logrus.NewEntry(p.logger)
+	}
+	return p.logger.WithField("position", fmt.Sprintf("%s:%d", pos.Name, pos.Pos))
+}
+
+// tableLog returns a log entry with a table and the current binlog position, for
+// log lines about a table outside of a change event's delivery
+func (p *Processor) tableLog(database, table string) *logrus.Entry {
+	return p.positionLog().WithFields(logrus.Fields{"database": database, "table": table})
 }
 
-// Close closes the processor and its database connection
+// Close stops delivery and closes the processor, its database connection,
+// replay guard and dead-letter file
 func (p *Processor) Close() {
+	if p.stopDelivery != nil {
+		p.stopDelivery()
+	}
 	if p.db != nil {
 		p.db.Close()
 	}
+	if p.replayGuard != nil {
+		p.replayGuard.Close()
+	}
+	if p.deadLetterFile != nil {
+		p.deadLetterFile.Close()
+	}
+}
+
+// columnInfo holds a table's column metadata
+type columnInfo struct {
+	names       []string
+	types       []string
+	primaryKeys []string
+	uniqueKey   []string // Columns of a NOT NULL unique key, for tables without a primary key
+	schema      []models.ColumnSchema
+	version     string // Hash of the column names and types
+	refreshed   bool   // Read again because a table map had a different number of columns
+}
+
+// schemaVersion hashes column names and types into a short, stable version string
+func schemaVersion(names, types []string) string {
+	h := fnv.New64a()
+	for i, name := range names {
+		h.Write([]byte(name))
+		h.Write([]byte{0})
+		if i < len(types) {
+			h.Write([]byte(types[i]))
+		}
+		h.Write([]byte{0})
+	}
+	return fmt.Sprintf("%016x", h.Sum64())
 }
 
 // getColumnInfo fetches column names and types from MySQL for a given table
-func (p *Processor) getColumnInfo(database, table string) ([]string, []string, error) {
+func (p *Processor) getColumnInfo(database, table string) (*columnInfo, error) {
 	// Check cache first
-	cacheKey := fmt.Sprintf("%s.%s", database, table)
-	if cols, ok := p.columnNames[cacheKey]; ok {
-		types, _ := p.columnTypes[cacheKey]
-		return cols, types, nil
+	cacheKey := tableKey(database, table)
+	if info, ok := p.columns.Get(cacheKey); ok {
+		return info, nil
+	}
+
+	info, err := p.readColumnInfo(database, table)
+	if err != nil {
+		return nil, err
+	}
+
+	// Cache the results
+	p.columns.Set(cacheKey, info)
+	p.tableLog(database, table).Debugf("Fetched %d column names and types for %s.%s", len(info.names), database, table)
+	p.watchSchema(database, table, info)
+
+	return info, nil
+}
+
+// tableColumns returns the column info for a table map event. A table map with a
+// different number of columns means the cached info is stale, e.g. after DDL that
+// wasn't in the binlog, so it's read again. It's read again only once: the binlog
+// may be behind the current schema, and stays different until it catches up.
+func (p *Processor) tableColumns(database, table string, tableMap *replication.TableMapEvent) (*columnInfo, error) {
+	info, err := p.getColumnInfo(database, table)
+	if err != nil {
+		return nil, err
+	}
+	info = p.withHiddenGIPK(database, table, info, tableMap)
+	if len(info.names) == int(tableMap.ColumnCount) || info.refreshed {
+		return info, nil
 	}
 
+	p.tableLog(database, table).Infof("Table map of %s.%s has %d columns but the cached column info has %d, refreshing it",
+		database, table, tableMap.ColumnCount, len(info.names))
+	p.count("columns.refreshed", "database:"+database, "table:"+table)
+	p.invalidateTable(tableKey(database, table))
+	info, err = p.getColumnInfo(database, table)
+	if err != nil {
+		return nil, err
+	}
+	info.refreshed = true
+	info = p.withHiddenGIPK(database, table, info, tableMap)
+	if len(info.names) != int(tableMap.ColumnCount) {
+		p.tableLog(database, table).Warnf("%s.%s has %d columns but its table map has %d, values may be attributed to the wrong columns",
+			database, table, len(info.names), tableMap.ColumnCount)
+	}
+	return info, nil
+}
+
+// readColumnInfo reads a table's column names and types from INFORMATION_SCHEMA
+func (p *Processor) readColumnInfo(database, table string) (*columnInfo, error) {
 	// Query INFORMATION_SCHEMA for column names and types
 	query := `
-		SELECT COLUMN_NAME, COLUMN_TYPE
+		SELECT COLUMN_NAME, COLUMN_TYPE, COLUMN_KEY, IS_NULLABLE, EXTRA, COLUMN_COMMENT
 		FROM INFORMATION_SCHEMA.COLUMNS 
 		WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? 
 		ORDER BY ORDINAL_POSITION
 	`
 	rows, err := p.db.Query(query, database, table)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to query column info: %w", err)
+		return nil, fmt.Errorf("failed to query column info: %w", err)
 	}
 	defer rows.Close()
 
 	var columns []string
 	var types []string
+	var primaryKeys []string
+	var schema []models.ColumnSchema
 	for rows.Next() {
-		var colName, columnType string
-		if err := rows.Scan(&colName, &columnType); err != nil {
-			return nil, nil, fmt.Errorf("failed to scan column info: %w", err)
+		var colName, columnType, columnKey, isNullable, extra, comment string
+		if err := rows.Scan(&colName, &columnType, &columnKey, &isNullable, &extra, &comment); err != nil {
+			return nil, fmt.Errorf("failed to scan column info: %w", err)
 		}
 		columns = append(columns, colName)
 		types = append(types, columnType) // Use COLUMN_TYPE for more detailed info
+		if columnKey == "PRI" {
+			primaryKeys = append(primaryKeys, colName)
+		}
+		schema = append(schema, models.ColumnSchema{
+			Name:       colName,
+			Type:       columnType,
+			Nullable:   isNullable == "YES",
+			PrimaryKey: columnKey == "PRI",
+			Invisible:  strings.Contains(strings.ToUpper(extra), "INVISIBLE"),
+			Comment:    comment,
+		})
 	}
 	if err := rows.Err(); err != nil {
-		return nil, nil, fmt.Errorf("error iterating columns: %w", err)
+		return nil, fmt.Errorf("error iterating columns: %w", err)
 	}
 
-	// Cache the results
-	p.columnNames[cacheKey] = columns
-	p.columnTypes[cacheKey] = types
-	p.logger.Debugf("Fetched %d column names and types for %s.%s", len(columns), database, table)
-
-	return columns, types, nil
+	info := &columnInfo{
+		names:       columns,
+		types:       types,
+		primaryKeys: primaryKeys,
+		schema:      schema,
+		version:     schemaVersion(columns, types),
+	}
+	if len(primaryKeys) == 0 && len(columns) > 0 {
+		uniqueKey, err := p.readUniqueKey(database, table, info)
+		if err != nil {
+			return nil, err
+		}
+		info.uniqueKey = uniqueKey
+	}
+	return info, nil
 }
 
 // ProcessRowEvent processes a row event and returns a change event
 func (p *Processor) ProcessRowEvent(event *replication.RowsEvent, eventType string) (*models.ChangeEvent, error) {
 	// Get table map for column information
-	tableMap, ok := p.tables[event.TableID]
+	tableMap, ok := p.tables.Get(event.TableID)
 	if !ok {
 		return nil, fmt.Errorf("table map not found for table ID %d", event.TableID)
 	}
@@ -125,77 +423,91 @@ func (p *Processor) ProcessRowEvent(event *replication.RowsEvent, eventType stri
 	// Get column names and types - try from TableMapEvent first (MySQL 8.0+), otherwise fetch from MySQL
 	var columnNames []string
 	var columnTypes []string
+	var primaryKey []string
+	var info *columnInfo
 	if len(tableMap.ColumnName) > 0 {
-		// Column names available in binlog (MySQL 8.0+ with binlog_row_metadata)
-		columnNames = make([]string, len(tableMap.ColumnName))
-		for i, col := range tableMap.ColumnName {
-			columnNames[i] = string(col)
+		// Column names available in binlog (MySQL 8.0+ with binlog_row_metadata=FULL)
+		p.logMetadataSource(database, table, "binlog")
+		columnNames = tableMap.ColumnNameString()
+		for _, i := range tableMap.PrimaryKey {
+			if int(i) < len(columnNames) {
+				primaryKey = append(primaryKey, columnNames[i])
+			}
 		}
 		// Still need to fetch types from MySQL for MySQL 8.0+
 		var err error
-		_, columnTypes, err = p.getColumnInfo(database, table)
+		info, err = p.tableColumns(database, table, tableMap)
 		if err != nil {
-			p.logger.Warnf("Failed to get column types: %v, continuing without type info", err)
+			p.tableLog(database, table).Warnf("Failed to get column types: %v, continuing without type info", err)
+		} else {
+			columnTypes = info.types
+			if len(primaryKey) == 0 {
+				primaryKey = info.primaryKeys
+			}
 		}
 	} else {
+		if p.fullRowMetadata {
+			p.tableLog(database, table).Debugf("Table map for %s.%s has no column names despite binlog_row_metadata=FULL", database, table)
+		}
 		// Fetch column names and types from MySQL (for MySQL 5.6/5.7)
+		p.logMetadataSource(database, table, "INFORMATION_SCHEMA")
 		var err error
-		columnNames, columnTypes, err = p.getColumnInfo(database, table)
+		info, err = p.tableColumns(database, table, tableMap)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get column info: %w", err)
 		}
-		// Ensure we have enough column names
-		if len(columnNames) < int(tableMap.ColumnCount) {
-			p.logger.Warnf("Column count mismatch: expected %d columns, got %d names", tableMap.ColumnCount, len(columnNames))
-		}
+		columnNames, columnTypes, primaryKey = info.names, info.types, info.primaryKeys
 	}
 
+	now := time.Now()
 	changeEvent := &models.ChangeEvent{
-		Database:  database,
-		Table:     table,
-		Timestamp: time.Now().Unix(),
-		Rows:      make([]map[string]interface{}, 0),
-		OldRows:   make([]map[string]interface{}, 0),
-		Type:      eventType,
+		Database:    database,
+		Table:       table,
+		Timestamp:   models.NewTimestamp(now),
+		ProcessedTS: now.UnixMilli(),
+		Rows:        make([]map[string]interface{}, 0),
+		OldRows:     make([]map[string]interface{}, 0),
+		Type:        eventType,
+	}
+
+	if len(p.queryContext) > 0 {
+		changeEvent.QueryContext = p.queryContext
+	}
+	changeEvent.PrimaryKey = primaryKey
+	changeEvent.GTID = p.lastGTID
+	changeEvent.TransactionID = p.txnID
+	if info != nil {
+		changeEvent.SchemaVersion = info.version
+		changeEvent.Schema = p.eventSchema(database, table, info)
+		p.announceSchema(database, table, info)
 	}
 
 	// Helper function to convert value based on column type
 	convertValue := func(value interface{}, colIndex int) interface{} {
-		if value == nil {
-			return nil
-		}
-		
-		// If we have column type info, check if it's a TEXT type
+		colType := ""
 		if colIndex < len(columnTypes) {
-			colType := strings.ToUpper(columnTypes[colIndex])
-			// Check if it's a TEXT type (TEXT, TINYTEXT, MEDIUMTEXT, LONGTEXT)
-			if strings.Contains(colType, "TEXT") {
-				// Convert []byte to string for TEXT columns
-				if b, ok := value.([]byte); ok {
-					return string(b)
-				}
-			}
-			// For BLOB types, keep as base64 (or could convert to string if desired)
-			// BLOB types are kept as []byte which will be base64 encoded in JSON
-		}
-		
-		// For []byte values without type info, try to convert to string
-		// This handles cases where type info is not available
-		if b, ok := value.([]byte); ok {
-			// Try to detect if it's valid UTF-8 text
-			if len(b) > 0 {
-				// Check if it looks like text (not binary)
-				// Simple heuristic: if it's valid UTF-8 and doesn't contain null bytes, treat as text
-				if len(b) < 65535 { // Reasonable size limit for TEXT
-					if str := string(b); len(str) == len(b) {
-						// No null bytes, likely text
-						return str
-					}
-				}
+			colType = columnTypes[colIndex]
+		}
+		// The binlog decoder formats TIMESTAMP values in the local time zone
+		return p.columnValue(value, colType, time.Local)
+	}
+
+	// Helper function to build a row map. With binlog_row_image=MINIMAL or NOBLOB,
+	// columns that weren't written to the binlog are left out instead of reported as NULL.
+	buildRow := func(rowIndex int) map[string]interface{} {
+		row := event.Rows[rowIndex]
+		var skipped []int
+		if rowIndex < len(event.SkippedColumns) {
+			skipped = event.SkippedColumns[rowIndex]
+		}
+		rowMap := make(map[string]interface{})
+		for j := 0; j < len(row) && j < len(columnNames); j++ {
+			if len(skipped) > 0 && containsIndex(skipped, j) {
+				continue
 			}
+			rowMap[columnNames[j]] = convertValue(row[j], j)
 		}
-		
-		return value
+		return rowMap
 	}
 
 	// Process rows based on event type
@@ -203,46 +515,873 @@ func (p *Processor) ProcessRowEvent(event *replication.RowsEvent, eventType stri
 		// For UPDATE, event.Rows contains [old_row_1, new_row_1, old_row_2, new_row_2, ...]
 		for i := 0; i < len(event.Rows); i += 2 {
 			if i+1 < len(event.Rows) {
-				// Old row
-				oldRowMap := make(map[string]interface{})
-				for j := 0; j < len(event.Rows[i]) && j < len(columnNames); j++ {
-					oldRowMap[columnNames[j]] = convertValue(event.Rows[i][j], j)
+				changeEvent.OldRows = append(changeEvent.OldRows, buildRow(i))
+				changeEvent.Rows = append(changeEvent.Rows, buildRow(i+1))
+			}
+		}
+	} else {
+		// For INSERT and DELETE, all rows are the affected rows
+		for i := range event.Rows {
+			changeEvent.Rows = append(changeEvent.Rows, buildRow(i))
+		}
+	}
+	p.setRowKey(changeEvent, info)
+
+	return changeEvent, nil
+}
+
+// columnValue converts a column value with the type conversion of events.types if
+// enabled, taking TIMESTAMP strings to be in timestampZone. Otherwise ENUM and SET
+// values are resolved to their labels unless events.raw_enums is set.
+func (p *Processor) columnValue(value interface{}, colType string, timestampZone *time.Location) interface{} {
+	if p.types != nil && colType != "" {
+		return p.types.Convert(value, colType, timestampZone)
+	}
+	if !p.config.Events.RawEnums && colType != "" {
+		value = typeconv.Labels(value, colType)
+	}
+	return convertValue(value, colType)
+}
+
+// convertValue converts a column value based on the column type (empty if unknown)
+func convertValue(value interface{}, colType string) interface{} {
+	if value == nil {
+		return nil
+	}
+
+	// If we have column type info, check if it's a TEXT type
+	if colType != "" {
+		// Check if it's a TEXT type (TEXT, TINYTEXT, MEDIUMTEXT, LONGTEXT)
+		if strings.Contains(strings.ToUpper(colType), "TEXT") {
+			// Convert []byte to string for TEXT columns
+			if b, ok := value.([]byte); ok {
+				return string(b)
+			}
+		}
+		// For BLOB types, keep as base64 (or could convert to string if desired)
+		// BLOB types are kept as []byte which will be base64 encoded in JSON
+	}
+
+	// For []byte values without type info, try to convert to string
+	// This handles cases where type info is not available
+	if b, ok := value.([]byte); ok {
+		// Try to detect if it's valid UTF-8 text
+		if len(b) > 0 {
+			// Check if it looks like text (not binary)
+			// Simple heuristic: if it's valid UTF-8 and doesn't contain null bytes, treat as text
+			if len(b) < 65535 { // Reasonable size limit for TEXT
+				if str := string(b); len(str) == len(b) {
+					// No null bytes, likely text
+					return str
 				}
-				changeEvent.OldRows = append(changeEvent.OldRows, oldRowMap)
+			}
+		}
+	}
 
-				// New row
-				newRowMap := make(map[string]interface{})
-				for j := 0; j < len(event.Rows[i+1]) && j < len(columnNames); j++ {
-					newRowMap[columnNames[j]] = convertValue(event.Rows[i+1][j], j)
+	return value
+}
+
+// eventSchema returns the column metadata to attach to an event, according to events.schema
+func (p *Processor) eventSchema(database, table string, info *columnInfo) []models.ColumnSchema {
+	switch p.config.Events.Schema {
+	case "always":
+		return info.schema
+	case "first":
+		key := tableKey(database, table)
+		if p.schemaSent[key] {
+			return nil
+		}
+		p.schemaSent[key] = true
+		return info.schema
+	}
+	return nil
+}
+
+// announceSchema publishes the table's schema if it hasn't been announced yet or
+// DDL may have changed it since
+func (p *Processor) announceSchema(database, table string, info *columnInfo) {
+	if !p.config.Events.Announcements.Enabled {
+		return
+	}
+	key := tableKey(database, table)
+	announced, seen := p.announced[key]
+	if announced {
+		return
+	}
+	reason := "first_seen"
+	if seen {
+		reason = "ddl"
+	}
+
+	pos := p.reader.Position()
+	announcement := &models.TableSchemaEvent{
+		Type:       "SCHEMA",
+		Timestamp:  time.Now().Unix(),
+		Database:   database,
+		Table:      table,
+		Reason:     reason,
+		Columns:    info.schema,
+		PrimaryKey: info.primaryKeys,
+		BinlogFile: pos.Name,
+		BinlogPos:  pos.Pos,
+	}
+	if err := p.publisher.PublishJSON(p.config.Events.Announcements.Subject, announcement); err != nil {
+		p.tableLog(database, table).Warnf("Failed to announce schema of %s.%s: %v", database, table, err)
+		return
+	}
+	p.announced[key] = true
+	p.tableLog(database, table).Debugf("Announced schema of %s.%s (%s)", database, table, reason)
+}
+
+// handleDDL drops cached column info for the tables a DDL statement may have
+// changed, so their schema is re-read and re-announced on the next row event
+func (p *Processor) handleDDL(schema, query string) {
+	tables, ok := binlog.DDLTables(query, schema)
+	if !ok {
+		p.positionLog().Debugf("DDL may affect any table, clearing column cache: %s", query)
+		p.columns.Clear()
+		for key := range p.announced {
+			p.announced[key] = false
+		}
+		for key := range p.schemaSent {
+			delete(p.schemaSent, key)
+		}
+		return
+	}
+	for _, t := range tables {
+		key := tableKey(t.Schema, t.Name)
+		if p.filter.Allow(t.Schema, t.Name) {
+			p.publisher.Alert(models.AlertSeverityWarning, "schema_drift",
+				fmt.Sprintf("Schema of captured table %s.%s changed", t.Schema, t.Name), map[string]interface{}{
+					"database": t.Schema,
+					"table":    t.Name,
+					"query":    query,
+				})
+		}
+		p.invalidateTable(key)
+		p.tableLog(t.Schema, t.Name).Debugf("DDL changed %s.%s, column info will be refreshed", t.Schema, t.Name)
+
+		// Check the new schema against the processor rules right away
+		if p.filter.Allow(t.Schema, t.Name) {
+			if _, err := p.getColumnInfo(t.Schema, t.Name); err != nil {
+				p.tableLog(t.Schema, t.Name).Warnf("Failed to read schema of %s.%s after DDL: %v", t.Schema, t.Name, err)
+			}
+		}
+	}
+}
+
+// invalidateTable drops a table's cached column info, so it's read again and its
+// schema announced and attached to events again
+func (p *Processor) invalidateTable(key string) {
+	p.columns.Delete(key)
+	if _, seen := p.announced[key]; seen {
+		p.announced[key] = false
+	}
+	delete(p.schemaSent, key)
+}
+
+// containsIndex reports whether a column index is in the list
+func containsIndex(indexes []int, i int) bool {
+	for _, idx := range indexes {
+		if idx == i {
+			return true
+		}
+	}
+	return false
+}
+
+// captureQueryContext remembers a statement's comment annotations so they can be
+// attached to the row events that follow. Each statement replaces the previous
+// one's, so rows of a statement without annotations get none; BEGIN clears them.
+func (p *Processor) captureQueryContext(query string) {
+	if !p.config.Binlog.QueryContext {
+		return
+	}
+	p.queryContext = parseQueryContext(query)
+}
+
+// publishDDL publishes a DDL statement that changes captured tables to the DDL subject if enabled
+func (p *Processor) publishDDL(e *replication.QueryEvent, header *replication.EventHeader) {
+	if !p.config.Binlog.DDL.Enabled {
+		return
+	}
+
+	query := strings.TrimSpace(string(e.Query))
+	if !binlog.IsDDL(query) {
+		return
+	}
+	schema := string(e.Schema)
+	var tables []models.DDLTable
+	changed, ok := binlog.DDLTables(query, schema)
+	if ok {
+		for _, t := range changed {
+			if p.filter.Allow(t.Schema, t.Name) {
+				tables = append(tables, models.DDLTable{Database: t.Schema, Table: t.Name})
+			}
+		}
+		if len(tables) == 0 {
+			return
+		}
+	} else if !p.filter.Allow(schema, "") {
+		return
+	}
+
+	ddl := &models.DDLEvent{
+		Type:       "DDL",
+		Timestamp:  int64(header.Timestamp),
+		Database:   schema,
+		Query:      query,
+		Tables:     tables,
+		GTID:       p.lastGTID,
+		BinlogFile: p.reader.Position().Name,
+		BinlogPos:  header.LogPos,
+	}
+	if err := p.publisher.PublishJSON(p.config.Binlog.DDL.Subject, ddl); err != nil {
+		p.positionLog().Errorf("Error publishing DDL event: %v", err)
+	}
+}
+
+// publishStatement publishes a non-DDL statement to the statements subject if enabled
+func (p *Processor) publishStatement(e *replication.QueryEvent, header *replication.EventHeader) {
+	if !p.config.Binlog.Statements.Enabled {
+		return
+	}
+
+	query := strings.TrimSpace(string(e.Query))
+	if binlog.IsTransactionControl(query) || binlog.IsDDL(query) {
+		return
+	}
+	if !p.filter.Allow(string(e.Schema), "") {
+		return
+	}
+
+	stmt := &models.StatementEvent{
+		Type:          "STATEMENT",
+		Database:      string(e.Schema),
+		Query:         query,
+		Timestamp:     int64(header.Timestamp),
+		ExecutionTime: e.ExecutionTime,
+		ErrorCode:     e.ErrorCode,
+	}
+	if err := p.publisher.PublishJSON(p.config.Binlog.Statements.Subject, stmt); err != nil {
+		p.positionLog().Errorf("Error publishing statement: %v", err)
+	}
+}
+
+// runHeartbeat publishes heartbeat events until the context is cancelled
+func (p *Processor) runHeartbeat(ctx context.Context) {
+	ticker := time.NewTicker(p.config.Heartbeat.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pos := p.reader.Position()
+			heartbeat := &models.HeartbeatEvent{
+				Type:               "HEARTBEAT",
+				Timestamp:          time.Now().Unix(),
+				BinlogFile:         pos.Name,
+				BinlogPos:          pos.Pos,
+				LastEventTimestamp: p.lastEventTS.Load(),
+			}
+			if p.config.CaughtUp.Enabled {
+				caughtUp := p.caughtUp.Load()
+				heartbeat.CaughtUp = &caughtUp
+			}
+			if err := p.publisher.PublishJSON(p.config.Heartbeat.Subject, heartbeat); err != nil {
+				p.logger.Warnf("Failed to publish heartbeat: %v", err)
+			}
+		}
+	}
+}
+
+// markCheckpoint records the watermark of a checkpoint. Without a commit tracker
+// it takes effect immediately; otherwise it waits for commitPosition.
+func (p *Processor) markCheckpoint(pos mysql.Position) {
+	mark := models.WatermarkEvent{
+		Type:       "WATERMARK",
+		BinlogFile: pos.Name,
+		BinlogPos:  pos.Pos,
+		GTID:       p.lastGTID,
+		CommitTime: p.boundaryTime,
+	}
+
+	p.watermarkMu.Lock()
+	defer p.watermarkMu.Unlock()
+	if p.commits == nil {
+		p.watermark = mark
+		return
+	}
+	p.pendingMarks = append(p.pendingMarks, mark)
+}
+
+// commitPosition persists a position committed by the commit tracker and makes
+// the matching checkpoint the watermark
+func (p *Processor) commitPosition(pos mysql.Position) error {
+	if err := p.reader.Commit(pos); err != nil {
+		return err
+	}
+
+	p.watermarkMu.Lock()
+	defer p.watermarkMu.Unlock()
+	// The tracker commits checkpoints in order, skipping those superseded
+	// before they became committable
+	for len(p.pendingMarks) > 0 {
+		mark := p.pendingMarks[0]
+		committed := mysql.Position{Name: mark.BinlogFile, Pos: mark.BinlogPos}
+		if committed.Compare(pos) > 0 {
+			break
+		}
+		p.pendingMarks = p.pendingMarks[1:]
+		p.watermark = mark
+	}
+	return nil
+}
+
+// runWatermark announces the last committed position until the context is cancelled
+func (p *Processor) runWatermark(ctx context.Context) {
+	ticker := time.NewTicker(p.config.Watermark.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.watermarkMu.Lock()
+			watermark := p.watermark
+			p.watermarkMu.Unlock()
+
+			// Nothing committed yet
+			if watermark.BinlogFile == "" {
+				continue
+			}
+
+			watermark.Timestamp = time.Now().Unix()
+			if err := p.publisher.PublishJSON(p.config.Watermark.Subject, &watermark); err != nil {
+				p.logger.Warnf("Failed to publish watermark: %v", err)
+			}
+			if p.config.Watermark.KVBucket != "" {
+				if err := p.publisher.PutKV(p.config.Watermark.KVBucket, p.config.Watermark.KVKey, &watermark); err != nil {
+					p.logger.Warnf("Failed to store watermark in KV: %v", err)
 				}
-				changeEvent.Rows = append(changeEvent.Rows, newRowMap)
 			}
 		}
+	}
+}
+
+// CacheStats returns hit/miss counters for the metadata caches
+func (p *Processor) CacheStats() map[string]cache.Stats {
+	return map[string]cache.Stats{
+		"table_map": p.tables.Stats(),
+		"columns":   p.columns.Stats(),
+	}
+}
+
+// runCacheStats logs cache hit/miss rates until the context is cancelled
+func (p *Processor) runCacheStats(ctx context.Context) {
+	ticker := time.NewTicker(p.config.Cache.StatsInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for name, stats := range p.CacheStats() {
+				hitRate := 0.0
+				if total := stats.Hits + stats.Misses; total > 0 {
+					hitRate = float64(stats.Hits) / float64(total) * 100
+				}
+				p.logger.WithFields(logrus.Fields{
+					"cache":     name,
+					"size":      stats.Size,
+					"hits":      stats.Hits,
+					"misses":    stats.Misses,
+					"evictions": stats.Evictions,
+				}).Infof("Cache %s hit rate: %.1f%%", name, hitRate)
+			}
+		}
+	}
+}
+
+// allowOrigin reports whether an event's originating server and, on MariaDB, its
+// transaction's GTID domain pass the filters
+func (p *Processor) allowOrigin(header *replication.EventHeader) bool {
+	return !p.skipDomain && p.filter.AllowServer(header.ServerID)
+}
+
+// commitTime returns the commit time of the transaction an event belongs to, in
+// Unix milliseconds: from its GTID event on MySQL 8.0.1+, otherwise the event's
+// binlog timestamp, which has second precision
+func (p *Processor) commitTime(header *replication.EventHeader) int64 {
+	if p.txnCommitTS > 0 {
+		return p.txnCommitTS
+	}
+	return int64(header.Timestamp) * 1000
+}
+
+// eventOrigin returns the origin of events written by the server with the given
+// server_id, or nil unless events.metadata is enabled
+func (p *Processor) eventOrigin(serverID uint32) *models.EventOrigin {
+	if p.origins == nil {
+		return nil
+	}
+	p.originMu.Lock()
+	defer p.originMu.Unlock()
+	origin, ok := p.origins[serverID]
+	if !ok {
+		origin = &models.EventOrigin{
+			Host:     p.config.MySQL.Host,
+			Port:     p.config.MySQL.Port,
+			ServerID: serverID,
+			Source:   p.config.SourceName,
+		}
+		p.origins[serverID] = origin
+	}
+	return origin
+}
+
+// emit routes, splits and size-limits a change event and hands the results to the delivery chain
+func (p *Processor) emit(ctx context.Context, changeEvent *models.ChangeEvent) {
+	// Rows read with SELECT (snapshots and backfills) have no binlog event header
+	if changeEvent.Origin == nil {
+		changeEvent.Origin = p.eventOrigin(0)
+	}
+	if changeEvent.Keyless && p.config.Routing.Keyless.Policy == "skip" {
+		p.skipKeyless(changeEvent)
+		return
+	}
+	if p.config.Events.Transactions.Envelope && changeEvent.TransactionID != "" {
+		p.envelopeChange(ctx, changeEvent)
+		return
+	}
+
+	// Route to subjects, then split events with too many rows
+	var events []*models.ChangeEvent
+	for _, routed := range p.router.Route(changeEvent) {
+		events = append(events, p.rowLimiter.Split(routed)...)
+	}
+	for _, out := range events {
+		out.CorrelationID = p.correlationID(out)
+		if p.commits != nil {
+			out.OnDone = p.commits.Track()
+		}
+		if !p.limitRows(ctx, out) {
+			continue
+		}
+		if p.config.Events.Transactions.Metadata && out.TransactionID != "" {
+			p.holdTransactionEvent(ctx, out)
+			continue
+		}
+		p.dispatch(ctx, out)
+	}
+}
+
+// positionDedupID returns the dedup ID of the event at a binlog position. With
+// several sources it's prefixed with the source name: shards write the same
+// binlog file names, and a stream capturing several of them mustn't drop one
+// shard's event as a duplicate of another's.
+func positionDedupID(source, file string, pos uint32) string {
+	return sourceDedupID(source, fmt.Sprintf("%s:%d", file, pos))
+}
+
+// sourceDedupID prefixes a dedup ID with the source name, if any
+func sourceDedupID(source, id string) string {
+	if source == "" || id == "" {
+		return id
+	}
+	return source + ":" + id
+}
+
+// limitRows enforces row size limits on an event. Returns false if the event was
+// dropped. A failed dead-letter publish is handled by the publish error policy.
+func (p *Processor) limitRows(ctx context.Context, event *models.ChangeEvent) bool {
+	for attempt := 1; ; attempt++ {
+		keep, err := p.rowLimiter.Apply(event)
+		if err == nil {
+			if !keep && event.OnDone != nil {
+				event.OnDone()
+			}
+			return keep
+		}
+		p.logger.WithFields(event.LogFields()).Errorf("Error publishing oversized event: %v", err)
+		switch p.onError(ctx, letterFor(StagePublish, event), attempt, err) {
+		case outcomeRetry:
+			continue
+		case outcomeStopped:
+			// At-most-once delivery already persisted the position
+			if p.commits != nil {
+				return false
+			}
+		}
+		if event.OnDone != nil {
+			event.OnDone()
+		}
+		return false
+	}
+}
+
+// drain waits until every dispatched event has been delivered or dropped, or
+// an error policy stops the service
+func (p *Processor) drain(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		p.inflight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return nil
+	case err := <-p.failed:
+		return err
+	}
+	select {
+	case err := <-p.failed:
+		return err
+	default:
+		return nil
+	}
+}
+
+// shutdown delivers the events read before reading stopped, waiting up to
+// delivery.drain_timeout for them, then stops delivery. Positions are persisted
+// as they're delivered, so the last checkpoint is known when it returns.
+func (p *Processor) shutdown(ctx context.Context) error {
+	defer p.stopDelivery()
+	timeout := p.config.Delivery.DrainTimeout
+	if timeout < 0 {
+		return nil
+	}
+
+	if p.commits == nil {
+		// Positions were persisted as events were read: publish what was read
+		// of the current transaction, or it's lost
+		p.flushCoalesced(ctx)
+		p.flushPartialTransaction(ctx)
 	} else {
-		// For INSERT and DELETE, all rows are the affected rows
-		for _, row := range event.Rows {
-			rowMap := make(map[string]interface{})
-			for j := 0; j < len(row) && j < len(columnNames); j++ {
-				rowMap[columnNames[j]] = convertValue(row[j], j)
+		// The interrupted transaction is read again from its start after a
+		// restart. Publishing part of it now could make JetStream drop the
+		// complete events as duplicates.
+		p.coalesced = nil
+		p.discardTransaction()
+	}
+
+	start := time.Now()
+	timer := time.AfterFunc(timeout, p.stopDelivery)
+	defer timer.Stop()
+	if p.batcher != nil {
+		p.batcher.Flush(ctx)
+	}
+	if err := p.drain(ctx); err != nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		p.logger.Warnf("Drain timed out after %s with events still in flight", timeout)
+		return nil
+	}
+	p.logger.Infof("Delivered in-flight events in %s", time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// startDelivery starts the delivery workers, priority scheduler and batcher, if
+// configured. They keep running when ctx is cancelled, until stopDelivery.
+func (p *Processor) startDelivery(ctx context.Context) {
+	p.deliveryOnce.Do(func() {
+		p.deliveryCtx, p.stopDelivery = context.WithCancel(context.WithoutCancel(ctx))
+		ctx := p.deliveryCtx
+		if p.workers != nil {
+			p.workers.Start(ctx)
+		}
+		if p.scheduler != nil {
+			go p.scheduler.Run(ctx)
+		}
+		if p.batcher != nil {
+			go p.batcher.Run(ctx)
+		}
+	})
+}
+
+// checkpoint marks the current position as a transaction boundary that can be
+// persisted once everything before it has been delivered. Events still being
+// coalesced or held back for transaction metadata belong to the transaction, so
+// they're emitted first. The watermark follows committed checkpoints.
+func (p *Processor) checkpoint(ctx context.Context, header *replication.EventHeader) {
+	p.flushCoalesced(ctx)
+	p.endTransaction(ctx, 0)
+	pos := p.reader.Position()
+	// Rotate events carry no timestamp, so they keep the last transaction's
+	if header.Timestamp > 0 {
+		p.boundaryTime = int64(header.Timestamp)
+	}
+	if p.config.Watermark.Enabled {
+		p.markCheckpoint(pos)
+	}
+	if p.commits != nil {
+		p.commits.Checkpoint(pos)
+	}
+}
+
+// dispatch hands a change event to the configured delivery chain
+func (p *Processor) dispatch(ctx context.Context, changeEvent *models.ChangeEvent) {
+	p.inflight.Add(1)
+	onDone := changeEvent.OnDone
+	changeEvent.OnDone = func() {
+		if onDone != nil {
+			onDone()
+		}
+		p.inflight.Done()
+	}
+
+	switch {
+	case p.scheduler != nil:
+		if err := p.scheduler.Submit(ctx, changeEvent); err != nil {
+			p.logger.WithFields(changeEvent.LogFields()).Errorf("Error scheduling event: %v", err)
+		}
+	case p.workers != nil:
+		p.workers.Submit(ctx, changeEvent)
+	default:
+		p.deliver(ctx, changeEvent)
+	}
+}
+
+// deliver transforms and publishes a change event
+func (p *Processor) deliver(ctx context.Context, changeEvent *models.ChangeEvent) {
+	// Store event info before transformation (in case event is rejected)
+	database := changeEvent.Database
+	table := changeEvent.Table
+	eventType := changeEvent.Type
+	dedupID := changeEvent.DedupID
+	eventID := changeEvent.ID
+	correlationID := changeEvent.CorrelationID
+	subject := changeEvent.Subject
+	onDone := changeEvent.OnDone
+	if onDone == nil {
+		onDone = func() {}
+	}
+	log := p.logger.WithFields(changeEvent.LogFields())
+	defer p.reportPanic(changeEvent)
+
+	// Events published before a restart rewound to the last persisted position
+	if p.replayGuard != nil && p.replayGuard.Seen(dedupID) {
+		log.Debugf("Skipping already published event %s for %s.%s", dedupID, database, table)
+		p.count("events.replayed", eventTags(changeEvent)...)
+		onDone()
+		return
+	}
+
+	// Apply transformations if transformer is configured
+	events := []*models.ChangeEvent{changeEvent}
+	if changeEvent.Envelope != nil {
+		if p.encodeEnvelope(ctx, changeEvent, log) == outcomeStopped {
+			if p.commits == nil {
+				onDone()
+			}
+			return
+		}
+	} else if p.transformer != nil {
+		var result outcome
+		events, result = p.transform(ctx, changeEvent, log)
+		switch result {
+		case outcomeDropped:
+			onDone()
+			return
+		case outcomeStopped:
+			// At-most-once delivery already persisted the position
+			if p.commits == nil {
+				onDone()
+			}
+			return
+		}
+		for i, transformed := range events {
+			// Scripts may set their own IDs and subjects; otherwise the original ones carry over
+			transformed.DedupID = dedupID
+			if transformed.ID == "" {
+				transformed.ID = eventID
+			}
+			if transformed.CorrelationID == "" {
+				transformed.CorrelationID = correlationID
+			}
+			if transformed.Subject == "" {
+				transformed.Subject = subject
+			}
+			// Outputs of a split event need IDs of their own
+			if len(events) > 1 {
+				transformed.DedupID = fmt.Sprintf("%s/%d", dedupID, i)
+				if transformed.ID == eventID {
+					transformed.ID = fmt.Sprintf("%s/%d", eventID, i)
+				}
 			}
-			changeEvent.Rows = append(changeEvent.Rows, rowMap)
 		}
 	}
 
-	return changeEvent, nil
+	if p.oversize != nil {
+		guarded := make([]*models.ChangeEvent, 0, len(events))
+		for _, event := range events {
+			checked, oversized := p.oversize.Apply(event)
+			if oversized {
+				p.count("events.oversize", append(eventTags(event), "strategy:"+p.config.Limits.Oversize.Strategy)...)
+			}
+			guarded = append(guarded, checked...)
+		}
+		events = guarded
+	}
+
+	if p.batchable(changeEvent) {
+		p.batcher.Add(ctx, events, func() {
+			if p.replayGuard != nil {
+				p.replayGuard.Add(dedupID)
+			}
+			onDone()
+		})
+		return
+	}
+
+	for _, event := range events {
+		log := p.logger.WithFields(event.LogFields())
+		switch p.publish(ctx, event, log) {
+		case outcomeOK:
+			log.Infof("Processed %s event for %s.%s (%d rows)",
+				eventType, event.Database, event.Table, len(event.Rows))
+			p.countPublished(event)
+		case outcomeStopped:
+			// At-most-once delivery already persisted the position
+			if p.commits == nil {
+				onDone()
+			}
+			return
+		}
+	}
+	if p.replayGuard != nil {
+		p.replayGuard.Add(dedupID)
+	}
+	onDone()
+}
+
+// publish publishes a change event, applying the publish error policy to failures.
+// By default failed publishes are retried with at-least-once and exactly-once
+// delivery, since the position can't advance past an unpublished event.
+func (p *Processor) publish(ctx context.Context, event *models.ChangeEvent, log *logrus.Entry) outcome {
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		err := p.throttler.Publish(ctx, event)
+		if p.metrics != nil {
+			p.metrics.Timing("publish.latency", time.Since(start))
+		}
+		if err == nil {
+			return outcomeOK
+		}
+		log.Errorf("Error publishing event: %v", err)
+		if ctx.Err() != nil {
+			return outcomeStopped
+		}
+		if result := p.onError(ctx, letterFor(StagePublish, event), attempt, err); result != outcomeRetry {
+			return result
+		}
+	}
+}
+
+// decode turns a row event into a change event, applying the decode error policy
+// to failures. Returns nil if there's no event to emit.
+func (p *Processor) decode(ctx context.Context, e *replication.RowsEvent, eventType string, header *replication.EventHeader) *models.ChangeEvent {
+	for attempt := 1; ; attempt++ {
+		changeEvent, err := p.ProcessRowEvent(e, eventType)
+		if err == nil {
+			return changeEvent
+		}
+		p.tableLog(string(e.Table.Schema), string(e.Table.Table)).WithField("event_type", eventType).Errorf("Error processing %s event: %v", eventType, err)
+		letter := &models.DeadLetterEvent{
+			Stage:      StageDecode,
+			BinlogFile: p.reader.Position().Name,
+			BinlogPos:  header.LogPos,
+		}
+		if e.Table != nil {
+			letter.Database = string(e.Table.Schema)
+			letter.Table = string(e.Table.Table)
+		}
+		if p.onError(ctx, letter, attempt, err) != outcomeRetry {
+			return nil
+		}
+	}
 }
 
 // Start starts processing binlog events
 func (p *Processor) Start(ctx context.Context) error {
 	p.logger.Info("Starting event processor...")
 
+	if p.config.Heartbeat.Enabled {
+		go p.runHeartbeat(ctx)
+	}
+	if p.config.Watermark.Enabled {
+		go p.runWatermark(ctx)
+	}
+	if p.config.CaughtUp.Enabled && p.masterPos != nil {
+		go p.runCaughtUp(ctx)
+	}
+	if p.config.Cache.StatsInterval > 0 {
+		go p.runCacheStats(ctx)
+	}
+	if p.metrics != nil {
+		go p.runMetrics(ctx)
+	}
+	if p.config.Alerts.SchemaDriftInterval > 0 {
+		go p.runSchemaDrift(ctx)
+	}
+	p.startDelivery(ctx)
+	readCtx := ctx
+	ctx = p.deliveryCtx
+
 	for {
 		select {
-		case <-ctx.Done():
+		case <-readCtx.Done():
 			p.logger.Info("Context cancelled, stopping event processor")
-			return nil
+			return p.shutdown(ctx)
+		case err := <-p.failed:
+			return err
 		default:
-			event, err := p.reader.ReadEvent()
+			if !p.waitResumed(readCtx) {
+				continue
+			}
+			event, err := p.reader.ReadEvent(readCtx)
+			if errors.Is(err, binlog.ErrResumed) {
+				// The interrupted transaction is read again from its start
+				p.coalesced = nil
+				p.discardTransaction()
+				p.queryContext = nil
+				p.txnID = ""
+				p.txnEvents = 0
+				p.publisher.Alert(models.AlertSeverityInfo, "source_resumed", "Binlog stream resumed after a broken connection", map[string]interface{}{
+					"binlog_file": p.reader.Position().Name,
+					"binlog_pos":  p.reader.Position().Pos,
+				})
+				continue
+			}
+			if errors.Is(err, binlog.ErrReconnectFailed) {
+				p.positionLog().Errorf("Binlog stream couldn't be restarted, stopping: %v", err)
+				p.reportError("read", err, map[string]interface{}{
+					"binlog_file": p.reader.Position().Name,
+					"binlog_pos":  p.reader.Position().Pos,
+				})
+				if shutdownErr := p.shutdown(ctx); shutdownErr != nil {
+					p.logger.Errorf("Shutdown after losing the binlog stream failed: %v", shutdownErr)
+				}
+				return err
+			}
+			if errors.Is(err, binlog.ErrEndOfRange) {
+				p.flushCoalesced(ctx)
+				if err := p.drain(readCtx); err != nil {
+					return err
+				}
+				p.positionLog().Infof("Reached end of binlog range at %s:%d", p.reader.Position().Name, p.reader.Position().Pos)
+				return nil
+			}
+			if err != nil && readCtx.Err() != nil {
+				// Reading was interrupted by the shutdown
+				continue
+			}
 			if err != nil {
 				// Check if it's a timeout error (context deadline exceeded)
 				// This is normal when there are no events, so we don't log it as an error
@@ -252,17 +1391,31 @@ func (p *Processor) Start(ctx context.Context) error {
 					continue
 				}
 				// Log other errors as they indicate real problems
-				p.logger.Errorf("Error reading binlog event: %v", err)
+				p.positionLog().Errorf("Error reading binlog event: %v", err)
+				p.count("errors", "stage:read")
+				p.reportError("read", err, map[string]interface{}{
+					"binlog_file": p.reader.Position().Name,
+					"binlog_pos":  p.reader.Position().Pos,
+				})
+				if binlog.IsPurged(err) && !p.purged {
+					p.purged = true
+					alertPurged(p.publisher, p.reader.Position(), err)
+				}
 				time.Sleep(1 * time.Second)
 				continue
 			}
 
+			if event.Header.Timestamp > 0 {
+				p.lastEventTS.Store(int64(event.Header.Timestamp))
+			}
+			p.count("binlog.events")
+
 			// Process row events
 			switch e := event.Event.(type) {
 			case *replication.TableMapEvent:
 				// Cache table map events for column information
-				p.tables[e.TableID] = e
-				p.logger.Debugf("Cached table map for %s.%s (ID: %d)", string(e.Schema), string(e.Table), e.TableID)
+				p.tables.Set(e.TableID, e)
+				p.tableLog(string(e.Schema), string(e.Table)).Debugf("Cached table map for %s.%s (ID: %d)", string(e.Schema), string(e.Table), e.TableID)
 
 			case *replication.RowsEvent:
 				// Determine event type from header
@@ -275,60 +1428,96 @@ func (p *Processor) Start(ctx context.Context) error {
 				case replication.DELETE_ROWS_EVENTv0, replication.DELETE_ROWS_EVENTv1, replication.DELETE_ROWS_EVENTv2:
 					eventType = "DELETE"
 				default:
-					p.logger.Debugf("Unhandled row event type: %d", event.Header.EventType)
+					p.positionLog().Debugf("Unhandled row event type: %d", event.Header.EventType)
 					continue
 				}
 
-				changeEvent, err := p.ProcessRowEvent(e, eventType)
-				if err != nil {
-					p.logger.Errorf("Error processing %s event: %v", eventType, err)
+				// Drop filtered and sampled-out events before doing any work on them
+				if !p.allowOrigin(event.Header) ||
+					!p.filter.Allow(string(e.Table.Schema), string(e.Table.Table)) ||
+					!p.filter.Sample(string(e.Table.Schema), string(e.Table.Table)) {
 					continue
 				}
 
-				// Store database/table info before transformation (in case event is rejected)
-				database := changeEvent.Database
-				table := changeEvent.Table
-
-				// Apply transformations if transformer is configured
-				if p.transformer != nil {
-					changeEvent, err = p.transformer.Transform(changeEvent)
-					if err != nil {
-						// Check if event was rejected (not an error, just skip publishing)
-						if errors.Is(err, ErrEventRejected) {
-							p.logger.Debugf("Event rejected by transformer: %s.%s (type: %s)", database, table, eventType)
-							continue
-						}
-						p.logger.Errorf("Error transforming event: %v", err)
-						continue
-					}
-					// Check if changeEvent became nil after transformation
-					if changeEvent == nil {
-						p.logger.Debugf("Event rejected by transformer: %s.%s (type: %s)", database, table, eventType)
-						continue
-					}
-				}
-
-				if err := p.publisher.Publish(changeEvent); err != nil {
-					p.logger.Errorf("Error publishing event: %v", err)
+				changeEvent := p.decode(ctx, e, eventType, event.Header)
+				if changeEvent == nil {
 					continue
 				}
-				p.logger.Infof("Processed %s event for %s.%s (%d rows)",
-					eventType, changeEvent.Database, changeEvent.Table, len(changeEvent.Rows))
+				changeEvent.BinlogFile = p.reader.Position().Name
+				changeEvent.BinlogPos = event.Header.LogPos
+				changeEvent.CommitTS = p.commitTime(event.Header)
+				changeEvent.Timestamp = models.NewTimestamp(time.UnixMilli(changeEvent.CommitTS))
+				changeEvent.DedupID = positionDedupID(p.config.SourceName, changeEvent.BinlogFile, changeEvent.BinlogPos)
+				changeEvent.ID = p.eventID(changeEvent.GTID, p.txnEvents)
+				changeEvent.Origin = p.eventOrigin(event.Header.ServerID)
+				p.txnEvents++
+
+				p.coalesce(ctx, changeEvent)
 
 			case *replication.RotateEvent:
-				p.logger.Infof("Binlog rotated to: %s", string(e.NextLogName))
+				p.positionLog().Infof("Binlog rotated to: %s", string(e.NextLogName))
 				// Position is already saved in ReadEvent
+				p.checkpoint(ctx, event.Header)
 
 			case *replication.QueryEvent:
-				p.logger.Debugf("Query event: %s", string(e.Query))
+				p.positionLog().Debugf("Query event: %s", string(e.Query))
+				p.captureQueryContext(string(e.Query))
+				if strings.EqualFold(strings.TrimSpace(string(e.Query)), "BEGIN") && p.txnID == "" {
+					// Without GTIDs a transaction is identified by the position of its BEGIN
+					p.txnID = fmt.Sprintf("%s:%d", p.reader.Position().Name, event.Header.LogPos-event.Header.EventSize)
+				}
+				if binlog.IsDDL(string(e.Query)) {
+					p.handleDDL(string(e.Schema), string(e.Query))
+					// DDL commits implicitly
+					p.queryContext = nil
+					p.txnID = ""
+					p.checkpoint(ctx, event.Header)
+				} else if strings.EqualFold(strings.TrimSpace(string(e.Query)), "COMMIT") {
+					// Transactions on non-transactional engines end with a COMMIT query instead of an XID
+					p.queryContext = nil
+					p.txnID = ""
+					p.checkpoint(ctx, event.Header)
+				}
+				if p.allowOrigin(event.Header) {
+					p.publishDDL(e, event.Header)
+					p.publishStatement(e, event.Header)
+					if p.config.Binlog.StatementFallback {
+						p.statementEvent(ctx, e, event.Header)
+					}
+				}
+
+			case *replication.RowsQueryEvent:
+				// Original statement text (requires binlog_rows_query_log_events=ON)
+				p.positionLog().Debugf("Rows query event: %s", string(e.Query))
+				p.captureQueryContext(string(e.Query))
 
 			case *replication.XIDEvent:
-				p.logger.Debugf("XID event: %d", e.XID)
+				p.positionLog().Debugf("XID event: %d", e.XID)
+				// Transaction committed - annotations don't carry over to the next one
+				p.queryContext = nil
+				p.txnID = ""
+				p.txnCommitTS = 0
+				p.flushCoalesced(ctx)
+				p.endTransaction(ctx, e.XID)
+				p.checkpoint(ctx, event.Header)
+
+			case *replication.GTIDEvent:
+				p.lastGTID = binlog.FormatGTID(e.SID, e.GNO)
+				p.txnID = p.lastGTID
+				p.txnEvents = 0
+				// MySQL 8.0.1+ records when the transaction committed on its original server, in microseconds
+				p.txnCommitTS = int64(e.OriginalCommitTimestamp / 1000)
+
+			case *replication.MariadbGTIDEvent:
+				p.lastGTID = e.GTID.String()
+				p.txnID = p.lastGTID
+				p.txnEvents = 0
+				p.txnCommitTS = 0
+				p.skipDomain = !p.filter.AllowDomain(e.GTID.DomainID)
 
 			default:
-				p.logger.Debugf("Unhandled event type: %T", e)
+				p.positionLog().Debugf("Unhandled event type: %T", e)
 			}
 		}
 	}
 }
-
@@ -0,0 +1,35 @@
+package processor
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// queryCommentPattern matches C-style comments in a SQL statement
+	queryCommentPattern = regexp.MustCompile(`(?s)/\*(.*?)\*/`)
+	// queryContextPattern matches key=value pairs; values may be single or double quoted
+	queryContextPattern = regexp.MustCompile(`([A-Za-z_][\w.\-]*)\s*=\s*('(?:[^']*)'|"(?:[^"]*)"|[^\s,'"]+)`)
+)
+
+// parseQueryContext extracts key=value annotations from comments in a SQL statement,
+// e.g. "/* app=checkout user=42 */ UPDATE ..." or sqlcommenter-style
+// "UPDATE ... /*app='checkout',user='42'*/". Returns nil if no annotations are found.
+func parseQueryContext(query string) map[string]string {
+	var ctx map[string]string
+	for _, comment := range queryCommentPattern.FindAllStringSubmatch(query, -1) {
+		// Skip MySQL optimizer hints and version-specific comments
+		body := comment[1]
+		if strings.HasPrefix(body, "+") || strings.HasPrefix(body, "!") {
+			continue
+		}
+
+		for _, match := range queryContextPattern.FindAllStringSubmatch(body, -1) {
+			if ctx == nil {
+				ctx = make(map[string]string)
+			}
+			ctx[match[1]] = strings.Trim(match[2], `'"`)
+		}
+	}
+	return ctx
+}
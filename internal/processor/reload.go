@@ -0,0 +1,113 @@
+package processor
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"mysql-cdc/internal/config"
+)
+
+// fileStamp identifies a version of a file
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+	missing bool
+}
+
+func statFile(path string) fileStamp {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{missing: true}
+	}
+	return fileStamp{modTime: info.ModTime(), size: info.Size()}
+}
+
+// ReloadWatcher reloads the processor rules and scripts when the config file or
+// one of the loaded scripts changes. Files are polled, so edits through editors
+// that replace the file and changes on network filesystems are seen too.
+type ReloadWatcher struct {
+	configPath  string
+	transformer *Transformer
+	reload      func() error
+	interval    time.Duration
+	stamps      map[string]fileStamp
+	logger      *logrus.Logger
+}
+
+// NewReloadWatcher creates a new reload watcher. reload loads the config file and
+// reloads the transformer. Returns nil unless processor.watch is enabled.
+func NewReloadWatcher(cfg *config.ProcessorConfig, configPath string, transformer *Transformer, reload func() error, logger *logrus.Logger) *ReloadWatcher {
+	if !cfg.Watch {
+		return nil
+	}
+	w := &ReloadWatcher{
+		configPath:  configPath,
+		transformer: transformer,
+		reload:      reload,
+		interval:    cfg.WatchInterval,
+		logger:      logger,
+	}
+	w.stamps = w.stat()
+	return w
+}
+
+// paths returns the files watched: the config file and the scripts loaded
+func (w *ReloadWatcher) paths() []string {
+	paths := []string{w.configPath}
+	for path := range w.transformer.ScriptHashes() {
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+func (w *ReloadWatcher) stat() map[string]fileStamp {
+	stamps := make(map[string]fileStamp)
+	for _, path := range w.paths() {
+		stamps[path] = statFile(path)
+	}
+	return stamps
+}
+
+// Run checks the files every interval until ctx is cancelled
+func (w *ReloadWatcher) Run(ctx context.Context) {
+	w.logger.Infof("Watching %s and processor scripts for changes every %s", w.configPath, w.interval)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.check()
+		}
+	}
+}
+
+// check reloads if a file changed since the last check. A file that's missing,
+// e.g. while it's being replaced, is checked again next time.
+func (w *ReloadWatcher) check() {
+	var changed string
+	for _, path := range w.paths() {
+		stamp := statFile(path)
+		if stamp.missing {
+			return
+		}
+		if previous, ok := w.stamps[path]; !ok || previous != stamp {
+			changed = path
+		}
+	}
+	if changed == "" {
+		return
+	}
+
+	w.logger.Infof("%s changed, reloading processor rules and scripts", changed)
+	if err := w.reload(); err != nil {
+		w.logger.Errorf("Failed to reload processor rules and scripts, keeping the running ones: %v", err)
+	}
+	// Taken after the reload, so the files of newly referenced scripts are watched
+	// too. A failed reload is retried once a file changes again.
+	w.stamps = w.stat()
+}
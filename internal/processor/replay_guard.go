@@ -0,0 +1,147 @@
+package processor
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"mysql-cdc/internal/config"
+)
+
+// ReplayGuard remembers the IDs of the most recently published events in a ring
+// persisted to file. After a restart rewinds to the last persisted position, events
+// that were already published before the restart are recognized and skipped.
+type ReplayGuard struct {
+	file   string
+	logger *logrus.Logger
+
+	mu    sync.Mutex
+	ring  []string        // Event IDs in publish order, oldest overwritten first
+	next  int             // Ring index of the next ID to record
+	ids   map[string]bool // IDs currently in the ring
+	dirty bool
+
+	stopFlush chan struct{}
+	flushDone chan struct{}
+}
+
+// NewReplayGuard creates a replay guard, loading previously published IDs from file
+func NewReplayGuard(cfg *config.ReplayGuardConfig, logger *logrus.Logger) (*ReplayGuard, error) {
+	g := &ReplayGuard{
+		file:      cfg.File,
+		logger:    logger,
+		ring:      make([]string, cfg.Size),
+		ids:       make(map[string]bool, cfg.Size),
+		stopFlush: make(chan struct{}),
+		flushDone: make(chan struct{}),
+	}
+
+	data, err := os.ReadFile(cfg.File)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read published IDs: %w", err)
+	}
+	for _, id := range strings.Split(string(data), "\n") {
+		if id != "" {
+			g.record(id)
+		}
+	}
+	g.dirty = false
+	if len(g.ids) > 0 {
+		logger.Infof("Loaded %d recently published event IDs from %s", len(g.ids), cfg.File)
+	}
+
+	go g.flushLoop(cfg.FlushInterval)
+	return g, nil
+}
+
+// Seen reports whether an event with the given ID was recently published
+func (g *ReplayGuard) Seen(id string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.ids[id]
+}
+
+// Add records the ID of a published event
+func (g *ReplayGuard) Add(id string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.record(id)
+}
+
+// record adds an ID to the ring, evicting the oldest one when full. Caller holds mu
+func (g *ReplayGuard) record(id string) {
+	if g.ids[id] {
+		return
+	}
+	if old := g.ring[g.next]; old != "" {
+		delete(g.ids, old)
+	}
+	g.ring[g.next] = id
+	g.ids[id] = true
+	g.next = (g.next + 1) % len(g.ring)
+	g.dirty = true
+}
+
+// flush writes the ring to file, oldest ID first, if it changed since the last write
+func (g *ReplayGuard) flush() error {
+	g.mu.Lock()
+	if !g.dirty {
+		g.mu.Unlock()
+		return nil
+	}
+	var b strings.Builder
+	for i := range g.ring {
+		if id := g.ring[(g.next+i)%len(g.ring)]; id != "" {
+			b.WriteString(id)
+			b.WriteByte('\n')
+		}
+	}
+	g.dirty = false
+	g.mu.Unlock()
+
+	// Write to a temporary file first so a crash can't leave a truncated ring
+	tmp := g.file + ".tmp"
+	err := os.WriteFile(tmp, []byte(b.String()), 0644)
+	if err == nil {
+		err = os.Rename(tmp, g.file)
+	}
+	if err != nil {
+		g.mu.Lock()
+		g.dirty = true
+		g.mu.Unlock()
+		return fmt.Errorf("failed to save published IDs: %w", err)
+	}
+	return nil
+}
+
+// flushLoop periodically writes the ring until Close is called
+func (g *ReplayGuard) flushLoop(interval time.Duration) {
+	defer close(g.flushDone)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-g.stopFlush:
+			return
+		case <-ticker.C:
+			if err := g.flush(); err != nil {
+				g.logger.Warnf("%v", err)
+			}
+		}
+	}
+}
+
+// Close stops the background flusher and writes the ring a final time
+func (g *ReplayGuard) Close() {
+	close(g.stopFlush)
+	<-g.flushDone
+	if err := g.flush(); err != nil {
+		g.logger.Warnf("%v", err)
+	}
+}
@@ -0,0 +1,55 @@
+package processor
+
+import (
+	"mysql-cdc/internal/models"
+)
+
+// ErrorReporter reports panics and repeated errors to an external service
+type ErrorReporter interface {
+	Error(kind string, err error, context map[string]interface{})
+	Panic(value interface{}, context map[string]interface{}) interface{}
+}
+
+// SetErrorReporter sets the reporter panics and pipeline errors are sent to
+func (p *Processor) SetErrorReporter(reporter ErrorReporter) {
+	p.reporter = reporter
+}
+
+// reportError records an occurrence of an error of the given kind
+func (p *Processor) reportError(kind string, err error, context map[string]interface{}) {
+	if p.reporter != nil {
+		p.reporter.Error(kind, err, context)
+	}
+}
+
+// reportPanic reports a panic while delivering an event, with the event as context,
+// before letting it continue. Must be deferred.
+func (p *Processor) reportPanic(event *models.ChangeEvent) {
+	if value := recover(); value != nil {
+		if p.reporter != nil {
+			value = p.reporter.Panic(value, letterContext(letterFor("deliver", event)))
+		}
+		panic(value)
+	}
+}
+
+// letterContext describes where an event failed: its stage, table, position and
+// a sample of its rows
+func letterContext(letter *models.DeadLetterEvent) map[string]interface{} {
+	context := map[string]interface{}{
+		"stage":       letter.Stage,
+		"database":    letter.Database,
+		"table":       letter.Table,
+		"binlog_file": letter.BinlogFile,
+		"binlog_pos":  letter.BinlogPos,
+	}
+	if event := letter.Event; event != nil {
+		context["event_id"] = event.ID
+		context["type"] = event.Type
+		context["rows"] = len(event.Rows)
+		if len(event.Rows) > 0 {
+			context["sample"] = event.Rows[0]
+		}
+	}
+	return context
+}
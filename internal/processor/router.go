@@ -0,0 +1,120 @@
+package processor
+
+import (
+	"fmt"
+	"strings"
+
+	"mysql-cdc/internal/config"
+	"mysql-cdc/internal/models"
+)
+
+// Router picks the subject of each change event from the routing.subject template.
+// With a {tenant} placeholder, rows are grouped by the value of the tenant column
+// and each group is published as its own event. Events of tables without a key use
+// routing.keyless.subject instead, if set.
+type Router struct {
+	config *config.RoutingConfig
+}
+
+// NewRouter creates a new router
+func NewRouter(cfg *config.RoutingConfig) *Router {
+	return &Router{config: cfg}
+}
+
+// Route returns the events to publish for the given event, with their subjects set.
+// Without a subject template the event is returned as-is.
+func (r *Router) Route(event *models.ChangeEvent) []*models.ChangeEvent {
+	if event.Keyless && r.config.Keyless.Subject != "" {
+		event.Subject = r.fill(r.config.Keyless.Subject, event, r.config.DefaultTenant)
+		return []*models.ChangeEvent{event}
+	}
+	if r.config.Subject == "" {
+		return []*models.ChangeEvent{event}
+	}
+	if !strings.Contains(r.config.Subject, "{tenant}") {
+		event.Subject = r.subject(event, "")
+		return []*models.ChangeEvent{event}
+	}
+
+	// Group rows (and their old rows) by tenant, keeping the order tenants first appear in
+	var tenants []string
+	groups := make(map[string]*models.ChangeEvent)
+	for i, row := range event.Rows {
+		tenant := r.tenant(row)
+		group, ok := groups[tenant]
+		if !ok {
+			part := *event
+			part.Rows = nil
+			part.OldRows = nil
+			group = &part
+			groups[tenant] = group
+			tenants = append(tenants, tenant)
+		}
+		group.Rows = append(group.Rows, row)
+		if i < len(event.OldRows) {
+			group.OldRows = append(group.OldRows, event.OldRows[i])
+		}
+	}
+	if len(tenants) == 0 {
+		event.Subject = r.subject(event, r.config.DefaultTenant)
+		return []*models.ChangeEvent{event}
+	}
+
+	events := make([]*models.ChangeEvent, 0, len(tenants))
+	for _, tenant := range tenants {
+		part := groups[tenant]
+		part.Subject = r.subject(part, tenant)
+		if len(tenants) > 1 {
+			// Parts of a split event need IDs of their own
+			part.ID = fmt.Sprintf("%s/%s", event.ID, tenant)
+			part.DedupID = fmt.Sprintf("%s/%s", event.DedupID, tenant)
+		}
+		events = append(events, part)
+	}
+	return events
+}
+
+// tenant returns the subject token for a row's tenant column value
+func (r *Router) tenant(row map[string]interface{}) string {
+	for col, v := range row {
+		if v == nil || !namesEqual(col, r.config.TenantColumn) {
+			continue
+		}
+		var s string
+		if b, ok := v.([]byte); ok {
+			s = string(b)
+		} else {
+			s = fmt.Sprint(v)
+		}
+		if s = subjectToken(s); s != "" {
+			return s
+		}
+	}
+	return r.config.DefaultTenant
+}
+
+// subject fills in the subject template for an event
+func (r *Router) subject(event *models.ChangeEvent, tenant string) string {
+	return r.fill(r.config.Subject, event, tenant)
+}
+
+// fill fills in the placeholders of a subject template
+func (r *Router) fill(template string, event *models.ChangeEvent, tenant string) string {
+	return strings.NewReplacer(
+		"{database}", subjectToken(event.Database),
+		"{table}", subjectToken(event.Table),
+		"{type}", strings.ToLower(event.Type),
+		"{tenant}", tenant,
+	).Replace(template)
+}
+
+// subjectToken replaces characters that aren't allowed in a NATS subject token
+func subjectToken(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '.', '*', '>', ' ', '\t', '\r', '\n':
+			return '_'
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,141 @@
+package processor
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+
+	"mysql-cdc/internal/models"
+)
+
+// gipkColumn is the column MySQL 8.0.30+ adds as a generated invisible primary key
+// to tables created without one (sql_generate_invisible_primary_key=ON)
+const gipkColumn = "my_row_id"
+
+// readUniqueKey returns the columns of the table's unique key with the fewest
+// columns that are all NOT NULL, or nil if it has none. Such a key identifies rows
+// as well as a primary key does.
+func (p *Processor) readUniqueKey(database, table string, info *columnInfo) ([]string, error) {
+	query := `
+		SELECT INDEX_NAME, COLUMN_NAME
+		FROM INFORMATION_SCHEMA.STATISTICS
+		WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? AND NON_UNIQUE = 0
+		ORDER BY INDEX_NAME, SEQ_IN_INDEX
+	`
+	rows, err := p.db.Query(query, database, table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query unique keys: %w", err)
+	}
+	defer rows.Close()
+
+	nullable := make(map[string]bool, len(info.schema))
+	for _, col := range info.schema {
+		nullable[col.Name] = col.Nullable
+	}
+
+	var indexes []string
+	columns := make(map[string][]string)
+	usable := make(map[string]bool)
+	for rows.Next() {
+		var index, column string
+		if err := rows.Scan(&index, &column); err != nil {
+			return nil, fmt.Errorf("failed to scan unique key: %w", err)
+		}
+		if _, seen := columns[index]; !seen {
+			indexes = append(indexes, index)
+			usable[index] = true
+		}
+		columns[index] = append(columns[index], column)
+		if n, ok := nullable[column]; !ok || n {
+			// Nullable columns (or expressions) don't identify rows
+			usable[index] = false
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating unique keys: %w", err)
+	}
+
+	var best []string
+	for _, index := range indexes {
+		if usable[index] && (best == nil || len(columns[index]) < len(best)) {
+			best = columns[index]
+		}
+	}
+	return best, nil
+}
+
+// withHiddenGIPK accounts for a generated invisible primary key hidden from
+// INFORMATION_SCHEMA (show_gipk_in_create_table_and_information_schema=OFF). The
+// binlog still carries it as the first column, so without it every value would be
+// attributed to the wrong column. It's recognized by the table map having one more
+// column than INFORMATION_SCHEMA reports, a BIGINT, while the table has no primary key.
+func (p *Processor) withHiddenGIPK(database, table string, info *columnInfo, tableMap *replication.TableMapEvent) *columnInfo {
+	if len(info.primaryKeys) > 0 || len(info.names) == 0 ||
+		int(tableMap.ColumnCount) != len(info.names)+1 ||
+		len(tableMap.ColumnType) == 0 || tableMap.ColumnType[0] != mysql.MYSQL_TYPE_LONGLONG {
+		return info
+	}
+	if names := tableMap.ColumnNameString(); len(names) > 0 && names[0] != gipkColumn {
+		return info
+	}
+
+	gipk := &columnInfo{
+		names:       append([]string{gipkColumn}, info.names...),
+		types:       append([]string{"bigint unsigned"}, info.types...),
+		primaryKeys: []string{gipkColumn},
+		schema: append([]models.ColumnSchema{{
+			Name:       gipkColumn,
+			Type:       "bigint unsigned",
+			PrimaryKey: true,
+			Invisible:  true,
+		}}, info.schema...),
+	}
+	gipk.version = schemaVersion(gipk.names, gipk.types)
+	p.columns.Set(tableKey(database, table), gipk)
+	p.logger.Infof("%s.%s has a generated invisible primary key hidden from INFORMATION_SCHEMA, using %s", database, table, gipkColumn)
+	return gipk
+}
+
+// setRowKey sets the columns identifying rows of a table without a primary key:
+// a NOT NULL unique key, or with routing.keyless.policy full_row, all columns.
+// Tables with neither key are marked keyless.
+func (p *Processor) setRowKey(event *models.ChangeEvent, info *columnInfo) {
+	if len(event.PrimaryKey) > 0 {
+		return
+	}
+	if info != nil && len(info.uniqueKey) > 0 {
+		event.RowKey = info.uniqueKey
+		return
+	}
+
+	event.Keyless = true
+	if p.config.Routing.Keyless.Policy != "full_row" {
+		return
+	}
+	if info != nil && len(info.names) > 0 {
+		event.RowKey = info.names
+		return
+	}
+	if len(event.Rows) > 0 {
+		for col := range event.Rows[0] {
+			event.RowKey = append(event.RowKey, col)
+		}
+		sort.Strings(event.RowKey)
+	}
+}
+
+// skipKeyless drops an event of a table without a key (routing.keyless.policy:
+// skip), logging the first one of each table
+func (p *Processor) skipKeyless(event *models.ChangeEvent) {
+	key := tableKey(event.Database, event.Table)
+	if !p.keylessLogged[key] {
+		p.keylessLogged[key] = true
+		p.logger.Warnf("%s.%s has no primary key or NOT NULL unique key, its events are skipped (routing.keyless.policy: skip)", event.Database, event.Table)
+	}
+	p.count("events.skipped_keyless", eventTags(event)...)
+	if event.OnDone != nil {
+		event.OnDone()
+	}
+}
@@ -0,0 +1,328 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sirupsen/logrus"
+
+	"mysql-cdc/internal/config"
+	"mysql-cdc/internal/models"
+)
+
+// Row size policies
+const (
+	RowPolicyTruncate   = "truncate"
+	RowPolicyReference  = "reference"
+	RowPolicyDeadLetter = "dead_letter"
+)
+
+// minTruncatedLength is the shortest a value is cut down to by the truncate policy
+const minTruncatedLength = 64
+
+// truncatedMarker is added to rows whose columns were truncated or replaced
+const truncatedMarker = "_truncated"
+
+// RowLimiter enforces the maximum serialized row size
+type RowLimiter struct {
+	config     *config.LimitsConfig
+	columnCaps map[string]int // ColumnMaxLength with normalized keys
+	publisher  Publisher
+	logger     *logrus.Logger
+}
+
+// NewRowLimiter creates a new row limiter
+func NewRowLimiter(cfg *config.LimitsConfig, publisher Publisher, logger *logrus.Logger) *RowLimiter {
+	columnCaps := make(map[string]int, len(cfg.ColumnMaxLength))
+	for key, max := range cfg.ColumnMaxLength {
+		columnCaps[columnCapKey(key)] = max
+	}
+
+	return &RowLimiter{
+		config:     cfg,
+		columnCaps: columnCaps,
+		publisher:  publisher,
+		logger:     logger,
+	}
+}
+
+// Apply enforces the column caps and row size limit on every row of the event.
+// Returns false if the event should not be published, and the error of a failed
+// dead-letter publish, after which the event should be handled like a failed publish.
+func (l *RowLimiter) Apply(event *models.ChangeEvent) (bool, error) {
+	if len(l.columnCaps) > 0 {
+		for _, rows := range [][]map[string]interface{}{event.Rows, event.OldRows} {
+			for _, row := range rows {
+				l.capColumns(event, row)
+			}
+		}
+	}
+
+	if l.config.MaxRowSize <= 0 {
+		return true, nil
+	}
+
+	oversized := false
+	for _, set := range []struct {
+		field string
+		rows  []map[string]interface{}
+	}{{"rows", event.Rows}, {"old_rows", event.OldRows}} {
+		for i, row := range set.rows {
+			size := rowSize(row)
+			if size <= l.config.MaxRowSize {
+				continue
+			}
+			oversized = true
+
+			switch l.config.RowSizePolicy {
+			case RowPolicyDeadLetter:
+				// Whole event is dead-lettered below
+			case RowPolicyReference:
+				l.referenceRow(event, row, fmt.Sprintf("%s/%s/%d", referenceID(event), set.field, i))
+			default:
+				l.truncateRow(row)
+			}
+			l.logger.Warnf("Row in %s.%s exceeds max row size (%d > %d bytes), applying '%s' policy",
+				event.Database, event.Table, size, l.config.MaxRowSize, l.config.RowSizePolicy)
+		}
+	}
+
+	if oversized && l.config.RowSizePolicy == RowPolicyDeadLetter {
+		if err := l.publisher.PublishJSON(l.config.DeadLetterSubject, event); err != nil {
+			return false, fmt.Errorf("failed to dead-letter oversized event: %w", err)
+		}
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// referenceID identifies an event in the object keys of its referenced columns.
+// The dedup ID is derived from the binlog position, so it stays the same when
+// the event is read again after a restart; events without one use their ID.
+func referenceID(event *models.ChangeEvent) string {
+	if event.DedupID != "" {
+		return event.DedupID
+	}
+	return event.ID
+}
+
+// Split splits an event with more rows than limits.max_rows_per_message into parts
+// of at most that many rows, numbered with part/parts. Each part has its own ID.
+func (l *RowLimiter) Split(event *models.ChangeEvent) []*models.ChangeEvent {
+	max := l.config.MaxRowsPerMessage
+	if max <= 0 || len(event.Rows) <= max {
+		return []*models.ChangeEvent{event}
+	}
+	events := splitEvent(event, max)
+	l.logger.Debugf("Split %s event for %s.%s (%d rows) into %d parts",
+		event.Type, event.Database, event.Table, len(event.Rows), len(events))
+	return events
+}
+
+// splitEvent splits an event into parts of at most max rows, numbered with
+// part/parts. Each part has its own ID.
+func splitEvent(event *models.ChangeEvent, max int) []*models.ChangeEvent {
+	parts := (len(event.Rows) + max - 1) / max
+	events := make([]*models.ChangeEvent, 0, parts)
+	for i := 0; i < parts; i++ {
+		start, end := i*max, (i+1)*max
+		if end > len(event.Rows) {
+			end = len(event.Rows)
+		}
+		part := *event
+		part.Rows = event.Rows[start:end]
+		part.OldRows = nil
+		if start < len(event.OldRows) {
+			part.OldRows = event.OldRows[start:min(end, len(event.OldRows))]
+		}
+		part.Part = i + 1
+		part.Parts = parts
+		part.ID = fmt.Sprintf("%s/%d", event.ID, part.Part)
+		part.DedupID = fmt.Sprintf("%s/%d", event.DedupID, part.Part)
+		events = append(events, &part)
+	}
+	return events
+}
+
+// capColumns truncates values of columns that have a configured max length
+func (l *RowLimiter) capColumns(event *models.ChangeEvent, row map[string]interface{}) {
+	var truncated []string
+	for col, value := range row {
+		max, ok := l.columnMaxLength(event.Database, event.Table, col)
+		if !ok {
+			continue
+		}
+
+		if cut, ok := truncateValue(value, max); ok {
+			row[col] = cut
+			truncated = append(truncated, col)
+		}
+	}
+
+	sort.Strings(truncated)
+	markTruncated(row, truncated)
+}
+
+// columnMaxLength looks up the most specific configured cap for a column
+func (l *RowLimiter) columnMaxLength(database, table, column string) (int, bool) {
+	column = strings.ToLower(column)
+	for _, key := range []string{
+		tableKey(database, table) + "." + column,
+		columnCapKey(table + "." + column),
+		column,
+	} {
+		if max, ok := l.columnCaps[key]; ok {
+			return max, true
+		}
+	}
+	return 0, false
+}
+
+// columnCapKey normalizes a "column", "table.column" or "database.table.column" key.
+// Column names are always case-insensitive; database/table names follow the server.
+func columnCapKey(key string) string {
+	i := strings.LastIndex(key, ".")
+	if i < 0 {
+		return strings.ToLower(key)
+	}
+	prefix := key[:i]
+	if !caseSensitiveNames.Load() {
+		prefix = strings.ToLower(prefix)
+	}
+	return prefix + "." + strings.ToLower(key[i+1:])
+}
+
+// truncateRow shortens the largest column values until the row fits
+func (l *RowLimiter) truncateRow(row map[string]interface{}) {
+	var truncated []string
+	for _, col := range columnsBySize(row) {
+		excess := rowSize(row) - l.config.MaxRowSize
+		if excess <= 0 {
+			break
+		}
+
+		size := valueLength(row[col])
+		if size <= minTruncatedLength {
+			continue
+		}
+
+		keep := size - excess
+		if keep < minTruncatedLength {
+			keep = minTruncatedLength
+		}
+		if cut, ok := truncateValue(row[col], keep); ok {
+			row[col] = cut
+			truncated = append(truncated, col)
+		}
+	}
+
+	markTruncated(row, truncated)
+}
+
+// referenceRow moves the largest column values to the object store and replaces them with a reference
+func (l *RowLimiter) referenceRow(event *models.ChangeEvent, row map[string]interface{}, id string) {
+	var replaced []string
+	for _, col := range columnsBySize(row) {
+		if rowSize(row) <= l.config.MaxRowSize {
+			break
+		}
+
+		data, err := json.Marshal(row[col])
+		if err != nil {
+			continue
+		}
+
+		key := fmt.Sprintf("%s/%s/%s/%s", event.Database, event.Table, id, col)
+		if err := l.publisher.PutObject(l.config.ReferenceBucket, key, data); err != nil {
+			l.logger.Errorf("Failed to store oversized column %s: %v, truncating instead", col, err)
+			l.truncateRow(row)
+			return
+		}
+
+		row[col] = map[string]interface{}{
+			"$ref": fmt.Sprintf("%s/%s", l.config.ReferenceBucket, key),
+			"size": len(data),
+		}
+		replaced = append(replaced, col)
+	}
+
+	markTruncated(row, replaced)
+}
+
+// truncateValue cuts a string or binary value to at most max bytes, keeping its
+// type. Strings are cut back to a character boundary so they stay valid UTF-8.
+// Returns false if the value isn't longer than max or can't be truncated.
+func truncateValue(value interface{}, max int) (interface{}, bool) {
+	switch v := value.(type) {
+	case string:
+		if len(v) <= max {
+			return nil, false
+		}
+		for max > 0 && !utf8.RuneStart(v[max]) {
+			max--
+		}
+		return v[:max], true
+	case []byte:
+		if len(v) <= max {
+			return nil, false
+		}
+		return v[:max], true
+	}
+	return nil, false
+}
+
+// valueLength returns the length in bytes of a string or binary value
+func valueLength(value interface{}) int {
+	switch v := value.(type) {
+	case string:
+		return len(v)
+	case []byte:
+		return len(v)
+	}
+	return 0
+}
+
+// markTruncated records the given columns in the row's truncation marker
+func markTruncated(row map[string]interface{}, cols []string) {
+	if len(cols) == 0 {
+		return
+	}
+	if existing, ok := row[truncatedMarker].([]string); ok {
+		cols = append(existing, cols...)
+	}
+	row[truncatedMarker] = cols
+}
+
+// rowSize returns the serialized JSON size of a row
+func rowSize(row map[string]interface{}) int {
+	data, err := json.Marshal(row)
+	if err != nil {
+		return 0
+	}
+	return len(data)
+}
+
+// columnsBySize returns the row's string and binary columns ordered from largest to smallest
+func columnsBySize(row map[string]interface{}) []string {
+	sizes := make(map[string]int, len(row))
+	cols := make([]string, 0, len(row))
+	for col, value := range row {
+		switch v := value.(type) {
+		case string:
+			sizes[col] = len(v)
+		case []byte:
+			sizes[col] = len(v)
+		default:
+			continue
+		}
+		cols = append(cols, col)
+	}
+	sort.Slice(cols, func(i, j int) bool {
+		return sizes[cols[i]] > sizes[cols[j]]
+	})
+	return cols
+}
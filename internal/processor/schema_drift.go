@@ -0,0 +1,145 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"mysql-cdc/internal/models"
+)
+
+// schemaDrift tracks the schema version of each captured table and the rule
+// columns found missing from it, so each change is reported once
+type schemaDrift struct {
+	mu     sync.Mutex
+	tables map[string]*driftState
+}
+
+// driftState is the last known schema of a table
+type driftState struct {
+	database string
+	table    string
+	version  string
+	missing  string // Rule columns missing from the schema, as reported
+}
+
+// watchSchema records the schema read for a table, raising alerts when it differs
+// from the one read before or no longer has columns the processor rules refer to
+func (p *Processor) watchSchema(database, table string, info *columnInfo) {
+	if len(info.names) == 0 || !p.filter.Allow(database, table) {
+		return
+	}
+	key := tableKey(database, table)
+
+	p.drift.mu.Lock()
+	state, ok := p.drift.tables[key]
+	if !ok {
+		state = &driftState{database: database, table: table}
+		p.drift.tables[key] = state
+	}
+	previous := state.version
+	state.version = info.version
+	p.drift.mu.Unlock()
+
+	if previous != "" && previous != info.version {
+		p.logger.Infof("Schema of %s.%s changed (version %s -> %s)", database, table, previous, info.version)
+	}
+	p.checkRuleColumns(state, info)
+}
+
+// checkRuleColumns alerts when columns referenced by include, exclude, rename,
+// anonymize or where rules are missing from a table's schema
+func (p *Processor) checkRuleColumns(state *driftState, info *columnInfo) {
+	if p.transformer == nil {
+		return
+	}
+	referenced := p.transformer.RuleColumns(state.database, state.table)
+	present := make(map[string]bool, len(info.names))
+	for _, name := range info.names {
+		present[strings.ToLower(name)] = true
+	}
+	var missing []string
+	for col, settings := range referenced {
+		if !present[strings.ToLower(col)] {
+			sort.Strings(settings)
+			missing = append(missing, fmt.Sprintf("%s (%s)", col, strings.Join(settings, ", ")))
+		}
+	}
+	sort.Strings(missing)
+	summary := strings.Join(missing, "; ")
+
+	p.drift.mu.Lock()
+	changed := state.missing != summary
+	state.missing = summary
+	p.drift.mu.Unlock()
+	if !changed {
+		return
+	}
+
+	if len(missing) == 0 {
+		p.logger.Infof("Processor rules for %s.%s match its schema again", state.database, state.table)
+		return
+	}
+	p.logger.Warnf("Processor rules for %s.%s refer to columns missing from the table: %s", state.database, state.table, summary)
+	p.publisher.Alert(models.AlertSeverityCritical, "schema_drift",
+		fmt.Sprintf("Processor rules for %s.%s refer to missing columns: %s", state.database, state.table, summary), map[string]interface{}{
+			"database":        state.database,
+			"table":           state.table,
+			"missing_columns": missing,
+			"schema_version":  info.version,
+		})
+}
+
+// runSchemaDrift periodically compares the schema of each captured table with
+// INFORMATION_SCHEMA, catching changes whose DDL wasn't seen in the binlog
+// (filtered out, or made with a tool like gh-ost that swaps tables)
+func (p *Processor) runSchemaDrift(ctx context.Context) {
+	ticker := time.NewTicker(p.config.Alerts.SchemaDriftInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.checkSchemaDrift()
+		}
+	}
+}
+
+// checkSchemaDrift compares each tracked table's schema with the live one
+func (p *Processor) checkSchemaDrift() {
+	p.drift.mu.Lock()
+	states := make([]*driftState, 0, len(p.drift.tables))
+	for _, state := range p.drift.tables {
+		states = append(states, state)
+	}
+	p.drift.mu.Unlock()
+
+	for _, state := range states {
+		live, err := p.readColumnInfo(state.database, state.table)
+		if err != nil {
+			p.logger.Warnf("Failed to check schema of %s.%s: %v", state.database, state.table, err)
+			continue
+		}
+
+		p.drift.mu.Lock()
+		previous := state.version
+		state.version = live.version
+		p.drift.mu.Unlock()
+
+		if live.version != previous {
+			p.logger.Warnf("Schema of %s.%s differs from the one in use (version %s -> %s)", state.database, state.table, previous, live.version)
+			p.publisher.Alert(models.AlertSeverityWarning, "schema_drift",
+				fmt.Sprintf("Schema of captured table %s.%s changed", state.database, state.table), map[string]interface{}{
+					"database":       state.database,
+					"table":          state.table,
+					"schema_version": live.version,
+				})
+		}
+		p.checkRuleColumns(state, live)
+	}
+}
@@ -0,0 +1,71 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+)
+
+// Captures reports whether changes to a table are captured by the table filters
+func (p *Processor) Captures(database, table string) bool {
+	return p.filter.Allow(database, table)
+}
+
+// SnapshotColumns returns the columns of a table, in the order EmitSnapshot takes
+// their values, and its primary key columns
+func (p *Processor) SnapshotColumns(database, table string) (columns, primaryKey []string, err error) {
+	info, err := p.getColumnInfo(database, table)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(info.names) == 0 {
+		return nil, nil, fmt.Errorf("table %s.%s not found", database, table)
+	}
+	return info.names, info.primaryKeys, nil
+}
+
+// EmitSnapshot publishes rows read by an initial snapshot as one SNAPSHOT event,
+// through the same routing, limits, transforms and delivery as binlog events. Each
+// row holds raw driver values in SnapshotColumns order. The event carries the
+// binlog position the snapshot was taken at.
+func (p *Processor) EmitSnapshot(ctx context.Context, database, table string, values [][]interface{}, position mysql.Position, dedupID string) error {
+	select {
+	case err := <-p.failed:
+		return err
+	default:
+	}
+
+	info, err := p.getColumnInfo(database, table)
+	if err != nil {
+		return err
+	}
+	rows := make([]map[string]interface{}, 0, len(values))
+	for _, row := range values {
+		if len(row) != len(info.names) {
+			return fmt.Errorf("%s.%s has %d columns, snapshot row has %d values", database, table, len(info.names), len(row))
+		}
+		converted := make(map[string]interface{}, len(row))
+		for i, name := range info.names {
+			converted[name] = p.columnValue(row[i], info.types[i], time.UTC)
+		}
+		rows = append(rows, converted)
+	}
+
+	p.startDelivery(ctx)
+	event := p.snapshotEvent(database, table, info, rows)
+	event.BinlogFile = position.Name
+	event.BinlogPos = position.Pos
+	event.DedupID = sourceDedupID(p.config.SourceName, dedupID)
+	p.setRowKey(event, info)
+	p.announceSchema(database, table, info)
+	p.emit(ctx, event)
+	return nil
+}
+
+// Drain waits until every emitted event has been delivered or dropped, returning
+// the error of a stage whose policy stops the service
+func (p *Processor) Drain(ctx context.Context) error {
+	return p.drain(ctx)
+}
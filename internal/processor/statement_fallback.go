@@ -0,0 +1,121 @@
+package processor
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/replication"
+
+	"mysql-cdc/internal/binlog"
+	"mysql-cdc/internal/models"
+)
+
+// statementEvent turns a DML statement logged in STATEMENT or MIXED format into a
+// best-effort change event. Only the values written in the statement are known:
+// INSERT rows hold the inserted values, UPDATE has one row of the SET values and
+// WHERE conditions with the conditions as its old row, and DELETE has one row of
+// the WHERE conditions, however many rows the statement actually changed.
+func (p *Processor) statementEvent(ctx context.Context, e *replication.QueryEvent, header *replication.EventHeader) {
+	query := strings.TrimSpace(string(e.Query))
+	if !binlog.IsDML(query) {
+		return
+	}
+
+	stmt, ok := binlog.ParseDML(query, string(e.Schema))
+	if !ok {
+		p.positionLog().Warnf("Statement-format DML can't be converted to a change event, its changes are not captured: %s", query)
+		p.count("statements.unparsed")
+		return
+	}
+	database, table := stmt.Table.Schema, stmt.Table.Name
+	if !p.filter.Allow(database, table) || !p.filter.Sample(database, table) {
+		return
+	}
+
+	commitTS := p.commitTime(header)
+	changeEvent := &models.ChangeEvent{
+		Type:          stmt.Type,
+		Database:      database,
+		Table:         table,
+		Timestamp:     models.NewTimestamp(time.UnixMilli(commitTS)),
+		CommitTS:      commitTS,
+		ProcessedTS:   time.Now().UnixMilli(),
+		Rows:          make([]map[string]interface{}, 0),
+		OldRows:       make([]map[string]interface{}, 0),
+		GTID:          p.lastGTID,
+		TransactionID: p.txnID,
+		BinlogFile:    p.reader.Position().Name,
+		BinlogPos:     header.LogPos,
+		BestEffort:    true,
+		Statement:     query,
+	}
+	if len(p.queryContext) > 0 {
+		changeEvent.QueryContext = p.queryContext
+	}
+
+	info, err := p.getColumnInfo(database, table)
+	if err != nil {
+		p.tableLog(database, table).Warnf("Failed to get column info for %s.%s: %v, continuing without it", database, table, err)
+	} else {
+		changeEvent.PrimaryKey = info.primaryKeys
+		changeEvent.SchemaVersion = info.version
+		changeEvent.Schema = p.eventSchema(database, table, info)
+		p.announceSchema(database, table, info)
+	}
+
+	switch stmt.Type {
+	case "INSERT":
+		columns := stmt.Columns
+		if len(columns) == 0 && info != nil {
+			columns = info.names
+		}
+		for _, values := range stmt.Values {
+			if len(values) != len(columns) {
+				p.tableLog(database, table).Warnf("INSERT into %s.%s has %d values for %d columns, its changes are not captured: %s",
+					database, table, len(values), len(columns), query)
+				p.count("statements.unparsed")
+				return
+			}
+			row := make(map[string]interface{}, len(values))
+			for i, value := range values {
+				row[columns[i]] = value
+			}
+			changeEvent.Rows = append(changeEvent.Rows, row)
+		}
+	case "UPDATE":
+		row := make(map[string]interface{}, len(stmt.Where)+len(stmt.Set))
+		for col, value := range stmt.Where {
+			row[col] = value
+		}
+		for col, value := range stmt.Set {
+			row[col] = value
+		}
+		changeEvent.Rows = append(changeEvent.Rows, row)
+		changeEvent.OldRows = append(changeEvent.OldRows, stmt.Where)
+	case "DELETE":
+		changeEvent.Rows = append(changeEvent.Rows, stmt.Where)
+	}
+	for i, row := range changeEvent.Rows {
+		if row == nil {
+			changeEvent.Rows[i] = make(map[string]interface{})
+		}
+	}
+	for i, row := range changeEvent.OldRows {
+		if row == nil {
+			changeEvent.OldRows[i] = make(map[string]interface{})
+		}
+	}
+
+	p.setRowKey(changeEvent, info)
+
+	changeEvent.DedupID = positionDedupID(p.config.SourceName, changeEvent.BinlogFile, changeEvent.BinlogPos)
+	changeEvent.ID = p.eventID(changeEvent.GTID, p.txnEvents)
+	changeEvent.Origin = p.eventOrigin(header.ServerID)
+	p.txnEvents++
+	p.count("statements.converted", "type:"+strings.ToLower(stmt.Type))
+
+	// Partial rows are never merged with row events
+	p.flushCoalesced(ctx)
+	p.emit(ctx, changeEvent)
+}
@@ -0,0 +1,140 @@
+package processor
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"mysql-cdc/internal/models"
+)
+
+// Status is a snapshot of the processor's runtime state, served by the admin API
+type Status struct {
+	BinlogFile string            `json:"binlog_file"` // Position of the last event read
+	BinlogPos  uint32            `json:"binlog_pos"`
+	LagSeconds *float64          `json:"lag_seconds"` // Between now and the last event's binlog timestamp; null before the first event
+	Paused     bool              `json:"paused"`
+	CaughtUp   bool              `json:"caught_up"`         // Only tracked with caught_up.enabled
+	Scripts    map[string]string `json:"scripts,omitempty"` // SHA-256 of each transform script by path
+}
+
+// TableStats counts a table's published change events
+type TableStats struct {
+	Events    map[string]uint64 `json:"events"` // By event type, lowercased
+	Rows      uint64            `json:"rows"`
+	LastEvent time.Time         `json:"last_event"` // When the table's last event was published
+}
+
+// tableStats holds the published event counts of each table
+type tableStats struct {
+	mu     sync.Mutex
+	tables map[string]*TableStats
+}
+
+// add counts a published event
+func (s *tableStats) add(event *models.ChangeEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tables == nil {
+		s.tables = make(map[string]*TableStats)
+	}
+	key := tableKey(event.Database, event.Table)
+	stats, ok := s.tables[key]
+	if !ok {
+		stats = &TableStats{Events: make(map[string]uint64)}
+		s.tables[key] = stats
+	}
+	stats.Events[strings.ToLower(event.Type)]++
+	stats.Rows += uint64(len(event.Rows))
+	stats.LastEvent = time.Now()
+}
+
+// Status returns the processor's runtime state
+func (p *Processor) Status() Status {
+	pos := p.reader.Position()
+	status := Status{
+		BinlogFile: pos.Name,
+		BinlogPos:  pos.Pos,
+		Paused:     p.Paused(),
+		CaughtUp:   p.caughtUp.Load(),
+	}
+	if ts := p.lastEventTS.Load(); ts > 0 {
+		lag := time.Since(time.Unix(ts, 0)).Seconds()
+		status.LagSeconds = &lag
+	}
+	if p.transformer != nil {
+		status.Scripts = p.transformer.ScriptHashes()
+	}
+	return status
+}
+
+// TableStats returns the published event counts of each table, by database.table
+func (p *Processor) TableStats() map[string]TableStats {
+	p.stats.mu.Lock()
+	defer p.stats.mu.Unlock()
+	tables := make(map[string]TableStats, len(p.stats.tables))
+	for key, stats := range p.stats.tables {
+		events := make(map[string]uint64, len(stats.Events))
+		for eventType, n := range stats.Events {
+			events[eventType] = n
+		}
+		tables[key] = TableStats{Events: events, Rows: stats.Rows, LastEvent: stats.LastEvent}
+	}
+	return tables
+}
+
+// Pause stops reading binlog events until Resume. Events already read are still
+// delivered. Returns false if reading was already paused.
+func (p *Processor) Pause() bool {
+	p.pauseMu.Lock()
+	defer p.pauseMu.Unlock()
+	if p.resumed != nil {
+		return false
+	}
+	p.resumed = make(chan struct{})
+	p.logger.Info("Paused reading binlog events")
+	return true
+}
+
+// Resume resumes reading binlog events after Pause. Returns false if reading
+// wasn't paused.
+func (p *Processor) Resume() bool {
+	p.pauseMu.Lock()
+	defer p.pauseMu.Unlock()
+	if p.resumed == nil {
+		return false
+	}
+	close(p.resumed)
+	p.resumed = nil
+	p.logger.Info("Resumed reading binlog events")
+	return true
+}
+
+// Paused reports whether reading binlog events is paused
+func (p *Processor) Paused() bool {
+	p.pauseMu.Lock()
+	defer p.pauseMu.Unlock()
+	return p.resumed != nil
+}
+
+// waitResumed blocks while reading is paused. It returns false if ctx was
+// cancelled or a stage failed in the meantime, which the read loop handles next.
+func (p *Processor) waitResumed(ctx context.Context) bool {
+	p.pauseMu.Lock()
+	resumed := p.resumed
+	p.pauseMu.Unlock()
+	if resumed == nil {
+		return true
+	}
+	select {
+	case <-resumed:
+		return true
+	case <-ctx.Done():
+		return false
+	case err := <-p.failed:
+		// Put it back for the read loop
+		p.failed <- err
+		return false
+	}
+}
@@ -0,0 +1,116 @@
+package processor
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"mysql-cdc/internal/config"
+	"mysql-cdc/internal/models"
+)
+
+// Throttler caps the publish rate of individual tables. Publishes of a throttled
+// table wait for the table's rate limiter and then publish synchronously, so the
+// publish error policy applies and an event only counts as delivered once it's
+// published. With pipeline.workers, other tables keep flowing on other workers.
+type Throttler struct {
+	rules     []config.ThrottleRule
+	publisher Publisher
+
+	mu       sync.Mutex
+	limiters map[string]*rateLimiter // Per "database.table"
+}
+
+// NewThrottler creates a new throttler
+func NewThrottler(rules []config.ThrottleRule, publisher Publisher) *Throttler {
+	return &Throttler{
+		rules:     rules,
+		publisher: publisher,
+		limiters:  make(map[string]*rateLimiter),
+	}
+}
+
+// Publish publishes the event, first waiting for its table's rate limit if it's
+// throttled
+func (t *Throttler) Publish(ctx context.Context, event *models.ChangeEvent) error {
+	if rule := t.match(event.Database, event.Table); rule != nil {
+		if !t.limiter(tableKey(event.Database, event.Table), rule).wait(ctx) {
+			return ctx.Err()
+		}
+	}
+	return t.publisher.Publish(event)
+}
+
+// match returns the first throttle rule matching the table
+func (t *Throttler) match(database, table string) *config.ThrottleRule {
+	for i := range t.rules {
+		if matchesTable(t.rules[i].Database, t.rules[i].Table, database, table) {
+			return &t.rules[i]
+		}
+	}
+	return nil
+}
+
+// limiter returns the table's rate limiter, creating it on first use
+func (t *Throttler) limiter(key string, rule *config.ThrottleRule) *rateLimiter {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	limiter, ok := t.limiters[key]
+	if !ok {
+		limiter = newRateLimiter(rule.MaxPerSecond, rule.Burst)
+		t.limiters[key] = limiter
+	}
+	return limiter
+}
+
+// rateLimiter is a simple token bucket, safe for concurrent use
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	burst    float64
+	tokens   float64
+	last     time.Time
+}
+
+// newRateLimiter creates a token bucket allowing perSecond events with the given burst
+func newRateLimiter(perSecond float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		interval: time.Duration(float64(time.Second) / perSecond),
+		burst:    float64(burst),
+		tokens:   float64(burst),
+		last:     time.Now(),
+	}
+}
+
+// wait blocks until a token is available. Returns false if the context was cancelled.
+func (r *rateLimiter) wait(ctx context.Context) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	r.tokens += float64(now.Sub(r.last)) / float64(r.interval)
+	if r.tokens > r.burst {
+		r.tokens = r.burst
+	}
+	r.last = now
+
+	if r.tokens < 1 {
+		delay := time.Duration((1 - r.tokens) * float64(r.interval))
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return false
+		case <-timer.C:
+		}
+		r.tokens = 1
+		r.last = time.Now()
+	}
+
+	r.tokens--
+	return true
+}
@@ -0,0 +1,165 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+
+	"mysql-cdc/internal/models"
+)
+
+// txnState is the transaction whose events are being grouped (events.transactions)
+type txnState struct {
+	id       string
+	sequence int                   // Events or TRANSACTION parts of the transaction emitted so far
+	numbered int                   // Changes numbered for TRANSACTION events
+	held     *models.ChangeEvent   // Latest event, held back until it's known whether it's the last (metadata only)
+	changes  []*models.ChangeEvent // Changes of the TRANSACTION part being built
+}
+
+// holdTransactionEvent numbers an event within its transaction and holds it back,
+// dispatching the previously held event. The transaction's end releases the last
+// one, flagged as such.
+func (p *Processor) holdTransactionEvent(ctx context.Context, event *models.ChangeEvent) {
+	p.startTransaction(ctx, event.TransactionID)
+	p.txn.sequence++
+	event.Transaction = &models.TransactionInfo{ID: p.txn.id, Sequence: p.txn.sequence}
+	if p.txn.held != nil {
+		p.dispatch(ctx, p.txn.held)
+	}
+	p.txn.held = event
+}
+
+// envelopeChange adds a change to the transaction's TRANSACTION event. A full
+// part is only published once the next change arrives, so the last part is never empty.
+func (p *Processor) envelopeChange(ctx context.Context, event *models.ChangeEvent) {
+	p.startTransaction(ctx, event.TransactionID)
+	if len(p.txn.changes) >= p.config.Events.Transactions.MaxChanges {
+		p.publishEnvelope(ctx, 0, false)
+	}
+
+	event.CorrelationID = p.correlationID(event)
+	if !p.limitRows(ctx, event) {
+		return
+	}
+	if p.config.Events.Transactions.Metadata {
+		// Changes are numbered across all parts of the transaction
+		p.txn.numbered++
+		event.Transaction = &models.TransactionInfo{ID: p.txn.id, Sequence: p.txn.numbered}
+	}
+	p.txn.changes = append(p.txn.changes, event)
+}
+
+// startTransaction ends the grouped transaction if an event belongs to another one
+func (p *Processor) startTransaction(ctx context.Context, id string) {
+	if p.txn.id != id {
+		p.endTransaction(ctx, 0)
+		p.txn.id = id
+	}
+}
+
+// endTransaction releases the grouped transaction's held event or last TRANSACTION
+// part, flagged as the transaction's last, with the XID of its commit if known
+func (p *Processor) endTransaction(ctx context.Context, xid uint64) {
+	if p.config.Events.Transactions.Envelope {
+		if n := len(p.txn.changes); n > 0 {
+			if last := p.txn.changes[n-1]; last.Transaction != nil {
+				last.Transaction.XID = xid
+				last.Transaction.Last = true
+			}
+			p.publishEnvelope(ctx, xid, true)
+		}
+	} else if held := p.txn.held; held != nil {
+		held.Transaction.XID = xid
+		held.Transaction.Last = true
+		p.dispatch(ctx, held)
+	}
+	p.txn = txnState{}
+}
+
+// discardTransaction drops the grouped transaction, which is read again after
+// the binlog stream resumed
+func (p *Processor) discardTransaction() {
+	if held := p.txn.held; held != nil && held.OnDone != nil {
+		held.OnDone()
+	}
+	p.txn = txnState{}
+}
+
+// flushPartialTransaction emits what was read of a transaction interrupted by a
+// shutdown. Its last event isn't known, so none is flagged.
+func (p *Processor) flushPartialTransaction(ctx context.Context) {
+	if held := p.txn.held; held != nil {
+		p.dispatch(ctx, held)
+	} else if len(p.txn.changes) > 0 {
+		p.publishEnvelope(ctx, 0, false)
+	}
+	p.txn = txnState{}
+}
+
+// publishEnvelope dispatches the changes collected for the transaction as one
+// TRANSACTION event. Its changes are transformed and encoded on delivery.
+func (p *Processor) publishEnvelope(ctx context.Context, xid uint64, last bool) {
+	changes := p.txn.changes
+	p.txn.changes = nil
+	p.txn.sequence++
+	first, final := changes[0], changes[len(changes)-1]
+
+	envelope := &models.ChangeEvent{
+		ID:            fmt.Sprintf("%s/%d", p.txn.id, p.txn.sequence),
+		Type:          "TRANSACTION",
+		Database:      first.Database,
+		Timestamp:     first.Timestamp,
+		CommitTS:      final.CommitTS,
+		ProcessedTS:   first.ProcessedTS,
+		Rows:          make([]map[string]interface{}, 0),
+		GTID:          first.GTID,
+		BinlogFile:    final.BinlogFile,
+		BinlogPos:     final.BinlogPos,
+		TransactionID: p.txn.id,
+		CorrelationID: first.CorrelationID,
+		Transaction:   &models.TransactionInfo{ID: p.txn.id, XID: xid, Sequence: p.txn.sequence, Last: last},
+		DedupID:       fmt.Sprintf("txn:%s/%d", p.txn.id, p.txn.sequence),
+		Envelope:      changes,
+		Origin:        first.Origin,
+	}
+	if p.commits != nil {
+		envelope.OnDone = p.commits.Track()
+	}
+	p.count("transactions.envelopes", "database:"+envelope.Database)
+	p.dispatch(ctx, envelope)
+}
+
+// encodeEnvelope transforms the changes of a TRANSACTION event and encodes them
+// the way they would be published on their own. Changes rejected by a transform
+// are left out.
+func (p *Processor) encodeEnvelope(ctx context.Context, envelope *models.ChangeEvent, log *logrus.Entry) outcome {
+	envelope.Changes = make([]json.RawMessage, 0, len(envelope.Envelope))
+	for _, change := range envelope.Envelope {
+		events := []*models.ChangeEvent{change}
+		if p.transformer != nil {
+			var result outcome
+			events, result = p.transform(ctx, change, log.WithFields(change.LogFields()))
+			switch result {
+			case outcomeDropped:
+				continue
+			case outcomeStopped:
+				return outcomeStopped
+			}
+		}
+		for _, event := range events {
+			data := event.RawJSON
+			if len(data) == 0 {
+				var err error
+				if data, err = json.Marshal(event.Encoding(p.config.Events.Format)); err != nil {
+					log.Errorf("Failed to encode %s change of %s.%s, leaving it out: %v", event.Type, event.Database, event.Table, err)
+					continue
+				}
+			}
+			envelope.Changes = append(envelope.Changes, data)
+		}
+	}
+	return outcomeOK
+}
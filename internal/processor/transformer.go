@@ -1,11 +1,15 @@
 package processor
 
 import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"strings"
+	"sync"
 
 	"github.com/dop251/goja"
 	"github.com/nats-io/nats.go"
@@ -21,21 +25,44 @@ var ErrEventRejected = errors.New("event rejected by transformer")
 
 // Transformer transforms change events based on configuration rules
 type Transformer struct {
-	config      *config.ProcessorConfig
-	logger      *logrus.Logger
-	rules       []*RuleMatcher
-	jsScript    string     // Cached script content
-	natsConn    *nats.Conn // NATS connection for JavaScript bindings
+	config    *config.ProcessorConfig
+	logger    *logrus.Logger
+	rules     []*RuleMatcher
+	script    *jsScript   // processor.script (nil if not set)
+	jsScripts []*jsScript // Every loaded script, including the rules' own
+	natsConn  *nats.Conn  // NATS connection for JavaScript bindings
+
+	http *httpBinding // nil unless processor.http is enabled
+
+	// Held for reading while transforming, for writing while the rules and
+	// scripts are replaced or the worker pools stopped
+	mu sync.RWMutex
+}
+
+// jsScript is a transform script compiled once, with the runtimes that run it.
+// Every script has runtimes of its own, so scripts never share global state.
+type jsScript struct {
+	path     string
+	hash     string // SHA-256 of the script's content
+	program  *goja.Program
+	jobs     chan jsJob // Work queue of the script's worker pool (nil if not pooled)
+	runtimes sync.Pool  // Idle *jsRuntime, reused across events without a worker pool
 }
 
 // RuleMatcher matches and applies transformation rules
 type RuleMatcher struct {
-	database   string
-	table      string
-	include    map[string]bool
-	exclude    map[string]bool
-	rename     map[string]string
-	addFields  map[string]string
+	database  string
+	table     string
+	include   map[string]bool
+	exclude   map[string]bool
+	rename    map[string]string
+	addFields map[string]string
+	anonymize map[string]*Anonymizer
+	mask      map[string]*Masker
+	hash      map[string]*Hasher
+	binary    map[string]*BinaryEncoder
+	script    *jsScript   // Script transforming the matched tables after the rule (nil if none)
+	where     *Expression // Condition rows must match to be kept (nil = all rows)
 }
 
 // NewTransformer creates a new transformer with the given configuration
@@ -56,20 +83,18 @@ func NewTransformer(cfg *config.ProcessorConfig, logger *logrus.Logger, natsConn
 		natsConn: natsConn,
 	}
 
+	if cfg.HTTP.Enabled {
+		transformer.http = newHTTPBinding(&cfg.HTTP)
+	}
+
 	// Load JavaScript script if specified
 	if cfg.Script != "" {
-		scriptContent, err := os.ReadFile(cfg.Script)
+		script, err := transformer.loadScript(cfg.Script)
 		if err != nil {
-			return nil, fmt.Errorf("failed to read JavaScript script file: %w", err)
+			transformer.Close()
+			return nil, err
 		}
-		
-		// Validate script has transform function
-		if err := transformer.validateJavaScriptScript(string(scriptContent)); err != nil {
-			return nil, fmt.Errorf("invalid JavaScript script: %w", err)
-		}
-		
-		transformer.jsScript = string(scriptContent)
-		logger.Infof("Loaded JavaScript transformation script: %s", cfg.Script)
+		transformer.script = script
 	}
 
 	// Load YAML-based rules if specified
@@ -83,6 +108,10 @@ func NewTransformer(cfg *config.ProcessorConfig, logger *logrus.Logger, natsConn
 				exclude:   make(map[string]bool),
 				rename:    rule.Rename,
 				addFields: rule.AddFields,
+				anonymize: make(map[string]*Anonymizer),
+				mask:      make(map[string]*Masker),
+				hash:      make(map[string]*Hasher),
+				binary:    make(map[string]*BinaryEncoder),
 			}
 
 			// Build include set
@@ -95,6 +124,61 @@ func NewTransformer(cfg *config.ProcessorConfig, logger *logrus.Logger, natsConn
 				matcher.exclude[strings.ToLower(field)] = true
 			}
 
+			// Build anonymizers
+			for field, preset := range rule.Anonymize {
+				anonymizer, err := NewAnonymizer(preset, cfg.AnonymizeSalt)
+				if err != nil {
+					transformer.Close()
+					return nil, fmt.Errorf("invalid anonymizer for field '%s': %w", field, err)
+				}
+				matcher.anonymize[strings.ToLower(field)] = anonymizer
+			}
+
+			// Build maskers and hashers
+			for _, spec := range rule.Mask {
+				field, masker, err := NewMasker(spec)
+				if err != nil {
+					transformer.Close()
+					return nil, err
+				}
+				matcher.mask[strings.ToLower(field)] = masker
+			}
+			for field, algorithm := range rule.Hash {
+				hasher, err := NewHasher(algorithm, cfg.AnonymizeSalt)
+				if err != nil {
+					transformer.Close()
+					return nil, fmt.Errorf("invalid hash for field '%s': %w", field, err)
+				}
+				matcher.hash[strings.ToLower(field)] = hasher
+			}
+			for field, spec := range rule.Binary {
+				encoder, err := NewBinaryEncoder(spec)
+				if err != nil {
+					transformer.Close()
+					return nil, fmt.Errorf("invalid binary mode for field '%s': %w", field, err)
+				}
+				matcher.binary[strings.ToLower(field)] = encoder
+			}
+
+			if rule.Where != "" {
+				where, err := ParseExpression(rule.Where)
+				if err != nil {
+					transformer.Close()
+					return nil, fmt.Errorf("processor rule for %s: invalid where: %w", ruleTables(rule), err)
+				}
+				matcher.where = where
+			}
+
+			// Load the rule's own script
+			if rule.Script != "" {
+				script, err := transformer.loadScript(rule.Script)
+				if err != nil {
+					transformer.Close()
+					return nil, fmt.Errorf("processor rule for %s: %w", ruleTables(rule), err)
+				}
+				matcher.script = script
+			}
+
 			rules = append(rules, matcher)
 		}
 		transformer.rules = rules
@@ -103,10 +187,85 @@ func NewTransformer(cfg *config.ProcessorConfig, logger *logrus.Logger, natsConn
 	return transformer, nil
 }
 
+// loadScript reads, validates and compiles a transform script and, with
+// processor.workers, starts its worker pool
+func (t *Transformer) loadScript(path string) (*jsScript, error) {
+	scriptContent, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JavaScript script file: %w", err)
+	}
+
+	// Validate script has transform function
+	if err := t.validateJavaScriptScript(string(scriptContent)); err != nil {
+		return nil, fmt.Errorf("invalid JavaScript script %s: %w", path, err)
+	}
+
+	program, err := goja.Compile(path, string(scriptContent), false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile JavaScript script %s: %w", path, err)
+	}
+	sum := sha256.Sum256(scriptContent)
+	script := &jsScript{path: path, hash: hex.EncodeToString(sum[:]), program: program}
+	t.jsScripts = append(t.jsScripts, script)
+	t.logger.Infof("Loaded JavaScript transformation script: %s", path)
+
+	if t.config.Workers > 0 {
+		if err := t.startJSWorkers(script, t.config.Workers); err != nil {
+			return nil, fmt.Errorf("failed to start JavaScript workers for %s: %w", path, err)
+		}
+		t.logger.Infof("Started %d JavaScript transform workers for %s", t.config.Workers, path)
+	}
+	return script, nil
+}
+
+// Reload replaces the rules and scripts with those of cfg, e.g. after the script
+// files changed. Transforms in progress finish first. If cfg fails to load, the
+// transformer is left unchanged.
+func (t *Transformer) Reload(cfg *config.ProcessorConfig) error {
+	if err := ValidateRules(cfg); err != nil {
+		return err
+	}
+	next, err := NewTransformer(cfg, t.logger, t.natsConn)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	old := t.jsScripts
+	t.config, t.rules, t.script, t.jsScripts, t.http = next.config, next.rules, next.script, next.jsScripts, next.http
+	t.mu.Unlock()
+	stopJSWorkers(old)
+	t.logger.Info("Reloaded processor rules and scripts")
+	return nil
+}
+
+// ScriptHashes returns the SHA-256 of each loaded script's content by path
+func (t *Transformer) ScriptHashes() map[string]string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	hashes := make(map[string]string, len(t.jsScripts))
+	for _, script := range t.jsScripts {
+		hashes[script.path] = script.hash
+	}
+	return hashes
+}
+
+// ruleTables describes the tables a rule matches in errors and logs
+func ruleTables(rule config.ProcessorRule) string {
+	database, table := rule.Database, rule.Table
+	if database == "" {
+		database = "*"
+	}
+	if table == "" {
+		table = "*"
+	}
+	return database + "." + table
+}
+
 // validateJavaScriptScript validates that the script exports a transform function
 func (t *Transformer) validateJavaScriptScript(scriptContent string) error {
 	vm := goja.New()
-	
+
 	// Execute the script - it can be:
 	// 1. An anonymous function: (function(event) { return event; })
 	// 2. A named function: function transform(event) { return event; }
@@ -115,7 +274,7 @@ func (t *Transformer) validateJavaScriptScript(scriptContent string) error {
 	if err != nil {
 		return fmt.Errorf("failed to execute script: %w", err)
 	}
-	
+
 	// Check if the script result is a function (anonymous function)
 	if result != nil && !goja.IsUndefined(result) && !goja.IsNull(result) {
 		if _, ok := goja.AssertFunction(result); ok {
@@ -123,7 +282,7 @@ func (t *Transformer) validateJavaScriptScript(scriptContent string) error {
 			return nil
 		}
 	}
-	
+
 	// Check if there's a named 'transform' function (backward compatibility)
 	transformVar := vm.Get("transform")
 	if transformVar != nil && !goja.IsUndefined(transformVar) && !goja.IsNull(transformVar) {
@@ -132,60 +291,100 @@ func (t *Transformer) validateJavaScriptScript(scriptContent string) error {
 			return nil
 		}
 	}
-	
+
 	return fmt.Errorf("script must export a function (either anonymous function or named 'transform' function)")
 }
 
-// Transform applies transformation rules to a change event
-func (t *Transformer) Transform(event *models.ChangeEvent) (*models.ChangeEvent, error) {
+// Transform applies transformation rules to a change event. JavaScript transforms may
+// turn one event into several, each optionally routed to its own subject.
+func (t *Transformer) Transform(event *models.ChangeEvent) ([]*models.ChangeEvent, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
 	// If processor is disabled, return event as-is
 	if t.config == nil || !t.config.Enabled {
-		return event, nil
+		return []*models.ChangeEvent{event}, nil
 	}
 
 	// Use JavaScript script if available (takes precedence over YAML rules)
-	if t.jsScript != "" {
-		return t.transformWithJavaScript(event)
+	if t.script != nil {
+		return t.transformWithJavaScript(t.script, event)
 	}
 
-	// Use YAML-based rules if available
-	if len(t.rules) > 0 {
-		return t.transformWithRules(event)
+	// Use YAML-based rules if available, then the matched rule's own script
+	if rule := t.matchRule(event.Database, event.Table); rule != nil {
+		transformed := t.applyRule(event, rule)
+		if rule.where != nil && len(event.Rows) > 0 && len(transformed.Rows) == 0 {
+			t.logger.Debugf("Event rejected by where condition: %s.%s (type: %s)", event.Database, event.Table, event.Type)
+			return nil, ErrEventRejected
+		}
+		if rule.script != nil {
+			return t.transformWithJavaScript(rule.script, transformed)
+		}
+		return []*models.ChangeEvent{transformed}, nil
 	}
 
 	// No transformation configured, return event as-is
-	return event, nil
+	return []*models.ChangeEvent{event}, nil
 }
 
-// transformWithJavaScript transforms an event using JavaScript script
-func (t *Transformer) transformWithJavaScript(event *models.ChangeEvent) (*models.ChangeEvent, error) {
-	// Convert event to JSON for JavaScript
-	eventJSON, err := json.Marshal(event)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal event to JSON: %w", err)
+// transformWithJavaScript transforms an event using a JavaScript script
+func (t *Transformer) transformWithJavaScript(script *jsScript, event *models.ChangeEvent) ([]*models.ChangeEvent, error) {
+	// Hand the event to the script's worker pool if one is running
+	if script.jobs != nil {
+		job := jsJob{event: event, result: make(chan jsResult, 1)}
+		script.jobs <- job
+		res := <-job.result
+		return res.events, res.err
 	}
 
-	t.logger.Debugf("Transforming event with JavaScript: %s.%s (type: %s)", event.Database, event.Table, event.Type)
+	// Borrow an idle runtime, creating one if there's none (goja.Runtime is not thread-safe)
+	rt, ok := script.runtimes.Get().(*jsRuntime)
+	if !ok {
+		vm, callable, err := t.newJSRuntime(script)
+		if err != nil {
+			return nil, err
+		}
+		rt = &jsRuntime{vm: vm, callable: callable}
+	}
+	defer script.runtimes.Put(rt)
+	return t.runJavaScript(rt.vm, rt.callable, event)
+}
+
+// jsRuntime is a runtime with the script loaded
+type jsRuntime struct {
+	vm       *goja.Runtime
+	callable goja.Callable
+}
 
-	// Create a new runtime context for this transformation (goja.Runtime is not thread-safe)
+// newJSRuntime creates a runtime with bindings installed, runs the compiled script
+// and returns the script's transform function
+func (t *Transformer) newJSRuntime(script *jsScript) (*goja.Runtime, goja.Callable, error) {
 	vm := goja.New()
 
 	// Setup console bindings for JavaScript
 	if err := t.setupConsoleBindings(vm); err != nil {
-		return nil, fmt.Errorf("failed to setup console bindings: %w", err)
+		return nil, nil, fmt.Errorf("failed to setup console bindings: %w", err)
 	}
 
 	// Expose NATS functionality to JavaScript if NATS connection is available
 	if t.natsConn != nil {
 		if err := t.setupNATSBindings(vm); err != nil {
-			return nil, fmt.Errorf("failed to setup NATS bindings: %w", err)
+			return nil, nil, fmt.Errorf("failed to setup NATS bindings: %w", err)
+		}
+	}
+
+	// Expose HTTP requests to allowed hosts if enabled
+	if t.http != nil {
+		if err := t.setupHTTPBindings(vm); err != nil {
+			return nil, nil, fmt.Errorf("failed to setup HTTP bindings: %w", err)
 		}
 	}
 
 	// Execute the script - support both anonymous functions and named functions
-	scriptResult, err := vm.RunString(t.jsScript)
+	scriptResult, err := vm.RunProgram(script.program)
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute JavaScript script: %w", err)
+		return nil, nil, fmt.Errorf("failed to execute JavaScript script: %w", err)
 	}
 
 	var callable goja.Callable
@@ -194,9 +393,6 @@ func (t *Transformer) transformWithJavaScript(event *models.ChangeEvent) (*model
 	// Check if script returned an anonymous function
 	if scriptResult != nil && !goja.IsUndefined(scriptResult) && !goja.IsNull(scriptResult) {
 		callable, ok = goja.AssertFunction(scriptResult)
-		if ok {
-			// Anonymous function - use it directly
-		}
 	}
 
 	// If not anonymous function, check for named 'transform' function (backward compatibility)
@@ -208,19 +404,19 @@ func (t *Transformer) transformWithJavaScript(event *models.ChangeEvent) (*model
 	}
 
 	if !ok {
-		return nil, fmt.Errorf("script must export a function (either anonymous function or named 'transform' function)")
+		return nil, nil, fmt.Errorf("script must export a function (either anonymous function or named 'transform' function)")
 	}
 
-	// Parse event JSON in JavaScript
-	if err := vm.Set("eventJSON", string(eventJSON)); err != nil {
-		return nil, fmt.Errorf("failed to set event JSON: %w", err)
-	}
+	return vm, callable, nil
+}
 
-	parseResult, err := vm.RunString("JSON.parse(eventJSON)")
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse event JSON: %w", err)
-	}
-	eventObj := parseResult
+// runJavaScript calls the transform function on the given runtime. The function returns
+// an event, a {subject, event} object, or an array of either to publish several events.
+func (t *Transformer) runJavaScript(vm *goja.Runtime, callable goja.Callable, event *models.ChangeEvent) ([]*models.ChangeEvent, error) {
+	t.logger.Debugf("Transforming event with JavaScript: %s.%s (type: %s)", event.Database, event.Table, event.Type)
+
+	// Hand the event to JavaScript as native Go maps - no JSON encoding needed
+	eventObj := vm.ToValue(eventToJSObject(event))
 
 	// Call the transform function
 	result, err := callable(goja.Undefined(), eventObj)
@@ -235,9 +431,55 @@ func (t *Transformer) transformWithJavaScript(event *models.ChangeEvent) (*model
 		return nil, ErrEventRejected
 	}
 
-	// Convert result back to Go struct
-	exported := result.Export()
-	resultJSON, err := json.Marshal(exported)
+	// Consume the exported result directly
+	var outputs []interface{}
+	switch v := result.Export().(type) {
+	case map[string]interface{}:
+		outputs = []interface{}{v}
+	case []interface{}:
+		outputs = v
+	default:
+		return nil, fmt.Errorf("transform function must return an object or array, got %s", result.ExportType())
+	}
+
+	events := make([]*models.ChangeEvent, 0, len(outputs))
+	for i, output := range outputs {
+		if output == nil {
+			continue
+		}
+		outputMap, ok := output.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("transform function output %d must be an object, got %T", i, output)
+		}
+
+		// {subject, event} routes the event to its own subject
+		subject := ""
+		if inner, ok := outputMap["event"].(map[string]interface{}); ok {
+			if subject, ok = outputMap["subject"].(string); !ok {
+				return nil, fmt.Errorf("transform function output %d must have a string subject", i)
+			}
+			outputMap = inner
+		}
+
+		transformed, err := t.eventFromJS(outputMap)
+		if err != nil {
+			return nil, err
+		}
+		transformed.Subject = subject
+		events = append(events, transformed)
+	}
+
+	if len(events) == 0 {
+		t.logger.Infof("Event rejected by JavaScript transformer: %s.%s (type: %s)", event.Database, event.Table, event.Type)
+		return nil, ErrEventRejected
+	}
+	return events, nil
+}
+
+// eventFromJS builds a change event from an object returned by a transform function
+func (t *Transformer) eventFromJS(resultMap map[string]interface{}) (*models.ChangeEvent, error) {
+	// Marshal once for publishing, preserving extra fields added by JavaScript
+	resultJSON, err := json.Marshal(resultMap)
 	if err != nil {
 		t.logger.Errorf("Failed to marshal JavaScript result: %v", err)
 		return nil, fmt.Errorf("failed to marshal result: %w", err)
@@ -245,16 +487,9 @@ func (t *Transformer) transformWithJavaScript(event *models.ChangeEvent) (*model
 
 	t.logger.Debugf("JavaScript transformation result: %s", string(resultJSON))
 
-	// Unmarshal into a map first to preserve all fields
-	var resultMap map[string]interface{}
-	if err := json.Unmarshal(resultJSON, &resultMap); err != nil {
-		t.logger.Errorf("Failed to unmarshal JavaScript result to map: %v, JSON: %s", err, string(resultJSON))
-		return nil, fmt.Errorf("failed to unmarshal result: %w", err)
-	}
-
 	// Extract known fields for ChangeEvent struct
 	transformed := &models.ChangeEvent{}
-	
+
 	if v, ok := resultMap["type"].(string); ok {
 		transformed.Type = v
 	}
@@ -264,8 +499,14 @@ func (t *Transformer) transformWithJavaScript(event *models.ChangeEvent) (*model
 	if v, ok := resultMap["table"].(string); ok {
 		transformed.Table = v
 	}
-	if v, ok := resultMap["timestamp"].(float64); ok {
-		transformed.Timestamp = int64(v)
+	if v, ok := resultMap["id"].(string); ok {
+		transformed.ID = v
+	}
+	if v, ok := resultMap["correlation_id"].(string); ok {
+		transformed.CorrelationID = v
+	}
+	if v, ok := models.ParseTimestamp(resultMap["timestamp"]); ok {
+		transformed.Timestamp = v
 	}
 	if v, ok := resultMap["rows"].([]interface{}); ok {
 		transformed.Rows = make([]map[string]interface{}, 0, len(v))
@@ -287,54 +528,223 @@ func (t *Transformer) transformWithJavaScript(event *models.ChangeEvent) (*model
 	// Store the raw JSON to preserve extra fields added by JavaScript
 	// The publisher will use this if available
 	transformed.RawJSON = resultJSON
-	
+
 	t.logger.Debugf("Successfully transformed event: %s.%s", transformed.Database, transformed.Table)
 	return transformed, nil
 }
 
-// transformWithRules transforms an event using YAML-based rules
-func (t *Transformer) transformWithRules(event *models.ChangeEvent) (*models.ChangeEvent, error) {
-	// Find matching rule
-	var matchedRule *RuleMatcher
-	for _, rule := range t.rules {
-		if rule.matches(event.Database, event.Table) {
-			matchedRule = rule
-			break
+// eventToJSObject converts an event to the plain map/slice form exposed to JavaScript.
+// Field names match the event's JSON encoding and binary values are base64-encoded
+// strings, so scripts see the same shape consumers do.
+func eventToJSObject(event *models.ChangeEvent) map[string]interface{} {
+	obj := map[string]interface{}{
+		"type":      event.Type,
+		"database":  event.Database,
+		"table":     event.Table,
+		"timestamp": event.Timestamp.Value(),
+		"rows":      rowsToJS(event.Rows),
+	}
+	if event.CommitTS > 0 {
+		obj["commit_ts"] = event.CommitTS
+	}
+	if event.ProcessedTS > 0 {
+		obj["processed_ts"] = event.ProcessedTS
+	}
+	if len(event.OldRows) > 0 {
+		obj["old_rows"] = rowsToJS(event.OldRows)
+	}
+	if len(event.QueryContext) > 0 {
+		queryContext := make(map[string]interface{}, len(event.QueryContext))
+		for k, v := range event.QueryContext {
+			queryContext[k] = v
+		}
+		obj["query_context"] = queryContext
+	}
+	for key, value := range map[string]string{
+		"id":             event.ID,
+		"gtid":           event.GTID,
+		"binlog_file":    event.BinlogFile,
+		"transaction_id": event.TransactionID,
+		"schema_version": event.SchemaVersion,
+		"correlation_id": event.CorrelationID,
+		"statement":      event.Statement,
+	} {
+		if value != "" {
+			obj[key] = value
+		}
+	}
+	if event.BestEffort {
+		obj["best_effort"] = true
+	}
+	if event.BinlogPos > 0 {
+		obj["binlog_pos"] = event.BinlogPos
+	}
+	if event.Parts > 0 {
+		obj["part"] = event.Part
+		obj["parts"] = event.Parts
+	}
+	if event.Transaction != nil {
+		transaction := map[string]interface{}{
+			"id":       event.Transaction.ID,
+			"sequence": event.Transaction.Sequence,
+		}
+		if event.Transaction.XID > 0 {
+			transaction["xid"] = event.Transaction.XID
+		}
+		if event.Transaction.Last {
+			transaction["last"] = true
+		}
+		obj["transaction"] = transaction
+	}
+	if len(event.PrimaryKey) > 0 {
+		primaryKey := make([]interface{}, len(event.PrimaryKey))
+		for i, col := range event.PrimaryKey {
+			primaryKey[i] = col
+		}
+		obj["primary_key"] = primaryKey
+	}
+	if len(event.RowKey) > 0 {
+		rowKey := make([]interface{}, len(event.RowKey))
+		for i, col := range event.RowKey {
+			rowKey[i] = col
+		}
+		obj["row_key"] = rowKey
+	}
+	if len(event.Schema) > 0 {
+		schema := make([]interface{}, len(event.Schema))
+		for i, col := range event.Schema {
+			column := map[string]interface{}{
+				"name":     col.Name,
+				"type":     col.Type,
+				"nullable": col.Nullable,
+			}
+			if col.PrimaryKey {
+				column["primary_key"] = true
+			}
+			if col.Invisible {
+				column["invisible"] = true
+			}
+			if col.Comment != "" {
+				column["comment"] = col.Comment
+			}
+			schema[i] = column
+		}
+		obj["schema"] = schema
+	}
+	return obj
+}
+
+// rowsToJS converts rows to a slice of maps that JavaScript can modify in place
+func rowsToJS(rows []map[string]interface{}) []interface{} {
+	out := make([]interface{}, len(rows))
+	for i, row := range rows {
+		copied := make(map[string]interface{}, len(row))
+		for k, v := range row {
+			switch value := v.(type) {
+			case []byte:
+				v = base64.StdEncoding.EncodeToString(value)
+			case map[string]interface{}, []interface{}:
+				v = jsonNumbersToJS(value)
+			}
+			copied[k] = v
+		}
+		out[i] = copied
+	}
+	return out
+}
+
+// jsonNumbersToJS copies a JSON column value parsed by events.types, turning its
+// exact json.Number numbers into JavaScript numbers
+func jsonNumbersToJS(v interface{}) interface{} {
+	switch value := v.(type) {
+	case json.Number:
+		f, err := value.Float64()
+		if err != nil {
+			return value.String()
 		}
+		return f
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(value))
+		for k, elem := range value {
+			copied[k] = jsonNumbersToJS(elem)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(value))
+		for i, elem := range value {
+			copied[i] = jsonNumbersToJS(elem)
+		}
+		return copied
 	}
+	return v
+}
 
-	// If no rule matches, return event as-is
-	if matchedRule == nil {
-		return event, nil
+// matchRule returns the first rule matching the table, or nil
+func (t *Transformer) matchRule(database, table string) *RuleMatcher {
+	for _, rule := range t.rules {
+		if rule.matches(database, table) {
+			return rule
+		}
 	}
+	return nil
+}
 
+// applyRule transforms an event using a YAML-based rule
+func (t *Transformer) applyRule(event *models.ChangeEvent, matchedRule *RuleMatcher) *models.ChangeEvent {
 	// Create a copy of the event for transformation
 	transformed := &models.ChangeEvent{
-		Type:      event.Type,
-		Database:  event.Database,
-		Table:     event.Table,
-		Timestamp: event.Timestamp,
-		Rows:      make([]map[string]interface{}, 0, len(event.Rows)),
-		OldRows:   make([]map[string]interface{}, 0, len(event.OldRows)),
-	}
+		ID:           event.ID,
+		Type:         event.Type,
+		Database:     event.Database,
+		Table:        event.Table,
+		Timestamp:    event.Timestamp,
+		CommitTS:     event.CommitTS,
+		ProcessedTS:  event.ProcessedTS,
+		Rows:         make([]map[string]interface{}, 0, len(event.Rows)),
+		OldRows:      make([]map[string]interface{}, 0, len(event.OldRows)),
+		QueryContext: event.QueryContext,
+		PrimaryKey:   renameColumns(event.PrimaryKey, matchedRule),
+		RowKey:       renameColumns(event.RowKey, matchedRule),
+		Keyless:      event.Keyless,
+		Schema:       transformSchema(event.Schema, matchedRule),
+
+		GTID:          event.GTID,
+		BinlogFile:    event.BinlogFile,
+		BinlogPos:     event.BinlogPos,
+		TransactionID: event.TransactionID,
+		SchemaVersion: event.SchemaVersion,
+		CorrelationID: event.CorrelationID,
+		Subject:       event.Subject,
+		Part:          event.Part,
+		Parts:         event.Parts,
+		Transaction:   event.Transaction,
+		BestEffort:    event.BestEffort,
+		Statement:     event.Statement,
+		Origin:        event.Origin,
+	}
+
+	// Transform rows and their old rows (for UPDATE events), skipping the rows
+	// the rule's where condition doesn't match
+	for i, row := range event.Rows {
+		var oldRow map[string]interface{}
+		if i < len(event.OldRows) {
+			oldRow = event.OldRows[i]
+		}
+		if matchedRule.where != nil && !matchedRule.where.Match(row, oldRow) {
+			continue
+		}
 
-	// Transform rows
-	for _, row := range event.Rows {
 		transformedRow := t.transformRow(row, matchedRule)
 		if transformedRow != nil {
 			transformed.Rows = append(transformed.Rows, transformedRow)
 		}
-	}
-
-	// Transform old rows (for UPDATE events)
-	for _, oldRow := range event.OldRows {
 		transformedOldRow := t.transformRow(oldRow, matchedRule)
 		if transformedOldRow != nil {
 			transformed.OldRows = append(transformed.OldRows, transformedOldRow)
 		}
 	}
 
-	return transformed, nil
+	return transformed
 }
 
 // transformRow applies transformation rules to a single row
@@ -364,6 +774,20 @@ func (t *Transformer) transformRow(row map[string]interface{}, rule *RuleMatcher
 			continue
 		}
 
+		// Anonymize, mask or hash the value if configured for this field
+		if anonymizer, ok := rule.anonymize[keyLower]; ok {
+			value = anonymizer.Apply(value)
+		} else if masker, ok := rule.mask[keyLower]; ok {
+			value = masker.Apply(value)
+		} else if hasher, ok := rule.hash[keyLower]; ok {
+			value = hasher.Apply(value)
+		} else if encoder, ok := rule.binary[keyLower]; ok {
+			var keep bool
+			if value, keep = encoder.Apply(value); !keep {
+				continue
+			}
+		}
+
 		// Determine the output key name (rename if specified)
 		outputKey := key
 		if newName, ok := rule.rename[keyLower]; ok {
@@ -376,15 +800,100 @@ func (t *Transformer) transformRow(row map[string]interface{}, rule *RuleMatcher
 	return transformed
 }
 
+// renameColumns applies the rule's rename settings to a list of column names
+func renameColumns(columns []string, rule *RuleMatcher) []string {
+	if len(columns) == 0 || len(rule.rename) == 0 {
+		return columns
+	}
+	renamed := make([]string, len(columns))
+	for i, col := range columns {
+		if newName, ok := rule.rename[strings.ToLower(col)]; ok {
+			col = newName
+		}
+		renamed[i] = col
+	}
+	return renamed
+}
+
+// transformSchema applies the rule's include, exclude and rename settings to column metadata
+func transformSchema(schema []models.ColumnSchema, rule *RuleMatcher) []models.ColumnSchema {
+	if len(schema) == 0 {
+		return nil
+	}
+
+	transformed := make([]models.ColumnSchema, 0, len(schema))
+	for _, col := range schema {
+		nameLower := strings.ToLower(col.Name)
+		if len(rule.exclude) > 0 && rule.exclude[nameLower] {
+			continue
+		}
+		if len(rule.include) > 0 && !rule.include[nameLower] {
+			continue
+		}
+		if encoder, ok := rule.binary[nameLower]; ok && encoder.mode == BinarySkip {
+			continue
+		}
+		if newName, ok := rule.rename[nameLower]; ok {
+			col.Name = newName
+		}
+		transformed = append(transformed, col)
+	}
+	return transformed
+}
+
+// RuleColumns returns the columns the rule applied to a table refers to by name,
+// with the rule settings (include, exclude, rename, anonymize, mask, hash, binary, where) that refer to them
+func (t *Transformer) RuleColumns(database, table string) map[string][]string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for _, rule := range t.rules {
+		if !rule.matches(database, table) {
+			continue
+		}
+		columns := make(map[string][]string)
+		for col := range rule.include {
+			columns[col] = append(columns[col], "include")
+		}
+		for col := range rule.exclude {
+			columns[col] = append(columns[col], "exclude")
+		}
+		for col := range rule.rename {
+			key := strings.ToLower(col)
+			columns[key] = append(columns[key], "rename")
+		}
+		for col := range rule.anonymize {
+			columns[col] = append(columns[col], "anonymize")
+		}
+		for col := range rule.mask {
+			columns[col] = append(columns[col], "mask")
+		}
+		for col := range rule.hash {
+			columns[col] = append(columns[col], "hash")
+		}
+		for col := range rule.binary {
+			columns[col] = append(columns[col], "binary")
+		}
+		if rule.where != nil {
+			for _, col := range rule.where.Columns() {
+				if settings := columns[col]; len(settings) == 0 || settings[len(settings)-1] != "where" {
+					columns[col] = append(settings, "where")
+				}
+			}
+		}
+		return columns
+	}
+	return nil
+}
+
 // matches checks if a rule matches the given database and table
 func (r *RuleMatcher) matches(database, table string) bool {
 	// Match database (empty = all databases)
-	if r.database != "" && !strings.EqualFold(r.database, database) {
+	if r.database != "" && !namesEqual(r.database, database) {
 		return false
 	}
 
 	// Match table (empty = all tables)
-	if r.table != "" && !strings.EqualFold(r.table, table) {
+	if r.table != "" && !namesEqual(r.table, table) {
 		return false
 	}
 
@@ -657,11 +1166,61 @@ func ValidateRules(cfg *config.ProcessorConfig) error {
 	}
 
 	for i, rule := range cfg.Rules {
+		if rule.Where != "" {
+			if _, err := ParseExpression(rule.Where); err != nil {
+				return fmt.Errorf("processor rule %d: invalid where: %w", i, err)
+			}
+		}
+		if rule.Script != "" {
+			if _, err := os.Stat(rule.Script); os.IsNotExist(err) {
+				return fmt.Errorf("processor rule %d: JavaScript script file not found: %s", i, rule.Script)
+			}
+		}
+
 		// Validate that include and exclude are not both specified
 		if len(rule.Include) > 0 && len(rule.Exclude) > 0 {
 			return fmt.Errorf("processor rule %d: cannot specify both 'include' and 'exclude' fields", i)
 		}
 
+		for field, preset := range rule.Anonymize {
+			if _, err := NewAnonymizer(preset, cfg.AnonymizeSalt); err != nil {
+				return fmt.Errorf("processor rule %d: field '%s': %w", i, field, err)
+			}
+		}
+
+		// Each column can be anonymized, masked or hashed, but only one of them
+		redacted := make(map[string]string)
+		for field := range rule.Anonymize {
+			redacted[strings.ToLower(field)] = "anonymize"
+		}
+		for _, spec := range rule.Mask {
+			field, _, err := NewMasker(spec)
+			if err != nil {
+				return fmt.Errorf("processor rule %d: %w", i, err)
+			}
+			if setting, ok := redacted[strings.ToLower(field)]; ok {
+				return fmt.Errorf("processor rule %d: field '%s' is in both '%s' and 'mask'", i, field, setting)
+			}
+			redacted[strings.ToLower(field)] = "mask"
+		}
+		for field, algorithm := range rule.Hash {
+			if _, err := NewHasher(algorithm, cfg.AnonymizeSalt); err != nil {
+				return fmt.Errorf("processor rule %d: field '%s': %w", i, field, err)
+			}
+			if setting, ok := redacted[strings.ToLower(field)]; ok {
+				return fmt.Errorf("processor rule %d: field '%s' is in both '%s' and 'hash'", i, field, setting)
+			}
+			redacted[strings.ToLower(field)] = "hash"
+		}
+		for field, spec := range rule.Binary {
+			if _, err := NewBinaryEncoder(spec); err != nil {
+				return fmt.Errorf("processor rule %d: field '%s': %w", i, field, err)
+			}
+			if setting, ok := redacted[strings.ToLower(field)]; ok {
+				return fmt.Errorf("processor rule %d: field '%s' is in both '%s' and 'binary'", i, field, setting)
+			}
+		}
+
 		// Validate rename keys exist in include list if include is specified
 		// If exclude is specified, rename can be used for any field not in exclude
 		// If neither is specified, rename can be used for any field
@@ -683,4 +1242,3 @@ func ValidateRules(cfg *config.ProcessorConfig) error {
 
 	return nil
 }
-
@@ -0,0 +1,159 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+
+	"mysql-cdc/internal/config"
+	"mysql-cdc/internal/models"
+)
+
+// defaultWorkerQueueSize is the number of events buffered per worker
+const defaultWorkerQueueSize = 1000
+
+// WorkerPool transforms and publishes events in parallel. Events are assigned to a
+// worker by hashing their ordering key (table or primary key), so events with the
+// same key are always handled by the same worker, in order.
+type WorkerPool struct {
+	config  *config.PipelineConfig
+	queues  []chan *models.ChangeEvent
+	deliver func(ctx context.Context, event *models.ChangeEvent)
+	logger  *logrus.Logger
+}
+
+// NewWorkerPool creates a new worker pool
+func NewWorkerPool(cfg *config.PipelineConfig, deliver func(ctx context.Context, event *models.ChangeEvent), logger *logrus.Logger) *WorkerPool {
+	size := cfg.QueueSize
+	if size <= 0 {
+		size = defaultWorkerQueueSize
+	}
+
+	queues := make([]chan *models.ChangeEvent, cfg.Workers)
+	for i := range queues {
+		queues[i] = make(chan *models.ChangeEvent, size)
+	}
+
+	return &WorkerPool{
+		config:  cfg,
+		queues:  queues,
+		deliver: deliver,
+		logger:  logger,
+	}
+}
+
+// Start starts the workers
+func (w *WorkerPool) Start(ctx context.Context) {
+	for i, queue := range w.queues {
+		go w.run(ctx, queue)
+		w.logger.Debugf("Started pipeline worker %d", i)
+	}
+}
+
+// Submit queues an event on its worker, blocking if the worker's queue is full.
+// With the primary_key ordering key, multi-row events are split into single-row
+// events so each row can go to the worker that owns its key.
+func (w *WorkerPool) Submit(ctx context.Context, event *models.ChangeEvent) {
+	if w.config.Key == "primary_key" && len(rowKey(event)) > 0 && len(event.Rows) > 1 {
+		for _, single := range splitRows(event) {
+			w.enqueue(ctx, single)
+		}
+		return
+	}
+	w.enqueue(ctx, event)
+}
+
+// enqueue sends the event to the worker that owns its key
+func (w *WorkerPool) enqueue(ctx context.Context, event *models.ChangeEvent) {
+	h := fnv.New32a()
+	h.Write([]byte(w.key(event)))
+	queue := w.queues[h.Sum32()%uint32(len(w.queues))]
+
+	select {
+	case queue <- event:
+	case <-ctx.Done():
+	}
+}
+
+// key returns the ordering key of an event
+func (w *WorkerPool) key(event *models.ChangeEvent) string {
+	key := tableKey(event.Database, event.Table)
+	columns := rowKey(event)
+	if w.config.Key != "primary_key" || len(columns) == 0 || len(event.Rows) == 0 {
+		return key
+	}
+	// An UPDATE that changes the key is ordered with the events of its old key, so
+	// it's delivered after them and before a row reusing the old key
+	row := event.Rows[0]
+	if len(event.OldRows) > 0 {
+		row = event.OldRows[0]
+	}
+	for _, col := range columns {
+		key += fmt.Sprintf("|%v", row[col])
+	}
+	return key
+}
+
+// rowKey returns the columns identifying an event's rows: the primary key, or for
+// tables without one, the row key (unique key or full row)
+func rowKey(event *models.ChangeEvent) []string {
+	if len(event.PrimaryKey) > 0 {
+		return event.PrimaryKey
+	}
+	return event.RowKey
+}
+
+// run delivers events from a worker's queue until the context is cancelled
+func (w *WorkerPool) run(ctx context.Context, queue chan *models.ChangeEvent) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-queue:
+			w.deliver(ctx, event)
+		}
+	}
+}
+
+// splitRows splits a multi-row event into one event per row
+func splitRows(event *models.ChangeEvent) []*models.ChangeEvent {
+	// The original event is done once all of its parts are
+	onDone := event.OnDone
+	if onDone != nil {
+		remaining := int32(len(event.Rows))
+		onDone = func() {
+			if atomic.AddInt32(&remaining, -1) == 0 {
+				event.OnDone()
+			}
+		}
+	}
+
+	events := make([]*models.ChangeEvent, 0, len(event.Rows))
+	for i, row := range event.Rows {
+		single := *event
+		single.Rows = []map[string]interface{}{row}
+		single.OldRows = nil
+		if i < len(event.OldRows) {
+			single.OldRows = []map[string]interface{}{event.OldRows[i]}
+		}
+		single.OnDone = onDone
+		if event.Transaction != nil && event.Transaction.Last && i < len(event.Rows)-1 {
+			// Only the final row is the last of its transaction
+			transaction := *event.Transaction
+			transaction.Last = false
+			transaction.XID = 0
+			single.Transaction = &transaction
+		}
+		if event.ID != "" {
+			single.ID = fmt.Sprintf("%s/%d", event.ID, i)
+		}
+		if event.DedupID != "" {
+			single.DedupID = fmt.Sprintf("%s/%d", event.DedupID, i)
+		}
+		events = append(events, &single)
+	}
+	return events
+}
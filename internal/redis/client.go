@@ -0,0 +1,147 @@
+// Package redis is a minimal Redis client. It speaks just enough of the Redis
+// protocol (RESP) for the position store and the Redis Streams sink.
+package redis
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Options are the connection settings of a client
+type Options struct {
+	Address  string
+	Password string
+	DB       int
+	Timeout  time.Duration // Dial, read and write timeout
+}
+
+// Client is a connection to a Redis server, reconnecting as needed
+type Client struct {
+	opts Options
+	mu   sync.Mutex
+	conn net.Conn
+	rd   *bufio.Reader
+}
+
+// Dial connects to a server, authenticating and selecting the database
+func Dial(opts Options) (*Client, error) {
+	c := &Client{opts: opts}
+	if err := c.connect(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Error is an error reply from the server
+type Error string
+
+func (e Error) Error() string { return string(e) }
+
+// Do runs a command, reconnecting first if the connection was lost. The reply is
+// a string for simple strings, []byte for bulk strings, nil for a null reply or
+// an int64 for integers; error replies are returned as Error.
+func (c *Client) Do(args ...string) (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		if err := c.connect(); err != nil {
+			return nil, err
+		}
+	}
+	reply, err := c.command(args...)
+	if _, ok := err.(Error); !ok && err != nil {
+		// The connection is in an unknown state
+		c.conn.Close()
+		c.conn = nil
+	}
+	return reply, err
+}
+
+// Close closes the connection
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+// connect dials the server, authenticates and selects the database
+func (c *Client) connect() error {
+	conn, err := net.DialTimeout("tcp", c.opts.Address, c.opts.Timeout)
+	if err != nil {
+		return fmt.Errorf("failed to connect to Redis at %s: %w", c.opts.Address, err)
+	}
+	c.conn = conn
+	c.rd = bufio.NewReader(conn)
+	if c.opts.Password != "" {
+		if _, err := c.command("AUTH", c.opts.Password); err != nil {
+			c.conn.Close()
+			c.conn = nil
+			return fmt.Errorf("redis AUTH failed: %w", err)
+		}
+	}
+	if c.opts.DB != 0 {
+		if _, err := c.command("SELECT", strconv.Itoa(c.opts.DB)); err != nil {
+			c.conn.Close()
+			c.conn = nil
+			return fmt.Errorf("redis SELECT failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// command sends a command and reads its reply
+func (c *Client) command(args ...string) (interface{}, error) {
+	c.conn.SetDeadline(time.Now().Add(c.opts.Timeout))
+
+	var req strings.Builder
+	fmt.Fprintf(&req, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&req, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(c.conn, req.String()); err != nil {
+		return nil, err
+	}
+
+	line, err := c.rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("empty reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, Error(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid bulk length %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(c.rd, data); err != nil {
+			return nil, err
+		}
+		return data[:n], nil
+	default:
+		return nil, fmt.Errorf("unexpected reply %q", line)
+	}
+}
@@ -0,0 +1,243 @@
+package reporting
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"mysql-cdc/internal/config"
+)
+
+// Report is the body posted to the error webhook
+type Report struct {
+	Timestamp   int64                  `json:"timestamp"`
+	Level       string                 `json:"level"` // error, or fatal for panics
+	Message     string                 `json:"message"`
+	Count       int                    `json:"count,omitempty"` // Occurrences within the window, for repeated errors
+	Stack       string                 `json:"stack,omitempty"` // Goroutine stack, for panics
+	Host        string                 `json:"host,omitempty"`
+	Environment string                 `json:"environment,omitempty"`
+	Context     map[string]interface{} `json:"context,omitempty"` // Position, table and event sample
+}
+
+// Reporter sends panics and repeated pipeline errors to Sentry and/or a generic webhook
+type Reporter struct {
+	config *config.ErrorReportingConfig
+	sentry *sentryTarget // nil unless sentry_dsn is set
+	client *http.Client
+	host   string
+	logger *logrus.Logger
+
+	mu     sync.Mutex
+	errors map[string]*errorWindow // Occurrences of each kind of error in the current window
+}
+
+// errorWindow counts occurrences of a kind of error since the window started
+type errorWindow struct {
+	start    time.Time
+	count    int
+	reported bool
+}
+
+// sentryTarget is the store endpoint and auth header derived from a Sentry DSN
+type sentryTarget struct {
+	url  string
+	auth string
+}
+
+// reported wraps a panic value that has already been reported, so it isn't
+// reported again by a recover further up the stack
+type reported struct {
+	value interface{}
+}
+
+func (r reported) Error() string {
+	return fmt.Sprint(r.value)
+}
+
+// NewReporter creates a new error reporter. Returns nil if neither Sentry nor a webhook is configured.
+func NewReporter(cfg *config.ErrorReportingConfig, logger *logrus.Logger) (*Reporter, error) {
+	if cfg.SentryDSN == "" && cfg.WebhookURL == "" {
+		return nil, nil
+	}
+
+	r := &Reporter{
+		config: cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+		logger: logger,
+		errors: make(map[string]*errorWindow),
+	}
+	r.host, _ = os.Hostname()
+
+	if cfg.SentryDSN != "" {
+		target, err := parseDSN(cfg.SentryDSN)
+		if err != nil {
+			return nil, err
+		}
+		r.sentry = target
+	}
+	return r, nil
+}
+
+// parseDSN derives the store endpoint from a DSN like https://<key>@<host>/<project>
+func parseDSN(dsn string) (*sentryTarget, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sentry_dsn: %w", err)
+	}
+	key := u.User.Username()
+	path := strings.TrimSuffix(u.Path, "/")
+	i := strings.LastIndex(path, "/")
+	if key == "" || i < 0 || path[i+1:] == "" {
+		return nil, fmt.Errorf("invalid sentry_dsn: expected <scheme>://<key>@<host>/<project>")
+	}
+	return &sentryTarget{
+		url:  fmt.Sprintf("%s://%s%s/api/%s/store/", u.Scheme, u.Host, path[:i], path[i+1:]),
+		auth: fmt.Sprintf("Sentry sentry_version=7, sentry_client=mysql-cdc, sentry_key=%s", key),
+	}, nil
+}
+
+// Error records an occurrence of an error. Errors of the same kind are reported
+// once they occur threshold times within the window, then at most once per window.
+func (r *Reporter) Error(kind string, err error, context map[string]interface{}) {
+	now := time.Now()
+
+	r.mu.Lock()
+	w, ok := r.errors[kind]
+	if !ok || now.Sub(w.start) >= r.config.Window {
+		w = &errorWindow{start: now}
+		r.errors[kind] = w
+	}
+	w.count++
+	report := w.count >= r.config.Threshold && !w.reported
+	if report {
+		w.reported = true
+	}
+	count := w.count
+	r.mu.Unlock()
+
+	if report {
+		go r.send(&Report{
+			Timestamp: now.Unix(),
+			Level:     "error",
+			Message:   err.Error(),
+			Count:     count,
+			Context:   context,
+		})
+	}
+}
+
+// Panic reports a recovered panic and returns the value to re-panic with
+func (r *Reporter) Panic(value interface{}, context map[string]interface{}) interface{} {
+	if _, ok := value.(reported); ok {
+		return value
+	}
+	r.send(&Report{
+		Timestamp: time.Now().Unix(),
+		Level:     "fatal",
+		Message:   fmt.Sprintf("panic: %v", value),
+		Stack:     string(debug.Stack()),
+		Context:   context,
+	})
+	return reported{value: value}
+}
+
+// Recover reports a panic unwinding the calling goroutine, then lets it continue.
+// Use with defer; does nothing on a nil reporter.
+func (r *Reporter) Recover() {
+	if r == nil {
+		return
+	}
+	if value := recover(); value != nil {
+		panic(r.Panic(value, nil))
+	}
+}
+
+// send delivers a report to the configured targets
+func (r *Reporter) send(report *Report) {
+	report.Host = r.host
+	report.Environment = r.config.Environment
+
+	if r.sentry != nil {
+		if err := r.post(r.sentry.url, sentryEvent(report), map[string]string{"X-Sentry-Auth": r.sentry.auth}); err != nil {
+			r.logger.Warnf("Failed to report error to Sentry: %v", err)
+		}
+	}
+	if r.config.WebhookURL != "" {
+		if err := r.post(r.config.WebhookURL, report, nil); err != nil {
+			r.logger.Warnf("Failed to report error to webhook: %v", err)
+		}
+	}
+}
+
+// post sends v as JSON to the given URL
+func (r *Reporter) post(target string, v interface{}, headers map[string]string) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal report: %w", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// sentryEvent converts a report to a Sentry store API event
+func sentryEvent(report *Report) map[string]interface{} {
+	id := make([]byte, 16)
+	rand.Read(id)
+
+	extra := make(map[string]interface{}, len(report.Context)+2)
+	for k, v := range report.Context {
+		extra[k] = v
+	}
+	if report.Count > 0 {
+		extra["count"] = report.Count
+	}
+	if report.Stack != "" {
+		extra["stack"] = report.Stack
+	}
+
+	event := map[string]interface{}{
+		"event_id":    hex.EncodeToString(id),
+		"timestamp":   time.Unix(report.Timestamp, 0).UTC().Format(time.RFC3339),
+		"level":       report.Level,
+		"logger":      "mysql-cdc",
+		"platform":    "go",
+		"message":     report.Message,
+		"server_name": report.Host,
+		"extra":       extra,
+	}
+	if report.Environment != "" {
+		event["environment"] = report.Environment
+	}
+	if stage, ok := report.Context["stage"].(string); ok {
+		event["tags"] = map[string]string{"stage": stage}
+	}
+	return event
+}
@@ -0,0 +1,95 @@
+package sink
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"mysql-cdc/internal/config"
+	"mysql-cdc/internal/kafka"
+	"mysql-cdc/internal/models"
+	"mysql-cdc/internal/nats"
+)
+
+// kafkaAcks maps the acks setting to the acknowledgement produce requests ask for
+var kafkaAcks = map[string]int16{"all": -1, "leader": 1, "none": 0}
+
+// kafkaSink produces each change event to a Kafka topic, keyed by the primary key
+// of its first row so a row's changes stay in one partition
+type kafkaSink struct {
+	config   *config.SinkConfig
+	format   string // sinks[].format
+	producer *kafka.Producer
+}
+
+func newKafkaSink(cfg *config.SinkConfig, format string, logger *logrus.Logger) (Sink, error) {
+	opts := kafka.Options{
+		Brokers:     cfg.Brokers,
+		ClientID:    "mysql-cdc",
+		Username:    cfg.Username,
+		Password:    cfg.Password,
+		Acks:        kafkaAcks[cfg.Acks],
+		Compression: cfg.Compression,
+		Timeout:     cfg.Timeout,
+	}
+	if cfg.TLS {
+		opts.TLS = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	producer, err := kafka.Dial(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &kafkaSink{config: cfg, format: format, producer: producer}, nil
+}
+
+func (s *kafkaSink) Publish(event *models.ChangeEvent) error {
+	data, err := encode(event, s.format)
+	if err != nil {
+		return err
+	}
+	topic := strings.NewReplacer(
+		"{database}", event.Database,
+		"{table}", event.Table,
+		"{type}", strings.ToLower(event.Type),
+	).Replace(s.config.Topic)
+
+	var headers []kafka.Header
+	if event.ID != "" {
+		headers = append(headers, kafka.Header{Key: nats.EventIDHeader, Value: []byte(event.ID)})
+	}
+	if event.CorrelationID != "" {
+		headers = append(headers, kafka.Header{Key: nats.CorrelationIDHeader, Value: []byte(event.CorrelationID)})
+	}
+	if err := s.producer.Produce(topic, messageKey(event), data, headers); err != nil {
+		return fmt.Errorf("failed to produce event to topic %s: %w", topic, err)
+	}
+	return nil
+}
+
+func (s *kafkaSink) Close() error {
+	return s.producer.Close()
+}
+
+// messageKey returns the key columns of an event's first row as a JSON object,
+// or nil for events without rows or key columns
+func messageKey(event *models.ChangeEvent) []byte {
+	columns := event.PrimaryKey
+	if len(columns) == 0 {
+		columns = event.RowKey
+	}
+	if len(columns) == 0 || len(event.Rows) == 0 {
+		return nil
+	}
+	key := make(map[string]interface{}, len(columns))
+	for _, col := range columns {
+		key[col] = event.Rows[0][col]
+	}
+	data, err := json.Marshal(key)
+	if err != nil {
+		return nil
+	}
+	return data
+}
@@ -0,0 +1,234 @@
+package sink
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+)
+
+// Parquet physical types, converted types and codecs used by the writer
+const (
+	parquetInt64     = 2
+	parquetByteArray = 6
+
+	parquetNone            = -1 // No converted type
+	parquetUTF8            = 0
+	parquetTimestampMillis = 9
+
+	parquetPlain = 0
+	parquetRLE   = 3
+
+	parquetUncompressed = 0
+	parquetGzip         = 2
+)
+
+// parquetColumn is a required column of a Parquet file
+type parquetColumn struct {
+	name      string
+	kind      int32 // parquetInt64 or parquetByteArray
+	converted int32
+	values    func(i int) interface{} // int64 or string value of row i
+}
+
+// writeParquet encodes rows as a Parquet file with one row group and one PLAIN
+// data page per column, gzipped if compress is set. Columns are required, so
+// pages carry no levels.
+func writeParquet(columns []parquetColumn, rows int, compress bool) ([]byte, error) {
+	var file bytes.Buffer
+	file.WriteString("PAR1")
+
+	var chunks []thriftWriter
+	var totalSize int64
+	for _, column := range columns {
+		var plain bytes.Buffer
+		for i := 0; i < rows; i++ {
+			switch v := column.values(i).(type) {
+			case int64:
+				binary.Write(&plain, binary.LittleEndian, v)
+			case string:
+				binary.Write(&plain, binary.LittleEndian, uint32(len(v)))
+				plain.WriteString(v)
+			}
+		}
+		compressed, codec := &plain, int32(parquetUncompressed)
+		if compress {
+			compressed, codec = new(bytes.Buffer), parquetGzip
+			zw := gzip.NewWriter(compressed)
+			zw.Write(plain.Bytes())
+			if err := zw.Close(); err != nil {
+				return nil, err
+			}
+		}
+
+		var header thriftWriter
+		header.i32(1, 0) // DATA_PAGE
+		header.i32(2, int32(plain.Len()))
+		header.i32(3, int32(compressed.Len()))
+		header.beginStruct(5)
+		header.i32(1, int32(rows))
+		header.i32(2, parquetPlain)
+		header.i32(3, parquetRLE)
+		header.i32(4, parquetRLE)
+		header.endStruct()
+		header.stop()
+
+		offset := int64(file.Len())
+		file.Write(header.buf.Bytes())
+		file.Write(compressed.Bytes())
+		uncompressedSize := int64(header.buf.Len() + plain.Len())
+		compressedSize := int64(header.buf.Len() + compressed.Len())
+		totalSize += uncompressedSize
+
+		var chunk thriftWriter
+		chunk.i64(2, offset)
+		chunk.beginStruct(3)
+		chunk.i32(1, column.kind)
+		chunk.listI32(2, parquetPlain, parquetRLE)
+		chunk.listString(3, column.name)
+		chunk.i32(4, codec)
+		chunk.i64(5, int64(rows))
+		chunk.i64(6, uncompressedSize)
+		chunk.i64(7, compressedSize)
+		chunk.i64(9, offset)
+		chunk.endStruct()
+		chunk.stop()
+		chunks = append(chunks, chunk)
+	}
+
+	var meta thriftWriter
+	meta.i32(1, 1)
+	meta.beginList(2, thriftStruct, len(columns)+1)
+	meta.elem()
+	meta.str(4, "schema")
+	meta.i32(5, int32(len(columns)))
+	meta.stop()
+	for _, column := range columns {
+		meta.elem()
+		meta.i32(1, column.kind)
+		meta.i32(3, 0) // REQUIRED
+		meta.str(4, column.name)
+		if column.converted != parquetNone {
+			meta.i32(6, column.converted)
+		}
+		meta.stop()
+	}
+	meta.i64(3, int64(rows))
+	meta.beginList(4, thriftStruct, 1)
+	meta.elem()
+	meta.beginList(1, thriftStruct, len(chunks))
+	for _, chunk := range chunks {
+		meta.buf.Write(chunk.buf.Bytes())
+	}
+	meta.i64(2, totalSize)
+	meta.i64(3, int64(rows))
+	meta.stop()
+	meta.str(6, "mysql-cdc")
+	meta.stop()
+
+	file.Write(meta.buf.Bytes())
+	binary.Write(&file, binary.LittleEndian, uint32(meta.buf.Len()))
+	file.WriteString("PAR1")
+	return file.Bytes(), nil
+}
+
+// Thrift compact protocol types
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter writes the Thrift compact protocol encoding of the Parquet
+// metadata structs, tracking the last field ID of each open struct
+type thriftWriter struct {
+	buf   bytes.Buffer
+	last  int16
+	outer []int16
+}
+
+func (w *thriftWriter) field(id int16, kind byte) {
+	if delta := id - w.last; delta > 0 && delta <= 15 {
+		w.buf.WriteByte(byte(delta)<<4 | kind)
+	} else {
+		w.buf.WriteByte(kind)
+		w.zigzag(int64(id))
+	}
+	w.last = id
+}
+
+func (w *thriftWriter) varint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	w.buf.Write(b[:binary.PutUvarint(b[:], v)])
+}
+
+func (w *thriftWriter) zigzag(v int64) { w.varint(uint64(v<<1 ^ v>>63)) }
+
+func (w *thriftWriter) i32(id int16, v int32) {
+	w.field(id, thriftI32)
+	w.zigzag(int64(v))
+}
+
+func (w *thriftWriter) i64(id int16, v int64) {
+	w.field(id, thriftI64)
+	w.zigzag(v)
+}
+
+func (w *thriftWriter) str(id int16, s string) {
+	w.field(id, thriftBinary)
+	w.varint(uint64(len(s)))
+	w.buf.WriteString(s)
+}
+
+func (w *thriftWriter) beginStruct(id int16) {
+	w.field(id, thriftStruct)
+	w.outer = append(w.outer, w.last)
+	w.last = 0
+}
+
+func (w *thriftWriter) endStruct() {
+	w.buf.WriteByte(0)
+	w.last = w.outer[len(w.outer)-1]
+	w.outer = w.outer[:len(w.outer)-1]
+}
+
+// stop ends a struct written with elem, or the top-level struct
+func (w *thriftWriter) stop() {
+	if len(w.outer) == 0 {
+		w.buf.WriteByte(0)
+		return
+	}
+	w.endStruct()
+}
+
+func (w *thriftWriter) beginList(id int16, kind byte, size int) {
+	w.field(id, thriftList)
+	if size < 15 {
+		w.buf.WriteByte(byte(size)<<4 | kind)
+	} else {
+		w.buf.WriteByte(0xf0 | kind)
+		w.varint(uint64(size))
+	}
+}
+
+// elem begins a struct element of a list
+func (w *thriftWriter) elem() {
+	w.outer = append(w.outer, w.last)
+	w.last = 0
+}
+
+func (w *thriftWriter) listI32(id int16, values ...int32) {
+	w.beginList(id, thriftI32, len(values))
+	for _, v := range values {
+		w.zigzag(int64(v))
+	}
+}
+
+func (w *thriftWriter) listString(id int16, values ...string) {
+	w.beginList(id, thriftBinary, len(values))
+	for _, v := range values {
+		w.varint(uint64(len(v)))
+		w.buf.WriteString(v)
+	}
+}
@@ -0,0 +1,219 @@
+package sink
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+)
+
+// thriftReader decodes the Thrift compact protocol generically, following the
+// protocol specification rather than the writer: structs become maps by field ID,
+// integers int64, binaries []byte and lists []interface{}
+type thriftReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *thriftReader) byte() byte {
+	b := r.data[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.data[r.pos:])
+	if n <= 0 {
+		panic("invalid varint")
+	}
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) zigzag() int64 {
+	v := r.uvarint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *thriftReader) value(kind byte) interface{} {
+	switch kind {
+	case 1, 2: // Booleans in struct fields carry their value in the type
+		return kind == 1
+	case 3:
+		return int64(int8(r.byte()))
+	case 4, 5, 6:
+		return r.zigzag()
+	case 7:
+		v := binary.LittleEndian.Uint64(r.data[r.pos:])
+		r.pos += 8
+		return v
+	case 8:
+		n := int(r.uvarint())
+		b := r.data[r.pos : r.pos+n]
+		r.pos += n
+		return b
+	case 9, 10:
+		header := r.byte()
+		size := int(header >> 4)
+		if size == 15 {
+			size = int(r.uvarint())
+		}
+		list := make([]interface{}, size)
+		for i := range list {
+			list[i] = r.value(header & 0x0f)
+		}
+		return list
+	case 12:
+		return r.structure()
+	}
+	panic(fmt.Sprintf("unsupported thrift type %d", kind))
+}
+
+func (r *thriftReader) structure() map[int16]interface{} {
+	fields := make(map[int16]interface{})
+	var last int16
+	for {
+		header := r.byte()
+		if header == 0 {
+			return fields
+		}
+		id := last + int16(header>>4)
+		if header>>4 == 0 {
+			id = int16(r.zigzag())
+		}
+		fields[id] = r.value(header & 0x0f)
+		last = id
+	}
+}
+
+func TestWriteParquet(t *testing.T) {
+	t.Run("gzip", func(t *testing.T) { testWriteParquet(t, true) })
+	t.Run("uncompressed", func(t *testing.T) { testWriteParquet(t, false) })
+}
+
+func testWriteParquet(t *testing.T, compress bool) {
+	ids := []int64{1, -2, 1 << 40}
+	names := []string{"alice", "", "ünïcode"}
+	data, err := writeParquet([]parquetColumn{
+		{name: "id", kind: parquetInt64, converted: parquetNone, values: func(i int) interface{} { return ids[i] }},
+		{name: "name", kind: parquetByteArray, converted: parquetUTF8, values: func(i int) interface{} { return names[i] }},
+	}, len(ids), compress)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.HasPrefix(data, []byte("PAR1")) || !bytes.HasSuffix(data, []byte("PAR1")) {
+		t.Fatal("missing PAR1 magic")
+	}
+	footerLen := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	footerStart := len(data) - 8 - footerLen
+	footer := &thriftReader{data: data[footerStart : len(data)-8]}
+	meta := footer.structure()
+	if footer.pos != footerLen {
+		t.Fatalf("footer decoded %d of %d bytes", footer.pos, footerLen)
+	}
+
+	// FileMetaData: 1 version, 2 schema, 3 num_rows, 4 row_groups, 6 created_by
+	if meta[1] != int64(1) || meta[3] != int64(3) || string(meta[6].([]byte)) != "mysql-cdc" {
+		t.Fatalf("unexpected file metadata: %v", meta)
+	}
+	schema := meta[2].([]interface{})
+	if len(schema) != 3 {
+		t.Fatalf("got %d schema elements, want 3", len(schema))
+	}
+	root := schema[0].(map[int16]interface{})
+	if string(root[4].([]byte)) != "schema" || root[5] != int64(2) {
+		t.Errorf("unexpected root schema element: %v", root)
+	}
+	wantSchema := []struct {
+		name      string
+		kind      int64
+		converted interface{}
+	}{{"id", parquetInt64, nil}, {"name", parquetByteArray, int64(parquetUTF8)}}
+	for i, want := range wantSchema {
+		element := schema[i+1].(map[int16]interface{})
+		if string(element[4].([]byte)) != want.name || element[1] != want.kind || element[3] != int64(0) || element[6] != want.converted {
+			t.Errorf("schema element %d = %v, want %+v", i+1, element, want)
+		}
+	}
+
+	groups := meta[4].([]interface{})
+	if len(groups) != 1 {
+		t.Fatalf("got %d row groups, want 1", len(groups))
+	}
+	group := groups[0].(map[int16]interface{})
+	if group[3] != int64(3) {
+		t.Errorf("row group has %v rows, want 3", group[3])
+	}
+	chunks := group[1].([]interface{})
+	if len(chunks) != 2 {
+		t.Fatalf("got %d column chunks, want 2", len(chunks))
+	}
+
+	var values [][]interface{}
+	for i, c := range chunks {
+		chunk := c.(map[int16]interface{})
+		column := chunk[3].(map[int16]interface{})
+		// ColumnMetaData: 3 path_in_schema, 4 codec, 5 num_values, 7 total_compressed_size, 9 data_page_offset
+		if path := column[3].([]interface{}); len(path) != 1 || string(path[0].([]byte)) != wantSchema[i].name {
+			t.Errorf("column %d path = %v", i, path)
+		}
+		codec := int64(parquetUncompressed)
+		if compress {
+			codec = parquetGzip
+		}
+		if column[4] != codec || column[5] != int64(3) {
+			t.Errorf("column %d metadata = %v", i, column)
+		}
+		offset := int(column[9].(int64))
+		if chunk[2] != int64(offset) {
+			t.Errorf("column %d file_offset %v, data page at %d", i, chunk[2], offset)
+		}
+
+		page := &thriftReader{data: data[:footerStart], pos: offset}
+		header := page.structure()
+		// PageHeader: 1 type, 2 uncompressed size, 3 compressed size, 5 data page header
+		dataPage := header[5].(map[int16]interface{})
+		if header[1] != int64(0) || dataPage[1] != int64(3) || dataPage[2] != int64(parquetPlain) {
+			t.Errorf("column %d page header = %v", i, header)
+		}
+		compressedSize := int(header[3].(int64))
+		if got := page.pos - offset + compressedSize; int64(got) != column[7] {
+			t.Errorf("column %d total_compressed_size %v, page takes %d bytes", i, column[7], got)
+		}
+		plain := data[page.pos : page.pos+compressedSize]
+		if compress {
+			zr, err := gzip.NewReader(bytes.NewReader(plain))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if plain, err = io.ReadAll(zr); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if int64(len(plain)) != header[2] {
+			t.Errorf("column %d page is %d bytes uncompressed, header says %v", i, len(plain), header[2])
+		}
+
+		var decoded []interface{}
+		for len(plain) > 0 {
+			if wantSchema[i].kind == parquetInt64 {
+				decoded = append(decoded, int64(binary.LittleEndian.Uint64(plain)))
+				plain = plain[8:]
+				continue
+			}
+			n := binary.LittleEndian.Uint32(plain)
+			decoded = append(decoded, string(plain[4:4+n]))
+			plain = plain[4+n:]
+		}
+		values = append(values, decoded)
+	}
+
+	want := [][]interface{}{{int64(1), int64(-2), int64(1 << 40)}, {"alice", "", "ünïcode"}}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("decoded values %v, want %v", values, want)
+	}
+}
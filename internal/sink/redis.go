@@ -0,0 +1,161 @@
+package sink
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+
+	"mysql-cdc/internal/config"
+	"mysql-cdc/internal/models"
+	"mysql-cdc/internal/redis"
+)
+
+// maxRedirects bounds the cluster redirects followed for one event
+const maxRedirects = 5
+
+// redisSink adds each change event to a Redis stream with XADD. With a cluster,
+// commands go to the node that owns the key's slot, learned from MOVED replies.
+type redisSink struct {
+	config *config.SinkConfig
+	format string // sinks[].format
+	logger *logrus.Logger
+
+	mu      sync.Mutex
+	clients map[string]*redis.Client // By node address
+	slots   map[uint16]string        // Node address of each slot redirected by the cluster
+}
+
+func newRedisSink(cfg *config.SinkConfig, format string, logger *logrus.Logger) (Sink, error) {
+	s := &redisSink{
+		config:  cfg,
+		format:  format,
+		logger:  logger,
+		clients: make(map[string]*redis.Client),
+		slots:   make(map[uint16]string),
+	}
+	if _, err := s.client(cfg.Address); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *redisSink) Publish(event *models.ChangeEvent) error {
+	data, err := encode(event, s.format)
+	if err != nil {
+		return err
+	}
+	key := strings.NewReplacer(
+		"{database}", event.Database,
+		"{table}", event.Table,
+		"{type}", strings.ToLower(event.Type),
+	).Replace(s.config.Key)
+
+	args := []string{"XADD", key}
+	if s.config.MaxLen > 0 {
+		args = append(args, "MAXLEN", "~", strconv.FormatInt(s.config.MaxLen, 10))
+	}
+	args = append(args, "*", "event", string(data))
+	if event.ID != "" {
+		args = append(args, "id", event.ID)
+	}
+	if event.CorrelationID != "" {
+		args = append(args, "correlation_id", event.CorrelationID)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	slot := keySlot(key)
+	address, ok := s.slots[slot]
+	if !ok {
+		address = s.config.Address
+	}
+	asking := false
+	for i := 0; i <= maxRedirects; i++ {
+		client, err := s.client(address)
+		if err != nil {
+			return err
+		}
+		if asking {
+			if _, err := client.Do("ASKING"); err != nil {
+				return fmt.Errorf("redis ASKING failed: %w", err)
+			}
+		}
+		_, err = client.Do(args...)
+		var redisErr redis.Error
+		if !errors.As(err, &redisErr) {
+			if err != nil {
+				return fmt.Errorf("failed to add event to stream %s: %w", key, err)
+			}
+			return nil
+		}
+
+		// A cluster redirects keys of slots other nodes own: MOVED for good, ASK
+		// for this command only while the slot migrates
+		fields := strings.Fields(string(redisErr))
+		if len(fields) != 3 || fields[0] != "MOVED" && fields[0] != "ASK" {
+			return fmt.Errorf("failed to add event to stream %s: %w", key, err)
+		}
+		address, asking = fields[2], fields[0] == "ASK"
+		if !asking {
+			s.slots[slot] = address
+		}
+	}
+	return fmt.Errorf("failed to add event to stream %s: too many cluster redirects", key)
+}
+
+// client returns the connection to a node, connecting to it if needed
+func (s *redisSink) client(address string) (*redis.Client, error) {
+	if client, ok := s.clients[address]; ok {
+		return client, nil
+	}
+	client, err := redis.Dial(redis.Options{
+		Address:  address,
+		Password: s.config.Password,
+		DB:       s.config.DB,
+		Timeout:  s.config.Timeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.clients[address] = client
+	if address != s.config.Address {
+		s.logger.Infof("Sink %s connected to Redis cluster node %s", s.config.Name, address)
+	}
+	return client, nil
+}
+
+func (s *redisSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var errs []error
+	for _, client := range s.clients {
+		errs = append(errs, client.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// keySlot returns the cluster hash slot of a key: the CRC16 of the key, or of its
+// hash tag (the part between the first { and the next }, if not empty), mod 16384
+func keySlot(key string) uint16 {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	var crc uint16
+	for i := 0; i < len(key); i++ {
+		crc ^= uint16(key[i]) << 8
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc % 16384
+}
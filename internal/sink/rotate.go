@@ -0,0 +1,165 @@
+package sink
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"mysql-cdc/internal/config"
+)
+
+// rotatingFile appends to a file, moving it aside to a timestamped file once it
+// reaches the size limit or the rotation interval ends
+type rotatingFile struct {
+	path     string
+	maxSize  int64         // 0 = no limit
+	interval time.Duration // 0 = never
+	compress bool
+	logger   *logrus.Logger
+
+	file   *os.File
+	size   int64
+	period time.Time // Start of the rotation interval the file was written in
+
+	compressing sync.WaitGroup
+}
+
+func openRotatingFile(cfg *config.SinkConfig, logger *logrus.Logger) (*rotatingFile, error) {
+	f := &rotatingFile{
+		path:     cfg.Path,
+		maxSize:  cfg.MaxSize,
+		interval: cfg.RotateInterval,
+		compress: cfg.Compress,
+		logger:   logger,
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	// A file left by a previous run belongs to the interval it was last written in
+	if info, err := f.file.Stat(); err == nil && f.size > 0 {
+		f.period = f.periodOf(info.ModTime())
+	}
+	return f, nil
+}
+
+// open opens the file for appending
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", f.path, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat %s: %w", f.path, err)
+	}
+	f.file, f.size, f.period = file, info.Size(), f.periodOf(time.Now())
+	return nil
+}
+
+// periodOf returns the start of the rotation interval t is in
+func (f *rotatingFile) periodOf(t time.Time) time.Time {
+	if f.interval == 0 {
+		return time.Time{}
+	}
+	return t.UTC().Truncate(f.interval)
+}
+
+// Write writes p, rotating the file first if p would take it over the size limit
+// or the rotation interval has ended. Callers serialize writes.
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	now := f.periodOf(time.Now())
+	if f.size == 0 {
+		// An empty file belongs to the interval of its first write
+		f.period = now
+	} else if f.maxSize > 0 && f.size+int64(len(p)) > f.maxSize || !now.Equal(f.period) {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// rotate moves the file aside and opens a new one
+func (f *rotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("failed to close %s: %w", f.path, err)
+	}
+	rotated := f.rotatedName()
+	if err := os.Rename(f.path, rotated); err != nil {
+		// Keep appending to the current file rather than losing events
+		if openErr := f.open(); openErr != nil {
+			return openErr
+		}
+		return fmt.Errorf("failed to rotate %s: %w", f.path, err)
+	}
+	if f.compress {
+		f.compressing.Add(1)
+		go func() {
+			defer f.compressing.Done()
+			if err := gzipFile(rotated); err != nil {
+				f.logger.Errorf("Failed to compress rotated sink file %s: %v", rotated, err)
+			}
+		}()
+	}
+	return f.open()
+}
+
+// rotatedName returns a free name for the rotated file: the path with the UTC
+// time before the extension, e.g. events-20240101T120000Z.jsonl
+func (f *rotatingFile) rotatedName() string {
+	ext := filepath.Ext(f.path)
+	base := strings.TrimSuffix(f.path, ext)
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+	name := fmt.Sprintf("%s-%s%s", base, stamp, ext)
+	for i := 1; exists(name) || exists(name+".gz"); i++ {
+		name = fmt.Sprintf("%s-%s-%d%s", base, stamp, i, ext)
+	}
+	return name
+}
+
+func (f *rotatingFile) Close() error {
+	err := f.file.Close()
+	f.compressing.Wait()
+	return err
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// gzipFile compresses a file to path.gz and removes it
+func gzipFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(out)
+	_, err = io.Copy(zw, in)
+	if closeErr := zw.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,377 @@
+package sink
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"mysql-cdc/internal/config"
+	"mysql-cdc/internal/models"
+)
+
+// s3Record is a buffered change event
+type s3Record struct {
+	data       []byte // Encoded like published events
+	id         string
+	eventType  string
+	database   string
+	table      string
+	timestamp  time.Time
+	binlogFile string
+	binlogPos  uint32
+	gtid       string
+}
+
+// s3Sink buffers change events per database, table and date, and writes each
+// partition's events as an object every flush interval, or once max_events are
+// buffered. Objects are JSON lines or Parquet files, gzipped unless compression is none.
+type s3Sink struct {
+	config *config.SinkConfig
+	format string // sinks[].format
+	logger *logrus.Logger
+	client *http.Client
+
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+
+	mu       sync.Mutex
+	pending  map[string][]s3Record // By partition key prefix
+	buffered int
+	seq      int
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newS3Sink(cfg *config.SinkConfig, format string, logger *logrus.Logger) (Sink, error) {
+	s := &s3Sink{
+		config:          cfg,
+		format:          format,
+		logger:          logger,
+		client:          &http.Client{Timeout: cfg.Timeout},
+		accessKeyID:     cfg.AccessKeyID,
+		secretAccessKey: cfg.SecretAccessKey,
+		pending:         make(map[string][]s3Record),
+		stop:            make(chan struct{}),
+		done:            make(chan struct{}),
+	}
+	// Without keys in the config, use the standard AWS environment variables
+	if s.accessKeyID == "" {
+		s.accessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+		s.secretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		s.sessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	}
+	if s.accessKeyID == "" || s.secretAccessKey == "" {
+		return nil, fmt.Errorf("no S3 credentials: set access_key_id and secret_access_key, or AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	go s.run()
+	return s, nil
+}
+
+func (s *s3Sink) Publish(event *models.ChangeEvent) error {
+	data, err := encode(event, s.format)
+	if err != nil {
+		return err
+	}
+	record := s3Record{
+		data:       data,
+		id:         event.ID,
+		eventType:  event.Type,
+		database:   event.Database,
+		table:      event.Table,
+		timestamp:  event.Timestamp.Time,
+		binlogFile: event.BinlogFile,
+		binlogPos:  event.BinlogPos,
+		gtid:       event.GTID,
+	}
+	partition := path.Join(s.config.Prefix, partitionName(event.Database), partitionName(event.Table),
+		"date="+event.Timestamp.Time.UTC().Format("2006-01-02"))
+
+	s.mu.Lock()
+	// Uploads keep failing: fail the publish rather than buffer without limit
+	if s.buffered >= 10*s.config.MaxEvents {
+		s.mu.Unlock()
+		return fmt.Errorf("%d events waiting for S3 uploads that keep failing", s.buffered)
+	}
+	s.pending[partition] = append(s.pending[partition], record)
+	s.buffered++
+	var full []s3Record
+	if len(s.pending[partition]) >= s.config.MaxEvents {
+		full = s.pending[partition]
+		delete(s.pending, partition)
+	}
+	s.mu.Unlock()
+
+	// The event is buffered either way, so a failed upload is retried by the next flush
+	if full != nil {
+		if err := s.upload(partition, full); err != nil {
+			s.logger.Errorf("Sink %s failed to write %d events to S3, retrying on the next flush: %v", s.config.Name, len(full), err)
+			s.requeue(partition, full)
+		}
+	}
+	return nil
+}
+
+// Close writes the buffered events and stops flushing
+func (s *s3Sink) Close() error {
+	close(s.stop)
+	<-s.done
+	return s.flush()
+}
+
+// run flushes the buffered events every flush interval
+func (s *s3Sink) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.config.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			if err := s.flush(); err != nil {
+				s.logger.Errorf("Sink %s failed to write events to S3, retrying on the next flush: %v", s.config.Name, err)
+			}
+		}
+	}
+}
+
+// flush writes the buffered events of every partition
+func (s *s3Sink) flush() error {
+	s.mu.Lock()
+	pending := s.pending
+	s.pending = make(map[string][]s3Record)
+	s.mu.Unlock()
+
+	var errs []error
+	for partition, records := range pending {
+		if err := s.upload(partition, records); err != nil {
+			errs = append(errs, err)
+			s.requeue(partition, records)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// requeue puts back the records of a failed upload ahead of those buffered since
+func (s *s3Sink) requeue(partition string, records []s3Record) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending[partition] = append(records, s.pending[partition]...)
+}
+
+// upload writes records as a new object of the partition
+func (s *s3Sink) upload(partition string, records []s3Record) error {
+	s.mu.Lock()
+	s.seq++
+	name := fmt.Sprintf("%s-%06d", time.Now().UTC().Format("20060102T150405.000Z"), s.seq)
+	s.mu.Unlock()
+
+	var body []byte
+	var contentType string
+	var err error
+	compress := s.config.Compression == "gzip"
+	switch {
+	case s.config.ObjectFormat == "parquet":
+		name += ".parquet"
+		contentType = "application/vnd.apache.parquet"
+		body, err = encodeParquet(records, compress)
+	case compress:
+		name += ".jsonl.gz"
+		contentType = "application/gzip"
+		body, err = encodeJSONLines(records, true)
+	default:
+		name += ".jsonl"
+		contentType = "application/x-ndjson"
+		body, err = encodeJSONLines(records, false)
+	}
+	if err != nil {
+		return err
+	}
+	key := path.Join(partition, name)
+	if err := s.put(key, body, contentType); err != nil {
+		return fmt.Errorf("failed to write %s: %w", key, err)
+	}
+
+	s.mu.Lock()
+	s.buffered -= len(records)
+	s.mu.Unlock()
+	return nil
+}
+
+// put uploads an object with a PUT request signed with AWS Signature Version 4
+func (s *s3Sink) put(key string, body []byte, contentType string) error {
+	// AWS S3 is addressed virtual-hosted style, other endpoints path style
+	var target url.URL
+	objectPath := "/" + key
+	if s.config.Endpoint == "" {
+		target = url.URL{Scheme: "https", Host: fmt.Sprintf("%s.s3.%s.amazonaws.com", s.config.Bucket, s.config.Region)}
+	} else {
+		endpoint, err := url.Parse(s.config.Endpoint)
+		if err != nil {
+			return fmt.Errorf("invalid endpoint: %w", err)
+		}
+		target = url.URL{Scheme: endpoint.Scheme, Host: endpoint.Host}
+		objectPath = strings.TrimSuffix(endpoint.Path, "/") + "/" + s.config.Bucket + objectPath
+	}
+	target.Path = objectPath
+	target.RawPath = uriEncode(objectPath)
+
+	req, err := http.NewRequest(http.MethodPut, target.String(), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	now := time.Now().UTC()
+	payloadHash := sha256Hex(body)
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("X-Amz-Date", now.Format("20060102T150405Z"))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	signed := map[string]string{
+		"host":                 target.Host,
+		"x-amz-content-sha256": payloadHash,
+		"x-amz-date":           now.Format("20060102T150405Z"),
+	}
+	if s.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.sessionToken)
+		signed["x-amz-security-token"] = s.sessionToken
+	}
+	req.Header.Set("Authorization", signV4(http.MethodPut, target.RawPath, "", signed, payloadHash, now,
+		s.config.Region, "s3", s.accessKeyID, s.secretAccessKey))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected status %s: %s", resp.Status, bytes.TrimSpace(message))
+	}
+	return nil
+}
+
+// signV4 returns the Authorization header of a request signed with AWS Signature
+// Version 4. headers are the signed headers by lower-case name; canonicalURI and
+// canonicalQuery must already be encoded.
+func signV4(method, canonicalURI, canonicalQuery string, headers map[string]string, payloadHash string, now time.Time,
+	region, service, accessKeyID, secretAccessKey string) string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		method, canonicalURI, canonicalQuery, canonicalHeaders.String(), signedHeaders, payloadHash,
+	}, "\n")
+	date := now.UTC().Format("20060102")
+	scope := fmt.Sprintf("%s/%s/%s/aws4_request", date, region, service)
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256", now.UTC().Format("20060102T150405Z"), scope, sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+	signingKey := []byte("AWS4" + secretAccessKey)
+	for _, part := range []string{date, region, service, "aws4_request"} {
+		signingKey = hmacSHA256(signingKey, part)
+	}
+	return fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKeyID, scope, signedHeaders, hex.EncodeToString(hmacSHA256(signingKey, stringToSign)))
+}
+
+// encodeJSONLines encodes records as JSON lines, optionally gzipped
+func encodeJSONLines(records []s3Record, compress bool) ([]byte, error) {
+	var buf bytes.Buffer
+	if !compress {
+		for _, record := range records {
+			buf.Write(record.data)
+			buf.WriteByte('\n')
+		}
+		return buf.Bytes(), nil
+	}
+	zw := gzip.NewWriter(&buf)
+	for _, record := range records {
+		zw.Write(record.data)
+		zw.Write([]byte{'\n'})
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeParquet encodes records as a Parquet file with the event's metadata in
+// columns of their own and the whole event as JSON in the event column
+func encodeParquet(records []s3Record, compress bool) ([]byte, error) {
+	str := func(name string, value func(r *s3Record) string) parquetColumn {
+		return parquetColumn{name: name, kind: parquetByteArray, converted: parquetUTF8,
+			values: func(i int) interface{} { return value(&records[i]) }}
+	}
+	return writeParquet([]parquetColumn{
+		str("id", func(r *s3Record) string { return r.id }),
+		str("type", func(r *s3Record) string { return r.eventType }),
+		str("database", func(r *s3Record) string { return r.database }),
+		str("table", func(r *s3Record) string { return r.table }),
+		{name: "timestamp", kind: parquetInt64, converted: parquetTimestampMillis,
+			values: func(i int) interface{} { return records[i].timestamp.UnixMilli() }},
+		str("binlog_file", func(r *s3Record) string { return r.binlogFile }),
+		{name: "binlog_pos", kind: parquetInt64, converted: parquetNone,
+			values: func(i int) interface{} { return int64(records[i].binlogPos) }},
+		str("gtid", func(r *s3Record) string { return r.gtid }),
+		str("event", func(r *s3Record) string { return string(r.data) }),
+	}, len(records), compress)
+}
+
+// partitionName returns a database or table name as a key segment
+func partitionName(name string) string {
+	if name == "" {
+		return "_"
+	}
+	return name
+}
+
+// uriEncode encodes a path as in the canonical request of Signature Version 4:
+// every byte but unreserved characters and / is percent-encoded
+func uriEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
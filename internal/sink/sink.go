@@ -0,0 +1,183 @@
+package sink
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/sirupsen/logrus"
+
+	"mysql-cdc/internal/config"
+	"mysql-cdc/internal/models"
+	"mysql-cdc/internal/nats"
+)
+
+// Sink is a destination change events are published to
+type Sink interface {
+	Publish(event *models.ChangeEvent) error
+	Close() error
+}
+
+// Metrics receives per-sink counters
+type Metrics interface {
+	Count(name string, value int64, tags ...string)
+}
+
+// builders create the sinks of each type other than nats, encoding events in
+// the sink's format
+var builders = map[string]func(cfg *config.SinkConfig, format string, logger *logrus.Logger) (Sink, error){
+	"stdout":  newStdoutSink,
+	"file":    newFileSink,
+	"webhook": newWebhookSink,
+	"redis":   newRedisSink,
+	"kafka":   newKafkaSink,
+	"s3":      newS3Sink,
+}
+
+// namedSink is a configured sink
+type namedSink struct {
+	name       string
+	skipErrors bool // on_error: skip
+	singleRow  bool // format: debezium, which encodes one row per message
+	sink       Sink
+}
+
+// publish publishes an event to the sink, one row at a time if its format holds
+// a single row. Events from JavaScript transforms are published as they are.
+func (s namedSink) publish(event *models.ChangeEvent) error {
+	if !s.singleRow || len(event.RawJSON) > 0 || len(event.Rows) <= 1 {
+		return s.sink.Publish(event)
+	}
+	for _, row := range rowEvents(event) {
+		if err := s.sink.Publish(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rowEvents splits an event into single-row events, numbered with part/parts
+// like events split by limits.max_rows_per_message. Each has its own ID.
+func rowEvents(event *models.ChangeEvent) []*models.ChangeEvent {
+	events := make([]*models.ChangeEvent, len(event.Rows))
+	for i := range event.Rows {
+		part := *event
+		part.Rows = event.Rows[i : i+1]
+		part.OldRows = nil
+		if i < len(event.OldRows) {
+			part.OldRows = event.OldRows[i : i+1]
+		}
+		part.Part = i + 1
+		part.Parts = len(event.Rows)
+		part.ID = fmt.Sprintf("%s/%d", event.ID, part.Part)
+		part.DedupID = fmt.Sprintf("%s/%d", event.DedupID, part.Part)
+		events[i] = &part
+	}
+	return events
+}
+
+// Fanout publishes change events to every configured sink. Other messages, like
+// alerts, schema announcements and KV or object store writes, still go to NATS.
+type Fanout struct {
+	*nats.Publisher
+	sinks   []namedSink
+	metrics Metrics
+	logger  *logrus.Logger
+}
+
+// NewFanout creates the configured sinks, publishing to NATS with publisher.
+// Returns nil if NATS is the only sink.
+func NewFanout(cfg *config.Config, publisher *nats.Publisher, logger *logrus.Logger) (*Fanout, error) {
+	if len(cfg.Sinks) == 1 && cfg.Sinks[0].Type == "nats" {
+		return nil, nil
+	}
+
+	f := &Fanout{Publisher: publisher, logger: logger}
+	for i := range cfg.Sinks {
+		sinkCfg := &cfg.Sinks[i]
+		var s Sink = natsSink{publisher}
+		if sinkCfg.Type != "nats" {
+			var err error
+			s, err = builders[sinkCfg.Type](sinkCfg, sinkCfg.Format, logger)
+			if err != nil {
+				f.Close()
+				return nil, fmt.Errorf("failed to create sink %s: %w", sinkCfg.Name, err)
+			}
+		}
+		f.sinks = append(f.sinks, namedSink{
+			name:       sinkCfg.Name,
+			skipErrors: sinkCfg.OnError == "skip",
+			singleRow:  sinkCfg.Format == models.FormatDebezium,
+			sink:       s,
+		})
+		logger.Infof("Publishing change events to %s sink %s", sinkCfg.Type, sinkCfg.Name)
+	}
+	return f, nil
+}
+
+// SetMetrics sets the exporter per-sink counters are reported to
+func (f *Fanout) SetMetrics(metrics Metrics) {
+	f.metrics = metrics
+}
+
+// Publish publishes a change event to every sink that hasn't accepted it yet, so
+// a retried publish doesn't duplicate it on the sinks that succeeded. Errors of
+// sinks with on_error: skip are logged and don't fail the publish.
+func (f *Fanout) Publish(event *models.ChangeEvent) error {
+	var errs []error
+	for _, s := range f.sinks {
+		if slices.Contains(event.SinksDone, s.name) {
+			continue
+		}
+		if err := s.publish(event); err != nil {
+			f.count("sink.errors", s.name)
+			if !s.skipErrors {
+				errs = append(errs, fmt.Errorf("sink %s: %w", s.name, err))
+				continue
+			}
+			f.logger.WithFields(event.LogFields()).Warnf("Sink %s failed to publish %s event for %s.%s, skipping it: %v",
+				s.name, event.Type, event.Database, event.Table, err)
+		} else {
+			f.count("sink.published", s.name)
+		}
+		event.SinksDone = append(event.SinksDone, s.name)
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes the sinks. The NATS publisher is closed by its owner.
+func (f *Fanout) Close() {
+	for _, s := range f.sinks {
+		if err := s.sink.Close(); err != nil {
+			f.logger.Warnf("Failed to close sink %s: %v", s.name, err)
+		}
+	}
+}
+
+func (f *Fanout) count(name, sink string) {
+	if f.metrics != nil {
+		f.metrics.Count(name, 1, "sink:"+sink)
+	}
+}
+
+// natsSink publishes with the NATS publisher
+type natsSink struct {
+	publisher *nats.Publisher
+}
+
+func (s natsSink) Publish(event *models.ChangeEvent) error { return s.publisher.Publish(event) }
+func (s natsSink) Close() error                            { return nil }
+
+// encode encodes a change event like the NATS publisher does: raw JSON from a
+// JavaScript transform as-is, otherwise the event in the configured format
+func encode(event *models.ChangeEvent, format string) ([]byte, error) {
+	if len(event.RawJSON) > 0 {
+		return event.RawJSON, nil
+	}
+	data, err := json.Marshal(event.Encoding(format))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal event: %w", err)
+	}
+	return data, nil
+}
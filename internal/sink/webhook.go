@@ -0,0 +1,77 @@
+package sink
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+
+	"mysql-cdc/internal/config"
+	"mysql-cdc/internal/models"
+	"mysql-cdc/internal/nats"
+)
+
+// webhookSink POSTs each change event as JSON, gzipped with compression: gzip
+type webhookSink struct {
+	config *config.SinkConfig
+	client *http.Client
+	format string // sinks[].format
+}
+
+func newWebhookSink(cfg *config.SinkConfig, format string, _ *logrus.Logger) (Sink, error) {
+	return &webhookSink{
+		config: cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+		format: format,
+	}, nil
+}
+
+func (s *webhookSink) Publish(event *models.ChangeEvent) error {
+	data, err := encode(event, s.format)
+	if err != nil {
+		return err
+	}
+	if s.config.Compression == "gzip" {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write(data)
+		if err := zw.Close(); err != nil {
+			return fmt.Errorf("failed to compress event: %w", err)
+		}
+		data = buf.Bytes()
+	}
+	req, err := http.NewRequest(http.MethodPost, s.config.URL, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.config.Compression == "gzip" {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	if event.ID != "" {
+		req.Header.Set(nats.EventIDHeader, event.ID)
+	}
+	if event.CorrelationID != "" {
+		req.Header.Set(nats.CorrelationIDHeader, event.CorrelationID)
+	}
+	for key, value := range s.config.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+func (s *webhookSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
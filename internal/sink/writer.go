@@ -0,0 +1,56 @@
+package sink
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+
+	"mysql-cdc/internal/config"
+	"mysql-cdc/internal/models"
+)
+
+// writerSink writes change events as JSON lines
+type writerSink struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer // nil for stdout
+	format string    // sinks[].format
+}
+
+func newStdoutSink(_ *config.SinkConfig, format string, _ *logrus.Logger) (Sink, error) {
+	return &writerSink{w: os.Stdout, format: format}, nil
+}
+
+func newFileSink(cfg *config.SinkConfig, format string, logger *logrus.Logger) (Sink, error) {
+	file, err := openRotatingFile(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+	return &writerSink{w: file, closer: file, format: format}, nil
+}
+
+func (s *writerSink) Publish(event *models.ChangeEvent) error {
+	data, err := encode(event, s.format)
+	if err != nil {
+		return err
+	}
+	line := make([]byte, 0, len(data)+1)
+	line = append(append(line, data...), '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.w.Write(line); err != nil {
+		return fmt.Errorf("failed to write event: %w", err)
+	}
+	return nil
+}
+
+func (s *writerSink) Close() error {
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer.Close()
+}
@@ -0,0 +1,383 @@
+package snapshot
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	gomysql "github.com/go-mysql-org/go-mysql/mysql"
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/sirupsen/logrus"
+
+	"mysql-cdc/internal/config"
+	"mysql-cdc/internal/mysql"
+)
+
+// systemSchemas are never snapshotted
+var systemSchemas = []string{"mysql", "information_schema", "performance_schema", "sys"}
+
+// Emitter publishes snapshot rows through the change event pipeline
+type Emitter interface {
+	Captures(database, table string) bool
+	SnapshotColumns(database, table string) (columns, primaryKey []string, err error)
+	EmitSnapshot(ctx context.Context, database, table string, values [][]interface{}, position gomysql.Position, dedupID string) error
+	Drain(ctx context.Context) error
+}
+
+// Result describes a completed snapshot
+type Result struct {
+	Position gomysql.Position // Binlog position the snapshot is consistent with
+	GTIDSet  string           // Executed GTID set at that position, if GTIDs are used
+	Rows     int
+}
+
+// Snapshotter reads the existing rows of the captured tables in a consistent
+// snapshot and records the binlog position streaming continues from
+type Snapshotter struct {
+	db      *sql.DB
+	config  *config.SnapshotConfig
+	flavor  string
+	useGTID bool
+	logger  *logrus.Logger
+}
+
+// NewSnapshotter creates a snapshotter connecting as the replication user. Returns
+// nil if snapshots are disabled.
+func NewSnapshotter(cfg *config.Config, logger *logrus.Logger) (*Snapshotter, error) {
+	if !cfg.Snapshot.Enabled {
+		return nil, nil
+	}
+
+	dsn := mysqldriver.NewConfig()
+	dsn.User = cfg.MySQL.User
+	dsn.Passwd = cfg.MySQL.Password
+	dsn.Net = "tcp"
+	dsn.Addr = fmt.Sprintf("%s:%d", cfg.MySQL.Host, cfg.MySQL.Port)
+	if cfg.Events.Types.Enabled {
+		// TIMESTAMP values are read in UTC for the type conversion
+		dsn.Params = map[string]string{"time_zone": "'+00:00'"}
+	}
+	db, err := mysql.OpenDB(dsn, &cfg.MySQL.TLS)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database connection: %w", err)
+	}
+	// One connection holds the lock, the other reads the snapshot
+	db.SetMaxOpenConns(2)
+
+	return &Snapshotter{
+		db:      db,
+		config:  &cfg.Snapshot,
+		flavor:  cfg.MySQL.Flavor,
+		useGTID: cfg.MySQL.UseGTID,
+		logger:  logger,
+	}, nil
+}
+
+// Close closes the database connections
+func (s *Snapshotter) Close() {
+	s.db.Close()
+}
+
+// Run reads every table to snapshot in one consistent read transaction and emits
+// its rows in chunks, then waits until they're delivered. With the global lock,
+// writes are blocked only until the transaction and position are established.
+func (s *Snapshotter) Run(ctx context.Context, emitter Emitter) (*Result, error) {
+	lockConn, err := s.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	defer lockConn.Close()
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	defer conn.Close()
+
+	result := &Result{}
+	if s.config.Lock == "global" {
+		s.logger.Info("Locking tables to start the snapshot at a consistent position")
+		if _, err := lockConn.ExecContext(ctx, "FLUSH TABLES WITH READ LOCK"); err != nil {
+			return nil, fmt.Errorf("failed to lock tables (snapshot.lock: none doesn't need the lock): %w", err)
+		}
+		err := s.begin(ctx, conn)
+		if err == nil {
+			err = s.position(ctx, lockConn, result)
+		}
+		if _, unlockErr := lockConn.ExecContext(ctx, "UNLOCK TABLES"); unlockErr != nil && err == nil {
+			err = fmt.Errorf("failed to unlock tables: %w", unlockErr)
+		}
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		// Reading the position first means changes made before the transaction
+		// starts are both in the snapshot and streamed after it, but none is missed
+		if err := s.position(ctx, lockConn, result); err != nil {
+			return nil, err
+		}
+		if err := s.begin(ctx, conn); err != nil {
+			return nil, err
+		}
+	}
+	defer conn.ExecContext(context.Background(), "ROLLBACK")
+	s.logger.Infof("Snapshot consistent with binlog position %s:%d", result.Position.Name, result.Position.Pos)
+
+	tables, err := s.tables(ctx, conn, emitter)
+	if err != nil {
+		return nil, err
+	}
+	for _, table := range tables {
+		rows, err := s.snapshotTable(ctx, conn, emitter, table[0], table[1], result.Position)
+		result.Rows += rows
+		if err != nil {
+			return result, fmt.Errorf("failed to snapshot %s.%s: %w", table[0], table[1], err)
+		}
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		s.logger.Infof("Snapshot of %s.%s complete: %d rows", table[0], table[1], rows)
+	}
+
+	if err := emitter.Drain(ctx); err != nil {
+		return result, err
+	}
+	s.logger.Infof("Snapshot complete: %d rows of %d tables", result.Rows, len(tables))
+	return result, ctx.Err()
+}
+
+// begin starts the read transaction every table is read in
+func (s *Snapshotter) begin(ctx context.Context, conn *sql.Conn) error {
+	if _, err := conn.ExecContext(ctx, "SET SESSION TRANSACTION ISOLATION LEVEL REPEATABLE READ"); err != nil {
+		return fmt.Errorf("failed to set isolation level: %w", err)
+	}
+	if _, err := conn.ExecContext(ctx, "START TRANSACTION WITH CONSISTENT SNAPSHOT"); err != nil {
+		return fmt.Errorf("failed to start snapshot transaction: %w", err)
+	}
+	return nil
+}
+
+// position reads the current binlog position and, if GTIDs are used, the executed GTID set
+func (s *Snapshotter) position(ctx context.Context, conn *sql.Conn, result *Result) error {
+	file, pos, err := mysql.BinlogStatus(ctx, conn)
+	if err != nil {
+		return err
+	}
+	result.Position = gomysql.Position{Name: file, Pos: pos}
+
+	if !s.useGTID {
+		return nil
+	}
+	query := "SELECT @@GLOBAL.gtid_executed"
+	if s.flavor == "mariadb" {
+		query = "SELECT @@GLOBAL.gtid_binlog_pos"
+	}
+	if err := conn.QueryRowContext(ctx, query).Scan(&result.GTIDSet); err != nil {
+		return fmt.Errorf("failed to read executed GTID set: %w", err)
+	}
+	// gtid_executed wraps lines between UUIDs
+	result.GTIDSet = strings.ReplaceAll(result.GTIDSet, "\n", "")
+	return nil
+}
+
+// tables returns the database and name of the tables to snapshot: snapshot.tables,
+// or every base table captured by the filters
+func (s *Snapshotter) tables(ctx context.Context, conn *sql.Conn, emitter Emitter) ([][2]string, error) {
+	var tables [][2]string
+	if len(s.config.Tables) > 0 {
+		for _, name := range s.config.Tables {
+			database, table, _ := strings.Cut(name, ".")
+			if !emitter.Captures(database, table) {
+				s.logger.Warnf("Not snapshotting %s: its changes aren't captured", name)
+				continue
+			}
+			tables = append(tables, [2]string{database, table})
+		}
+		return tables, nil
+	}
+
+	query := fmt.Sprintf("SELECT TABLE_SCHEMA, TABLE_NAME FROM INFORMATION_SCHEMA.TABLES "+
+		"WHERE TABLE_TYPE = 'BASE TABLE' AND TABLE_SCHEMA NOT IN ('%s') ORDER BY TABLE_SCHEMA, TABLE_NAME",
+		strings.Join(systemSchemas, "', '"))
+	rows, err := conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var database, table string
+		if err := rows.Scan(&database, &table); err != nil {
+			return nil, fmt.Errorf("failed to scan table name: %w", err)
+		}
+		if emitter.Captures(database, table) {
+			tables = append(tables, [2]string{database, table})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tables: %w", err)
+	}
+	return tables, nil
+}
+
+// snapshotTable reads a table in primary key order, one chunk per query, and emits
+// each chunk as an event. A table without a primary key is read in a single query.
+func (s *Snapshotter) snapshotTable(ctx context.Context, conn *sql.Conn, emitter Emitter, database, table string, position gomysql.Position) (int, error) {
+	columns, primaryKey, err := emitter.SnapshotColumns(database, table)
+	if err != nil {
+		return 0, err
+	}
+	quoted := make([]string, len(columns))
+	for i, name := range columns {
+		quoted[i] = quoteIdentifier(name)
+	}
+	selectRows := fmt.Sprintf("SELECT %s FROM %s.%s", strings.Join(quoted, ", "), quoteIdentifier(database), quoteIdentifier(table))
+	// The snapshot.where condition limits every query; chunks after the first add
+	// their key condition to it
+	filter, afterKey := "", " WHERE "
+	if condition, ok := s.config.Where[database+"."+table]; ok {
+		filter = fmt.Sprintf(" WHERE (%s)", condition)
+		afterKey = filter + " AND "
+		s.logger.Infof("Snapshotting the rows of %s.%s where %s", database, table, condition)
+	}
+
+	if len(primaryKey) == 0 {
+		return s.readChunks(ctx, conn, emitter, database, table, position, selectRows+filter, nil, nil)
+	}
+
+	keyIndexes := make([]int, len(primaryKey))
+	quotedKey := make([]string, len(primaryKey))
+	placeholders := make([]string, len(primaryKey))
+	for i, key := range primaryKey {
+		for j, name := range columns {
+			if name == key {
+				keyIndexes[i] = j
+			}
+		}
+		quotedKey[i] = quoteIdentifier(key)
+		placeholders[i] = "?"
+	}
+	orderBy := fmt.Sprintf(" ORDER BY %s LIMIT %d", strings.Join(quotedKey, ", "), s.config.ChunkSize)
+
+	// The first chunk starts at the beginning, the next ones after the last key read
+	total := 0
+	query := selectRows + filter + orderBy
+	var after []interface{}
+	for ctx.Err() == nil {
+		read, last, err := s.readChunk(ctx, conn, emitter, database, table, position, query, after, keyIndexes)
+		total += read
+		if err != nil || read < s.config.ChunkSize {
+			return total, err
+		}
+		query = fmt.Sprintf("%s%s(%s) > (%s)%s", selectRows, afterKey, strings.Join(quotedKey, ", "), strings.Join(placeholders, ", "), orderBy)
+		after = last
+	}
+	return total, ctx.Err()
+}
+
+// readChunk reads and emits one chunk of a table ordered by primary key, returning
+// the number of rows and the key values of the last one
+func (s *Snapshotter) readChunk(ctx context.Context, conn *sql.Conn, emitter Emitter, database, table string, position gomysql.Position, query string, after []interface{}, keyIndexes []int) (int, []interface{}, error) {
+	var last []interface{}
+	read, err := s.readChunks(ctx, conn, emitter, database, table, position, query, after, func(row []interface{}, types []*sql.ColumnType) {
+		last = make([]interface{}, len(keyIndexes))
+		for i, index := range keyIndexes {
+			last[i] = keyArg(row[index], types[index])
+		}
+	})
+	return read, last, err
+}
+
+// readChunks runs a query and emits its rows in chunks of snapshot.chunk_size,
+// calling onRow with the raw values of each row read
+func (s *Snapshotter) readChunks(ctx context.Context, conn *sql.Conn, emitter Emitter, database, table string, position gomysql.Position, query string, args []interface{}, onRow func(row []interface{}, types []*sql.ColumnType)) (int, error) {
+	rows, err := conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read rows: %w", err)
+	}
+	defer rows.Close()
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read column types: %w", err)
+	}
+
+	total := 0
+	var chunk [][]interface{}
+	emit := func() error {
+		// Chunks of a table with a primary key are identified by their first key,
+		// so a snapshot taken again at the same position is deduplicated
+		dedupID := ""
+		if onRow != nil {
+			dedupID = fmt.Sprintf("snapshot:%s:%d:%s.%s:%s", position.Name, position.Pos, database, table, keyString(args))
+		}
+		err := emitter.EmitSnapshot(ctx, database, table, chunk, position, dedupID)
+		total += len(chunk)
+		chunk = nil
+		return err
+	}
+	for rows.Next() {
+		values := make([]interface{}, len(types))
+		dest := make([]interface{}, len(values))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return total, fmt.Errorf("failed to scan row: %w", err)
+		}
+		chunk = append(chunk, values)
+		if onRow != nil {
+			onRow(values, types)
+		}
+		if len(chunk) == s.config.ChunkSize {
+			if err := emit(); err != nil {
+				return total, err
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return total, fmt.Errorf("error iterating rows: %w", err)
+	}
+	if len(chunk) > 0 {
+		if err := emit(); err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// keyArg converts a raw integer key value to a number, so that it's compared as
+// an integer rather than as a string converted to a float
+func keyArg(value interface{}, columnType *sql.ColumnType) interface{} {
+	raw, ok := value.([]byte)
+	if !ok {
+		return value
+	}
+	switch columnType.DatabaseTypeName() {
+	case "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "BIGINT":
+		if i, err := strconv.ParseInt(string(raw), 10, 64); err == nil {
+			return i
+		}
+	case "UNSIGNED TINYINT", "UNSIGNED SMALLINT", "UNSIGNED MEDIUMINT", "UNSIGNED INT", "UNSIGNED BIGINT":
+		if u, err := strconv.ParseUint(string(raw), 10, 64); err == nil {
+			return u
+		}
+	}
+	return value
+}
+
+// keyString formats key values for a dedup ID; the first chunk has none
+func keyString(values []interface{}) string {
+	parts := make([]string, len(values))
+	for i, value := range values {
+		if raw, ok := value.([]byte); ok {
+			value = string(raw)
+		}
+		parts[i] = fmt.Sprint(value)
+	}
+	return strings.Join(parts, ",")
+}
+
+// quoteIdentifier quotes a database, table or column name for use in a query
+func quoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
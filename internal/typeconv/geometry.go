@@ -0,0 +1,247 @@
+package typeconv
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// WKB geometry types
+const (
+	wkbPoint              = 1
+	wkbLineString         = 2
+	wkbPolygon            = 3
+	wkbMultiPoint         = 4
+	wkbMultiLineString    = 5
+	wkbMultiPolygon       = 6
+	wkbGeometryCollection = 7
+)
+
+// geometry is a decoded WKB geometry
+type geometry struct {
+	kind     uint32
+	coords   [][]float64 // Points of a point or line string
+	rings    [][][]float64
+	children []*geometry // Members of a multi-geometry or collection
+}
+
+// spatial converts a spatial value as MySQL stores it, a 4-byte little-endian SRID
+// followed by the WKB geometry, to WKT (EWKT with the SRID if not 0) or GeoJSON
+func (c *Converter) spatial(data []byte) (interface{}, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("spatial value too short")
+	}
+	srid := binary.LittleEndian.Uint32(data)
+	r := &wkbReader{data: data[4:]}
+	g, err := r.geometry()
+	if err != nil {
+		return nil, err
+	}
+	if c.geometry == GeometryGeoJSON {
+		return g.geoJSON(), nil
+	}
+	var b strings.Builder
+	if srid != 0 {
+		fmt.Fprintf(&b, "SRID=%d;", srid)
+	}
+	g.writeWKT(&b, true)
+	return b.String(), nil
+}
+
+// wkbReader decodes Well-Known Binary
+type wkbReader struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+func (r *wkbReader) uint32() (uint32, error) {
+	if len(r.data) < 4 {
+		return 0, fmt.Errorf("truncated WKB")
+	}
+	v := r.order.Uint32(r.data)
+	r.data = r.data[4:]
+	return v, nil
+}
+
+func (r *wkbReader) points() ([][]float64, error) {
+	n, err := r.uint32()
+	if err != nil {
+		return nil, err
+	}
+	if uint64(n)*16 > uint64(len(r.data)) {
+		return nil, fmt.Errorf("truncated WKB")
+	}
+	points := make([][]float64, n)
+	for i := range points {
+		points[i] = []float64{
+			math.Float64frombits(r.order.Uint64(r.data)),
+			math.Float64frombits(r.order.Uint64(r.data[8:])),
+		}
+		r.data = r.data[16:]
+	}
+	return points, nil
+}
+
+func (r *wkbReader) geometry() (*geometry, error) {
+	if len(r.data) < 1 {
+		return nil, fmt.Errorf("truncated WKB")
+	}
+	switch r.data[0] {
+	case 0:
+		r.order = binary.BigEndian
+	case 1:
+		r.order = binary.LittleEndian
+	default:
+		return nil, fmt.Errorf("invalid WKB byte order %d", r.data[0])
+	}
+	r.data = r.data[1:]
+	kind, err := r.uint32()
+	if err != nil {
+		return nil, err
+	}
+	g := &geometry{kind: kind}
+	switch kind {
+	case wkbPoint:
+		if len(r.data) < 16 {
+			return nil, fmt.Errorf("truncated WKB")
+		}
+		g.coords = [][]float64{{
+			math.Float64frombits(r.order.Uint64(r.data)),
+			math.Float64frombits(r.order.Uint64(r.data[8:])),
+		}}
+		r.data = r.data[16:]
+	case wkbLineString:
+		g.coords, err = r.points()
+	case wkbPolygon:
+		var n uint32
+		if n, err = r.uint32(); err != nil {
+			return nil, err
+		}
+		for i := uint32(0); i < n && err == nil; i++ {
+			var ring [][]float64
+			ring, err = r.points()
+			g.rings = append(g.rings, ring)
+		}
+	case wkbMultiPoint, wkbMultiLineString, wkbMultiPolygon, wkbGeometryCollection:
+		var n uint32
+		if n, err = r.uint32(); err != nil {
+			return nil, err
+		}
+		for i := uint32(0); i < n && err == nil; i++ {
+			var child *geometry
+			child, err = r.geometry()
+			g.children = append(g.children, child)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported WKB geometry type %d", kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+var wktNames = map[uint32]string{
+	wkbPoint:              "POINT",
+	wkbLineString:         "LINESTRING",
+	wkbPolygon:            "POLYGON",
+	wkbMultiPoint:         "MULTIPOINT",
+	wkbMultiLineString:    "MULTILINESTRING",
+	wkbMultiPolygon:       "MULTIPOLYGON",
+	wkbGeometryCollection: "GEOMETRYCOLLECTION",
+}
+
+// writeWKT writes the geometry as WKT, with its type name unless it's a member of
+// a multi-geometry
+func (g *geometry) writeWKT(b *strings.Builder, named bool) {
+	if named {
+		b.WriteString(wktNames[g.kind])
+	}
+	empty := len(g.coords) == 0 && len(g.rings) == 0 && len(g.children) == 0
+	if empty {
+		b.WriteString(" EMPTY")
+		return
+	}
+	switch g.kind {
+	case wkbPoint, wkbLineString:
+		writeWKTPoints(b, g.coords)
+	case wkbPolygon:
+		b.WriteByte('(')
+		for i, ring := range g.rings {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			writeWKTPoints(b, ring)
+		}
+		b.WriteByte(')')
+	default:
+		b.WriteByte('(')
+		for i, child := range g.children {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			child.writeWKT(b, g.kind == wkbGeometryCollection)
+		}
+		b.WriteByte(')')
+	}
+}
+
+func writeWKTPoints(b *strings.Builder, points [][]float64) {
+	b.WriteByte('(')
+	for i, p := range points {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.FormatFloat(p[0], 'f', -1, 64))
+		b.WriteByte(' ')
+		b.WriteString(strconv.FormatFloat(p[1], 'f', -1, 64))
+	}
+	b.WriteByte(')')
+}
+
+var geoJSONTypes = map[uint32]string{
+	wkbPoint:              "Point",
+	wkbLineString:         "LineString",
+	wkbPolygon:            "Polygon",
+	wkbMultiPoint:         "MultiPoint",
+	wkbMultiLineString:    "MultiLineString",
+	wkbMultiPolygon:       "MultiPolygon",
+	wkbGeometryCollection: "GeometryCollection",
+}
+
+// geoJSON returns the geometry as a GeoJSON geometry object
+func (g *geometry) geoJSON() map[string]interface{} {
+	obj := map[string]interface{}{"type": geoJSONTypes[g.kind]}
+	if g.kind == wkbGeometryCollection {
+		geometries := make([]interface{}, len(g.children))
+		for i, child := range g.children {
+			geometries[i] = child.geoJSON()
+		}
+		obj["geometries"] = geometries
+		return obj
+	}
+	obj["coordinates"] = g.coordinates()
+	return obj
+}
+
+// coordinates returns the GeoJSON coordinates of a geometry other than a collection
+func (g *geometry) coordinates() interface{} {
+	switch g.kind {
+	case wkbPoint:
+		if len(g.coords) == 0 {
+			return []float64{}
+		}
+		return g.coords[0]
+	case wkbLineString:
+		return g.coords
+	case wkbPolygon:
+		return g.rings
+	}
+	coords := make([]interface{}, len(g.children))
+	for i, child := range g.children {
+		coords[i] = child.coordinates()
+	}
+	return coords
+}
@@ -0,0 +1,376 @@
+// Package typeconv converts MySQL column values, as decoded from the binlog or
+// read with database/sql, to stable JSON types: DECIMAL as strings, DATETIME and
+// TIMESTAMP as RFC 3339 UTC strings, JSON as nested values, ENUM and SET by name,
+// BIT as integers and spatial types as WKT or GeoJSON.
+package typeconv
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"mysql-cdc/internal/config"
+)
+
+// Geometry encodings
+const (
+	GeometryWKT     = "wkt"
+	GeometryGeoJSON = "geojson"
+)
+
+// columns caches parsed column types by COLUMN_TYPE
+var columns sync.Map
+
+// Converter converts column values according to their column type
+type Converter struct {
+	zone     *time.Location // Zone DATETIME values are in
+	geometry string         // GeometryWKT or GeometryGeoJSON
+}
+
+// New creates a converter. Returns nil if events.types is disabled.
+func New(cfg *config.TypesConfig) (*Converter, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	zone, err := time.LoadLocation(cfg.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid events.types.timezone: %w", err)
+	}
+	return &Converter{zone: zone, geometry: cfg.Geometry}, nil
+}
+
+// column is a parsed COLUMN_TYPE
+type column struct {
+	base     string   // Lower-case type name, e.g. "int" or "datetime"
+	length   int      // Display width, precision or number of bits (0 if not given)
+	unsigned bool     // Integer column declared UNSIGNED
+	values   []string // Members of an ENUM or SET, in declaration order
+}
+
+// Convert converts a column value given its COLUMN_TYPE as in INFORMATION_SCHEMA
+// (e.g. "decimal(10,2)" or "enum('a','b')"). TIMESTAMP values read as strings are
+// taken to be in timestampZone: the binlog decoder formats them in the local zone,
+// queries in the session time zone. Values of unknown types are returned as is,
+// with byte slices as strings.
+func (c *Converter) Convert(value interface{}, columnType string, timestampZone *time.Location) interface{} {
+	if value == nil {
+		return nil
+	}
+	col := parseColumn(columnType)
+	switch col.base {
+	case "tinyint", "smallint", "mediumint", "int", "integer", "bigint":
+		return integer(value, col)
+	case "float", "double", "real":
+		if b, ok := value.([]byte); ok {
+			if f, err := strconv.ParseFloat(string(b), 64); err == nil {
+				return f
+			}
+		}
+		return value
+	case "decimal", "numeric":
+		return decimal(value)
+	case "datetime":
+		return dateTime(value, c.zone)
+	case "timestamp":
+		return dateTime(value, timestampZone)
+	case "date":
+		switch v := value.(type) {
+		case time.Time:
+			return v.Format("2006-01-02")
+		case []byte:
+			value = string(v)
+		}
+		if value == "0000-00-00" {
+			return nil
+		}
+		return value
+	case "year":
+		if b, ok := value.([]byte); ok {
+			if year, err := strconv.Atoi(string(b)); err == nil {
+				return year
+			}
+		}
+		return value
+	case "json":
+		return jsonValue(value)
+	case "enum":
+		return enumValue(value, col)
+	case "set":
+		return setValue(value, col)
+	case "bit":
+		return bitValue(value, col)
+	case "geometry", "point", "linestring", "polygon", "multipoint", "multilinestring", "multipolygon",
+		"geometrycollection", "geomcollection":
+		b, ok := value.([]byte)
+		if !ok {
+			if s, isString := value.(string); isString {
+				b = []byte(s)
+			} else {
+				return value
+			}
+		}
+		g, err := c.spatial(b)
+		if err != nil {
+			return b
+		}
+		return g
+	case "binary", "varbinary", "tinyblob", "blob", "mediumblob", "longblob":
+		// Binary data is base64-encoded in JSON
+		if s, ok := value.(string); ok {
+			return []byte(s)
+		}
+		return value
+	}
+	if b, ok := value.([]byte); ok {
+		return string(b)
+	}
+	return value
+}
+
+// Labels resolves an ENUM or SET value as decoded from the binlog, an index or a
+// bitmask, to its label or comma-separated labels, as queries return them. Other
+// values are returned unchanged.
+func Labels(value interface{}, columnType string) interface{} {
+	if _, ok := value.(int64); !ok {
+		return value
+	}
+	col := parseColumn(columnType)
+	switch col.base {
+	case "enum":
+		return enumValue(value, col)
+	case "set":
+		return strings.Join(setValue(value, col).([]string), ",")
+	}
+	return value
+}
+
+// parseColumn returns the parsed column type, caching it
+func parseColumn(columnType string) *column {
+	if col, ok := columns.Load(columnType); ok {
+		return col.(*column)
+	}
+	col := parseColumnType(columnType)
+	columns.Store(columnType, col)
+	return col
+}
+
+// parseColumnType parses a COLUMN_TYPE such as "int(10) unsigned" or "set('a','b')"
+func parseColumnType(columnType string) *column {
+	col := &column{}
+	name, rest := columnType, ""
+	if i := strings.IndexAny(columnType, "( "); i >= 0 {
+		name, rest = columnType[:i], columnType[i:]
+	}
+	col.base = strings.ToLower(name)
+	col.unsigned = strings.Contains(strings.ToLower(rest), "unsigned")
+	if !strings.HasPrefix(rest, "(") {
+		return col
+	}
+	if col.base == "enum" || col.base == "set" {
+		col.values = quotedList(rest[1:])
+		return col
+	}
+	if end := strings.IndexAny(rest, ",)"); end > 0 {
+		col.length, _ = strconv.Atoi(rest[1:end])
+	}
+	return col
+}
+
+// quotedList parses the quoted members of an ENUM or SET definition, after its
+// opening parenthesis. Quotes within members are doubled or escaped.
+func quotedList(s string) []string {
+	var values []string
+	var cur strings.Builder
+	inQuote := false
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case inQuote && ch == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			cur.WriteByte('\'')
+			i++
+		case inQuote && ch == '\\' && i+1 < len(s):
+			cur.WriteByte(s[i+1])
+			i++
+		case ch == '\'':
+			if inQuote {
+				values = append(values, cur.String())
+				cur.Reset()
+			}
+			inQuote = !inQuote
+		case inQuote:
+			cur.WriteByte(ch)
+		case ch == ')':
+			return values
+		}
+	}
+	return values
+}
+
+// integer returns an integer value, reinterpreting the negative values the binlog
+// decoder returns for UNSIGNED columns above the signed range
+func integer(value interface{}, col *column) interface{} {
+	var v int64
+	switch n := value.(type) {
+	case int8:
+		v = int64(n)
+	case int16:
+		v = int64(n)
+	case int32:
+		v = int64(n)
+	case int64:
+		v = n
+	case int:
+		v = int64(n)
+	case []byte:
+		if col.unsigned {
+			if u, err := strconv.ParseUint(string(n), 10, 64); err == nil {
+				return u
+			}
+		} else if i, err := strconv.ParseInt(string(n), 10, 64); err == nil {
+			return i
+		}
+		return string(n)
+	default:
+		return value
+	}
+	if !col.unsigned || v >= 0 {
+		return v
+	}
+	switch col.base {
+	case "tinyint":
+		return uint64(uint8(v))
+	case "smallint":
+		return uint64(uint16(v))
+	case "mediumint":
+		return uint64(v) & 0xffffff
+	case "int", "integer":
+		return uint64(uint32(v))
+	}
+	return uint64(v)
+}
+
+// decimal returns a DECIMAL value as a string, so no precision is lost to floats
+func decimal(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case fmt.Stringer:
+		return v.String()
+	}
+	return value
+}
+
+// dateTime returns a DATETIME or TIMESTAMP value as an RFC 3339 UTC string, taking
+// strings to be in zone. Zero dates, which have no time, are null.
+func dateTime(value interface{}, zone *time.Location) interface{} {
+	var s string
+	switch v := value.(type) {
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	default:
+		return value
+	}
+	if strings.HasPrefix(s, "0000-00-00") {
+		return nil
+	}
+	t, err := time.ParseInLocation("2006-01-02 15:04:05", s, zone)
+	if err != nil {
+		return s
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// jsonValue parses a JSON column into nested values, keeping numbers exact
+func jsonValue(value interface{}) interface{} {
+	var data []byte
+	switch v := value.(type) {
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return value
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var parsed interface{}
+	if err := dec.Decode(&parsed); err != nil {
+		return string(data)
+	}
+	return parsed
+}
+
+// enumValue returns the member name of an ENUM value; the binlog holds its
+// 1-based index, 0 for the empty string stored for invalid values
+func enumValue(value interface{}, col *column) interface{} {
+	switch v := value.(type) {
+	case int64:
+		if v == 0 {
+			return ""
+		}
+		if v > 0 && int(v) <= len(col.values) {
+			return col.values[v-1]
+		}
+		return v
+	case []byte:
+		return string(v)
+	}
+	return value
+}
+
+// setValue returns the member names of a SET value as a list; the binlog holds
+// a bitmask of the members, queries a comma-separated list
+func setValue(value interface{}, col *column) interface{} {
+	names := []string{}
+	switch v := value.(type) {
+	case int64:
+		for i, name := range col.values {
+			if v&(1<<uint(i)) != 0 {
+				names = append(names, name)
+			}
+		}
+		return names
+	case []byte:
+		value = string(v)
+	}
+	s, ok := value.(string)
+	if !ok {
+		return value
+	}
+	if s != "" {
+		names = strings.Split(s, ",")
+	}
+	return names
+}
+
+// bitValue returns a BIT value as an unsigned integer, or a boolean for BIT(1);
+// queries return the bits as big-endian bytes
+func bitValue(value interface{}, col *column) interface{} {
+	var v uint64
+	switch n := value.(type) {
+	case int64:
+		v = uint64(n)
+	case []byte:
+		for _, b := range n {
+			v = v<<8 | uint64(b)
+		}
+	default:
+		return value
+	}
+	if col.length == 1 {
+		return v != 0
+	}
+	return v
+}
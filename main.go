@@ -2,17 +2,26 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
 	"syscall"
 
 	"github.com/sirupsen/logrus"
 
-	"mysql-cdc/internal/binlog"
+	"mysql-cdc/internal/admin"
 	"mysql-cdc/internal/config"
+	"mysql-cdc/internal/metrics"
+	"mysql-cdc/internal/models"
 	"mysql-cdc/internal/mysql"
 	"mysql-cdc/internal/nats"
+	"mysql-cdc/internal/notify"
 	"mysql-cdc/internal/processor"
+	"mysql-cdc/internal/reporting"
+	"mysql-cdc/internal/sink"
 )
 
 func main() {
@@ -23,10 +32,44 @@ func main() {
 	})
 	logger.SetLevel(logrus.InfoLevel)
 
+	// "mysql-cdc check [config]" runs the preflight checks and exits;
+	// "mysql-cdc backfill --table db.t --pk-from x --pk-to y [--source name] [config]" publishes a key range and exits;
+	// "--until-caught-up" stops once the master's position at startup is reached
+	if len(os.Args) > 1 && os.Args[1] == "backfill" {
+		opts, configPath, err := parseBackfillArgs(os.Args[2:])
+		if err != nil {
+			logger.Fatalf("Invalid backfill arguments: %v", err)
+		}
+		cfg, err := config.LoadConfig(configPath)
+		if err != nil {
+			logger.Fatalf("Failed to load config: %v", err)
+		}
+		configureLogger(logger, &cfg.Logging)
+		sourceCfg, err := cfg.Source(opts.source)
+		if err != nil {
+			logger.Fatalf("Invalid backfill arguments: %v", err)
+		}
+		os.Exit(runBackfill(sourceCfg, opts, logger))
+	}
+
+	var args []string
+	untilCaughtUp := false
+	for _, arg := range os.Args[1:] {
+		if arg == "--until-caught-up" {
+			untilCaughtUp = true
+			continue
+		}
+		args = append(args, arg)
+	}
+	checkOnly := len(args) > 0 && args[0] == "check"
+	if checkOnly {
+		args = args[1:]
+	}
+
 	// Load configuration
 	configPath := "config.yaml"
-	if len(os.Args) > 1 {
-		configPath = os.Args[1]
+	if len(args) > 0 {
+		configPath = args[0]
 	}
 
 	cfg, err := config.LoadConfig(configPath)
@@ -34,63 +77,59 @@ func main() {
 		logger.Fatalf("Failed to load config: %v", err)
 	}
 
-	// Set log level from config
-	if level, err := logrus.ParseLevel(cfg.Logging.Level); err == nil {
-		logger.SetLevel(level)
+	// Set log level and format from config
+	configureLogger(logger, &cfg.Logging)
+
+	if checkOnly {
+		code := 0
+		for _, sourceCfg := range cfg.SourceConfigs() {
+			if sourceCfg.SourceName != "" {
+				fmt.Printf("Source %s:\n", sourceCfg.SourceName)
+			}
+			if runCheck(sourceCfg, logger) != 0 {
+				code = 1
+			}
+		}
+		os.Exit(code)
 	}
 
 	logger.Info("Starting MySQL CDC service...")
 
-	// Log MySQL version if specified
-	if cfg.MySQL.Version != "" {
-		logger.Infof("MySQL version: %s", cfg.MySQL.Version)
-	}
-	if cfg.MySQL.UseGTID {
-		logger.Info("GTID replication will be used")
-	}
-
-	// Verify MySQL connection and permissions before starting binlog sync
-	logger.Info("Verifying MySQL connection and permissions...")
-	checker := mysql.NewChecker(
-		cfg.MySQL.Host,
-		cfg.MySQL.Port,
-		cfg.MySQL.User,
-		cfg.MySQL.Password,
-		logger,
-	)
-	if err := checker.CheckConnectionAndPermissions(); err != nil {
-		logger.Fatalf("MySQL connection/permission check failed: %v", err)
-	}
-
-	// Initialize binlog reader
-	reader, err := binlog.NewReader(
-		cfg.MySQL.Host,
-		cfg.MySQL.Port,
-		cfg.MySQL.User,
-		cfg.MySQL.Password,
-		cfg.MySQL.ServerID,
-		cfg.MySQL.Flavor,
-		cfg.MySQL.UseGTID,
-		cfg.Binlog.PositionFile,
-		cfg.Binlog.StartPosition,
-		logger,
-	)
-	if err != nil {
-		logger.Fatalf("Failed to create binlog reader: %v", err)
+	// Connect to the MySQL server of each source
+	var sources []*source
+	for _, sourceCfg := range cfg.SourceConfigs() {
+		src := connectSource(sourceCfg, untilCaughtUp, logger)
+		defer src.disconnect()
+		if len(sources) > 0 && src.caseSensitive != sources[0].caseSensitive {
+			logger.Fatalf("Sources %s and %s compare table names differently (lower_case_table_names), set mysql.lower_case_table_names",
+				sources[0].cfg.SourceName, src.cfg.SourceName)
+		}
+		sources = append(sources, src)
 	}
-	defer reader.Close()
+	models.SetTimestampUnit(cfg.Events.Timestamp)
+	models.SetDebeziumServerName(cfg.Events.Debezium.ServerName)
 
 	// Validate processor configuration
 	if err := processor.ValidateRules(&cfg.Processor); err != nil {
 		logger.Fatalf("Invalid processor configuration: %v", err)
 	}
 
+	// Initialize payload signer (nil if signing is disabled)
+	signer, err := nats.NewSigner(&cfg.NATS.Signing)
+	if err != nil {
+		logger.Fatalf("Failed to create payload signer: %v", err)
+	}
+
 	// Initialize NATS publisher first (needed for transformer)
 	publisher, err := nats.NewPublisher(
 		cfg.NATS.URL,
+		&cfg.NATS.Auth,
+		&cfg.NATS.TLS,
 		cfg.NATS.Subject,
 		cfg.NATS.MaxReconnect,
 		cfg.NATS.ReconnectWait,
+		signer,
+		cfg.Events.Format,
 		logger,
 	)
 	if err != nil {
@@ -98,11 +137,78 @@ func main() {
 	}
 	defer publisher.Close()
 
-	// Initialize transformer with NATS connection
-	transformer, err := processor.NewTransformer(&cfg.Processor, logger, publisher.GetConn())
+	if cfg.NATS.JetStream.Enabled {
+		if err := enableJetStream(cfg, publisher); err != nil {
+			logger.Fatalf("Failed to enable JetStream publishing: %v", err)
+		}
+	}
+	logger.Infof("Delivery mode: %s", cfg.Delivery.Mode)
+
+	// Setup graceful shutdown
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+
+	// Initialize error reporter (nil if neither Sentry nor a webhook is configured)
+	reporter, err := reporting.NewReporter(&cfg.ErrorReporting, logger)
 	if err != nil {
-		logger.Fatalf("Failed to create transformer: %v", err)
+		logger.Fatalf("Failed to create error reporter: %v", err)
+	}
+
+	// Initialize metrics exporter (nil if disabled)
+	statsd, err := metrics.NewStatsD(&cfg.Metrics.StatsD, logger)
+	if err != nil {
+		logger.Fatalf("Failed to create StatsD exporter: %v", err)
+	}
+	if statsd != nil {
+		defer statsd.Close()
+	}
+
+	// Initialize webhook notifier (nil if notifications are disabled)
+	notifier := notify.NewNotifier(&cfg.Notifications, logger)
+	if notifier != nil {
+		publisher.OnAlert(notifier.Alert)
+	}
+
+	if cfg.Alerts.Enabled {
+		publisher.EnableAlerts(cfg.Alerts.Subject)
+		logger.Infof("Publishing operational alerts to %s", cfg.Alerts.Subject)
+	}
+
+	// Report downstream JetStream consumer lag
+	if cfg.NATS.ConsumerLag.Enabled {
+		if cfg.NATS.ConsumerLag.Stream == "" || len(cfg.NATS.ConsumerLag.Consumers) == 0 {
+			logger.Fatal("nats.consumer_lag requires a stream and at least one consumer")
+		}
+		lagMonitor := nats.NewLagMonitor(publisher, &cfg.NATS.ConsumerLag, logger)
+		if statsd != nil {
+			lagMonitor.SetMetrics(statsd)
+		}
+		go lagMonitor.Run(ctx)
+	}
+
+	// Alert when NATS can't keep up
+	if cfg.NATS.SlowSink.Enabled {
+		slowSinkMonitor := nats.NewSlowSinkMonitor(publisher, &cfg.NATS.SlowSink, logger)
+		go slowSinkMonitor.Run(ctx)
 	}
+
+	startSources := func(ctx context.Context) error {
+		return runSources(ctx, sources, reporter)
+	}
+
+	// Passthrough publishes raw binlog events and skips decoding and transformation
+	if cfg.Binlog.Passthrough.Enabled {
+		logger.Info("Binlog passthrough enabled: rows are not decoded, filtered or transformed")
+		for _, src := range sources {
+			src.startPassthrough(publisher)
+		}
+		run(ctx, cancel, sigChan, startSources, reporter, notifier, logger)
+		return
+	}
+
 	if cfg.Processor.Enabled {
 		if cfg.Processor.Script != "" {
 			logger.Info("Processor/transformer enabled with JavaScript script")
@@ -111,46 +217,176 @@ func main() {
 		}
 	}
 
-	// Create event processor
-	proc, err := processor.NewProcessor(
-		reader,
-		publisher,
-		transformer,
-		cfg.MySQL.Host,
-		cfg.MySQL.Port,
-		cfg.MySQL.User,
-		cfg.MySQL.Password,
-		logger,
-	)
+	// Fan change events out to the configured sinks (nil if NATS is the only one)
+	var eventPublisher processor.Publisher = publisher
+	fanout, err := sink.NewFanout(cfg, publisher, logger)
 	if err != nil {
-		logger.Fatalf("Failed to create event processor: %v", err)
+		logger.Fatalf("Failed to create sinks: %v", err)
+	}
+	if fanout != nil {
+		defer fanout.Close()
+		if statsd != nil {
+			fanout.SetMetrics(statsd)
+		}
+		eventPublisher = fanout
 	}
-	defer proc.Close()
 
-	// Setup graceful shutdown
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	// Create the transformer and event processor of each source
+	controllers := make(map[string]admin.Controller, len(sources))
+	for _, src := range sources {
+		src.startProcessor(publisher, eventPublisher, reporter, statsd)
+		defer src.close()
+		controllers[src.cfg.SourceName] = src.proc
+	}
 
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	// Reload the processor rules and scripts from the config file, keeping the
+	// binlog position, on SIGHUP, file changes and admin API requests
+	reloadTransforms := func() error {
+		newCfg, err := config.LoadConfig(configPath)
+		if err != nil {
+			return err
+		}
+		for _, src := range sources {
+			sourceCfg, err := newCfg.Source(src.cfg.SourceName)
+			if err != nil {
+				return fmt.Errorf("sources can't be changed without a restart: %w", err)
+			}
+			if err := src.transformer.Reload(&sourceCfg.Processor); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hupChan:
+				logger.Info("Received SIGHUP, reloading processor rules and scripts")
+				if err := reloadTransforms(); err != nil {
+					logger.Errorf("Failed to reload processor rules and scripts, keeping the running ones: %v", err)
+				}
+			}
+		}
+	}()
+	// Sources share the processor settings, so the scripts of one are those of all
+	if watcher := processor.NewReloadWatcher(&cfg.Processor, configPath, sources[0].transformer, reloadTransforms, logger); watcher != nil {
+		go watcher.Run(ctx)
+	}
+
+	// Serve the admin API (nil if disabled)
+	adminServer := admin.NewServer(&cfg.Admin, controllers, reloadTransforms, logger)
+	if adminServer != nil {
+		if err := adminServer.Start(); err != nil {
+			logger.Fatalf("Failed to start admin API: %v", err)
+		}
+		defer adminServer.Close()
+	}
+
+	run(ctx, cancel, sigChan, startSources, reporter, notifier, logger)
+}
+
+// setNameMatching matches table names the way the source server compares them,
+// and returns whether they're compared case-sensitively
+func setNameMatching(cfg *config.Config, checker *mysql.Checker, logger *logrus.Logger) bool {
+	lowerCaseTableNames := cfg.MySQL.LowerCaseTableNames
+	if lowerCaseTableNames == "auto" {
+		value, err := checker.LowerCaseTableNames()
+		if err != nil {
+			logger.Warnf("Could not detect lower_case_table_names, assuming case-insensitive names: %v", err)
+			lowerCaseTableNames = "1"
+		} else {
+			lowerCaseTableNames = strconv.Itoa(value)
+		}
+	}
+	processor.SetCaseSensitiveNames(lowerCaseTableNames == "0")
+	logger.Infof("Table name matching is case-%s (lower_case_table_names=%s)",
+		map[bool]string{true: "sensitive", false: "insensitive"}[lowerCaseTableNames == "0"], lowerCaseTableNames)
+	return lowerCaseTableNames == "0"
+}
+
+// enableJetStream publishes change events to JetStream, creating the configured
+// stream first if asked to
+func enableJetStream(cfg *config.Config, publisher *nats.Publisher) error {
+	js := cfg.NATS.JetStream
+	if js.Create {
+		if err := publisher.EnsureStream(js.Stream, streamSubjects(cfg), js.DuplicateWindow); err != nil {
+			return err
+		}
+	}
+	return publisher.EnableJetStream(cfg.Delivery.AckTimeout, js.Dedup, js.Stream)
+}
+
+// streamSubjects returns the subjects a created stream captures: nats.subject and
+// the routed subjects of each source, with template placeholders as wildcards
+func streamSubjects(cfg *config.Config) []string {
+	subjects := []string{cfg.NATS.Subject}
+	for _, sourceCfg := range cfg.SourceConfigs() {
+		for _, template := range []string{sourceCfg.Routing.Subject, sourceCfg.Routing.Keyless.Subject} {
+			if template == "" {
+				continue
+			}
+			subject := strings.NewReplacer("{database}", "*", "{table}", "*", "{type}", "*", "{tenant}", "*").Replace(template)
+			if !slices.Contains(subjects, subject) {
+				subjects = append(subjects, subject)
+			}
+		}
+	}
+	return subjects
+}
+
+// configureLogger applies the log level and format of the logging config
+func configureLogger(logger *logrus.Logger, cfg *config.LoggingConfig) {
+	if level, err := logrus.ParseLevel(cfg.Level); err == nil {
+		logger.SetLevel(level)
+	}
+	if cfg.Format == "json" {
+		logger.SetFormatter(&logrus.JSONFormatter{})
+	}
+}
+
+// run starts processing in a goroutine and waits for a shutdown signal or processing error.
+// After a signal it waits for processing to stop, unless a second signal arrives.
+func run(ctx context.Context, cancel context.CancelFunc, sigChan <-chan os.Signal, start func(context.Context) error, reporter *reporting.Reporter, notifier *notify.Notifier, logger *logrus.Logger) {
+	notifier.Notify(models.AlertSeverityInfo, notify.Started, "MySQL CDC service started", nil)
 
-	// Start processing in goroutine
 	errChan := make(chan error, 1)
 	go func() {
-		errChan <- proc.Start(ctx)
+		defer reporter.Recover()
+		errChan <- start(ctx)
 	}()
 
 	// Wait for signal or error
 	select {
 	case sig := <-sigChan:
 		logger.Infof("Received signal: %v, shutting down...", sig)
+		// Reading stops and in-flight events are delivered (within delivery.drain_timeout)
+		// before the deferred closes persist the position and disconnect
 		cancel()
+		select {
+		case err := <-errChan:
+			if err != nil {
+				logger.Errorf("Processor error while shutting down: %v", err)
+			}
+		case sig := <-sigChan:
+			logger.Warnf("Received signal: %v, stopping without waiting for in-flight events", sig)
+		}
+		notifier.Notify(models.AlertSeverityInfo, notify.Stopped, "MySQL CDC service stopped", map[string]interface{}{
+			"signal": sig.String(),
+		})
 	case err := <-errChan:
 		if err != nil {
 			logger.Errorf("Processor error: %v", err)
+			notifier.Notify(models.AlertSeverityCritical, notify.Failed, "MySQL CDC service failed", map[string]interface{}{
+				"error": err.Error(),
+			})
+		} else {
+			notifier.Notify(models.AlertSeverityInfo, notify.Stopped, "MySQL CDC service stopped", nil)
 		}
 	}
 
 	logger.Info("MySQL CDC service stopped")
 }
-
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	gomysql "github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/sirupsen/logrus"
+
+	"mysql-cdc/internal/binlog"
+	"mysql-cdc/internal/config"
+	"mysql-cdc/internal/metrics"
+	"mysql-cdc/internal/mysql"
+	"mysql-cdc/internal/nats"
+	"mysql-cdc/internal/position"
+	"mysql-cdc/internal/processor"
+	"mysql-cdc/internal/reporting"
+	"mysql-cdc/internal/snapshot"
+)
+
+// source is the pipeline of one MySQL server: its binlog reader and the processor
+// of its events. A process runs one per sources entry, or one for the top-level
+// mysql settings.
+type source struct {
+	cfg           *config.Config
+	logger        *logrus.Logger
+	checker       *mysql.Checker
+	server        *mysql.ServerInfo // nil if the server couldn't be detected
+	positionStore position.Store
+	reader        *binlog.Reader
+	bounded       bool
+	caseSensitive bool // Table names are compared case-sensitively
+
+	transformer *processor.Transformer // nil in passthrough mode
+	proc        *processor.Processor   // nil in passthrough mode
+	snapshotter *snapshot.Snapshotter
+	start       func(context.Context) error
+}
+
+// sourceHook adds the source's name to its log lines
+type sourceHook string
+
+func (h sourceHook) Levels() []logrus.Level { return logrus.AllLevels }
+
+func (h sourceHook) Fire(entry *logrus.Entry) error {
+	entry.Data["source"] = string(h)
+	return nil
+}
+
+// sourceLogger returns the logger of a source: the service's logger, with the
+// source's name on every line for sources entries
+func sourceLogger(logger *logrus.Logger, name string) *logrus.Logger {
+	if name == "" {
+		return logger
+	}
+	sourceLogger := logrus.New()
+	sourceLogger.SetOutput(logger.Out)
+	sourceLogger.SetFormatter(logger.Formatter)
+	sourceLogger.SetLevel(logger.Level)
+	sourceLogger.AddHook(sourceHook(name))
+	return sourceLogger
+}
+
+// connectSource verifies the MySQL server of a source and creates its binlog reader
+func connectSource(cfg *config.Config, untilCaughtUp bool, logger *logrus.Logger) *source {
+	logger = sourceLogger(logger, cfg.SourceName)
+	s := &source{cfg: cfg, logger: logger}
+
+	// Verify MySQL connection and permissions before starting binlog sync
+	logger.Infof("Verifying MySQL connection and permissions on %s:%d...", cfg.MySQL.Host, cfg.MySQL.Port)
+	checker := mysql.NewChecker(
+		cfg.MySQL.Host,
+		cfg.MySQL.Port,
+		cfg.MySQL.User,
+		cfg.MySQL.Password,
+		&cfg.MySQL.TLS,
+		cfg.MySQL.Strict,
+		logger,
+	)
+	s.checker = checker
+	if err := checker.CheckConnectionAndPermissions(); err != nil {
+		logger.Fatalf("MySQL connection/permission check failed: %v", err)
+	}
+	if err := checker.CheckServerID(cfg.MySQL.ServerID); err != nil {
+		logger.Fatalf("MySQL server_id check failed: %v", err)
+	}
+
+	// Detect flavor, version and GTID mode from the server
+	server, err := checker.DetectServer()
+	if err != nil {
+		if cfg.MySQL.Strict {
+			logger.Fatalf("Could not detect MySQL server flavor/version: %v", err)
+		}
+		logger.Warnf("Could not detect MySQL server flavor/version: %v", err)
+		if cfg.MySQL.Flavor == "auto" {
+			cfg.MySQL.Flavor = "mysql"
+		}
+	} else {
+		s.server = server
+		logger.Infof("Detected %s server version %s (GTID mode: %v, binlog_row_metadata: %s, binlog_row_image: %s)",
+			server.Flavor, server.Version, server.GTIDMode, server.RowMetadata, server.RowImage)
+		if cfg.MySQL.Flavor == "auto" {
+			cfg.MySQL.Flavor = server.Flavor
+		} else if cfg.MySQL.Flavor != server.Flavor {
+			logger.Warnf("Configured flavor %s doesn't match detected flavor %s", cfg.MySQL.Flavor, server.Flavor)
+		}
+		if cfg.MySQL.Version == "" {
+			cfg.MySQL.Version = fmt.Sprintf("%d.%d", server.Major, server.Minor)
+		}
+		if err := checker.CheckServerConfig(server, cfg.MySQL.UseGTID, cfg.MySQL.MinBinlogRetention); err != nil {
+			logger.Fatalf("MySQL server configuration check failed: %v", err)
+		}
+		if cfg.MySQL.UseGTID && !server.GTIDMode {
+			logger.Warn("GTID replication requested but GTID mode is off on the server, using file:position")
+			cfg.MySQL.UseGTID = false
+		}
+	}
+	// Column metadata is read from INFORMATION_SCHEMA unless the binlog carries it
+	if server == nil || !strings.EqualFold(server.RowMetadata, "FULL") {
+		if err := metadataChecker(cfg, logger).CheckSelectAccess(cfg.Filters.Tables); err != nil {
+			logger.Fatalf("MySQL permission check failed: %v", err)
+		}
+	} else {
+		logger.Info("Column metadata comes from the binlog, SELECT permission is not required")
+	}
+	logger.Infof("MySQL flavor: %s, version: %s", cfg.MySQL.Flavor, cfg.MySQL.Version)
+	if cfg.MySQL.UseGTID {
+		logger.Info("GTID replication will be used")
+	}
+
+	// Percona Server speaks the MySQL replication protocol
+	replicationFlavor := cfg.MySQL.Flavor
+	if replicationFlavor == "percona" {
+		replicationFlavor = "mysql"
+	}
+
+	s.caseSensitive = setNameMatching(cfg, checker, logger)
+
+	// A bounded run covers an explicit range and keeps its own position file (if any)
+	positionFile := cfg.Binlog.PositionFile
+	start := gomysql.Position{Pos: cfg.Binlog.StartPosition}
+	s.bounded = cfg.Binlog.Range.End != ""
+	if s.bounded && untilCaughtUp {
+		logger.Fatal("--until-caught-up can't be combined with binlog.range")
+	}
+	if s.bounded {
+		positionFile = cfg.Binlog.Range.PositionFile
+		if cfg.Binlog.Range.Start != "" {
+			start = binlog.ParsePosition(cfg.Binlog.Range.Start)
+		}
+		logger.Infof("Bounded run until %s", cfg.Binlog.Range.End)
+	}
+
+	// Bounded runs always keep their position in a file, leaving the service's position untouched
+	if s.bounded {
+		if positionFile != "" {
+			s.positionStore = position.NewFileStore(positionFile)
+		}
+	} else {
+		s.positionStore, err = position.NewStore(cfg, positionFile, logger)
+		if err != nil {
+			logger.Fatalf("Failed to create position store: %v", err)
+		}
+	}
+
+	// Initialize binlog reader
+	tlsConfig, err := mysql.TLSConfig(&cfg.MySQL.TLS, cfg.MySQL.Host)
+	if err != nil {
+		logger.Fatalf("Failed to set up MySQL TLS: %v", err)
+	}
+	reader, err := binlog.NewReader(
+		cfg.MySQL.Host,
+		cfg.MySQL.Port,
+		cfg.MySQL.User,
+		cfg.MySQL.Password,
+		tlsConfig,
+		cfg.MySQL.ServerID,
+		replicationFlavor,
+		cfg.MySQL.UseGTID,
+		s.positionStore,
+		start,
+		cfg.Binlog.PositionFlushInterval,
+		cfg.Binlog.EventTypes,
+		cfg.Binlog.Passthrough.Enabled,
+		logger,
+	)
+	if err != nil {
+		logger.Fatalf("Failed to create binlog reader: %v", err)
+	}
+	s.reader = reader
+	// After a server restart, verify the server again before resuming
+	reader.SetReconnectCheck(func() error {
+		if err := checker.CheckConnectionAndPermissions(); err != nil {
+			return err
+		}
+		server, err := checker.DetectServer()
+		if err != nil {
+			return err
+		}
+		return checker.CheckServerConfig(server, cfg.MySQL.UseGTID, cfg.MySQL.MinBinlogRetention)
+	})
+	reader.SetReconnectPolicy(cfg.MySQL.Reconnect.InitialDelay, cfg.MySQL.Reconnect.MaxDelay, cfg.MySQL.Reconnect.MaxRetries)
+	if s.bounded {
+		reader.SetEnd(binlog.ParsePosition(cfg.Binlog.Range.End))
+	}
+	if untilCaughtUp {
+		file, pos, err := checker.MasterPosition()
+		if err != nil {
+			logger.Fatalf("Failed to get master position for --until-caught-up: %v", err)
+		}
+		reader.SetEnd(gomysql.Position{Name: file, Pos: pos})
+		logger.Infof("Streaming until caught up with master position %s:%d", file, pos)
+	}
+	// At-least-once and exactly-once delivery persist positions only after acks;
+	// at-most-once persists positions as events are read
+	if cfg.Delivery.Mode != "at_most_once" {
+		reader.EnableManualCommit()
+	}
+	return s
+}
+
+// disconnect closes the binlog reader, persisting its position, and the position store
+func (s *source) disconnect() {
+	s.reader.Close()
+	if s.positionStore != nil {
+		s.positionStore.Close()
+	}
+}
+
+// startPassthrough sets the source up to publish raw binlog events
+func (s *source) startPassthrough(publisher *nats.Publisher) {
+	passthrough := processor.NewPassthrough(s.reader, publisher, s.cfg, s.logger)
+	s.start = passthrough.Start
+}
+
+// startProcessor creates the transformer and event processor of the source
+func (s *source) startProcessor(publisher *nats.Publisher, eventPublisher processor.Publisher, reporter *reporting.Reporter, statsd *metrics.StatsD) {
+	cfg, logger := s.cfg, s.logger
+
+	// Initialize transformer with NATS connection
+	transformer, err := processor.NewTransformer(&cfg.Processor, logger, publisher.GetConn())
+	if err != nil {
+		logger.Fatalf("Failed to create transformer: %v", err)
+	}
+	s.transformer = transformer
+
+	// Create event processor
+	proc, err := processor.NewProcessor(
+		s.reader,
+		eventPublisher,
+		transformer,
+		cfg,
+		logger,
+	)
+	if err != nil {
+		logger.Fatalf("Failed to create event processor: %v", err)
+	}
+	s.proc = proc
+	if s.server != nil {
+		proc.SetRowFormat(s.server.RowMetadata, s.server.RowImage)
+	}
+	proc.SetMasterPosition(func() (gomysql.Position, error) {
+		file, pos, err := s.checker.MasterPosition()
+		return gomysql.Position{Name: file, Pos: pos}, err
+	})
+
+	if reporter != nil {
+		proc.SetErrorReporter(reporter)
+	}
+	if statsd != nil {
+		proc.SetMetrics(statsd)
+	}
+
+	// On first run, snapshot the existing rows and stream from the snapshot's position
+	s.start = proc.Start
+	if cfg.Snapshot.Enabled && !s.bounded {
+		if s.reader.HasSavedPosition() {
+			logger.Info("Resuming from the saved position, no initial snapshot needed")
+		} else {
+			snapshotter, err := snapshot.NewSnapshotter(cfg, logger)
+			if err != nil {
+				logger.Fatalf("Failed to create snapshotter: %v", err)
+			}
+			s.snapshotter = snapshotter
+			s.start = func(ctx context.Context) error {
+				result, err := snapshotter.Run(ctx, proc)
+				if ctx.Err() != nil {
+					// Nothing was persisted, so the snapshot starts over next time
+					return nil
+				}
+				if err != nil {
+					return fmt.Errorf("initial snapshot failed: %w", err)
+				}
+				if err := s.reader.Reposition(result.Position, result.GTIDSet); err != nil {
+					return err
+				}
+				return proc.Start(ctx)
+			}
+		}
+	}
+}
+
+// close stops the source's processor and closes its transformer
+func (s *source) close() {
+	if s.snapshotter != nil {
+		s.snapshotter.Close()
+	}
+	if s.proc != nil {
+		s.proc.Close()
+	}
+	if s.transformer != nil {
+		s.transformer.Close()
+	}
+}
+
+// runSources runs the sources' pipelines until they stop. If one fails, the
+// others are stopped and its error returned.
+func runSources(ctx context.Context, sources []*source, reporter *reporting.Reporter) error {
+	if len(sources) == 1 {
+		return sources[0].start(ctx)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errs := make(chan error, len(sources))
+	for _, s := range sources {
+		go func(s *source) {
+			defer reporter.Recover()
+			err := s.start(ctx)
+			if err != nil {
+				err = fmt.Errorf("source %s: %w", s.cfg.SourceName, err)
+				cancel()
+			}
+			errs <- err
+		}(s)
+	}
+	var first error
+	for range sources {
+		if err := <-errs; err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}